@@ -0,0 +1,73 @@
+package dto
+
+import (
+	"ambassador/domain/entities"
+	"time"
+)
+
+// AccountExportFormat identifies the layout of an account export, so that
+// consumers can tell future versions apart.
+const AccountExportFormat = "ambassador.account-export.v1"
+
+// AccountExport gathers everything stored about a user for a data
+// portability request.
+type AccountExport struct {
+	User        *entities.User
+	Identities  []*entities.ExternalIdentity
+	Sessions    []*entities.Session
+	Passkeys    []*entities.WebAuthnCredential
+	MFAEnabled  bool
+	AuditEvents []*entities.AuditEvent
+	ExportedAt  time.Time
+}
+
+type ExportedProfile struct {
+	*UserResponse
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty"`
+}
+
+type ExportedIdentity struct {
+	Provider  entities.RegistrationMethod `json:"provider"`
+	Subject   string                      `json:"subject"`
+	Email     string                      `json:"email,omitempty"`
+	CreatedAt time.Time                   `json:"createdAt"`
+}
+
+type AccountExportResponse struct {
+	Format      string                `json:"format"`
+	ExportedAt  time.Time             `json:"exportedAt"`
+	Profile     *ExportedProfile      `json:"profile"`
+	Identities  []*ExportedIdentity   `json:"identities"`
+	Sessions    []*SessionResponse    `json:"sessions"`
+	Passkeys    []*PasskeyResponse    `json:"passkeys"`
+	MFAEnabled  bool                  `json:"mfaEnabled"`
+	AuditEvents []*AuditEventResponse `json:"auditEvents"`
+}
+
+func ToAccountExportResponse(export *AccountExport) *AccountExportResponse {
+	profile := &ExportedProfile{UserResponse: ToUserResponse(export.User)}
+	if !export.User.EmailVerifiedAt.IsZero() {
+		profile.EmailVerifiedAt = &export.User.EmailVerifiedAt
+	}
+
+	identities := make([]*ExportedIdentity, 0, len(export.Identities))
+	for _, identity := range export.Identities {
+		identities = append(identities, &ExportedIdentity{
+			Provider:  identity.Provider,
+			Subject:   identity.Subject,
+			Email:     identity.Email,
+			CreatedAt: identity.CreatedAt,
+		})
+	}
+
+	return &AccountExportResponse{
+		Format:      AccountExportFormat,
+		ExportedAt:  export.ExportedAt,
+		Profile:     profile,
+		Identities:  identities,
+		Sessions:    ToSessionResponses(export.Sessions, ""),
+		Passkeys:    ToPasskeyResponses(export.Passkeys),
+		MFAEnabled:  export.MFAEnabled,
+		AuditEvents: ToAuditEventResponses(export.AuditEvents),
+	}
+}
@@ -0,0 +1,73 @@
+package dto
+
+import (
+	"ambassador/domain/entities"
+	"time"
+)
+
+// AdminUserDetail is what an operator sees of a single account.
+type AdminUserDetail struct {
+	User         *entities.User
+	Sessions     []*entities.Session
+	MFAEnabled   bool
+	PasskeyCount int
+	LockedUntil  time.Time
+}
+
+type SetUserRolesRequest struct {
+	Roles  []string            `json:"roles" validate:"required"`
+	Client entities.ClientInfo `json:"-"`
+}
+
+type AdminUserResponse struct {
+	*UserResponse
+	IsActive bool `json:"isActive"`
+	// DeletedAt is set while the account waits to be purged.
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+type AdminUsersResponse struct {
+	Users []*AdminUserResponse `json:"users"`
+	// NextCursor fetches the next, older page. It is empty on the last page.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+type AdminUserDetailResponse struct {
+	User         *AdminUserResponse `json:"user"`
+	Sessions     []*SessionResponse `json:"sessions"`
+	MFAEnabled   bool               `json:"mfaEnabled"`
+	PasskeyCount int                `json:"passkeyCount"`
+	LockedUntil  *time.Time         `json:"lockedUntil,omitempty"`
+}
+
+func ToAdminUserResponse(user *entities.User) *AdminUserResponse {
+	res := &AdminUserResponse{
+		UserResponse: ToUserResponse(user),
+		IsActive:     user.IsActive,
+	}
+	if user.IsDeleted() {
+		res.DeletedAt = &user.DeletedAt
+	}
+	return res
+}
+
+func ToAdminUsersResponse(users []*entities.User, nextCursor string) *AdminUsersResponse {
+	responses := make([]*AdminUserResponse, 0, len(users))
+	for _, user := range users {
+		responses = append(responses, ToAdminUserResponse(user))
+	}
+	return &AdminUsersResponse{Users: responses, NextCursor: nextCursor}
+}
+
+func ToAdminUserDetailResponse(detail *AdminUserDetail) *AdminUserDetailResponse {
+	res := &AdminUserDetailResponse{
+		User:         ToAdminUserResponse(detail.User),
+		Sessions:     ToSessionResponses(detail.Sessions, ""),
+		MFAEnabled:   detail.MFAEnabled,
+		PasskeyCount: detail.PasskeyCount,
+	}
+	if !detail.LockedUntil.IsZero() {
+		res.LockedUntil = &detail.LockedUntil
+	}
+	return res
+}
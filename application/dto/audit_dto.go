@@ -0,0 +1,46 @@
+package dto
+
+import (
+	"ambassador/domain/entities"
+	"time"
+)
+
+type AuditEventResponse struct {
+	ID        string            `json:"id"`
+	Type      string            `json:"type"`
+	UserID    string            `json:"userId,omitempty"`
+	ActorID   string            `json:"actorId,omitempty"`
+	IPAddress string            `json:"ipAddress,omitempty"`
+	UserAgent string            `json:"userAgent,omitempty"`
+	RequestID string            `json:"requestId,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+type AuditEventsResponse struct {
+	Events []*AuditEventResponse `json:"events"`
+	// NextCursor fetches the next, older page. It is empty on the last page.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+func ToAuditEventsResponse(events []*entities.AuditEvent, nextCursor string) *AuditEventsResponse {
+	return &AuditEventsResponse{Events: ToAuditEventResponses(events), NextCursor: nextCursor}
+}
+
+func ToAuditEventResponses(events []*entities.AuditEvent) []*AuditEventResponse {
+	responses := make([]*AuditEventResponse, 0, len(events))
+	for _, event := range events {
+		responses = append(responses, &AuditEventResponse{
+			ID:        event.ID,
+			Type:      string(event.Type),
+			UserID:    event.UserID,
+			ActorID:   event.ActorID,
+			IPAddress: event.IPAddress,
+			UserAgent: event.UserAgent,
+			RequestID: event.RequestID,
+			Details:   event.Details,
+			CreatedAt: event.CreatedAt,
+		})
+	}
+	return responses
+}
@@ -11,16 +11,201 @@ type RegisterRequest struct {
 	Gender             entities.Gender             `json:"gender" validate:"required,oneof=male female other prefer_not_to_say"`
 	DateOfBirth        string                      `json:"dateOfBirth" validate:"required"`
 	RegistrationMethod entities.RegistrationMethod `json:"registrationMethod" validate:"required,oneof=email google apple"`
-	Password           string                      `json:"password,omitempty" validate:"required_if=RegistrationMethod email,min=8"`
+	Password           string                      `json:"password,omitempty" validate:"required_if=RegistrationMethod email"`
+	IDToken            string                      `json:"idToken,omitempty"`
+	// Nonce is required with IDToken and must come from POST /auth/oauth/nonce.
+	Nonce              string                      `json:"nonce,omitempty"`
+	Locale             string                      `json:"locale,omitempty"`
+	DeviceName         string                      `json:"deviceName,omitempty" validate:"max=64"`
+	Client             entities.ClientInfo         `json:"-"`
 }
 
+// DeviceName optionally labels the session the login starts, e.g. "Work
+// laptop". Client is filled in by the handler.
 type LoginRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password"`
+	Email      string              `json:"email" validate:"required,email"`
+	Password   string              `json:"password"`
+	DeviceName string              `json:"deviceName,omitempty" validate:"max=64"`
+	Client     entities.ClientInfo `json:"-"`
+}
+
+// OAuthLoginRequest signs in with a provider ID token, which must carry a
+// Nonce issued by POST /auth/oauth/nonce. The profile fields are only needed
+// the first time, when no account exists for the identity yet. Password is
+// only needed to link the identity to an existing account whose email has
+// not been verified.
+type OAuthLoginRequest struct {
+	Provider    entities.RegistrationMethod `json:"provider" validate:"required,oneof=google apple"`
+	IDToken     string                      `json:"idToken" validate:"required"`
+	Nonce       string                      `json:"nonce" validate:"required"`
+	Password    string                      `json:"password,omitempty"`
+	FullName    string                      `json:"fullName,omitempty"`
+	Gender      entities.Gender             `json:"gender,omitempty"`
+	DateOfBirth string                      `json:"dateOfBirth,omitempty"`
+	Locale      string                      `json:"locale,omitempty"`
+	DeviceName  string                      `json:"deviceName,omitempty" validate:"max=64"`
+	Client      entities.ClientInfo         `json:"-"`
+}
+
+// OAuthNonceResponse carries a single-use nonce for the client to pass to
+// the provider and send back with the ID token.
+type OAuthNonceResponse struct {
+	Nonce     string `json:"nonce"`
+	ExpiresIn int64  `json:"expiresIn"`
 }
 
 type RefreshTokenRequest struct {
-	RefreshToken string `json:"refreshToken" validate:"required"`
+	RefreshToken string              `json:"refreshToken" validate:"required"`
+	Client       entities.ClientInfo `json:"-"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string              `json:"token" validate:"required"`
+	NewPassword string              `json:"newPassword" validate:"required"`
+	Client      entities.ClientInfo `json:"-"`
+}
+
+// UpdateProfileRequest changes the fields that are set and leaves empty
+// ones as they are.
+type UpdateProfileRequest struct {
+	FullName    string              `json:"fullName,omitempty" validate:"min=2,max=100"`
+	Gender      entities.Gender     `json:"gender,omitempty" validate:"oneof=male female other prefer_not_to_say"`
+	DateOfBirth string              `json:"dateOfBirth,omitempty"`
+	Client      entities.ClientInfo `json:"-"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string              `json:"currentPassword" validate:"required"`
+	NewPassword     string              `json:"newPassword" validate:"required"`
+	Client          entities.ClientInfo `json:"-"`
+}
+
+// ChangeEmailRequest starts an email change. Password is required for
+// accounts that sign in with one.
+type ChangeEmailRequest struct {
+	NewEmail string              `json:"newEmail" validate:"required,email"`
+	Password string              `json:"password,omitempty"`
+	Client   entities.ClientInfo `json:"-"`
+}
+
+// EmailChangeTokenRequest confirms or reverts an email change.
+type EmailChangeTokenRequest struct {
+	Token  string              `json:"token" validate:"required"`
+	Client entities.ClientInfo `json:"-"`
+}
+
+// DeleteAccountRequest closes the caller's account. Password is required
+// for accounts that sign in with one.
+type DeleteAccountRequest struct {
+	Password string              `json:"password,omitempty"`
+	Client   entities.ClientInfo `json:"-"`
+}
+
+type RestoreAccountRequest struct {
+	Token  string              `json:"token" validate:"required"`
+	Client entities.ClientInfo `json:"-"`
+}
+
+type AccountDeletionResponse struct {
+	// PurgeAt is when the account and its data are removed for good.
+	PurgeAt time.Time `json:"purgeAt"`
+}
+
+type VerifyEmailRequest struct {
+	Token  string              `json:"token" validate:"required"`
+	Client entities.ClientInfo `json:"-"`
+}
+
+type UnlockAccountRequest struct {
+	Token  string              `json:"token" validate:"required"`
+	Client entities.ClientInfo `json:"-"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type MFACodeRequest struct {
+	Code   string              `json:"code" validate:"required"`
+	Client entities.ClientInfo `json:"-"`
+}
+
+// MFAVerifyRequest completes a login for a user with MFA enabled. Code may
+// be a TOTP code or a recovery code.
+type MFAVerifyRequest struct {
+	MFAToken   string              `json:"mfaToken" validate:"required"`
+	Code       string              `json:"code" validate:"required"`
+	DeviceName string              `json:"deviceName,omitempty" validate:"max=64"`
+	Client     entities.ClientInfo `json:"-"`
+}
+
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfaRequired"`
+	MFAToken    string `json:"mfaToken"`
+	ExpiresIn   int64  `json:"expiresIn"`
+}
+
+// ExpiredPasswordChangeRequest completes a login that was held back because
+// the password has expired.
+type ExpiredPasswordChangeRequest struct {
+	PasswordChangeToken string              `json:"passwordChangeToken" validate:"required"`
+	NewPassword         string              `json:"newPassword" validate:"required"`
+	DeviceName          string              `json:"deviceName,omitempty" validate:"max=64"`
+	Client              entities.ClientInfo `json:"-"`
+}
+
+// MagicLinkRequest asks for a sign-in link to be emailed.
+type MagicLinkRequest struct {
+	Email  string              `json:"email" validate:"required,email"`
+	Client entities.ClientInfo `json:"-"`
+}
+
+// MagicLinkResponse carries the nonce the emailed link is bound to. The
+// client must keep it and present it with the link.
+type MagicLinkResponse struct {
+	Nonce     string `json:"nonce"`
+	ExpiresIn int64  `json:"expiresIn"`
+}
+
+// ConsumeMagicLinkRequest signs in with the token from a magic link and the
+// nonce returned when it was requested on this device.
+type ConsumeMagicLinkRequest struct {
+	Token      string              `json:"token" validate:"required"`
+	Nonce      string              `json:"nonce" validate:"required"`
+	DeviceName string              `json:"deviceName,omitempty" validate:"max=64"`
+	Client     entities.ClientInfo `json:"-"`
+}
+
+type PasswordChangeChallengeResponse struct {
+	PasswordChangeRequired bool   `json:"passwordChangeRequired"`
+	PasswordChangeToken    string `json:"passwordChangeToken"`
+	ExpiresIn              int64  `json:"expiresIn"`
+}
+
+type TOTPEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauthUri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// PasswordPolicyResponse describes what a new password must look like.
+type PasswordPolicyResponse struct {
+	entities.PasswordRequirements
+	// Screening names the checks made against password lists and the
+	// user's own details, such as "common" or "breached".
+	Screening []string `json:"screening"`
+}
+
+// PasswordPolicyViolationsResponse lists every rule a password failed.
+type PasswordPolicyViolationsResponse struct {
+	Violations []entities.PasswordViolation `json:"violations"`
 }
 
 type UserResponse struct {
@@ -30,21 +215,26 @@ type UserResponse struct {
 	Gender             entities.Gender           `json:"gender"`
 	DateOfBirth        string                    `json:"dateOfBirth"`
 	RegistrationMethod entities.RegistrationMethod `json:"registrationMethod"`
+	EmailVerified      bool                      `json:"emailVerified"`
+	Locale             string                    `json:"locale,omitempty"`
+	Roles              []string                  `json:"roles"`
 	CreatedAt          time.Time                 `json:"createdAt"`
 	UpdatedAt          time.Time                 `json:"updatedAt"`
 }
 
 type AuthResponse struct {
 	User         *UserResponse `json:"user"`
-	AccessToken  string        `json:"accessToken"`
-	RefreshToken string        `json:"refreshToken"`
-	ExpiresIn    int64         `json:"expiresIn"`
+	AccessToken  string        `json:"accessToken,omitempty"`
+	RefreshToken string        `json:"refreshToken,omitempty"`
+	ExpiresIn    int64         `json:"expiresIn,omitempty"`
+	Scopes       []string      `json:"scopes,omitempty"`
 }
 
 type RefreshResponse struct {
-	AccessToken  string `json:"accessToken"`
-	RefreshToken string `json:"refreshToken"`
-	ExpiresIn    int64  `json:"expiresIn"`
+	AccessToken  string   `json:"accessToken"`
+	RefreshToken string   `json:"refreshToken,omitempty"`
+	ExpiresIn    int64    `json:"expiresIn"`
+	Scopes       []string `json:"scopes,omitempty"`
 }
 
 func ToUserResponse(user *entities.User) *UserResponse {
@@ -55,18 +245,27 @@ func ToUserResponse(user *entities.User) *UserResponse {
 		Gender:             user.Gender,
 		DateOfBirth:        user.DateOfBirth.Format("2006-01-02"),
 		RegistrationMethod: user.RegistrationMethod,
+		EmailVerified:      user.EmailVerified,
+		Locale:             user.Locale,
+		Roles:              user.Roles,
 		CreatedAt:          user.CreatedAt,
 		UpdatedAt:          user.UpdatedAt,
 	}
 }
 
+// ToAuthResponse tolerates a nil tokenPair for accounts that were created
+// but may not sign in until their email address is verified.
 func ToAuthResponse(user *entities.User, tokenPair *entities.TokenPair) *AuthResponse {
+	if tokenPair == nil {
+		return &AuthResponse{User: ToUserResponse(user)}
+	}
 	expiresIn := int64(tokenPair.AccessToken.ExpiresAt.Sub(time.Now()).Seconds())
 	return &AuthResponse{
 		User:         ToUserResponse(user),
 		AccessToken:  tokenPair.AccessToken.Value,
 		RefreshToken: tokenPair.RefreshToken.Value,
 		ExpiresIn:    expiresIn,
+		Scopes:       tokenPair.AccessToken.Scopes,
 	}
 }
 
@@ -76,5 +275,22 @@ func ToRefreshResponse(tokenPair *entities.TokenPair) *RefreshResponse {
 		AccessToken:  tokenPair.AccessToken.Value,
 		RefreshToken: tokenPair.RefreshToken.Value,
 		ExpiresIn:    expiresIn,
+		Scopes:       tokenPair.AccessToken.Scopes,
+	}
+}
+
+func ToMFAChallengeResponse(challenge *entities.Challenge) *MFAChallengeResponse {
+	return &MFAChallengeResponse{
+		MFARequired: true,
+		MFAToken:    challenge.Token,
+		ExpiresIn:   int64(time.Until(challenge.ExpiresAt).Seconds()),
+	}
+}
+
+func ToPasswordChangeChallengeResponse(challenge *entities.Challenge) *PasswordChangeChallengeResponse {
+	return &PasswordChangeChallengeResponse{
+		PasswordChangeRequired: true,
+		PasswordChangeToken:    challenge.Token,
+		ExpiresIn:              int64(time.Until(challenge.ExpiresAt).Seconds()),
 	}
 }
\ No newline at end of file
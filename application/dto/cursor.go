@@ -0,0 +1,32 @@
+package dto
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EncodeCursor returns an opaque keyset cursor for listings ordered by
+// creation time and then ID, pointing just past the given row.
+func EncodeCursor(createdAt time.Time, id string) string {
+	raw := strconv.FormatInt(createdAt.UnixNano(), 10) + ":" + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", errors.New("invalid cursor")
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok || id == "" {
+		return time.Time{}, "", errors.New("invalid cursor")
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, "", errors.New("invalid cursor")
+	}
+	return time.Unix(0, n).UTC(), id, nil
+}
@@ -0,0 +1,38 @@
+package dto
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.UTC)
+
+	before, id, err := DecodeCursor(EncodeCursor(createdAt, "user-1"))
+	if err != nil {
+		t.Fatalf("DecodeCursor: %v", err)
+	}
+	if !before.Equal(createdAt) || id != "user-1" {
+		t.Errorf("decoded %v %q, want %v %q", before, id, createdAt, "user-1")
+	}
+}
+
+func TestDecodeCursorRejectsTamperedCursors(t *testing.T) {
+	encode := func(raw string) string { return base64.RawURLEncoding.EncodeToString([]byte(raw)) }
+	cursors := map[string]string{
+		"not base64":     "!!not-a-cursor!!",
+		"padded base64":  base64.URLEncoding.EncodeToString([]byte("1:user-1")),
+		"no separator":   encode("1709296200"),
+		"no id":          encode("1709296200:"),
+		"text timestamp": encode("yesterday:user-1"),
+		"overflow":       encode("99999999999999999999:user-1"),
+	}
+	for name, cursor := range cursors {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := DecodeCursor(cursor); err == nil || err.Error() != "invalid cursor" {
+				t.Errorf("DecodeCursor(%q) returned %v, want invalid cursor", cursor, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package dto
+
+import "time"
+
+type AddSigningKeyRequest struct {
+	Algorithm     string `json:"algorithm" validate:"required,oneof=RS256 ES256 EdDSA"`
+	PrivateKeyPEM string `json:"privateKeyPem,omitempty"`
+	Promote       bool   `json:"promote"`
+}
+
+type SigningKeyResponse struct {
+	KeyID     string     `json:"kid"`
+	Algorithm string     `json:"algorithm"`
+	Primary   bool       `json:"primary"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RetiresAt *time.Time `json:"retiresAt,omitempty"`
+}
@@ -0,0 +1,68 @@
+package dto
+
+import (
+	"ambassador/domain/entities"
+	"encoding/json"
+	"time"
+)
+
+// BeginPasskeyLoginRequest may name the account to sign in to. The email is
+// not used to narrow the ceremony: the authenticator always offers the
+// passkeys it holds for this site.
+type BeginPasskeyLoginRequest struct {
+	Email string `json:"email,omitempty"`
+}
+
+// Credential is the PublicKeyCredential returned by the browser, serialised
+// with its binary fields as base64url.
+type FinishPasskeyRegistrationRequest struct {
+	CeremonyID string              `json:"ceremonyId" validate:"required"`
+	Name       string              `json:"name" validate:"max=64"`
+	Credential json.RawMessage     `json:"credential" validate:"required"`
+	Client     entities.ClientInfo `json:"-"`
+}
+
+type FinishPasskeyLoginRequest struct {
+	CeremonyID string              `json:"ceremonyId" validate:"required"`
+	Credential json.RawMessage     `json:"credential" validate:"required"`
+	DeviceName string              `json:"deviceName,omitempty" validate:"max=64"`
+	Client     entities.ClientInfo `json:"-"`
+}
+
+// PasskeyCeremonyResponse carries the options to pass to
+// navigator.credentials.create or get, and the ID to send back on finish.
+type PasskeyCeremonyResponse struct {
+	CeremonyID string      `json:"ceremonyId"`
+	Options    interface{} `json:"options"`
+}
+
+type PasskeyResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Transports []string   `json:"transports,omitempty"`
+	Synced     bool       `json:"synced"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+func ToPasskeyResponse(credential *entities.WebAuthnCredential) *PasskeyResponse {
+	resp := &PasskeyResponse{
+		ID:         credential.ID,
+		Name:       credential.Name,
+		Transports: credential.Transports,
+		Synced:     credential.BackupState,
+		CreatedAt:  credential.CreatedAt,
+	}
+	if !credential.LastUsedAt.IsZero() {
+		resp.LastUsedAt = &credential.LastUsedAt
+	}
+	return resp
+}
+
+func ToPasskeyResponses(credentials []*entities.WebAuthnCredential) []*PasskeyResponse {
+	responses := make([]*PasskeyResponse, 0, len(credentials))
+	for _, credential := range credentials {
+		responses = append(responses, ToPasskeyResponse(credential))
+	}
+	return responses
+}
@@ -0,0 +1,39 @@
+package dto
+
+import (
+	"ambassador/domain/entities"
+	"time"
+)
+
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	DeviceName string    `json:"deviceName,omitempty"`
+	UserAgent  string    `json:"userAgent,omitempty"`
+	IPAddress  string    `json:"ipAddress,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	// Current marks the session the request was made from.
+	Current bool `json:"current"`
+}
+
+type RevokeSessionsResponse struct {
+	Revoked int `json:"revoked"`
+}
+
+func ToSessionResponses(sessions []*entities.Session, currentID string) []*SessionResponse {
+	responses := make([]*SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		responses = append(responses, &SessionResponse{
+			ID:         session.ID,
+			DeviceName: session.DeviceName,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.ID == currentID,
+		})
+	}
+	return responses
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"ambassador/infrastructure/mail"
+	"context"
+	"errors"
+	"time"
+)
+
+// purgeBatchSize bounds how many accounts are loaded at once while purging.
+const purgeBatchSize = 100
+
+// WithAccountDeletion sets how long a deleted account can still be restored
+// before PurgeDeletedAccounts removes it for good.
+func WithAccountDeletion(grace time.Duration) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.deletionGrace = grace
+	}
+}
+
+// DeleteAccount closes the user's account. It is signed out everywhere and
+// refused at every login straight away, but is only purged once the grace
+// period has passed; until then the link emailed to the user restores it.
+func (s *AuthServiceImpl) DeleteAccount(userID string, req *dto.DeleteAccountRequest) (time.Time, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return time.Time{}, errors.New("user not found")
+	}
+	if user.IsDeleted() {
+		return time.Time{}, errors.New("account is already scheduled for deletion")
+	}
+
+	if user.RegistrationMethod == entities.RegMethodEmail && !s.hasher.CheckPassword(req.Password, user.PasswordHash) {
+		return time.Time{}, errors.New("current password is incorrect")
+	}
+
+	now := time.Now()
+	purgeAt := now.Add(s.deletionGrace)
+	user.DeletedAt = now
+	user.IsActive = false
+	user.UpdatedAt = now
+	secret, restoreToken := entities.NewOneTimeToken(user.ID, entities.TokenTypeAccountRestore, s.deletionGrace)
+
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		if err := tx.Users.Save(user); err != nil {
+			return err
+		}
+		if err := tx.Tokens.DeleteByUserID(user.ID); err != nil {
+			return err
+		}
+		if err := tx.Sessions.DeleteByUserID(user.ID); err != nil {
+			return err
+		}
+		return tx.Tokens.Save(restoreToken)
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	s.sendMail(user, mail.TemplateAccountDeletion, map[string]interface{}{
+		"Link":      s.link("/restore-account", secret),
+		"PurgeDate": purgeAt.UTC().Format("2 January 2006"),
+	})
+
+	s.audit.Record(entities.NewAuditEvent(entities.AuditAccountDeleted, user.ID, req.Client, map[string]string{
+		"purgeAt": purgeAt.UTC().Format(time.RFC3339),
+	}))
+	return purgeAt, nil
+}
+
+// RestoreAccount consumes the link sent by DeleteAccount and reopens the
+// account. The user signs in again as usual afterwards.
+func (s *AuthServiceImpl) RestoreAccount(req *dto.RestoreAccountRequest) error {
+	token, err := s.tokenRepo.FindByValue(entities.HashTokenValue(req.Token))
+	if err != nil || token.Type != entities.TokenTypeAccountRestore {
+		return errors.New("invalid or expired account restore token")
+	}
+
+	if token.IsExpired() {
+		s.tokenRepo.Delete(token.Value)
+		return errors.New("invalid or expired account restore token")
+	}
+
+	user, err := s.userRepo.FindByID(token.UserID)
+	if err != nil || !user.IsDeleted() {
+		return errors.New("invalid or expired account restore token")
+	}
+
+	user.DeletedAt = time.Time{}
+	user.IsActive = true
+	user.UpdatedAt = time.Now()
+
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		if err := tx.Users.Save(user); err != nil {
+			return err
+		}
+		return tx.Tokens.DeleteAllUserTokens(user.ID, entities.TokenTypeAccountRestore)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.audit.Record(entities.NewAuditEvent(entities.AuditAccountRestored, user.ID, req.Client, nil))
+	return nil
+}
+
+// PurgeDeletedAccounts removes every account whose grace period has passed,
+// along with its tokens, sessions, linked identities, second factors,
+// passkeys and password history. Audit events are kept. It returns how many accounts were purged.
+func (s *AuthServiceImpl) PurgeDeletedAccounts() (int, error) {
+	cutoff := time.Now().Add(-s.deletionGrace)
+	purged := 0
+	for {
+		users, err := s.userRepo.List(repositories.UserFilter{DeletedBefore: cutoff, Limit: purgeBatchSize})
+		if err != nil {
+			return purged, err
+		}
+		for _, user := range users {
+			if err := s.purgeAccount(user); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+		if len(users) < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+func (s *AuthServiceImpl) purgeAccount(user *entities.User) error {
+	err := s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		if err := tx.Tokens.DeleteByUserID(user.ID); err != nil {
+			return err
+		}
+		if err := tx.Sessions.DeleteByUserID(user.ID); err != nil {
+			return err
+		}
+		if err := tx.Identities.DeleteByUserID(user.ID); err != nil {
+			return err
+		}
+		if err := tx.MFA.DeleteTOTP(user.ID); err != nil {
+			return err
+		}
+		if err := tx.MFA.ReplaceRecoveryCodes(user.ID, nil); err != nil {
+			return err
+		}
+		if tx.WebAuthn != nil {
+			if err := tx.WebAuthn.DeleteByUserID(user.ID); err != nil {
+				return err
+			}
+		}
+		if tx.PasswordHistory != nil {
+			if err := tx.PasswordHistory.DeleteByUserID(user.ID); err != nil {
+				return err
+			}
+		}
+		return tx.Users.Delete(user.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.throttleRepo != nil {
+		s.throttleRepo.Reset(entities.LoginThrottleKey(user.Email.String()))
+	}
+
+	s.audit.Record(entities.NewAuditEvent(entities.AuditAccountPurged, user.ID, entities.ClientInfo{}, map[string]string{
+		"deletedAt": user.DeletedAt.UTC().Format(time.RFC3339),
+		"grace":     s.deletionGrace.String(),
+	}))
+	return nil
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// deleteAccount closes user's account and returns the token of the restore
+// link that was mailed.
+func (e *testEnv) deleteAccount(t *testing.T, user *entities.User) string {
+	t.Helper()
+	if _, err := e.svc.DeleteAccount(user.ID, &dto.DeleteAccountRequest{Password: testPassword}); err != nil {
+		t.Fatalf("DeleteAccount: %v", err)
+	}
+	return e.mailedToken(t, user.Email.String(), "/restore-account")
+}
+
+// backdateDeletion moves the deletion of userID to before the grace period.
+func (e *testEnv) backdateDeletion(t *testing.T, userID string) {
+	t.Helper()
+	user, err := e.repos.Users.FindByID(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.DeletedAt = time.Now().Add(-e.svc.deletionGrace - time.Minute)
+	if err := e.repos.Users.Save(user); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeleteAccountSignsOutAndRefusesLogin(t *testing.T) {
+	env := newTestEnv(t)
+	user, tokenPair := env.register(t, "ann@example.com")
+
+	if _, err := env.svc.DeleteAccount(user.ID, &dto.DeleteAccountRequest{Password: "Wrong-passw0rd"}); err == nil {
+		t.Fatal("DeleteAccount accepted a wrong password")
+	}
+	purgeAt, err := env.svc.DeleteAccount(user.ID, &dto.DeleteAccountRequest{Password: testPassword})
+	if err != nil {
+		t.Fatalf("DeleteAccount: %v", err)
+	}
+	if want := time.Now().Add(env.svc.deletionGrace); purgeAt.Before(want.Add(-time.Minute)) || purgeAt.After(want) {
+		t.Errorf("purge at %v, want after the grace period", purgeAt)
+	}
+
+	if _, err := env.svc.RefreshToken(&dto.RefreshTokenRequest{RefreshToken: tokenPair.RefreshToken.Value}); err == nil {
+		t.Error("refresh token still works after the deletion")
+	}
+	if _, _, err := env.svc.Login(&dto.LoginRequest{Email: "ann@example.com", Password: testPassword}); err == nil {
+		t.Error("a deleted account signed in")
+	}
+	if _, err := env.svc.DeleteAccount(user.ID, &dto.DeleteAccountRequest{Password: testPassword}); err == nil {
+		t.Error("an account was deleted twice")
+	}
+}
+
+func TestRestoreAccountReopensAccountOnce(t *testing.T) {
+	env := newTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+	restore := env.deleteAccount(t, user)
+
+	if err := env.svc.RestoreAccount(&dto.RestoreAccountRequest{Token: restore}); err != nil {
+		t.Fatalf("RestoreAccount: %v", err)
+	}
+	if _, _, err := env.svc.Login(&dto.LoginRequest{Email: "ann@example.com", Password: testPassword}); err != nil {
+		t.Errorf("Login after the restore: %v", err)
+	}
+	if err := env.svc.RestoreAccount(&dto.RestoreAccountRequest{Token: restore}); err == nil {
+		t.Error("a used restore link was accepted again")
+	}
+}
+
+func TestRestoreAccountRejectsExpiredLink(t *testing.T) {
+	env := newTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+	restore := env.deleteAccount(t, user)
+	env.expireToken(t, restore)
+
+	if err := env.svc.RestoreAccount(&dto.RestoreAccountRequest{Token: restore}); err == nil {
+		t.Fatal("an expired restore link was accepted")
+	}
+	if user, _ := env.repos.Users.FindByID(user.ID); !user.IsDeleted() {
+		t.Error("the account was restored")
+	}
+}
+
+func TestPurgeDeletedAccountsRemovesEverything(t *testing.T) {
+	env, webAuthnRepo := newPasskeyTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+	env.enableTOTP(t, user.ID)
+	env.registerPasskey(t, user.ID)
+	ceremonyID, _, err := env.svc.BeginPasskeyRegistration(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.svc.ChangePassword(user.ID, "", &dto.ChangePasswordRequest{CurrentPassword: testPassword, NewPassword: newTestPassword}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.DeleteAccount(user.ID, &dto.DeleteAccountRequest{Password: newTestPassword}); err != nil {
+		t.Fatal(err)
+	}
+	kept, _ := env.register(t, "bob@example.com")
+	env.registerPasskey(t, kept.ID)
+
+	if purged, err := env.svc.PurgeDeletedAccounts(); err != nil || purged != 0 {
+		t.Fatalf("PurgeDeletedAccounts within the grace period = %d, %v; want nothing purged", purged, err)
+	}
+	env.backdateDeletion(t, user.ID)
+	if purged, err := env.svc.PurgeDeletedAccounts(); err != nil || purged != 1 {
+		t.Fatalf("PurgeDeletedAccounts = %d, %v; want 1", purged, err)
+	}
+
+	if _, err := env.repos.Users.FindByID(user.ID); err == nil {
+		t.Error("user was not purged")
+	}
+	if credentials, _ := webAuthnRepo.FindCredentialsByUserID(user.ID); len(credentials) != 0 {
+		t.Errorf("%d passkeys survived the purge", len(credentials))
+	}
+	if _, err := webAuthnRepo.TakeSession(ceremonyID); err == nil {
+		t.Error("an unfinished passkey ceremony survived the purge")
+	}
+	if entries, _ := env.repos.PasswordHistory.FindRecent(user.ID, 5); len(entries) != 0 {
+		t.Errorf("%d password history entries survived the purge", len(entries))
+	}
+	if _, err := env.repos.MFA.FindTOTP(user.ID); err == nil {
+		t.Error("TOTP credential survived the purge")
+	}
+	if events := env.auditEvents(t, user.ID, entities.AuditAccountPurged); len(events) != 1 {
+		t.Errorf("recorded %d purges, want 1", len(events))
+	}
+	if credentials, _ := webAuthnRepo.FindCredentialsByUserID(kept.ID); len(credentials) != 1 {
+		t.Error("another user's passkey was purged")
+	}
+}
+
+func TestPurgeDeletedAccountIsOneUnitOfWork(t *testing.T) {
+	env, webAuthnRepo := newPasskeyTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+	env.registerPasskey(t, user.ID)
+	if err := env.svc.ChangePassword(user.ID, "", &dto.ChangePasswordRequest{CurrentPassword: testPassword, NewPassword: newTestPassword}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.DeleteAccount(user.ID, &dto.DeleteAccountRequest{Password: newTestPassword}); err != nil {
+		t.Fatal(err)
+	}
+	env.backdateDeletion(t, user.ID)
+
+	env.svc.uow = failingUnitOfWork{env.svc.uow}
+	if _, err := env.svc.PurgeDeletedAccounts(); !errors.Is(err, errCommitFailed) {
+		t.Fatalf("PurgeDeletedAccounts = %v, want the commit failure", err)
+	}
+	if _, err := env.repos.Users.FindByID(user.ID); err != nil {
+		t.Errorf("user was deleted by a failed purge: %v", err)
+	}
+	if credentials, _ := webAuthnRepo.FindCredentialsByUserID(user.ID); len(credentials) != 1 {
+		t.Error("passkey was deleted by a failed purge")
+	}
+	if entries, _ := env.repos.PasswordHistory.FindRecent(user.ID, 5); len(entries) == 0 {
+		t.Error("password history was deleted by a failed purge")
+	}
+}
+
+func TestExportAccountLeavesOutSecrets(t *testing.T) {
+	env, _ := newPasskeyTestEnv(t)
+	env.svc.auditRepo = env.auditLog
+	user, tokenPair := env.register(t, "ann@example.com")
+	secret, _ := env.enableTOTP(t, user.ID)
+	env.registerPasskey(t, user.ID)
+
+	export, err := env.svc.ExportAccount(user.ID, entities.ClientInfo{})
+	if err != nil {
+		t.Fatalf("ExportAccount: %v", err)
+	}
+	if export.User.ID != user.ID || !export.MFAEnabled || len(export.Passkeys) != 1 || len(export.Sessions) != 1 {
+		t.Errorf("export = %+v, want the profile, MFA, one passkey and one session", export)
+	}
+	if len(export.AuditEvents) == 0 {
+		t.Error("export has no audit events")
+	}
+
+	data, err := json.Marshal(dto.ToAccountExportResponse(export))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := env.repos.Users.FindByID(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]string{
+		"password hash": stored.PasswordHash,
+		"TOTP secret":   secret,
+		"refresh token": tokenPair.RefreshToken.Value,
+		"passkey key":   base64.StdEncoding.EncodeToString(export.Passkeys[0].PublicKey),
+	} {
+		if strings.Contains(string(data), value) {
+			t.Errorf("export contains the %s", name)
+		}
+	}
+	if events := env.auditEvents(t, user.ID, entities.AuditAccountExported); len(events) != 1 {
+		t.Errorf("recorded %d exports, want 1", len(events))
+	}
+}
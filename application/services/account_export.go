@@ -0,0 +1,47 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"errors"
+	"time"
+)
+
+// ExportAccount collects everything stored about the user for a data
+// portability request. Secrets such as password hashes, tokens and TOTP
+// seeds are never part of it.
+func (s *AuthServiceImpl) ExportAccount(userID string, client entities.ClientInfo) (*dto.AccountExport, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	export := &dto.AccountExport{
+		User:       user,
+		MFAEnabled: s.mfaEnabled(user.ID),
+		ExportedAt: time.Now().UTC(),
+	}
+
+	if s.identityRepo != nil {
+		if export.Identities, err = s.identityRepo.FindByUserID(user.ID); err != nil {
+			return nil, err
+		}
+	}
+	if export.Sessions, err = s.ListSessions(user.ID); err != nil {
+		return nil, err
+	}
+	if s.webAuthnRepo != nil {
+		if export.Passkeys, err = s.webAuthnRepo.FindCredentialsByUserID(user.ID); err != nil {
+			return nil, err
+		}
+	}
+	if s.auditRepo != nil {
+		if export.AuditEvents, err = s.auditRepo.Find(repositories.AuditEventFilter{UserID: user.ID}); err != nil {
+			return nil, err
+		}
+	}
+
+	s.audit.Record(entities.NewAuditEvent(entities.AuditAccountExported, user.ID, client, nil))
+	return export, nil
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithAccessPolicy sets the roles that may be assigned to users. Without it
+// only the built-in roles are accepted.
+func WithAccessPolicy(policy entities.Policy) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.policy = policy
+	}
+}
+
+// actor names who performed an administrative action in audit records.
+func actor(client entities.ClientInfo) string {
+	if client.ActorID == "" {
+		return "admin_token"
+	}
+	return client.ActorID
+}
+
+func (s *AuthServiceImpl) AdminListUsers(filter repositories.UserFilter) ([]*entities.User, error) {
+	return s.userRepo.List(filter)
+}
+
+func (s *AuthServiceImpl) AdminGetUser(userID string) (*dto.AdminUserDetail, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	sessions, err := s.ListSessions(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &dto.AdminUserDetail{
+		User:       user,
+		Sessions:   sessions,
+		MFAEnabled: s.mfaEnabled(user.ID),
+	}
+	if s.webAuthnRepo != nil {
+		credentials, err := s.webAuthnRepo.FindCredentialsByUserID(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		detail.PasskeyCount = len(credentials)
+	}
+	if s.throttleRepo != nil {
+		if throttle, err := s.throttleRepo.Find(entities.LoginThrottleKey(user.Email.String())); err == nil && throttle.IsLocked() {
+			detail.LockedUntil = throttle.LockedUntil
+		}
+	}
+	return detail, nil
+}
+
+// AdminSetUserActive deactivates or reactivates an account. Deactivated
+// users are signed out everywhere and refused at every login.
+func (s *AuthServiceImpl) AdminSetUserActive(userID string, active bool, client entities.ClientInfo) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	if user.IsDeleted() {
+		return errors.New("account is scheduled for deletion")
+	}
+	if user.IsActive == active {
+		return nil
+	}
+
+	user.IsActive = active
+	user.UpdatedAt = time.Now()
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		if err := tx.Users.Save(user); err != nil {
+			return err
+		}
+		if active {
+			return nil
+		}
+		return revokeAllSessions(tx, user.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	eventType := entities.AuditUserDeactivated
+	if active {
+		eventType = entities.AuditUserReactivated
+	}
+	s.audit.Record(entities.NewAuditEvent(eventType, user.ID, client, map[string]string{
+		"actor": actor(client),
+	}))
+	return nil
+}
+
+// AdminForceLogout ends every session of the user and returns how many
+// there were.
+func (s *AuthServiceImpl) AdminForceLogout(userID string, client entities.ClientInfo) (int, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return 0, errors.New("user not found")
+	}
+
+	sessions, err := s.ListSessions(user.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		return revokeAllSessions(tx, user.ID)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	s.audit.Record(entities.NewAuditEvent(entities.AuditUserForcedLogout, user.ID, client, map[string]string{
+		"actor":    actor(client),
+		"sessions": strconv.Itoa(len(sessions)),
+	}))
+	return len(sessions), nil
+}
+
+// AdminForcePasswordReset invalidates the user's password, signs them out
+// everywhere and emails them a reset link. They cannot sign in with a
+// password until they have chosen a new one.
+func (s *AuthServiceImpl) AdminForcePasswordReset(userID string, client entities.ClientInfo) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	if user.RegistrationMethod != entities.RegMethodEmail {
+		return errors.New("user does not sign in with a password")
+	}
+
+	// The old password is remembered so it cannot simply be chosen again.
+	oldHash := user.PasswordHash
+	user.PasswordHash = ""
+	user.UpdatedAt = time.Now()
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		if err := tx.Users.Save(user); err != nil {
+			return err
+		}
+		if err := s.rememberPassword(tx, user.ID, oldHash); err != nil {
+			return err
+		}
+		return revokeAllSessions(tx, user.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.sendPasswordReset(user); err != nil {
+		return err
+	}
+
+	s.audit.Record(entities.NewAuditEvent(entities.AuditPasswordResetForced, user.ID, client, map[string]string{
+		"actor": actor(client),
+	}))
+	return nil
+}
+
+// AdminSetUserRoles replaces the user's roles and signs them out
+// everywhere. Self-contained access tokens carry the roles they were issued
+// with, so revoking their sessions is what stops the old roles being used.
+func (s *AuthServiceImpl) AdminSetUserRoles(userID string, req *dto.SetUserRolesRequest) (*entities.User, error) {
+	roles := make([]string, 0, len(req.Roles))
+	seen := make(map[string]bool)
+	for _, role := range req.Roles {
+		role = strings.TrimSpace(role)
+		if !s.policy.HasRole(role) {
+			return nil, errors.New("unknown role " + strconv.Quote(role))
+		}
+		if !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	previous := user.Roles
+	user.Roles = roles
+	user.UpdatedAt = time.Now()
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		if err := tx.Users.Save(user); err != nil {
+			return err
+		}
+		return revokeAllSessions(tx, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(entities.NewAuditEvent(entities.AuditRolesChanged, user.ID, req.Client, map[string]string{
+		"actor": actor(req.Client),
+		"from":  strings.Join(previous, " "),
+		"to":    strings.Join(roles, " "),
+	}))
+	return user, nil
+}
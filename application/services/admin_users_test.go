@@ -0,0 +1,244 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	domainrepos "ambassador/domain/repositories"
+	"ambassador/infrastructure/security"
+	"strings"
+	"testing"
+	"time"
+)
+
+func withTestJWTs(t *testing.T) AuthServiceOption {
+	t.Helper()
+	keys := security.NewKeyManager(time.Hour, t.TempDir())
+	key, err := security.GenerateSigningKey(security.AlgES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.Add(key, true); err != nil {
+		t.Fatal(err)
+	}
+	return WithAccessTokenIssuer(security.NewJWTIssuer(keys, security.JWTConfig{Issuer: "ambassador", TTL: 15 * time.Minute}))
+}
+
+func TestAdminSetUserRolesRevokesIssuedTokens(t *testing.T) {
+	env := newTestEnv(t, withTestJWTs(t))
+	user, _ := env.register(t, "ann@example.com")
+	if _, err := env.svc.AdminSetUserRoles(user.ID, &dto.SetUserRolesRequest{Roles: []string{entities.RoleAdmin}}); err != nil {
+		t.Fatal(err)
+	}
+
+	admin, _, err := env.svc.Login(&dto.LoginRequest{Email: "ann@example.com", Password: testPassword})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, token, err := env.svc.Authenticate(admin.AccessToken.Value); err != nil || !entities.DefaultPolicy().Allows(token.Roles, "users:write") {
+		t.Fatalf("admin token was not accepted as admin: %v", err)
+	}
+
+	if _, err := env.svc.AdminSetUserRoles(user.ID, &dto.SetUserRolesRequest{Roles: []string{entities.RoleUser}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := env.svc.Authenticate(admin.AccessToken.Value); err == nil {
+		t.Error("access token issued with the old roles still authenticates")
+	}
+	if _, err := env.svc.RefreshToken(&dto.RefreshTokenRequest{RefreshToken: admin.RefreshToken.Value}); err == nil {
+		t.Error("refresh token issued with the old roles still refreshes")
+	}
+}
+
+func TestAdminListUsersSearchesEmailAndName(t *testing.T) {
+	env := newTestEnv(t)
+	ann, _ := env.register(t, "ann@example.com")
+	bob, _ := env.register(t, "bob@example.com")
+	carol, _ := env.register(t, "carol@example.com")
+	for user, name := range map[*entities.User]string{bob: "Robert Annand", carol: "Carol Smith"} {
+		user.FullName = name
+		if err := env.repos.Users.Save(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := map[string][]string{
+		"ANN@":        {ann.ID},
+		"annand":      {bob.ID},
+		"ann":         {bob.ID, ann.ID},
+		" smith ":     {carol.ID},
+		"example.COM": {carol.ID, bob.ID, ann.ID},
+		"nobody":      {},
+	}
+	for query, want := range cases {
+		t.Run(query, func(t *testing.T) {
+			users, err := env.svc.AdminListUsers(domainrepos.UserFilter{Query: query})
+			if err != nil {
+				t.Fatalf("AdminListUsers: %v", err)
+			}
+			if got := userIDs(users); strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("matched %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestAdminListUsersWalksPagesWithCursor(t *testing.T) {
+	env := newTestEnv(t)
+	var want []string
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"} {
+		user, _ := env.register(t, email)
+		want = append([]string{user.ID}, want...)
+	}
+
+	var got []string
+	filter := domainrepos.UserFilter{Limit: 2}
+	for page := 0; page < 5; page++ {
+		users, err := env.svc.AdminListUsers(filter)
+		if err != nil {
+			t.Fatalf("AdminListUsers: %v", err)
+		}
+		got = append(got, userIDs(users)...)
+		if len(users) < filter.Limit {
+			break
+		}
+		last := users[len(users)-1]
+		filter.BeforeTime, filter.BeforeID, err = dto.DecodeCursor(dto.EncodeCursor(last.CreatedAt, last.ID))
+		if err != nil {
+			t.Fatalf("DecodeCursor: %v", err)
+		}
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("pages listed %v, want every user once, newest first: %v", got, want)
+	}
+}
+
+func TestAdminSetUserRolesRevokesSessions(t *testing.T) {
+	env := newTestEnv(t)
+	user, first := env.register(t, "ann@example.com")
+	second, err := env.svc.startSession(user, "", entities.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, others := env.register(t, "bob@example.com")
+
+	updated, err := env.svc.AdminSetUserRoles(user.ID, &dto.SetUserRolesRequest{Roles: []string{entities.RoleAdmin, entities.RoleAdmin}})
+	if err != nil {
+		t.Fatalf("AdminSetUserRoles: %v", err)
+	}
+	if strings.Join(updated.Roles, ",") != entities.RoleAdmin {
+		t.Errorf("roles = %v, want only %s", updated.Roles, entities.RoleAdmin)
+	}
+	if sessions, _ := env.svc.ListSessions(user.ID); len(sessions) != 0 {
+		t.Errorf("%d sessions survived the role change", len(sessions))
+	}
+	for _, tokenPair := range []*entities.TokenPair{first, second} {
+		if _, err := env.svc.RefreshToken(&dto.RefreshTokenRequest{RefreshToken: tokenPair.RefreshToken.Value}); err == nil {
+			t.Error("refresh token still works after the role change")
+		}
+	}
+	if _, err := env.repos.Sessions.FindByID(others.RefreshToken.FamilyID); err != nil {
+		t.Errorf("session of %s was revoked: %v", other.Email, err)
+	}
+}
+
+func TestAdminSetUserRolesRejectsUnknownRole(t *testing.T) {
+	env := newTestEnv(t)
+	user, tokenPair := env.register(t, "ann@example.com")
+
+	if _, err := env.svc.AdminSetUserRoles(user.ID, &dto.SetUserRolesRequest{Roles: []string{"root"}}); err == nil {
+		t.Fatal("AdminSetUserRoles accepted an unknown role")
+	}
+	if _, err := env.repos.Sessions.FindByID(tokenPair.RefreshToken.FamilyID); err != nil {
+		t.Errorf("session was revoked by a rejected role change: %v", err)
+	}
+}
+
+func TestAdminForcePasswordReset(t *testing.T) {
+	env := newTestEnv(t)
+	user, tokenPair := env.register(t, "ann@example.com")
+	env.mailer.Reset()
+
+	if err := env.svc.AdminForcePasswordReset(user.ID, entities.ClientInfo{ActorID: "admin-1"}); err != nil {
+		t.Fatalf("AdminForcePasswordReset: %v", err)
+	}
+
+	if _, _, err := env.svc.Login(&dto.LoginRequest{Email: "ann@example.com", Password: testPassword}); err == nil {
+		t.Error("old password still signs in")
+	}
+	if _, err := env.svc.RefreshToken(&dto.RefreshTokenRequest{RefreshToken: tokenPair.RefreshToken.Value}); err == nil {
+		t.Error("refresh token still works after the forced reset")
+	}
+	if events := env.auditEvents(t, user.ID, entities.AuditPasswordResetForced); len(events) != 1 || events[0].Details["actor"] != "admin-1" {
+		t.Errorf("audit events = %v, want one naming the admin", events)
+	}
+
+	secret := env.mailedToken(t, "ann@example.com", "/reset-password")
+	if err := env.svc.ResetPassword(&dto.ResetPasswordRequest{Token: secret, NewPassword: testPassword}); err == nil {
+		t.Error("the invalidated password was accepted as the new one")
+	}
+	if err := env.svc.ResetPassword(&dto.ResetPasswordRequest{Token: secret, NewPassword: newTestPassword}); err != nil {
+		t.Fatalf("ResetPassword with the mailed link: %v", err)
+	}
+	if _, _, err := env.svc.Login(&dto.LoginRequest{Email: "ann@example.com", Password: newTestPassword}); err != nil {
+		t.Errorf("Login with the new password: %v", err)
+	}
+}
+
+func TestAdminForcePasswordResetUnknownUser(t *testing.T) {
+	env := newTestEnv(t)
+	env.register(t, "ann@example.com")
+	env.mailer.Reset()
+
+	if err := env.svc.AdminForcePasswordReset("missing", entities.ClientInfo{}); err == nil || err.Error() != "user not found" {
+		t.Errorf("AdminForcePasswordReset = %v, want user not found", err)
+	}
+	if len(env.mailer.Messages()) != 0 {
+		t.Error("a reset link was mailed")
+	}
+}
+
+func userIDs(users []*entities.User) []string {
+	ids := make([]string, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+	return ids
+}
+
+func TestAdminListAuditEvents(t *testing.T) {
+	env := newTestEnv(t)
+	if _, err := env.svc.AdminListAuditEvents(domainrepos.AuditEventFilter{}); err == nil {
+		t.Error("AdminListAuditEvents succeeded without an audit history")
+	}
+
+	env = newTestEnv(t)
+	env.svc.auditRepo = env.auditLog
+	user, _ := env.register(t, "ann@example.com")
+	events, err := env.svc.AdminListAuditEvents(domainrepos.AuditEventFilter{UserID: user.ID, Type: entities.AuditUserRegistered})
+	if err != nil {
+		t.Fatalf("AdminListAuditEvents: %v", err)
+	}
+	if len(events) != 1 || events[0].UserID != user.ID {
+		t.Errorf("AdminListAuditEvents = %v, want the registration", events)
+	}
+}
+
+func TestAdminActionsRecordTheActor(t *testing.T) {
+	env := newTestEnv(t)
+	env.svc.auditRepo = env.auditLog
+	user, _ := env.register(t, "ann@example.com")
+	admin, _ := env.register(t, "admin@example.com")
+
+	if _, err := env.svc.AdminForceLogout(user.ID, entities.ClientInfo{ActorID: admin.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := env.svc.AdminListAuditEvents(domainrepos.AuditEventFilter{ActorID: admin.ID})
+	if err != nil {
+		t.Fatalf("AdminListAuditEvents: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != entities.AuditUserForcedLogout || events[0].UserID != user.ID {
+		t.Errorf("events by the admin = %v, want the forced logout of %s", events, user.ID)
+	}
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"errors"
+)
+
+// WithAuditHistory lets account exports include the user's audit events.
+// Without it exports leave them out.
+func WithAuditHistory(repo repositories.AuditRepository) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.auditRepo = repo
+	}
+}
+
+// AdminListAuditEvents searches the stored audit trail, newest first. It
+// fails when the service was built without WithAuditHistory.
+func (s *AuthServiceImpl) AdminListAuditEvents(filter repositories.AuditEventFilter) ([]*entities.AuditEvent, error) {
+	if s.auditRepo == nil {
+		return nil, errors.New("audit history is not available")
+	}
+	return s.auditRepo.Find(filter)
+}
+
+// recordLoginSucceeded records a completed sign-in. method is how the user
+// authenticated, e.g. "password", "passkey" or the OAuth provider.
+func (s *AuthServiceImpl) recordLoginSucceeded(userID, method string, tokenPair *entities.TokenPair, client entities.ClientInfo) {
+	s.audit.Record(entities.NewAuditEvent(entities.AuditLoginSucceeded, userID, client, map[string]string{
+		"method":    method,
+		"sessionId": tokenPair.RefreshToken.FamilyID,
+	}))
+}
+
+// recordLoginFailed records a rejected sign-in. userID is empty when no
+// account matched the email address.
+func (s *AuthServiceImpl) recordLoginFailed(userID, email, reason string, client entities.ClientInfo) {
+	s.audit.Record(entities.NewAuditEvent(entities.AuditLoginFailed, userID, client, map[string]string{
+		"email":  email,
+		"reason": reason,
+	}))
+}
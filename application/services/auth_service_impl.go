@@ -4,46 +4,219 @@ import (
 	"ambassador/application/dto"
 	"ambassador/domain/entities"
 	"ambassador/domain/repositories"
+	domainservices "ambassador/domain/services"
+	"ambassador/infrastructure/audit"
+	"ambassador/infrastructure/mail"
+	"ambassador/infrastructure/oauth"
+	"ambassador/infrastructure/passkey"
 	"ambassador/infrastructure/security"
+	"context"
 	"errors"
-	"regexp"
+	"fmt"
+	"log"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type AuthServiceImpl struct {
-	userRepo  repositories.UserRepository
-	tokenRepo repositories.TokenRepository
-	hasher    security.PasswordHasher
+	userRepo          repositories.UserRepository
+	tokenRepo         repositories.TokenRepository
+	sessionRepo       repositories.SessionRepository
+	uow               repositories.UnitOfWork
+	hasher            security.PasswordHasher
+	accessTokens      security.AccessTokenIssuer
+	audit             domainservices.AuditLogger
+	refreshReuseGrace time.Duration
+	identityRepo      repositories.IdentityRepository
+	idTokenVerifiers  map[entities.RegistrationMethod]oauth.IDTokenVerifier
+	mailer            mail.Mailer
+	mailTemplates     *mail.Renderer
+	linkBaseURL       string
+	passwordResetTTL  time.Duration
+	unverifiedPolicy  UnverifiedLoginPolicy
+	verificationTTL   time.Duration
+	emailChangeTTL    time.Duration
+	emailRevertTTL    time.Duration
+	passwordPolicy    *security.PasswordPolicy
+	passwordHistory   repositories.PasswordHistoryRepository
+	passwordMaxAge    time.Duration
+	linkSigner        *security.LinkSigner
+	magicLinkTTL      time.Duration
+	deletionGrace     time.Duration
+	auditRepo         repositories.AuditRepository
+	mfaRepo           repositories.MFARepository
+	totpIssuer        string
+	secretBox         *security.SecretBox
+	mfaChallengeTTL   time.Duration
+	mfaAttempts       *mfaAttempts
+	webAuthnRepo      repositories.WebAuthnRepository
+	passkeys          *passkey.RelyingParty
+	throttleRepo      repositories.LoginThrottleRepository
+	lockout           LockoutPolicy
+	policy            entities.Policy
+	dummyHashOnce     sync.Once
+	dummyHash         string
 }
 
-func NewAuthService(userRepo repositories.UserRepository, tokenRepo repositories.TokenRepository, hasher security.PasswordHasher) *AuthServiceImpl {
-	return &AuthServiceImpl{
-		userRepo:  userRepo,
-		tokenRepo: tokenRepo,
-		hasher:    hasher,
+// UnverifiedLoginPolicy decides what a user whose email address has not been
+// verified yet may do.
+type UnverifiedLoginPolicy string
+
+const (
+	// UnverifiedLoginAllow treats unverified users like everybody else.
+	UnverifiedLoginAllow UnverifiedLoginPolicy = "allow"
+	// UnverifiedLoginLimited issues access tokens restricted to
+	// entities.LimitedScopes until the address is verified.
+	UnverifiedLoginLimited UnverifiedLoginPolicy = "limited"
+	// UnverifiedLoginBlock refuses to issue tokens at all.
+	UnverifiedLoginBlock UnverifiedLoginPolicy = "block"
+)
+
+type AuthServiceOption func(*AuthServiceImpl)
+
+// WithAccessTokenIssuer replaces the default opaque access tokens, e.g. with
+// signed JWTs.
+func WithAccessTokenIssuer(issuer security.AccessTokenIssuer) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.accessTokens = issuer
 	}
 }
 
-func validatePassword(password string) error {
-	if len(password) < 8 {
-		return errors.New("password must be at least 8 characters long")
+func WithAuditLogger(logger domainservices.AuditLogger) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.audit = logger
 	}
-	if !regexp.MustCompile(`[A-Z]`).MatchString(password) {
-		return errors.New("password must contain at least one uppercase letter")
+}
+
+// WithRefreshReuseGrace sets how long a rotated refresh token may still be
+// exchanged, so that a client racing two refreshes is not treated as a
+// replay attack.
+func WithRefreshReuseGrace(grace time.Duration) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.refreshReuseGrace = grace
 	}
-	if !regexp.MustCompile(`[a-z]`).MatchString(password) {
-		return errors.New("password must contain at least one lowercase letter")
+}
+
+// WithIdentityProviders enables registration and login with provider ID
+// tokens, e.g. Sign in with Google or Apple.
+func WithIdentityProviders(identityRepo repositories.IdentityRepository, verifiers map[entities.RegistrationMethod]oauth.IDTokenVerifier) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.identityRepo = identityRepo
+		s.idTokenVerifiers = verifiers
 	}
-	if !regexp.MustCompile(`[0-9]`).MatchString(password) {
-		return errors.New("password must contain at least one number")
+}
+
+// WithMailer sets where outgoing emails go. Send is called on the request
+// path, so slow transports such as SMTP should be wrapped in a mail.Queue.
+func WithMailer(mailer mail.Mailer) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.mailer = mailer
 	}
-	if !regexp.MustCompile(`[!@#$%^&*()_+\-=\[\]{}|;:,.<>?]`).MatchString(password) {
-		return errors.New("password must contain at least one special character")
+}
+
+func WithMailTemplates(renderer *mail.Renderer) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.mailTemplates = renderer
+	}
+}
+
+// WithLinkBaseURL sets the front-end URL that links in outgoing emails
+// point at.
+func WithLinkBaseURL(baseURL string) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.linkBaseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+func WithPasswordResetTTL(ttl time.Duration) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.passwordResetTTL = ttl
+	}
+}
+
+// WithPasswordPolicy replaces the rules new passwords must satisfy.
+func WithPasswordPolicy(policy *security.PasswordPolicy) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.passwordPolicy = policy
+	}
+}
+
+// WithEmailVerification sets the unverified login policy and how long
+// verification links stay valid.
+func WithEmailVerification(policy UnverifiedLoginPolicy, ttl time.Duration) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.unverifiedPolicy = policy
+		s.verificationTTL = ttl
+	}
+}
+
+// WithMFA enables TOTP two-factor authentication. issuer is the account name
+// shown in authenticator apps; box, when non-nil, encrypts TOTP secrets at
+// rest.
+func WithMFA(mfaRepo repositories.MFARepository, issuer string, box *security.SecretBox, challengeTTL time.Duration) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.mfaRepo = mfaRepo
+		s.totpIssuer = issuer
+		s.secretBox = box
+		s.mfaChallengeTTL = challengeTTL
+	}
+}
+
+func NewAuthService(userRepo repositories.UserRepository, tokenRepo repositories.TokenRepository, sessionRepo repositories.SessionRepository, uow repositories.UnitOfWork, hasher security.PasswordHasher, opts ...AuthServiceOption) *AuthServiceImpl {
+	// The embedded templates always include the "en" locale.
+	mailTemplates, _ := mail.NewRenderer(mail.DefaultTemplates(), "en")
+
+	s := &AuthServiceImpl{
+		userRepo:          userRepo,
+		tokenRepo:         tokenRepo,
+		sessionRepo:       sessionRepo,
+		uow:               uow,
+		hasher:            hasher,
+		accessTokens:      security.NewOpaqueTokenIssuer(tokenRepo),
+		audit:             audit.NewLogAuditLogger(),
+		refreshReuseGrace: 10 * time.Second,
+		mailer:            mail.NewLogMailer(),
+		mailTemplates:     mailTemplates,
+		linkBaseURL:       "http://localhost:3000",
+		passwordResetTTL:  30 * time.Minute,
+		unverifiedPolicy:  UnverifiedLoginAllow,
+		verificationTTL:   24 * time.Hour,
+		emailChangeTTL:    24 * time.Hour,
+		emailRevertTTL:    7 * 24 * time.Hour,
+		deletionGrace:     30 * 24 * time.Hour,
+		magicLinkTTL:      15 * time.Minute,
+		passwordPolicy:    security.DefaultPasswordPolicy(),
+		totpIssuer:        "Ambassador",
+		mfaChallengeTTL:   5 * time.Minute,
+		mfaAttempts:       newMFAAttempts(),
+		policy:            entities.DefaultPolicy(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// validatePassword checks a new password against the password policy.
+// email and fullName belong to the account it is for.
+func (s *AuthServiceImpl) validatePassword(password, email, fullName string) error {
+	return s.passwordPolicy.Validate(security.PasswordCandidate{
+		Password: password,
+		Email:    email,
+		FullName: fullName,
+	})
+}
+
+// PasswordPolicy describes the rules validatePassword applies.
+func (s *AuthServiceImpl) PasswordPolicy() *dto.PasswordPolicyResponse {
+	return &dto.PasswordPolicyResponse{
+		PasswordRequirements: s.passwordPolicy.Requirements(),
+		Screening:            s.passwordPolicy.Screening(),
 	}
-	return nil
 }
 
 func (s *AuthServiceImpl) generateUniqueToken(userID string, tokenType entities.TokenType) (*entities.Token, error) {
@@ -69,6 +242,76 @@ func (s *AuthServiceImpl) generateUniqueToken(userID string, tokenType entities.
 	return token, nil
 }
 
+// newTokenPair issues an access and refresh token belonging to familyID; an
+// empty familyID starts a new family. The family ID doubles as the ID of the
+// Session the tokens belong to.
+func (s *AuthServiceImpl) newTokenPair(user *entities.User, familyID string) (*entities.TokenPair, error) {
+	if familyID == "" {
+		familyID = entities.NewTokenFamilyID()
+	}
+
+	accessToken, err := s.accessTokens.Issue(user.ID, familyID, user.Roles, s.scopesFor(user))
+	if err != nil {
+		return nil, err
+	}
+
+	return &entities.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: entities.NewRefreshTokenInFamily(user.ID, familyID),
+	}, nil
+}
+
+// scopesFor returns the scopes an access token for user should carry. Scopes
+// are decided at issue time, so a user who verifies their email gets full
+// access on their next refresh.
+func (s *AuthServiceImpl) scopesFor(user *entities.User) []string {
+	if s.unverifiedPolicy == UnverifiedLoginLimited && !user.EmailVerified {
+		return entities.LimitedScopes
+	}
+	return nil
+}
+
+func (s *AuthServiceImpl) checkEmailVerified(user *entities.User) error {
+	if s.unverifiedPolicy == UnverifiedLoginBlock && !user.EmailVerified {
+		return errors.New("email address not verified")
+	}
+	return nil
+}
+
+// sendMail renders template in the user's locale and hands it to the
+// mailer. Failures are only logged: callers such as ForgotPassword must
+// respond the same way whether or not an email went out.
+func (s *AuthServiceImpl) sendMail(user *entities.User, template string, data map[string]interface{}) {
+	s.sendMailTo(user, user.Email.String(), template, data)
+}
+
+// sendMailTo is sendMail for an address other than the user's current one.
+func (s *AuthServiceImpl) sendMailTo(user *entities.User, to, template string, data map[string]interface{}) {
+	data["Name"] = user.FullName
+	msg, err := s.mailTemplates.Render(template, user.Locale, to, data)
+	if err != nil {
+		log.Printf("mail: failed to render %s for user %s: %v", template, user.ID, err)
+		return
+	}
+	if err := s.mailer.Send(msg); err != nil {
+		log.Printf("mail: failed to send %s to user %s: %v", template, user.ID, err)
+	}
+}
+
+// link builds a front-end URL carrying a one-time token secret.
+func (s *AuthServiceImpl) link(path, secret string) string {
+	return fmt.Sprintf("%s%s?token=%s", s.linkBaseURL, path, url.QueryEscape(secret))
+}
+
+func (s *AuthServiceImpl) saveTokenPair(tokenRepo repositories.TokenRepository, tokenPair *entities.TokenPair) error {
+	if !s.accessTokens.Stateless() {
+		if err := tokenRepo.Save(tokenPair.AccessToken); err != nil {
+			return err
+		}
+	}
+	return tokenRepo.Save(tokenPair.RefreshToken)
+}
+
 func (s *AuthServiceImpl) Register(req *dto.RegisterRequest) (*entities.User, *entities.TokenPair, error) {
 	exists, err := s.userRepo.ExistsByEmail(req.Email)
 	if err != nil {
@@ -87,20 +330,35 @@ func (s *AuthServiceImpl) Register(req *dto.RegisterRequest) (*entities.User, *e
 		return nil, nil, err
 	}
 
-	var passwordHash string
+	var (
+		passwordHash string
+		identity     *oauth.Identity
+	)
 	if req.RegistrationMethod == entities.RegMethodEmail {
 		if strings.TrimSpace(req.Password) == "" {
 			return nil, nil, errors.New("password is required for email registration")
 		}
-		if err := validatePassword(req.Password); err != nil {
+		if err := s.validatePassword(req.Password, req.Email, req.FullName); err != nil {
 			return nil, nil, err
 		}
 		passwordHash, err = s.hasher.HashPassword(req.Password)
 		if err != nil {
 			return nil, nil, err
 		}
-	} else if req.Password != "" {
-		return nil, nil, errors.New("password should not be provided for OAuth registration")
+	} else {
+		if req.Password != "" {
+			return nil, nil, errors.New("password should not be provided for OAuth registration")
+		}
+		identity, err = s.verifyIDToken(req.RegistrationMethod, req.IDToken, req.Nonce)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !identity.EmailVerified || identity.Email != strings.ToLower(strings.TrimSpace(req.Email)) {
+			return nil, nil, errors.New("email does not match the verified provider account")
+		}
+		if _, err := s.identityRepo.FindByProviderSubject(req.RegistrationMethod, identity.Subject); err == nil {
+			return nil, nil, errors.New("user already exists")
+		}
 	}
 
 	user, err := entities.NewUser(
@@ -116,56 +374,360 @@ func (s *AuthServiceImpl) Register(req *dto.RegisterRequest) (*entities.User, *e
 	}
 
 	user.ID = uuid.New().String()
+	user.Locale = mail.NormalizeLocale(req.Locale)
+
+	// The provider has already checked that the user owns the address.
+	var verifySecret string
+	var verifyToken *entities.Token
+	if identity != nil {
+		user.MarkEmailVerified()
+	} else {
+		verifySecret, verifyToken = entities.NewOneTimeToken(user.ID, entities.TokenTypeEmailVerify, s.verificationTTL)
+	}
+
+	// Under the block policy an unverified user gets no tokens until the
+	// address is confirmed.
+	var (
+		tokenPair *entities.TokenPair
+		session   *entities.Session
+	)
+	if s.checkEmailVerified(user) == nil {
+		tokenPair, session, err = s.newSession(user, req.DeviceName, req.Client)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 
-	if err := s.userRepo.Save(user); err != nil {
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		if err := tx.Users.Save(user); err != nil {
+			return err
+		}
+		if identity != nil {
+			link := entities.NewExternalIdentity(req.RegistrationMethod, identity.Subject, user.ID, identity.Email)
+			if err := tx.Identities.Save(link); err != nil {
+				return err
+			}
+		}
+		if verifyToken != nil {
+			if err := tx.Tokens.Save(verifyToken); err != nil {
+				return err
+			}
+		}
+		if tokenPair == nil {
+			return nil
+		}
+		return s.saveSession(tx, tokenPair, session)
+	})
+	if err != nil {
 		return nil, nil, err
 	}
 
-	tokenPair := entities.NewTokenPair(user.ID)
-
-	if err := s.tokenRepo.Save(tokenPair.AccessToken); err != nil {
-		return nil, nil, err
+	if verifyToken != nil {
+		s.sendVerificationEmail(user, verifySecret)
 	}
-	if err := s.tokenRepo.Save(tokenPair.RefreshToken); err != nil {
-		return nil, nil, err
+
+	s.audit.Record(entities.NewAuditEvent(entities.AuditUserRegistered, user.ID, req.Client, map[string]string{
+		"method": string(user.RegistrationMethod),
+	}))
+	if tokenPair != nil {
+		s.recordLoginSucceeded(user.ID, string(user.RegistrationMethod), tokenPair, req.Client)
 	}
 
 	return user, tokenPair, nil
 }
 
-func (s *AuthServiceImpl) Login(req *dto.LoginRequest) (*entities.TokenPair, error) {
+// Login checks the user's password. Users with MFA enabled get a Challenge
+// instead of a TokenPair, to be completed through VerifyMFA; so do users
+// whose password has expired, through ChangeExpiredPassword.
+//
+// Failed attempts are throttled per email address whether or not an account
+// exists for it, so the responses do not reveal which addresses are
+// registered.
+func (s *AuthServiceImpl) Login(req *dto.LoginRequest) (*entities.TokenPair, *entities.Challenge, error) {
+	throttleKey := entities.LoginThrottleKey(req.Email)
+	if err := s.checkLoginThrottle(throttleKey); err != nil {
+		s.recordLoginFailed("", req.Email, "throttled", req.Client)
+		return nil, nil, err
+	}
+
 	user, err := s.userRepo.FindByEmail(req.Email)
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		return nil, nil, s.failLogin(throttleKey, nil, req, "unknown_user", errors.New("invalid credentials"))
+	}
+
+	if user.IsDeleted() {
+		return nil, nil, s.failLogin(throttleKey, user, req, "account_deleted", errors.New("account is scheduled for deletion"))
 	}
 
 	if !user.IsActive {
-		return nil, errors.New("account is deactivated")
+		return nil, nil, s.failLogin(throttleKey, user, req, "account_deactivated", errors.New("account is deactivated"))
 	}
 
 	if user.RegistrationMethod != entities.RegMethodEmail {
-		return nil, errors.New("please use OAuth login method")
+		return nil, nil, s.failLogin(throttleKey, user, req, "oauth_account", errors.New("please use OAuth login method"))
 	}
 
 	if !s.hasher.CheckPassword(req.Password, user.PasswordHash) {
-		return nil, errors.New("invalid credentials")
+		s.recordLoginFailure(throttleKey, user, req.Client)
+		s.recordLoginFailed(user.ID, req.Email, "invalid_password", req.Client)
+		return nil, nil, errors.New("invalid credentials")
+	}
+	// With MFA the throttle is only reset once the code is in as well, as
+	// wrong codes count against it too.
+	mfa := s.mfaEnabled(user.ID)
+	if !mfa {
+		s.resetLoginThrottle(user)
+	}
+	s.upgradePasswordHash(user, req.Password)
+
+	if err := s.checkEmailVerified(user); err != nil {
+		s.recordLoginFailed(user.ID, req.Email, "email_not_verified", req.Client)
+		return nil, nil, err
+	}
+
+	if mfa {
+		challenge, err := s.newMFAChallenge(user.ID, "password")
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, challenge, nil
+	}
+
+	// The password change is only offered once every other factor has been
+	// checked, so that a stolen password alone cannot be used to replace it.
+	if user.PasswordExpired(s.passwordMaxAge) {
+		challenge, err := s.newPasswordExpiredChallenge(s.tokenRepo, user.ID, "password")
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, challenge, nil
+	}
+
+	tokenPair, err := s.startSession(user, req.DeviceName, req.Client)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.recordLoginSucceeded(user.ID, "password", tokenPair, req.Client)
+
+	return tokenPair, nil, nil
+}
+
+// failLogin turns a login down before the password was checked. It spends
+// as long as a password check would and counts against the throttle, so
+// that neither timing nor lockouts tell these cases from a wrong password.
+// user is nil when no account has the address.
+func (s *AuthServiceImpl) failLogin(throttleKey string, user *entities.User, req *dto.LoginRequest, reason string, err error) error {
+	s.hasher.CheckPassword(req.Password, s.dummyPasswordHash())
+	s.recordLoginFailure(throttleKey, user, req.Client)
+
+	userID := ""
+	if user != nil {
+		userID = user.ID
+	}
+	s.recordLoginFailed(userID, req.Email, reason, req.Client)
+	return err
+}
+
+// dummyPasswordHash is compared against when there is no password to check.
+func (s *AuthServiceImpl) dummyPasswordHash() string {
+	s.dummyHashOnce.Do(func() {
+		s.dummyHash, _ = s.hasher.HashPassword("ambassador-dummy-password")
+	})
+	return s.dummyHash
+}
+
+// upgradePasswordHash rehashes a password that has just been verified when
+// its stored hash came from an older algorithm or weaker parameters. Failing
+// to do so does not fail the login.
+func (s *AuthServiceImpl) upgradePasswordHash(user *entities.User, password string) {
+	if !s.hasher.NeedsRehash(user.PasswordHash) {
+		return
+	}
+
+	hash, err := s.hasher.HashPassword(password)
+	if err != nil {
+		log.Printf("failed to rehash password for user %s: %v", user.ID, err)
+		return
+	}
+	user.PasswordHash = hash
+	if err := s.userRepo.Save(user); err != nil {
+		log.Printf("failed to save rehashed password for user %s: %v", user.ID, err)
+	}
+}
+
+// OAuthLogin signs a user in with a provider ID token. A known identity logs
+// straight in; otherwise the identity is linked to the account with the same
+// verified email, or a new account is created from the request's profile.
+// Linking an account whose own address was never verified takes its
+// password, see confirmAccountLink.
+func (s *AuthServiceImpl) OAuthLogin(req *dto.OAuthLoginRequest) (*entities.User, *entities.TokenPair, *entities.Challenge, error) {
+	identity, err := s.verifyIDToken(req.Provider, req.IDToken, req.Nonce)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var (
+		user    *entities.User
+		link    *entities.ExternalIdentity
+		created bool
+	)
+	if existing, err := s.identityRepo.FindByProviderSubject(req.Provider, identity.Subject); err == nil {
+		user, err = s.userRepo.FindByID(existing.UserID)
+		if err != nil {
+			return nil, nil, nil, errors.New("user not found")
+		}
+	} else {
+		if !identity.EmailVerified || identity.Email == "" {
+			return nil, nil, nil, errors.New("provider account has no verified email")
+		}
+
+		user, err = s.userRepo.FindByEmail(identity.Email)
+		if err != nil {
+			user, err = s.newOAuthUser(req, identity)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			created = true
+		} else if err := s.confirmAccountLink(user, req); err != nil {
+			return nil, nil, nil, err
+		}
+		link = entities.NewExternalIdentity(req.Provider, identity.Subject, user.ID, identity.Email)
+	}
+
+	if !user.IsActive {
+		return nil, nil, nil, errors.New("account is deactivated")
+	}
+	// Linking leaves the account's verification as it was: the provider
+	// vouches for its own identity, not for whoever registered the address
+	// here first.
+	if err := s.checkEmailVerified(user); err != nil {
+		return nil, nil, nil, err
+	}
+
+	var (
+		tokenPair *entities.TokenPair
+		session   *entities.Session
+	)
+	mfa := s.mfaEnabled(user.ID)
+	if !mfa {
+		tokenPair, session, err = s.newSession(user, req.DeviceName, req.Client)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		if created {
+			if err := tx.Users.Save(user); err != nil {
+				return err
+			}
+		}
+		if link != nil {
+			if err := tx.Identities.Save(link); err != nil {
+				return err
+			}
+		}
+		if mfa {
+			return nil
+		}
+		return s.saveSession(tx, tokenPair, session)
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if created {
+		s.audit.Record(entities.NewAuditEvent(entities.AuditUserRegistered, user.ID, req.Client, map[string]string{
+			"method": string(req.Provider),
+		}))
+	}
+
+	if mfa {
+		challenge, err := s.newMFAChallenge(user.ID, "oauth")
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return user, nil, challenge, nil
+	}
+	s.recordLoginSucceeded(user.ID, string(req.Provider), tokenPair, req.Client)
+
+	return user, tokenPair, nil, nil
+}
+
+// confirmAccountLink guards linking a provider identity to an existing
+// account. An account whose address was never verified may have been
+// registered by someone else ahead of the address's owner, waiting to share
+// the account once the owner signs in with the provider; so its password
+// has to be given, and is throttled like a login.
+func (s *AuthServiceImpl) confirmAccountLink(user *entities.User, req *dto.OAuthLoginRequest) error {
+	if user.EmailVerified {
+		return nil
+	}
+	if req.Password == "" {
+		return errors.New("account password required to link this sign-in method")
 	}
 
-	s.tokenRepo.DeleteAllUserTokens(user.ID, entities.TokenTypeRefresh)
+	throttleKey := entities.LoginThrottleKey(user.Email.String())
+	if err := s.checkLoginThrottle(throttleKey); err != nil {
+		s.recordLoginFailed(user.ID, user.Email.String(), "throttled", req.Client)
+		return err
+	}
+	if user.PasswordHash == "" || !s.hasher.CheckPassword(req.Password, user.PasswordHash) {
+		s.recordLoginFailure(throttleKey, user, req.Client)
+		s.recordLoginFailed(user.ID, user.Email.String(), "invalid_password", req.Client)
+		return errors.New("invalid credentials")
+	}
+	if !s.mfaEnabled(user.ID) {
+		s.resetLoginThrottle(user)
+	}
+	return nil
+}
 
-	tokenPair := entities.NewTokenPair(user.ID)
+func (s *AuthServiceImpl) newOAuthUser(req *dto.OAuthLoginRequest, identity *oauth.Identity) (*entities.User, error) {
+	fullName := req.FullName
+	if strings.TrimSpace(fullName) == "" {
+		fullName = identity.Name
+	}
+	if strings.TrimSpace(fullName) == "" || req.Gender == "" || req.DateOfBirth == "" {
+		return nil, errors.New("registration details required")
+	}
 
-	if err := s.tokenRepo.Save(tokenPair.AccessToken); err != nil {
+	dob, err := time.Parse("2006-01-02", req.DateOfBirth)
+	if err != nil {
+		return nil, errors.New("invalid date of birth format")
+	}
+	if err := ValidateDateOfBirth(req.DateOfBirth); err != nil {
 		return nil, err
 	}
-	if err := s.tokenRepo.Save(tokenPair.RefreshToken); err != nil {
+
+	user, err := entities.NewUser(identity.Email, fullName, req.Gender, dob, req.Provider, "")
+	if err != nil {
 		return nil, err
 	}
+	user.ID = uuid.New().String()
+	user.Locale = mail.NormalizeLocale(req.Locale)
+	// OAuthLogin only gets here for a provider-verified email.
+	user.MarkEmailVerified()
 
-	return tokenPair, nil
+	return user, nil
 }
 
-func (s *AuthServiceImpl) RefreshToken(refreshTokenValue string) (*entities.TokenPair, error) {
+func (s *AuthServiceImpl) verifyIDToken(provider entities.RegistrationMethod, idToken, nonce string) (*oauth.Identity, error) {
+	verifier, ok := s.idTokenVerifiers[provider]
+	if !ok {
+		return nil, fmt.Errorf("%s sign-in is not configured", provider)
+	}
+	if strings.TrimSpace(idToken) == "" {
+		return nil, errors.New("id token is required for OAuth registration")
+	}
+	if err := s.consumeOAuthNonce(nonce); err != nil {
+		return nil, err
+	}
+	return verifier.Verify(idToken, nonce)
+}
+
+func (s *AuthServiceImpl) RefreshToken(req *dto.RefreshTokenRequest) (*entities.TokenPair, error) {
+	refreshTokenValue := req.RefreshToken
 	refreshToken, err := s.tokenRepo.FindByValue(refreshTokenValue)
 	if err != nil {
 		return nil, errors.New("invalid refresh token")
@@ -180,6 +742,21 @@ func (s *AuthServiceImpl) RefreshToken(refreshTokenValue string) (*entities.Toke
 		return nil, errors.New("refresh token expired")
 	}
 
+	if refreshToken.IsRotated() && time.Since(refreshToken.RotatedAt) > s.refreshReuseGrace {
+		// The token was already exchanged, so either the client or an attacker
+		// holds a stolen copy. Revoke the whole lineage so neither branch works.
+		err := s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+			return revokeSession(tx, refreshToken.FamilyID)
+		})
+		if err != nil {
+			return nil, err
+		}
+		s.audit.Record(entities.NewAuditEvent(entities.AuditRefreshTokenReuse, refreshToken.UserID, req.Client, map[string]string{
+			"familyId": refreshToken.FamilyID,
+		}))
+		return nil, errors.New("refresh token reuse detected")
+	}
+
 	user, err := s.userRepo.FindByID(refreshToken.UserID)
 	if err != nil {
 		return nil, errors.New("user not found")
@@ -189,56 +766,112 @@ func (s *AuthServiceImpl) RefreshToken(refreshTokenValue string) (*entities.Toke
 		return nil, errors.New("account is deactivated")
 	}
 
-	newTokenPair := entities.NewTokenPair(user.ID)
+	if err := s.checkEmailVerified(user); err != nil {
+		return nil, err
+	}
 
-	if err := s.tokenRepo.Save(newTokenPair.AccessToken); err != nil {
+	newTokenPair, err := s.newTokenPair(user, refreshToken.FamilyID)
+	if err != nil {
 		return nil, err
 	}
-	if err := s.tokenRepo.Save(newTokenPair.RefreshToken); err != nil {
+
+	// Tokens issued before sessions were tracked have no session yet, or no
+	// family at all; they get one now.
+	session, err := s.sessionRepo.FindByID(newTokenPair.RefreshToken.FamilyID)
+	if err != nil {
+		session = entities.NewSession(newTokenPair.RefreshToken, "", req.Client)
+	} else {
+		session.Touch(newTokenPair.RefreshToken, req.Client)
+	}
+
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		if err := s.saveSession(tx, newTokenPair, session); err != nil {
+			return err
+		}
+		if refreshToken.IsRotated() {
+			return nil
+		}
+		refreshToken.RotatedAt = time.Now()
+		return tx.Tokens.Save(refreshToken)
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	s.tokenRepo.Delete(refreshTokenValue)
+	s.audit.Record(entities.NewAuditEvent(entities.AuditTokenRefreshed, user.ID, req.Client, map[string]string{
+		"sessionId": session.ID,
+	}))
 
 	return newTokenPair, nil
 }
 
+// PurgeExpiredTokens deletes every token past its expiry. That includes
+// rotated refresh tokens, which are only kept until they would have expired
+// so that RefreshToken can recognise a replay.
+func (s *AuthServiceImpl) PurgeExpiredTokens() error {
+	return s.tokenRepo.DeleteExpired()
+}
+
 func (s *AuthServiceImpl) GetProfile(accessTokenValue string) (*entities.User, error) {
-	token, err := s.tokenRepo.FindByValue(accessTokenValue)
-	if err != nil {
-		return nil, errors.New("invalid access token")
-	}
+	user, _, err := s.Authenticate(accessTokenValue)
+	return user, err
+}
 
-	if token.Type != entities.TokenTypeAccess {
-		return nil, errors.New("invalid token type")
+// Authenticate validates an access token and returns its owner together with
+// the token, so callers can check the scopes it grants.
+func (s *AuthServiceImpl) Authenticate(accessTokenValue string) (*entities.User, *entities.Token, error) {
+	token, err := s.accessTokens.Validate(accessTokenValue)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if token.IsExpired() {
-		s.tokenRepo.Delete(accessTokenValue)
-		return nil, errors.New("access token expired")
+	// Opaque tokens are deleted along with their session; self-contained
+	// ones stay valid until they expire, so check the session is still live.
+	if s.accessTokens.Stateless() && token.FamilyID != "" {
+		if session, err := s.sessionRepo.FindByID(token.FamilyID); err != nil || session.IsExpired() {
+			return nil, nil, errors.New("session has been revoked")
+		}
 	}
 
 	user, err := s.userRepo.FindByID(token.UserID)
 	if err != nil {
-		return nil, errors.New("user not found")
+		return nil, nil, errors.New("user not found")
 	}
 
 	if !user.IsActive {
-		return nil, errors.New("account is deactivated")
+		return nil, nil, errors.New("account is deactivated")
 	}
 
-	return user, nil
+	// Tokens issued before roles existed carry none; fall back to the
+	// account's current roles.
+	if token.Roles == nil {
+		token.Roles = user.Roles
+	}
+
+	return user, token, nil
 }
 
-func (s *AuthServiceImpl) Logout(refreshTokenValue string) error {
+// Logout ends the session the refresh token belongs to. Other devices stay
+// signed in; see RevokeOtherSessions.
+func (s *AuthServiceImpl) Logout(refreshTokenValue string, client entities.ClientInfo) error {
 	refreshToken, err := s.tokenRepo.FindByValue(refreshTokenValue)
-	if err != nil {
+	if err != nil || refreshToken.Type != entities.TokenTypeRefresh {
 		return errors.New("invalid refresh token")
 	}
 
-	s.tokenRepo.DeleteAllUserTokens(refreshToken.UserID, entities.TokenTypeAccess)
-	s.tokenRepo.DeleteAllUserTokens(refreshToken.UserID, entities.TokenTypeRefresh)
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		if refreshToken.FamilyID == "" {
+			return tx.Tokens.Delete(refreshToken.Value)
+		}
+		return revokeSession(tx, refreshToken.FamilyID)
+	})
+	if err != nil {
+		return err
+	}
 
+	s.audit.Record(entities.NewAuditEvent(entities.AuditLogout, refreshToken.UserID, client, map[string]string{
+		"sessionId": refreshToken.FamilyID,
+	}))
 	return nil
 }
 
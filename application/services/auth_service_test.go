@@ -0,0 +1,141 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	domainrepos "ambassador/domain/repositories"
+	"ambassador/infrastructure/audit"
+	"ambassador/infrastructure/mail"
+	"ambassador/infrastructure/repositories"
+	"ambassador/infrastructure/security"
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+)
+
+const testPassword = "Passw0rd!x"
+
+// testEnv is an AuthServiceImpl on the in-memory repositories, with MFA
+// available and the repositories, mailer and audit trail exposed for
+// assertions.
+type testEnv struct {
+	svc      *AuthServiceImpl
+	repos    domainrepos.TxRepositories
+	mailer   *mail.MemoryMailer
+	auditLog domainrepos.AuditRepository
+}
+
+func newTestEnv(t *testing.T, opts ...AuthServiceOption) *testEnv {
+	t.Helper()
+	box, err := security.NewSecretBox(base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repos := domainrepos.TxRepositories{
+		Users:           repositories.NewMemoryUserRepository(),
+		Tokens:          repositories.NewMemoryTokenRepository(),
+		Identities:      repositories.NewMemoryIdentityRepository(),
+		MFA:             repositories.NewMemoryMFARepository(),
+		Sessions:        repositories.NewMemorySessionRepository(),
+		PasswordHistory: repositories.NewMemoryPasswordHistoryRepository(),
+		WebAuthn:        repositories.NewMemoryWebAuthnRepository(),
+	}
+	env := &testEnv{
+		repos:    repos,
+		mailer:   mail.NewMemoryMailer(),
+		auditLog: repositories.NewMemoryAuditRepository(),
+	}
+
+	opts = append([]AuthServiceOption{
+		WithMailer(env.mailer),
+		WithAuditLogger(audit.NewRepositoryAuditLogger(env.auditLog)),
+		WithIdentityProviders(repos.Identities, nil),
+		WithMFA(repos.MFA, "Ambassador", box, 5*time.Minute),
+		WithPasswordHistory(repos.PasswordHistory, 0),
+	}, opts...)
+	env.svc = NewAuthService(repos.Users, repos.Tokens, repos.Sessions, repositories.NewMemoryUnitOfWork(repos), security.NewBcryptHasher(), opts...)
+	return env
+}
+
+// register creates an email account with testPassword and returns it with
+// the tokens of its first session.
+func (e *testEnv) register(t *testing.T, email string) (*entities.User, *entities.TokenPair) {
+	t.Helper()
+	user, tokenPair, err := e.svc.Register(&dto.RegisterRequest{
+		Email:              email,
+		FullName:           "Ann Lee",
+		Gender:             entities.GenderFemale,
+		DateOfBirth:        "1990-01-02",
+		RegistrationMethod: entities.RegMethodEmail,
+		Password:           testPassword,
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	return user, tokenPair
+}
+
+// auditEvents returns the recorded events of type eventType for userID.
+func (e *testEnv) auditEvents(t *testing.T, userID string, eventType entities.AuditEventType) []*entities.AuditEvent {
+	t.Helper()
+	events, err := e.auditLog.Find(domainrepos.AuditEventFilter{UserID: userID, Type: eventType})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+// mailedToken returns the token from the last link to path that was mailed
+// to the address to.
+func (e *testEnv) mailedToken(t *testing.T, to, path string) string {
+	t.Helper()
+	pattern := regexp.MustCompile(regexp.QuoteMeta(path) + `\?token=([^\s"&<]+)`)
+	messages := e.mailer.SentTo(to)
+	for i := len(messages) - 1; i >= 0; i-- {
+		if match := pattern.FindStringSubmatch(messages[i].Text); match != nil {
+			token, err := url.QueryUnescape(match[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			return token
+		}
+	}
+	t.Fatalf("no %s link was mailed to %s", path, to)
+	return ""
+}
+
+// errCommitFailed is returned by failingUnitOfWork once fn has succeeded.
+var errCommitFailed = errors.New("commit failed")
+
+// failingUnitOfWork runs fn in the wrapped unit of work and then fails it,
+// so tests can check that every write fn made is rolled back together.
+type failingUnitOfWork struct {
+	domainrepos.UnitOfWork
+}
+
+func (u failingUnitOfWork) Do(ctx context.Context, fn func(tx *domainrepos.TxRepositories) error) error {
+	return u.UnitOfWork.Do(ctx, func(tx *domainrepos.TxRepositories) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return errCommitFailed
+	})
+}
+
+// expireToken moves the expiry of the stored token behind secret into the
+// past.
+func (e *testEnv) expireToken(t *testing.T, secret string) {
+	t.Helper()
+	token, err := e.repos.Tokens.FindByValue(entities.HashTokenValue(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token.ExpiresAt = time.Now().Add(-time.Minute)
+	if err := e.repos.Tokens.Save(token); err != nil {
+		t.Fatal(err)
+	}
+}
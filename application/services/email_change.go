@@ -0,0 +1,211 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"ambassador/infrastructure/mail"
+	"context"
+	"errors"
+	"time"
+)
+
+// WithEmailChangeTTL sets how long the confirmation link sent to a new
+// address is valid and how long the old address may revert the change.
+func WithEmailChangeTTL(confirmTTL, revertTTL time.Duration) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.emailChangeTTL = confirmTTL
+		s.emailRevertTTL = revertTTL
+	}
+}
+
+// RequestEmailChange emails a confirmation link to the new address and a
+// notice with a revert link to the current one. The address only changes
+// once the link is followed; starting again replaces the pending change.
+func (s *AuthServiceImpl) RequestEmailChange(userID string, req *dto.ChangeEmailRequest) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+
+	newEmail, err := entities.NewEmail(req.NewEmail)
+	if err != nil {
+		return err
+	}
+	if newEmail.String() == user.Email.String() {
+		return errors.New("new email address is the same as the current one")
+	}
+
+	if user.RegistrationMethod == entities.RegMethodEmail && !s.hasher.CheckPassword(req.Password, user.PasswordHash) {
+		return errors.New("current password is incorrect")
+	}
+
+	confirmSecret, confirmToken := entities.NewOneTimeToken(user.ID, entities.TokenTypeEmailChange, s.emailChangeTTL)
+	confirmToken.Subject = newEmail.String()
+	revertSecret, revertToken := entities.NewOneTimeToken(user.ID, entities.TokenTypeEmailRevert, s.emailRevertTTL)
+	revertToken.Subject = user.Email.String()
+
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		if err := tx.Tokens.DeleteAllUserTokens(user.ID, entities.TokenTypeEmailChange); err != nil {
+			return err
+		}
+		if err := tx.Tokens.DeleteAllUserTokens(user.ID, entities.TokenTypeEmailRevert); err != nil {
+			return err
+		}
+		if err := tx.Tokens.Save(confirmToken); err != nil {
+			return err
+		}
+		return tx.Tokens.Save(revertToken)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.sendMailTo(user, newEmail.String(), mail.TemplateEmailChange, map[string]interface{}{
+		"NewEmail":       newEmail.String(),
+		"Link":           s.link("/confirm-email-change", confirmSecret),
+		"ExpiresInHours": int(s.emailChangeTTL.Hours()),
+	})
+	s.sendMail(user, mail.TemplateEmailChangeNotice, map[string]interface{}{
+		"NewEmail":      newEmail.String(),
+		"Link":          s.link("/revert-email-change", revertSecret),
+		"ExpiresInDays": int(s.emailRevertTTL.Hours() / 24),
+	})
+
+	s.audit.Record(entities.NewAuditEvent(entities.AuditEmailChangeStarted, user.ID, req.Client, map[string]string{
+		"newEmail": newEmail.String(),
+	}))
+	return nil
+}
+
+// ConfirmEmailChange consumes the link sent to the new address and switches
+// the account over to it. The address must still be free at this point.
+// Reset, magic and verification links already sent to the old address stop
+// working.
+func (s *AuthServiceImpl) ConfirmEmailChange(req *dto.EmailChangeTokenRequest) error {
+	token, user, err := s.consumableToken(req.Token, entities.TokenTypeEmailChange, "invalid or expired email change token")
+	if err != nil {
+		return err
+	}
+
+	newEmail, err := entities.NewEmail(token.Subject)
+	if err != nil {
+		return err
+	}
+	oldEmail := user.Email.String()
+	user.Email = newEmail
+	user.MarkEmailVerified()
+
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		exists, err := tx.Users.ExistsByEmail(newEmail.String())
+		if err != nil {
+			return err
+		}
+		if exists {
+			return errors.New("email address is already in use")
+		}
+		if err := tx.Users.Save(user); err != nil {
+			return err
+		}
+		for _, tokenType := range []entities.TokenType{
+			entities.TokenTypeEmailChange,
+			entities.TokenTypePasswordReset,
+			entities.TokenTypeMagicLink,
+			entities.TokenTypeEmailVerify,
+		} {
+			if err := tx.Tokens.DeleteAllUserTokens(user.ID, tokenType); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.audit.Record(entities.NewAuditEvent(entities.AuditEmailChanged, user.ID, req.Client, map[string]string{
+		"from": oldEmail,
+		"to":   newEmail.String(),
+	}))
+	return nil
+}
+
+// RevertEmailChange consumes the link sent to the old address. It cancels a
+// pending change or restores the old address, and since the change may not
+// have been the owner's doing, signs out every device and, for password
+// accounts, requires a new password through a reset link.
+func (s *AuthServiceImpl) RevertEmailChange(req *dto.EmailChangeTokenRequest) error {
+	token, user, err := s.consumableToken(req.Token, entities.TokenTypeEmailRevert, "invalid or expired email revert token")
+	if err != nil {
+		return err
+	}
+
+	changedEmail := user.Email.String()
+	if changedEmail != token.Subject {
+		oldEmail, err := entities.NewEmail(token.Subject)
+		if err != nil {
+			return err
+		}
+		user.Email = oldEmail
+	}
+	user.MarkEmailVerified()
+	if user.RegistrationMethod == entities.RegMethodEmail {
+		user.PasswordHash = ""
+	}
+
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		if owner, err := tx.Users.FindByEmail(token.Subject); err == nil && owner.ID != user.ID {
+			return errors.New("email address is already in use")
+		}
+		if err := tx.Users.Save(user); err != nil {
+			return err
+		}
+		if err := tx.Tokens.DeleteAllUserTokens(user.ID, entities.TokenTypeEmailChange); err != nil {
+			return err
+		}
+		if err := tx.Tokens.DeleteAllUserTokens(user.ID, entities.TokenTypeEmailRevert); err != nil {
+			return err
+		}
+		return revokeAllSessions(tx, user.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	if user.RegistrationMethod == entities.RegMethodEmail {
+		if err := s.sendPasswordReset(user); err != nil {
+			return err
+		}
+	}
+
+	s.audit.Record(entities.NewAuditEvent(entities.AuditEmailChangeReverted, user.ID, req.Client, map[string]string{
+		"from": changedEmail,
+		"to":   user.Email.String(),
+	}))
+	return nil
+}
+
+// consumableToken looks up a one-time token of tokenType by its secret
+// together with its active owner, failing with invalidMessage otherwise.
+func (s *AuthServiceImpl) consumableToken(secret string, tokenType entities.TokenType, invalidMessage string) (*entities.Token, *entities.User, error) {
+	token, err := s.tokenRepo.FindByValue(entities.HashTokenValue(secret))
+	if err != nil || token.Type != tokenType {
+		return nil, nil, errors.New(invalidMessage)
+	}
+
+	if token.IsExpired() {
+		s.tokenRepo.Delete(token.Value)
+		return nil, nil, errors.New(invalidMessage)
+	}
+
+	user, err := s.userRepo.FindByID(token.UserID)
+	if err != nil {
+		return nil, nil, errors.New(invalidMessage)
+	}
+
+	if !user.IsActive {
+		return nil, nil, errors.New("account is deactivated")
+	}
+
+	return token, user, nil
+}
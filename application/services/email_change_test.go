@@ -0,0 +1,195 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"errors"
+	"testing"
+)
+
+// requestEmailChange moves user's account towards newEmail and returns the
+// tokens of the confirmation and revert links that were mailed.
+func (e *testEnv) requestEmailChange(t *testing.T, user *entities.User, newEmail string) (string, string) {
+	t.Helper()
+	oldEmail := user.Email.String()
+	if err := e.svc.RequestEmailChange(user.ID, &dto.ChangeEmailRequest{NewEmail: newEmail, Password: testPassword}); err != nil {
+		t.Fatalf("RequestEmailChange: %v", err)
+	}
+	return e.mailedToken(t, newEmail, "/confirm-email-change"), e.mailedToken(t, oldEmail, "/revert-email-change")
+}
+
+func (e *testEnv) email(t *testing.T, userID string) string {
+	t.Helper()
+	user, err := e.repos.Users.FindByID(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user.Email.String()
+}
+
+func TestRequestEmailChangeChecksPasswordAndAddress(t *testing.T) {
+	env := newTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+	env.mailer.Reset()
+
+	cases := map[string]*dto.ChangeEmailRequest{
+		"wrong password":  {NewEmail: "ann@example.org", Password: "Wrong-passw0rd"},
+		"same address":    {NewEmail: " ANN@example.com", Password: testPassword},
+		"invalid address": {NewEmail: "ann", Password: testPassword},
+	}
+	for name, req := range cases {
+		if err := env.svc.RequestEmailChange(user.ID, req); err == nil {
+			t.Errorf("%s: RequestEmailChange succeeded", name)
+		}
+	}
+	if len(env.mailer.Messages()) != 0 {
+		t.Error("a rejected email change sent mail")
+	}
+}
+
+func TestConfirmEmailChangeSwitchesAddress(t *testing.T) {
+	env := newTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+	confirm, _ := env.requestEmailChange(t, user, "ann@example.org")
+
+	if err := env.svc.ConfirmEmailChange(&dto.EmailChangeTokenRequest{Token: confirm}); err != nil {
+		t.Fatalf("ConfirmEmailChange: %v", err)
+	}
+	if got := env.email(t, user.ID); got != "ann@example.org" {
+		t.Errorf("email = %s, want the new address", got)
+	}
+	if _, _, err := env.svc.Login(&dto.LoginRequest{Email: "ann@example.org", Password: testPassword}); err != nil {
+		t.Errorf("Login with the new address: %v", err)
+	}
+	if _, _, err := env.svc.Login(&dto.LoginRequest{Email: "ann@example.com", Password: testPassword}); err == nil {
+		t.Error("the old address still signs in")
+	}
+	if err := env.svc.ConfirmEmailChange(&dto.EmailChangeTokenRequest{Token: confirm}); err == nil {
+		t.Error("a used confirmation link was accepted again")
+	}
+	if events := env.auditEvents(t, user.ID, entities.AuditEmailChanged); len(events) != 1 || events[0].Details["from"] != "ann@example.com" {
+		t.Errorf("audit events = %v, want one change from the old address", events)
+	}
+}
+
+func TestConfirmEmailChangeInvalidatesLinksSentToOldAddress(t *testing.T) {
+	env := newMagicLinkTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+	nonce, magic := env.requestMagicLink(t, "ann@example.com")
+	reset := env.forgotPassword(t, "ann@example.com")
+	confirm, revert := env.requestEmailChange(t, user, "ann@example.org")
+
+	if err := env.svc.ConfirmEmailChange(&dto.EmailChangeTokenRequest{Token: confirm}); err != nil {
+		t.Fatalf("ConfirmEmailChange: %v", err)
+	}
+
+	if err := env.svc.ResetPassword(&dto.ResetPasswordRequest{Token: reset, NewPassword: newTestPassword}); err == nil {
+		t.Error("a reset link sent to the old address still works")
+	}
+	if _, _, _, err := env.svc.ConsumeMagicLink(&dto.ConsumeMagicLinkRequest{Token: magic, Nonce: nonce}); err == nil {
+		t.Error("a magic link sent to the old address still works")
+	}
+	if err := env.svc.RevertEmailChange(&dto.EmailChangeTokenRequest{Token: revert}); err != nil {
+		t.Errorf("the revert link stopped working: %v", err)
+	}
+}
+
+func TestConfirmEmailChangeToAddressTakenMeanwhile(t *testing.T) {
+	env := newTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+	confirm, _ := env.requestEmailChange(t, user, "bob@example.com")
+	env.register(t, "bob@example.com")
+
+	err := env.svc.ConfirmEmailChange(&dto.EmailChangeTokenRequest{Token: confirm})
+	if err == nil || err.Error() != "email address is already in use" {
+		t.Fatalf("ConfirmEmailChange = %v, want the address refused", err)
+	}
+	if got := env.email(t, user.ID); got != "ann@example.com" {
+		t.Errorf("email = %s, want it unchanged", got)
+	}
+}
+
+func TestConfirmEmailChangeIsOneUnitOfWork(t *testing.T) {
+	env := newTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+	reset := env.forgotPassword(t, "ann@example.com")
+	confirm, _ := env.requestEmailChange(t, user, "ann@example.org")
+
+	env.svc.uow = failingUnitOfWork{env.svc.uow}
+	if err := env.svc.ConfirmEmailChange(&dto.EmailChangeTokenRequest{Token: confirm}); !errors.Is(err, errCommitFailed) {
+		t.Fatalf("ConfirmEmailChange = %v, want the commit failure", err)
+	}
+	if got := env.email(t, user.ID); got != "ann@example.com" {
+		t.Errorf("email = %s after a failed commit", got)
+	}
+	for name, secret := range map[string]string{"confirmation": confirm, "reset": reset} {
+		if _, err := env.repos.Tokens.FindByValue(entities.HashTokenValue(secret)); err != nil {
+			t.Errorf("%s token was deleted by a failed commit: %v", name, err)
+		}
+	}
+}
+
+func TestRevertEmailChangeRestoresAddressAndSecuresAccount(t *testing.T) {
+	env := newTestEnv(t)
+	user, tokenPair := env.register(t, "ann@example.com")
+	confirm, revert := env.requestEmailChange(t, user, "ann@example.org")
+	if err := env.svc.ConfirmEmailChange(&dto.EmailChangeTokenRequest{Token: confirm}); err != nil {
+		t.Fatal(err)
+	}
+	env.mailer.Reset()
+
+	if err := env.svc.RevertEmailChange(&dto.EmailChangeTokenRequest{Token: revert}); err != nil {
+		t.Fatalf("RevertEmailChange: %v", err)
+	}
+	if got := env.email(t, user.ID); got != "ann@example.com" {
+		t.Errorf("email = %s, want the old address back", got)
+	}
+	if _, err := env.svc.RefreshToken(&dto.RefreshTokenRequest{RefreshToken: tokenPair.RefreshToken.Value}); err == nil {
+		t.Error("sessions survived the revert")
+	}
+	if _, _, err := env.svc.Login(&dto.LoginRequest{Email: "ann@example.com", Password: testPassword}); err == nil {
+		t.Error("the password survived the revert")
+	}
+
+	reset := env.mailedToken(t, "ann@example.com", "/reset-password")
+	if err := env.svc.ResetPassword(&dto.ResetPasswordRequest{Token: reset, NewPassword: newTestPassword}); err != nil {
+		t.Errorf("ResetPassword with the link sent on revert: %v", err)
+	}
+	if err := env.svc.RevertEmailChange(&dto.EmailChangeTokenRequest{Token: revert}); err == nil {
+		t.Error("a used revert link was accepted again")
+	}
+}
+
+func TestRevertEmailChangeCancelsPendingChange(t *testing.T) {
+	env := newTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+	confirm, revert := env.requestEmailChange(t, user, "ann@example.org")
+
+	if err := env.svc.RevertEmailChange(&dto.EmailChangeTokenRequest{Token: revert}); err != nil {
+		t.Fatalf("RevertEmailChange: %v", err)
+	}
+	if err := env.svc.ConfirmEmailChange(&dto.EmailChangeTokenRequest{Token: confirm}); err == nil {
+		t.Error("the confirmation link still works after the change was reverted")
+	}
+	if got := env.email(t, user.ID); got != "ann@example.com" {
+		t.Errorf("email = %s, want it unchanged", got)
+	}
+}
+
+func TestRevertEmailChangeToAddressTakenMeanwhile(t *testing.T) {
+	env := newTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+	confirm, revert := env.requestEmailChange(t, user, "ann@example.org")
+	if err := env.svc.ConfirmEmailChange(&dto.EmailChangeTokenRequest{Token: confirm}); err != nil {
+		t.Fatal(err)
+	}
+	env.register(t, "ann@example.com")
+
+	err := env.svc.RevertEmailChange(&dto.EmailChangeTokenRequest{Token: revert})
+	if err == nil || err.Error() != "email address is already in use" {
+		t.Fatalf("RevertEmailChange = %v, want the address refused", err)
+	}
+	if got := env.email(t, user.ID); got != "ann@example.org" {
+		t.Errorf("email = %s, want it unchanged", got)
+	}
+}
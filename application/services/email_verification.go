@@ -0,0 +1,106 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"ambassador/infrastructure/mail"
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// verificationResendInterval is how long ResendVerification waits after a
+// link was issued before it mails another one, so the endpoint cannot be
+// used to flood an inbox.
+const verificationResendInterval = time.Minute
+
+// VerifyEmail consumes a verification token and marks the owner's email
+// address as verified.
+func (s *AuthServiceImpl) VerifyEmail(req *dto.VerifyEmailRequest) error {
+	verifyToken, err := s.tokenRepo.FindByValue(entities.HashTokenValue(req.Token))
+	if err != nil || verifyToken.Type != entities.TokenTypeEmailVerify {
+		return errors.New("invalid or expired verification token")
+	}
+
+	if verifyToken.IsExpired() {
+		s.tokenRepo.Delete(verifyToken.Value)
+		return errors.New("invalid or expired verification token")
+	}
+
+	user, err := s.userRepo.FindByID(verifyToken.UserID)
+	if err != nil {
+		return errors.New("invalid or expired verification token")
+	}
+
+	if !user.IsActive {
+		return errors.New("account is deactivated")
+	}
+
+	if !user.EmailVerified {
+		user.MarkEmailVerified()
+	}
+
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		if err := tx.Users.Save(user); err != nil {
+			return err
+		}
+		return tx.Tokens.DeleteAllUserTokens(user.ID, entities.TokenTypeEmailVerify)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.audit.Record(entities.NewAuditEvent(entities.AuditEmailVerified, user.ID, req.Client, map[string]string{
+		"email": user.Email.String(),
+	}))
+
+	return nil
+}
+
+// ResendVerification issues a fresh verification link, invalidating earlier
+// ones, at most once per verificationResendInterval. Like ForgotPassword it
+// returns nil for unknown or already verified addresses, and also when it
+// holds back a link.
+func (s *AuthServiceImpl) ResendVerification(req *dto.ResendVerificationRequest) error {
+	user, err := s.userRepo.FindByEmail(req.Email)
+	if err != nil || !user.IsActive || user.EmailVerified {
+		return nil
+	}
+
+	issued, err := s.tokenRepo.FindByUserID(user.ID, entities.TokenTypeEmailVerify)
+	if err != nil {
+		log.Printf("email verification: failed to look up tokens for user %s: %v", user.ID, err)
+		return nil
+	}
+	for _, token := range issued {
+		if time.Since(token.CreatedAt) < verificationResendInterval {
+			return nil
+		}
+	}
+
+	secret, verifyToken := entities.NewOneTimeToken(user.ID, entities.TokenTypeEmailVerify, s.verificationTTL)
+
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		if err := tx.Tokens.DeleteAllUserTokens(user.ID, entities.TokenTypeEmailVerify); err != nil {
+			return err
+		}
+		return tx.Tokens.Save(verifyToken)
+	})
+	if err != nil {
+		log.Printf("email verification: failed to store token for user %s: %v", user.ID, err)
+		return nil
+	}
+
+	s.sendVerificationEmail(user, secret)
+
+	return nil
+}
+
+func (s *AuthServiceImpl) sendVerificationEmail(user *entities.User, secret string) {
+	s.sendMail(user, mail.TemplateEmailVerification, map[string]interface{}{
+		"Link":           s.link("/verify-email", secret),
+		"ExpiresInHours": int(s.verificationTTL.Hours()),
+	})
+}
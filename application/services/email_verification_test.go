@@ -0,0 +1,89 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"testing"
+	"time"
+)
+
+func TestVerifyEmailTokenIsHashedAndSingleUse(t *testing.T) {
+	env := newTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+	secret := env.mailedToken(t, "ann@example.com", "/verify-email")
+
+	if _, err := env.repos.Tokens.FindByValue(secret); err == nil {
+		t.Error("the verification token is stored in the clear")
+	}
+
+	if err := env.svc.VerifyEmail(&dto.VerifyEmailRequest{Token: secret}); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	stored, err := env.repos.Users.FindByID(user.ID)
+	if err != nil || !stored.EmailVerified {
+		t.Fatal("the address was not marked verified")
+	}
+	if err := env.svc.VerifyEmail(&dto.VerifyEmailRequest{Token: secret}); err == nil {
+		t.Error("a used verification token was accepted again")
+	}
+}
+
+func TestVerifyEmailRejectsExpiredToken(t *testing.T) {
+	env := newTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+	secret := env.mailedToken(t, "ann@example.com", "/verify-email")
+	env.expireToken(t, secret)
+
+	if err := env.svc.VerifyEmail(&dto.VerifyEmailRequest{Token: secret}); err == nil {
+		t.Error("an expired verification token was accepted")
+	}
+	if stored, _ := env.repos.Users.FindByID(user.ID); stored.EmailVerified {
+		t.Error("the address was marked verified")
+	}
+}
+
+func TestResendVerificationIsRateLimited(t *testing.T) {
+	env := newTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+	first := env.mailedToken(t, "ann@example.com", "/verify-email")
+	env.mailer.Reset()
+
+	if err := env.svc.ResendVerification(&dto.ResendVerificationRequest{Email: "ann@example.com"}); err != nil {
+		t.Fatalf("ResendVerification: %v", err)
+	}
+	if len(env.mailer.Messages()) != 0 {
+		t.Fatal("a link was mailed again right after the first one")
+	}
+
+	// Once the interval has passed a new link replaces the first.
+	tokens, err := env.repos.Tokens.FindByUserID(user.ID, entities.TokenTypeEmailVerify)
+	if err != nil || len(tokens) != 1 {
+		t.Fatalf("verification tokens = %v, %v", tokens, err)
+	}
+	tokens[0].CreatedAt = time.Now().Add(-verificationResendInterval)
+	if err := env.repos.Tokens.Save(tokens[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.svc.ResendVerification(&dto.ResendVerificationRequest{Email: "ann@example.com"}); err != nil {
+		t.Fatalf("ResendVerification: %v", err)
+	}
+	second := env.mailedToken(t, "ann@example.com", "/verify-email")
+
+	if err := env.svc.VerifyEmail(&dto.VerifyEmailRequest{Token: first}); err == nil {
+		t.Error("the replaced link is still accepted")
+	}
+	if err := env.svc.VerifyEmail(&dto.VerifyEmailRequest{Token: second}); err != nil {
+		t.Errorf("VerifyEmail with the new link: %v", err)
+	}
+}
+
+func TestResendVerificationForUnknownAddressSendsNothing(t *testing.T) {
+	env := newTestEnv(t)
+
+	if err := env.svc.ResendVerification(&dto.ResendVerificationRequest{Email: "nobody@example.com"}); err != nil {
+		t.Errorf("ResendVerification returned %v, want nil like for a known address", err)
+	}
+	if len(env.mailer.Messages()) != 0 {
+		t.Error("a verification link was mailed for an unknown address")
+	}
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"ambassador/infrastructure/mail"
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+)
+
+// LockoutPolicy controls per-account throttling of password logins.
+type LockoutPolicy struct {
+	// Threshold failed attempts lock the account for Duration. Zero
+	// disables locking.
+	Threshold int
+	Duration  time.Duration
+	// Once BackoffAfter attempts have failed, each further attempt has to
+	// wait BackoffBase after the last failure, doubling with every failure
+	// up to BackoffMax. Zero disables the backoff.
+	BackoffAfter int
+	BackoffBase  time.Duration
+	BackoffMax   time.Duration
+	// ResetAfter is how long a failure is remembered.
+	ResetAfter time.Duration
+	// UnlockTTL is how long the unlock link sent on lockout stays valid.
+	UnlockTTL time.Duration
+}
+
+// WithLockout enables per-account login throttling.
+func WithLockout(throttleRepo repositories.LoginThrottleRepository, policy LockoutPolicy) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.throttleRepo = throttleRepo
+		s.lockout = policy
+	}
+}
+
+func (p LockoutPolicy) backoff(failures int) time.Duration {
+	if p.BackoffAfter <= 0 || failures < p.BackoffAfter {
+		return 0
+	}
+	exponent := failures - p.BackoffAfter
+	if exponent > 30 {
+		return p.BackoffMax
+	}
+	wait := p.BackoffBase << uint(exponent)
+	if wait > p.BackoffMax {
+		return p.BackoffMax
+	}
+	return wait
+}
+
+// checkLoginThrottle refuses a login for key while it is locked or backing
+// off, before the password is looked at.
+func (s *AuthServiceImpl) checkLoginThrottle(key string) error {
+	if s.throttleRepo == nil {
+		return nil
+	}
+
+	throttle, err := s.throttleRepo.Find(key)
+	if err != nil {
+		return nil
+	}
+
+	now := time.Now()
+	if throttle.IsLocked() {
+		return &entities.LoginThrottledError{RetryAfter: throttle.LockedUntil.Sub(now)}
+	}
+	if wait := s.lockout.backoff(throttle.FailedCount); wait > 0 {
+		if next := throttle.LastFailedAt.Add(wait); now.Before(next) {
+			return &entities.LoginThrottledError{RetryAfter: next.Sub(now)}
+		}
+	}
+	return nil
+}
+
+// recordLoginFailure counts a failed login for key and locks it once the
+// threshold is reached. user is nil when no account has the address; the
+// address is throttled all the same so responses do not reveal that.
+func (s *AuthServiceImpl) recordLoginFailure(key string, user *entities.User, client entities.ClientInfo) {
+	if s.throttleRepo == nil {
+		return
+	}
+
+	now := time.Now()
+	throttle, err := s.throttleRepo.RecordFailure(key, now, now.Add(-s.lockout.ResetAfter))
+	if err != nil {
+		log.Printf("lockout: failed to record login failure: %v", err)
+		return
+	}
+	if s.lockout.Threshold <= 0 || throttle.FailedCount < s.lockout.Threshold {
+		return
+	}
+
+	if err := s.throttleRepo.Lock(key, now.Add(s.lockout.Duration)); err != nil {
+		log.Printf("lockout: failed to lock account: %v", err)
+		return
+	}
+	if user == nil {
+		return
+	}
+
+	s.audit.Record(entities.NewAuditEvent(entities.AuditAccountLocked, user.ID, client, map[string]string{
+		"failedAttempts": strconv.Itoa(throttle.FailedCount),
+	}))
+	s.sendUnlockEmail(user)
+}
+
+func (s *AuthServiceImpl) resetLoginThrottle(user *entities.User) {
+	if s.throttleRepo == nil {
+		return
+	}
+	if err := s.throttleRepo.Reset(entities.LoginThrottleKey(user.Email.String())); err != nil {
+		log.Printf("lockout: failed to reset login throttle for user %s: %v", user.ID, err)
+	}
+}
+
+func (s *AuthServiceImpl) sendUnlockEmail(user *entities.User) {
+	secret, unlockToken := entities.NewOneTimeToken(user.ID, entities.TokenTypeAccountUnlock, s.lockout.UnlockTTL)
+
+	err := s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		if err := tx.Tokens.DeleteAllUserTokens(user.ID, entities.TokenTypeAccountUnlock); err != nil {
+			return err
+		}
+		return tx.Tokens.Save(unlockToken)
+	})
+	if err != nil {
+		log.Printf("lockout: failed to store unlock token for user %s: %v", user.ID, err)
+		return
+	}
+
+	s.sendMail(user, mail.TemplateAccountUnlock, map[string]interface{}{
+		"Link":           s.link("/unlock-account", secret),
+		"LockedMinutes":  int(s.lockout.Duration.Minutes()),
+		"ExpiresInHours": int(s.lockout.UnlockTTL.Hours()),
+	})
+}
+
+// UnlockAccount consumes the link emailed on lockout and lifts the lock.
+func (s *AuthServiceImpl) UnlockAccount(req *dto.UnlockAccountRequest) error {
+	unlockToken, err := s.tokenRepo.FindByValue(entities.HashTokenValue(req.Token))
+	if err != nil || unlockToken.Type != entities.TokenTypeAccountUnlock {
+		return errors.New("invalid or expired unlock token")
+	}
+
+	if unlockToken.IsExpired() {
+		s.tokenRepo.Delete(unlockToken.Value)
+		return errors.New("invalid or expired unlock token")
+	}
+
+	user, err := s.userRepo.FindByID(unlockToken.UserID)
+	if err != nil {
+		return errors.New("invalid or expired unlock token")
+	}
+
+	return s.unlock(user, "email", req.Client)
+}
+
+// AdminUnlockUser lifts a lockout on behalf of an administrator.
+func (s *AuthServiceImpl) AdminUnlockUser(userID string, client entities.ClientInfo) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+
+	return s.unlock(user, "admin", client)
+}
+
+func (s *AuthServiceImpl) unlock(user *entities.User, method string, client entities.ClientInfo) error {
+	if s.throttleRepo == nil {
+		return errors.New("account lockout is not configured")
+	}
+
+	if err := s.throttleRepo.Reset(entities.LoginThrottleKey(user.Email.String())); err != nil {
+		return err
+	}
+	if err := s.tokenRepo.DeleteAllUserTokens(user.ID, entities.TokenTypeAccountUnlock); err != nil {
+		return err
+	}
+
+	s.audit.Record(entities.NewAuditEvent(entities.AuditAccountUnlocked, user.ID, client, map[string]string{
+		"method": method,
+	}))
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"ambassador/infrastructure/repositories"
+	"ambassador/infrastructure/security"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// countingHasher counts password checks.
+type countingHasher struct {
+	security.PasswordHasher
+	checks int
+}
+
+func (h *countingHasher) CheckPassword(password, hash string) bool {
+	h.checks++
+	return h.PasswordHasher.CheckPassword(password, hash)
+}
+
+func withTestLockout(threshold int) AuthServiceOption {
+	return WithLockout(repositories.NewMemoryLoginThrottleRepository(), LockoutPolicy{
+		Threshold:  threshold,
+		Duration:   time.Hour,
+		ResetAfter: time.Hour,
+		UnlockTTL:  time.Hour,
+	})
+}
+
+func TestLoginFailuresLookAlike(t *testing.T) {
+	setups := map[string]func(t *testing.T, env *testEnv){
+		"unknown address": func(t *testing.T, env *testEnv) {},
+		"wrong password": func(t *testing.T, env *testEnv) {
+			env.register(t, "ann@example.com")
+		},
+		"deactivated": func(t *testing.T, env *testEnv) {
+			user, _ := env.register(t, "ann@example.com")
+			user.IsActive = false
+			if err := env.repos.Users.Save(user); err != nil {
+				t.Fatal(err)
+			}
+		},
+		"scheduled for deletion": func(t *testing.T, env *testEnv) {
+			user, _ := env.register(t, "ann@example.com")
+			user.DeletedAt = time.Now()
+			if err := env.repos.Users.Save(user); err != nil {
+				t.Fatal(err)
+			}
+		},
+		"oauth account": func(t *testing.T, env *testEnv) {
+			user, err := entities.NewUser("ann@example.com", "Ann Lee", entities.GenderFemale,
+				time.Date(1990, 1, 2, 0, 0, 0, 0, time.UTC), entities.RegMethodGoogle, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			user.ID = uuid.New().String()
+			if err := env.repos.Users.Save(user); err != nil {
+				t.Fatal(err)
+			}
+		},
+	}
+
+	for name, setup := range setups {
+		t.Run(name, func(t *testing.T) {
+			hasher := &countingHasher{PasswordHasher: security.NewBcryptHasher()}
+			env := newTestEnv(t, withTestLockout(2), func(s *AuthServiceImpl) { s.hasher = hasher })
+			setup(t, env)
+			req := &dto.LoginRequest{Email: "ann@example.com", Password: "Wrong-passw0rd"}
+
+			for i := 0; i < 2; i++ {
+				hasher.checks = 0
+				if _, _, err := env.svc.Login(req); err == nil {
+					t.Fatal("Login succeeded")
+				}
+				if hasher.checks != 1 {
+					t.Errorf("attempt %d made %d password checks, want 1", i+1, hasher.checks)
+				}
+			}
+
+			_, _, err := env.svc.Login(req)
+			var throttled *entities.LoginThrottledError
+			if !errors.As(err, &throttled) {
+				t.Errorf("third attempt returned %v, want the address locked", err)
+			}
+		})
+	}
+}
+
+func TestChangePasswordWrongCurrentPasswordCountsTowardsLockout(t *testing.T) {
+	env := newTestEnv(t, withTestLockout(3))
+	user, _ := env.register(t, "ann@example.com")
+	env.mailer.Reset()
+
+	wrong := &dto.ChangePasswordRequest{CurrentPassword: "Wrong-passw0rd", NewPassword: "Fresh-passw0rd"}
+	for i := 0; i < 3; i++ {
+		if err := env.svc.ChangePassword(user.ID, "", wrong); err == nil || err.Error() != "current password is incorrect" {
+			t.Fatalf("attempt %d returned %v, want the current password rejected", i+1, err)
+		}
+	}
+
+	var throttled *entities.LoginThrottledError
+	right := &dto.ChangePasswordRequest{CurrentPassword: testPassword, NewPassword: "Fresh-passw0rd"}
+	if err := env.svc.ChangePassword(user.ID, "", right); !errors.As(err, &throttled) {
+		t.Errorf("ChangePassword after the lockout returned %v, want it throttled", err)
+	}
+	if _, _, err := env.svc.Login(&dto.LoginRequest{Email: "ann@example.com", Password: testPassword}); !errors.As(err, &throttled) {
+		t.Errorf("Login after the lockout returned %v, want it throttled", err)
+	}
+	if len(env.mailer.SentTo("ann@example.com")) != 1 {
+		t.Error("no unlock link was mailed on lockout")
+	}
+}
+
+func TestChangePasswordResetsThrottleWithRightPassword(t *testing.T) {
+	env := newTestEnv(t, withTestLockout(3))
+	user, _ := env.register(t, "ann@example.com")
+
+	wrong := &dto.ChangePasswordRequest{CurrentPassword: "Wrong-passw0rd", NewPassword: "Fresh-passw0rd"}
+	for i := 0; i < 2; i++ {
+		env.svc.ChangePassword(user.ID, "", wrong)
+	}
+	if err := env.svc.ChangePassword(user.ID, "", &dto.ChangePasswordRequest{CurrentPassword: testPassword, NewPassword: "Fresh-passw0rd"}); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if err := env.svc.ChangePassword(user.ID, "", wrong); err == nil || err.Error() != "current password is incorrect" {
+		t.Errorf("ChangePassword returned %v, want the earlier failures forgotten", err)
+	}
+}
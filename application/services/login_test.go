@@ -0,0 +1,45 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/infrastructure/security"
+	"strings"
+	"testing"
+)
+
+func TestLoginUpgradesLegacyPasswordHash(t *testing.T) {
+	env := newTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+	legacy := user.PasswordHash
+	if !strings.HasPrefix(legacy, "$2a$") {
+		t.Fatalf("registered with %q, want a bcrypt hash", legacy)
+	}
+
+	// The deployment moves to Argon2id and keeps verifying bcrypt hashes.
+	env.svc.hasher = security.NewMultiHasher(security.NewArgon2idHasher(security.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1}), security.NewBcryptHasher())
+
+	if _, _, err := env.svc.Login(&dto.LoginRequest{Email: "ann@example.com", Password: "Wrong-passw0rd"}); err == nil {
+		t.Fatal("Login with a wrong password succeeded")
+	}
+	if stored, _ := env.repos.Users.FindByID(user.ID); stored.PasswordHash != legacy {
+		t.Error("a failed login rehashed the password")
+	}
+
+	if _, _, err := env.svc.Login(&dto.LoginRequest{Email: "ann@example.com", Password: testPassword}); err != nil {
+		t.Fatalf("Login with the bcrypt hash: %v", err)
+	}
+	stored, err := env.repos.Users.FindByID(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(stored.PasswordHash, "$argon2id$") {
+		t.Fatalf("stored hash %q was not upgraded", stored.PasswordHash)
+	}
+
+	if _, _, err := env.svc.Login(&dto.LoginRequest{Email: "ann@example.com", Password: testPassword}); err != nil {
+		t.Errorf("Login with the upgraded hash: %v", err)
+	}
+	if again, _ := env.repos.Users.FindByID(user.ID); again.PasswordHash != stored.PasswordHash {
+		t.Error("an up to date hash was rehashed")
+	}
+}
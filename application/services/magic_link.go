@@ -0,0 +1,203 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"ambassador/infrastructure/mail"
+	"ambassador/infrastructure/security"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithMagicLinks enables passwordless login through emailed links that are
+// signed by signer and stay valid for ttl.
+func WithMagicLinks(signer *security.LinkSigner, ttl time.Duration) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.linkSigner = signer
+		s.magicLinkTTL = ttl
+	}
+}
+
+// RequestMagicLink emails a sign-in link to the account behind req.Email and
+// returns the nonce the link is bound to. Only a client presenting the nonce
+// can use the link, so the requesting device has to keep it; a forwarded
+// email is useless on its own. A nonce is returned whether or not such an
+// account exists, so callers cannot use it to find out which addresses are
+// registered.
+func (s *AuthServiceImpl) RequestMagicLink(req *dto.MagicLinkRequest) (string, time.Time, error) {
+	if s.linkSigner == nil {
+		return "", time.Time{}, errors.New("magic links are not configured")
+	}
+
+	nonce, err := newMagicLinkNonce()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(s.magicLinkTTL)
+
+	user, err := s.userRepo.FindByEmail(req.Email)
+	if err != nil || !user.IsActive || user.RegistrationMethod != entities.RegMethodEmail {
+		return nonce, expiresAt, nil
+	}
+
+	secret, token := entities.NewOneTimeToken(user.ID, entities.TokenTypeMagicLink, s.magicLinkTTL)
+	token.Subject = entities.HashTokenValue(nonce)
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		if err := tx.Tokens.DeleteAllUserTokens(user.ID, entities.TokenTypeMagicLink); err != nil {
+			return err
+		}
+		return tx.Tokens.Save(token)
+	})
+	if err != nil {
+		log.Printf("magic link: failed to store token for user %s: %v", user.ID, err)
+		return nonce, expiresAt, nil
+	}
+
+	s.sendMail(user, mail.TemplateMagicLink, map[string]interface{}{
+		"Link":             s.link("/magic-link", s.signMagicLink(secret, token.ExpiresAt, token.Subject)),
+		"ExpiresInMinutes": int(s.magicLinkTTL.Minutes()),
+	})
+	s.audit.Record(entities.NewAuditEvent(entities.AuditMagicLinkRequested, user.ID, req.Client, nil))
+
+	return nonce, token.ExpiresAt, nil
+}
+
+// ConsumeMagicLink exchanges a magic link and the nonce it was requested
+// with for a TokenPair. Users with MFA enabled get a Challenge instead, to
+// be completed through VerifyMFA.
+func (s *AuthServiceImpl) ConsumeMagicLink(req *dto.ConsumeMagicLinkRequest) (*entities.User, *entities.TokenPair, *entities.Challenge, error) {
+	if s.linkSigner == nil {
+		return nil, nil, nil, errors.New("magic links are not configured")
+	}
+
+	nonceHash := entities.HashTokenValue(req.Nonce)
+	secret, ok := s.verifyMagicLink(req.Token, nonceHash)
+	if !ok {
+		return nil, nil, nil, errors.New("invalid or expired magic link")
+	}
+
+	link, err := s.tokenRepo.FindByValue(entities.HashTokenValue(secret))
+	if err != nil || link.Type != entities.TokenTypeMagicLink || subtle.ConstantTimeCompare([]byte(link.Subject), []byte(nonceHash)) != 1 {
+		return nil, nil, nil, errors.New("invalid or expired magic link")
+	}
+
+	if link.IsExpired() {
+		s.tokenRepo.Delete(link.Value)
+		return nil, nil, nil, errors.New("invalid or expired magic link")
+	}
+
+	user, err := s.userRepo.FindByID(link.UserID)
+	if err != nil {
+		return nil, nil, nil, errors.New("invalid or expired magic link")
+	}
+
+	if user.IsDeleted() {
+		s.recordLoginFailed(user.ID, user.Email.String(), "account_deleted", req.Client)
+		return nil, nil, nil, errors.New("account is scheduled for deletion")
+	}
+
+	if !user.IsActive {
+		s.recordLoginFailed(user.ID, user.Email.String(), "account_deactivated", req.Client)
+		return nil, nil, nil, errors.New("account is deactivated")
+	}
+
+	// The link was delivered to the address, which proves ownership.
+	verified := !user.EmailVerified
+	if verified {
+		user.MarkEmailVerified()
+	}
+
+	var (
+		tokenPair *entities.TokenPair
+		session   *entities.Session
+	)
+	mfa := s.mfaEnabled(user.ID)
+	if !mfa {
+		tokenPair, session, err = s.newSession(user, req.DeviceName, req.Client)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		if err := tx.Tokens.Delete(link.Value); err != nil {
+			return err
+		}
+		if verified {
+			if err := tx.Users.Save(user); err != nil {
+				return err
+			}
+		}
+		if mfa {
+			return nil
+		}
+		return s.saveSession(tx, tokenPair, session)
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if verified {
+		s.audit.Record(entities.NewAuditEvent(entities.AuditEmailVerified, user.ID, req.Client, map[string]string{
+			"email": user.Email.String(),
+		}))
+	}
+	if !mfa {
+		s.resetLoginThrottle(user)
+	}
+
+	if mfa {
+		challenge, err := s.newMFAChallenge(user.ID, "magic_link")
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return user, nil, challenge, nil
+	}
+	s.recordLoginSucceeded(user.ID, "magic_link", tokenPair, req.Client)
+
+	return user, tokenPair, nil, nil
+}
+
+// signMagicLink builds the token carried by a magic link: the one-time
+// secret and its expiry, signed together with the hash of the nonce the
+// link is bound to.
+func (s *AuthServiceImpl) signMagicLink(secret string, expiresAt time.Time, nonceHash string) string {
+	expiry := strconv.FormatInt(expiresAt.Unix(), 10)
+	signature := s.linkSigner.Sign(string(entities.TokenTypeMagicLink), secret, expiry, nonceHash)
+	return secret + "." + expiry + "." + signature
+}
+
+// verifyMagicLink checks the signature and expiry of a magic link token
+// without touching storage, and returns its one-time secret.
+func (s *AuthServiceImpl) verifyMagicLink(token, nonceHash string) (string, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", false
+	}
+	secret, expiry, signature := parts[0], parts[1], parts[2]
+
+	if !s.linkSigner.Verify(signature, string(entities.TokenTypeMagicLink), secret, expiry, nonceHash) {
+		return "", false
+	}
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return "", false
+	}
+	return secret, true
+}
+
+func newMagicLinkNonce() (string, error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(nonce), nil
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/infrastructure/security"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newMagicLinkTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	signer, err := security.NewLinkSigner(base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTestEnv(t, WithMagicLinks(signer, 15*time.Minute))
+}
+
+// requestMagicLink asks for a link to email and returns the nonce and the
+// token from the link that was mailed.
+func (e *testEnv) requestMagicLink(t *testing.T, email string) (string, string) {
+	t.Helper()
+	e.mailer.Reset()
+	nonce, _, err := e.svc.RequestMagicLink(&dto.MagicLinkRequest{Email: email})
+	if err != nil {
+		t.Fatalf("RequestMagicLink: %v", err)
+	}
+	if sent := len(e.mailer.SentTo(email)); sent != 1 {
+		t.Fatalf("sent %d messages to %s, want 1", sent, email)
+	}
+	return nonce, e.mailedToken(t, email, "/magic-link")
+}
+
+func TestMagicLinkIsSingleUse(t *testing.T) {
+	env := newMagicLinkTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+	nonce, token := env.requestMagicLink(t, "ann@example.com")
+
+	loggedIn, tokenPair, challenge, err := env.svc.ConsumeMagicLink(&dto.ConsumeMagicLinkRequest{Token: token, Nonce: nonce})
+	if err != nil {
+		t.Fatalf("ConsumeMagicLink: %v", err)
+	}
+	if loggedIn.ID != user.ID || tokenPair == nil || challenge != nil {
+		t.Fatalf("ConsumeMagicLink signed in %s with %v, challenge %v", loggedIn.ID, tokenPair, challenge)
+	}
+
+	if _, _, _, err := env.svc.ConsumeMagicLink(&dto.ConsumeMagicLinkRequest{Token: token, Nonce: nonce}); err == nil {
+		t.Error("a used magic link was accepted again")
+	}
+}
+
+func TestMagicLinkOnlyWorksWithItsNonce(t *testing.T) {
+	env := newMagicLinkTestEnv(t)
+	env.register(t, "ann@example.com")
+	nonce, token := env.requestMagicLink(t, "ann@example.com")
+
+	otherNonce, err := newMagicLinkNonce()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := env.svc.ConsumeMagicLink(&dto.ConsumeMagicLinkRequest{Token: token, Nonce: otherNonce}); err == nil {
+		t.Fatal("magic link was accepted with another device's nonce")
+	}
+
+	// The failed attempt does not spend the link.
+	if _, _, _, err := env.svc.ConsumeMagicLink(&dto.ConsumeMagicLinkRequest{Token: token, Nonce: nonce}); err != nil {
+		t.Errorf("ConsumeMagicLink with the right nonce: %v", err)
+	}
+}
+
+func TestMagicLinkRejectsTamperedExpiry(t *testing.T) {
+	env := newMagicLinkTestEnv(t)
+	env.register(t, "ann@example.com")
+	nonce, token := env.requestMagicLink(t, "ann@example.com")
+
+	parts := strings.Split(token, ".")
+	parts[1] = "99999999999"
+	if _, _, _, err := env.svc.ConsumeMagicLink(&dto.ConsumeMagicLinkRequest{Token: strings.Join(parts, "."), Nonce: nonce}); err == nil {
+		t.Error("magic link with an extended expiry was accepted")
+	}
+}
+
+func TestMagicLinkRequestForUnknownAddressSendsNothing(t *testing.T) {
+	env := newMagicLinkTestEnv(t)
+
+	nonce, _, err := env.svc.RequestMagicLink(&dto.MagicLinkRequest{Email: "nobody@example.com"})
+	if err != nil || nonce == "" {
+		t.Fatalf("RequestMagicLink = %q, %v; want a nonce like for a known address", nonce, err)
+	}
+	if len(env.mailer.Messages()) != 0 {
+		t.Error("a magic link was mailed for an unknown address")
+	}
+}
+
+func TestMagicLinkAsksForSecondFactor(t *testing.T) {
+	env := newMagicLinkTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+	secret, _ := env.enableTOTP(t, user.ID)
+	nonce, token := env.requestMagicLink(t, "ann@example.com")
+
+	_, tokenPair, challenge, err := env.svc.ConsumeMagicLink(&dto.ConsumeMagicLinkRequest{Token: token, Nonce: nonce})
+	if err != nil {
+		t.Fatalf("ConsumeMagicLink: %v", err)
+	}
+	if tokenPair != nil || challenge == nil {
+		t.Fatal("ConsumeMagicLink signed in without the second factor")
+	}
+	if _, err := env.verifyMFA(challenge.Token, totpCode(t, secret, currentStep()+1)); err != nil {
+		t.Errorf("VerifyMFA: %v", err)
+	}
+}
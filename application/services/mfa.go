@@ -0,0 +1,394 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"ambassador/infrastructure/security"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// maxMFAAttempts bounds how many wrong codes may be tried against a single
+// mfa_pending challenge before it is revoked and the user has to log in
+// again, or against a signed-in user's MFA settings within
+// mfaSettingsAttemptWindow.
+const maxMFAAttempts = 5
+
+// mfaSettingsAttemptWindow is how long wrong codes sent to change MFA
+// settings are remembered.
+const mfaSettingsAttemptWindow = 15 * time.Minute
+
+// errInvalidMFACode is returned for a wrong, reused or already spent code.
+var errInvalidMFACode = errors.New("invalid mfa code")
+
+// mfaAttempts counts failed codes per challenge, or per user for changes to
+// MFA settings. It is per process; wrong codes also count against the
+// account's login throttle, which is shared when lockout is configured.
+type mfaAttempts struct {
+	mu       sync.Mutex
+	failures map[string]int
+	expires  map[string]time.Time
+}
+
+func newMFAAttempts() *mfaAttempts {
+	return &mfaAttempts{failures: make(map[string]int), expires: make(map[string]time.Time)}
+}
+
+// fail records a failed attempt for key, remembered until expiresAt, and
+// reports whether key is now exhausted.
+func (a *mfaAttempts) fail(key string, expiresAt time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.prune()
+	a.failures[key]++
+	a.expires[key] = expiresAt
+	return a.failures[key] >= maxMFAAttempts
+}
+
+// exhausted reports whether key has used up its attempts.
+func (a *mfaAttempts) exhausted(key string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.prune()
+	return a.failures[key] >= maxMFAAttempts
+}
+
+func (a *mfaAttempts) clear(key string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.failures, key)
+	delete(a.expires, key)
+}
+
+func (a *mfaAttempts) prune() {
+	now := time.Now()
+	for key, expiresAt := range a.expires {
+		if now.After(expiresAt) {
+			delete(a.failures, key)
+			delete(a.expires, key)
+		}
+	}
+}
+
+// mfaEnabled reports whether userID has a confirmed authenticator.
+func (s *AuthServiceImpl) mfaEnabled(userID string) bool {
+	if s.mfaRepo == nil {
+		return false
+	}
+	credential, err := s.mfaRepo.FindTOTP(userID)
+	return err == nil && credential.IsConfirmed()
+}
+
+// newMFAChallenge asks for a second factor after the first one, named by
+// method, has been checked.
+func (s *AuthServiceImpl) newMFAChallenge(userID, method string) (*entities.Challenge, error) {
+	secret, token := entities.NewOneTimeToken(userID, entities.TokenTypeMFAPending, s.mfaChallengeTTL)
+	token.Subject = method
+	if err := s.tokenRepo.Save(token); err != nil {
+		return nil, err
+	}
+	return &entities.Challenge{
+		Type:      entities.TokenTypeMFAPending,
+		Token:     secret,
+		ExpiresAt: token.ExpiresAt,
+	}, nil
+}
+
+// checkSecondFactor accepts either a current TOTP code or an unused recovery
+// code, recording the use through tx so it only sticks if the surrounding
+// unit of work commits. It reports whether a recovery code was spent.
+func (s *AuthServiceImpl) checkSecondFactor(tx *repositories.TxRepositories, userID, code string) (bool, error) {
+	credential, err := tx.MFA.FindTOTP(userID)
+	if err != nil || !credential.IsConfirmed() {
+		return false, errors.New("mfa is not enabled")
+	}
+
+	secret, err := s.secretBox.Open(credential.Secret)
+	if err != nil {
+		return false, err
+	}
+	if step, ok := security.VerifyTOTP(secret, code, time.Now()); ok {
+		if step <= credential.LastUsedStep {
+			return false, errInvalidMFACode
+		}
+		credential.LastUsedStep = step
+		return false, tx.MFA.SaveTOTP(credential)
+	}
+
+	consumed, err := tx.MFA.ConsumeRecoveryCode(userID, entities.HashRecoveryCode(code))
+	if err != nil {
+		return false, err
+	}
+	if !consumed {
+		return false, errInvalidMFACode
+	}
+	return true, nil
+}
+
+func (s *AuthServiceImpl) recordRecoveryCodeUse(userID string, used bool, client entities.ClientInfo) {
+	if used {
+		s.audit.Record(entities.NewAuditEvent(entities.AuditMFARecoveryUsed, userID, client, nil))
+	}
+}
+
+// VerifyMFA exchanges an mfa_pending challenge and a second factor for a
+// TokenPair, or for a password_expired challenge when the password has to
+// be changed first.
+func (s *AuthServiceImpl) VerifyMFA(req *dto.MFAVerifyRequest) (*entities.User, *entities.TokenPair, *entities.Challenge, error) {
+	challenge, err := s.tokenRepo.FindByValue(entities.HashTokenValue(req.MFAToken))
+	if err != nil || challenge.Type != entities.TokenTypeMFAPending {
+		return nil, nil, nil, errors.New("invalid or expired mfa token")
+	}
+
+	if challenge.IsExpired() {
+		s.tokenRepo.Delete(challenge.Value)
+		return nil, nil, nil, errors.New("invalid or expired mfa token")
+	}
+
+	user, err := s.userRepo.FindByID(challenge.UserID)
+	if err != nil {
+		return nil, nil, nil, errors.New("invalid or expired mfa token")
+	}
+
+	if !user.IsActive {
+		return nil, nil, nil, errors.New("account is deactivated")
+	}
+
+	// Wrong codes count against the same throttle as wrong passwords, so
+	// starting a new login does not buy more guesses.
+	throttleKey := entities.LoginThrottleKey(user.Email.String())
+	if err := s.checkLoginThrottle(throttleKey); err != nil {
+		s.recordLoginFailed(user.ID, user.Email.String(), "throttled", req.Client)
+		return nil, nil, nil, err
+	}
+
+	var (
+		tokenPair      *entities.TokenPair
+		session        *entities.Session
+		passwordChange *entities.Challenge
+	)
+	// Challenges issued before the first factor was recorded were all
+	// password logins.
+	firstFactor := challenge.Subject
+	if firstFactor == "" {
+		firstFactor = "password"
+	}
+	// Only a login that used the password is held back when it expires.
+	expired := firstFactor == "password" && user.PasswordExpired(s.passwordMaxAge)
+	if !expired {
+		tokenPair, session, err = s.newSession(user, req.DeviceName, req.Client)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	var usedRecovery bool
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		var err error
+		if usedRecovery, err = s.checkSecondFactor(tx, user.ID, req.Code); err != nil {
+			return err
+		}
+		if err := tx.Tokens.Delete(challenge.Value); err != nil {
+			return err
+		}
+		if expired {
+			passwordChange, err = s.newPasswordExpiredChallenge(tx.Tokens, user.ID, secondFactorMethod(firstFactor, usedRecovery))
+			return err
+		}
+		return s.saveSession(tx, tokenPair, session)
+	})
+	if err != nil {
+		if errors.Is(err, errInvalidMFACode) {
+			s.recordLoginFailure(throttleKey, user, req.Client)
+			s.recordLoginFailed(user.ID, user.Email.String(), "invalid_mfa_code", req.Client)
+			if s.mfaAttempts.fail(challenge.Value, challenge.ExpiresAt) {
+				s.tokenRepo.Delete(challenge.Value)
+				s.mfaAttempts.clear(challenge.Value)
+				return nil, nil, nil, errors.New("too many invalid mfa codes, please log in again")
+			}
+		}
+		return nil, nil, nil, err
+	}
+	s.mfaAttempts.clear(challenge.Value)
+	s.resetLoginThrottle(user)
+	s.recordRecoveryCodeUse(user.ID, usedRecovery, req.Client)
+	if passwordChange != nil {
+		return user, nil, passwordChange, nil
+	}
+	s.recordLoginSucceeded(user.ID, secondFactorMethod(firstFactor, usedRecovery), tokenPair, req.Client)
+
+	return user, tokenPair, nil, nil
+}
+
+// secondFactorMethod is the login method recorded for a login whose first
+// factor was firstFactor, completed with a TOTP or recovery code.
+func secondFactorMethod(firstFactor string, usedRecovery bool) string {
+	if usedRecovery {
+		return firstFactor + "+recovery_code"
+	}
+	return firstFactor + "+totp"
+}
+
+// EnrollTOTP starts authenticator enrollment and returns the secret together
+// with an otpauth URI for QR codes. MFA is not enforced until ConfirmTOTP.
+// Starting again before confirming replaces the pending secret.
+func (s *AuthServiceImpl) EnrollTOTP(userID string) (string, string, error) {
+	if s.mfaRepo == nil {
+		return "", "", errors.New("mfa is not configured")
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return "", "", errors.New("user not found")
+	}
+	if user.RegistrationMethod != entities.RegMethodEmail {
+		return "", "", errors.New("mfa is only available for email accounts")
+	}
+	if s.mfaEnabled(userID) {
+		return "", "", errors.New("mfa is already enabled")
+	}
+
+	secret := security.GenerateTOTPSecret()
+	sealed, err := s.secretBox.Seal(secret)
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.mfaRepo.SaveTOTP(entities.NewTOTPCredential(userID, sealed)); err != nil {
+		return "", "", err
+	}
+
+	return secret, security.TOTPURI(s.totpIssuer, user.Email.String(), secret), nil
+}
+
+// ConfirmTOTP enables MFA once the user proves their authenticator works and
+// returns a fresh set of recovery codes. The codes are only shown here.
+func (s *AuthServiceImpl) ConfirmTOTP(userID string, req *dto.MFACodeRequest) ([]string, error) {
+	if s.mfaRepo == nil {
+		return nil, errors.New("mfa is not configured")
+	}
+
+	credential, err := s.mfaRepo.FindTOTP(userID)
+	if err != nil {
+		return nil, errors.New("mfa enrollment has not been started")
+	}
+	if credential.IsConfirmed() {
+		return nil, errors.New("mfa is already enabled")
+	}
+
+	secret, err := s.secretBox.Open(credential.Secret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := security.VerifyTOTP(secret, req.Code, time.Now())
+	if !ok {
+		return nil, errInvalidMFACode
+	}
+	credential.ConfirmedAt = time.Now()
+	credential.LastUsedStep = step
+
+	codes, records := entities.NewRecoveryCodes(userID)
+
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		if err := tx.MFA.SaveTOTP(credential); err != nil {
+			return err
+		}
+		return tx.MFA.ReplaceRecoveryCodes(userID, records)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(entities.NewAuditEvent(entities.AuditMFAEnabled, userID, req.Client, map[string]string{"method": "totp"}))
+
+	return codes, nil
+}
+
+// DisableTOTP turns MFA off after checking a current code or recovery code.
+func (s *AuthServiceImpl) DisableTOTP(userID string, req *dto.MFACodeRequest) error {
+	if s.mfaRepo == nil {
+		return errors.New("mfa is not configured")
+	}
+
+	usedRecovery, err := s.changeMFASettings(userID, req, func(tx *repositories.TxRepositories) error {
+		if err := tx.MFA.DeleteTOTP(userID); err != nil {
+			return err
+		}
+		if err := tx.MFA.ReplaceRecoveryCodes(userID, nil); err != nil {
+			return err
+		}
+		return tx.Tokens.DeleteAllUserTokens(userID, entities.TokenTypeMFAPending)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.recordRecoveryCodeUse(userID, usedRecovery, req.Client)
+	s.audit.Record(entities.NewAuditEvent(entities.AuditMFADisabled, userID, req.Client, map[string]string{"method": "totp"}))
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, used or not, after
+// checking a current code or recovery code.
+func (s *AuthServiceImpl) RegenerateRecoveryCodes(userID string, req *dto.MFACodeRequest) ([]string, error) {
+	if s.mfaRepo == nil {
+		return nil, errors.New("mfa is not configured")
+	}
+
+	codes, records := entities.NewRecoveryCodes(userID)
+
+	usedRecovery, err := s.changeMFASettings(userID, req, func(tx *repositories.TxRepositories) error {
+		return tx.MFA.ReplaceRecoveryCodes(userID, records)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordRecoveryCodeUse(userID, usedRecovery, req.Client)
+	s.audit.Record(entities.NewAuditEvent(entities.AuditMFARecoveryReset, userID, req.Client, nil))
+
+	return codes, nil
+}
+
+// changeMFASettings checks req.Code like checkSecondFactor and runs apply in
+// the same unit of work. The caller is signed in, but a stolen access token
+// must not turn the code into something that can be guessed at: wrong codes
+// count against the account's login throttle and are limited per user.
+func (s *AuthServiceImpl) changeMFASettings(userID string, req *dto.MFACodeRequest, apply func(tx *repositories.TxRepositories) error) (bool, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return false, errors.New("user not found")
+	}
+
+	attemptsKey := "user:" + userID
+	if s.mfaAttempts.exhausted(attemptsKey) {
+		return false, errors.New("too many invalid mfa codes, please try again later")
+	}
+	throttleKey := entities.LoginThrottleKey(user.Email.String())
+	if err := s.checkLoginThrottle(throttleKey); err != nil {
+		return false, err
+	}
+
+	var usedRecovery bool
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		var err error
+		if usedRecovery, err = s.checkSecondFactor(tx, userID, req.Code); err != nil {
+			return err
+		}
+		return apply(tx)
+	})
+	if err != nil {
+		if errors.Is(err, errInvalidMFACode) {
+			s.recordLoginFailure(throttleKey, user, req.Client)
+			s.mfaAttempts.fail(attemptsKey, time.Now().Add(mfaSettingsAttemptWindow))
+		}
+		return false, err
+	}
+	s.mfaAttempts.clear(attemptsKey)
+	return usedRecovery, nil
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"ambassador/infrastructure/repositories"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wrongCode never matches a TOTP code or a recovery code.
+const wrongCode = "abcdef"
+
+// totpCode computes the RFC 6238 code for secret at step, as an
+// authenticator app would.
+func totpCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
+
+func currentStep() int64 {
+	return time.Now().Unix() / 30
+}
+
+// enableTOTP enrolls and confirms an authenticator for userID with the code
+// of the current step, so the next step's code is the first one left.
+func (e *testEnv) enableTOTP(t *testing.T, userID string) (string, []string) {
+	t.Helper()
+	secret, _, err := e.svc.EnrollTOTP(userID)
+	if err != nil {
+		t.Fatalf("EnrollTOTP: %v", err)
+	}
+	codes, err := e.svc.ConfirmTOTP(userID, &dto.MFACodeRequest{Code: totpCode(t, secret, currentStep())})
+	if err != nil {
+		t.Fatalf("ConfirmTOTP: %v", err)
+	}
+	return secret, codes
+}
+
+// mfaLogin logs in with testPassword and returns the MFA challenge token.
+func (e *testEnv) mfaLogin(t *testing.T, email string) string {
+	t.Helper()
+	tokenPair, challenge, err := e.svc.Login(&dto.LoginRequest{Email: email, Password: testPassword})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if tokenPair != nil || challenge == nil || challenge.Type != entities.TokenTypeMFAPending {
+		t.Fatalf("Login did not ask for a second factor: %v %v", tokenPair, challenge)
+	}
+	return challenge.Token
+}
+
+func (e *testEnv) verifyMFA(mfaToken, code string) (*entities.TokenPair, error) {
+	_, tokenPair, _, err := e.svc.VerifyMFA(&dto.MFAVerifyRequest{MFAToken: mfaToken, Code: code})
+	return tokenPair, err
+}
+
+func TestVerifyMFARejectsReplayedTOTPCode(t *testing.T) {
+	env := newTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+	secret, _ := env.enableTOTP(t, user.ID)
+	code := totpCode(t, secret, currentStep()+1)
+
+	tokenPair, err := env.verifyMFA(env.mfaLogin(t, "ann@example.com"), code)
+	if err != nil || tokenPair == nil {
+		t.Fatalf("VerifyMFA: %v", err)
+	}
+
+	if _, err := env.verifyMFA(env.mfaLogin(t, "ann@example.com"), code); !errors.Is(err, errInvalidMFACode) {
+		t.Errorf("replayed code returned %v", err)
+	}
+}
+
+func TestVerifyMFARecoveryCodeIsSingleUse(t *testing.T) {
+	env := newTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+	_, codes := env.enableTOTP(t, user.ID)
+
+	if _, err := env.verifyMFA(env.mfaLogin(t, "ann@example.com"), codes[0]); err != nil {
+		t.Fatalf("VerifyMFA with a recovery code: %v", err)
+	}
+	if _, err := env.verifyMFA(env.mfaLogin(t, "ann@example.com"), codes[0]); !errors.Is(err, errInvalidMFACode) {
+		t.Errorf("spent recovery code returned %v", err)
+	}
+	if len(env.auditEvents(t, user.ID, entities.AuditMFARecoveryUsed)) != 1 {
+		t.Error("recovery code use was not audited")
+	}
+}
+
+func TestVerifyMFARevokesChallengeAfterTooManyCodes(t *testing.T) {
+	env := newTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+	secret, _ := env.enableTOTP(t, user.ID)
+	mfaToken := env.mfaLogin(t, "ann@example.com")
+
+	var err error
+	for i := 0; i < maxMFAAttempts; i++ {
+		_, err = env.verifyMFA(mfaToken, wrongCode)
+	}
+	if err == nil || !strings.Contains(err.Error(), "too many") {
+		t.Fatalf("last wrong code returned %v", err)
+	}
+	if _, err := env.verifyMFA(mfaToken, totpCode(t, secret, currentStep()+1)); err == nil {
+		t.Error("revoked challenge still accepts the right code")
+	}
+}
+
+func TestWrongMFACodesCountAgainstLoginThrottle(t *testing.T) {
+	env := newTestEnv(t, WithLockout(repositories.NewMemoryLoginThrottleRepository(), LockoutPolicy{
+		Threshold:  3,
+		Duration:   time.Hour,
+		ResetAfter: time.Hour,
+		UnlockTTL:  time.Hour,
+	}))
+	user, _ := env.register(t, "ann@example.com")
+	env.enableTOTP(t, user.ID)
+
+	// Logging in again for a fresh challenge does not reset the count.
+	for i := 0; i < 3; i++ {
+		if _, err := env.verifyMFA(env.mfaLogin(t, "ann@example.com"), wrongCode); !errors.Is(err, errInvalidMFACode) {
+			t.Fatalf("wrong code %d returned %v", i+1, err)
+		}
+	}
+
+	_, _, err := env.svc.Login(&dto.LoginRequest{Email: "ann@example.com", Password: testPassword})
+	var throttled *entities.LoginThrottledError
+	if !errors.As(err, &throttled) {
+		t.Errorf("login after repeated wrong codes returned %v, want the account locked", err)
+	}
+}
+
+func TestMFASettingsChangesLimitAttempts(t *testing.T) {
+	env := newTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+	secret, _ := env.enableTOTP(t, user.ID)
+
+	for i := 0; i < maxMFAAttempts; i++ {
+		if _, err := env.svc.RegenerateRecoveryCodes(user.ID, &dto.MFACodeRequest{Code: wrongCode}); !errors.Is(err, errInvalidMFACode) {
+			t.Fatalf("wrong code %d returned %v", i+1, err)
+		}
+	}
+
+	err := env.svc.DisableTOTP(user.ID, &dto.MFACodeRequest{Code: totpCode(t, secret, currentStep()+1)})
+	if err == nil || !strings.Contains(err.Error(), "too many") {
+		t.Fatalf("DisableTOTP after too many wrong codes returned %v", err)
+	}
+	if !env.svc.mfaEnabled(user.ID) {
+		t.Error("MFA was disabled after the attempt limit was reached")
+	}
+}
+
+func TestDisableTOTPWithCurrentCode(t *testing.T) {
+	env := newTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+	secret, _ := env.enableTOTP(t, user.ID)
+
+	if err := env.svc.DisableTOTP(user.ID, &dto.MFACodeRequest{Code: totpCode(t, secret, currentStep()+1)}); err != nil {
+		t.Fatalf("DisableTOTP: %v", err)
+	}
+	if env.svc.mfaEnabled(user.ID) {
+		t.Error("MFA is still enabled")
+	}
+}
+
+func TestOAuthLoginAsksForSecondFactor(t *testing.T) {
+	env := newOAuthTestEnv(t, googleIdentity)
+	user, _ := env.register(t, googleIdentity.Email)
+	user.MarkEmailVerified()
+	if err := env.repos.Users.Save(user); err != nil {
+		t.Fatal(err)
+	}
+	secret, _ := env.enableTOTP(t, user.ID)
+
+	_, tokenPair, challenge, err := env.oauthLogin(t, "")
+	if err != nil {
+		t.Fatalf("OAuthLogin: %v", err)
+	}
+	if tokenPair != nil || challenge == nil {
+		t.Fatal("OAuthLogin signed in without the second factor")
+	}
+	if _, err := env.verifyMFA(challenge.Token, totpCode(t, secret, currentStep()+1)); err != nil {
+		t.Errorf("VerifyMFA: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"ambassador/domain/entities"
+	"errors"
+	"time"
+)
+
+// oauthNonceTTL is how long a client has to complete a provider sign-in
+// after asking for a nonce.
+const oauthNonceTTL = 10 * time.Minute
+
+// IssueOAuthNonce returns a nonce for the client to pass to the identity
+// provider, which puts it in the ID token. Only an ID token carrying a nonce
+// issued here is accepted, once, so a token captured from another sign-in
+// cannot be replayed.
+func (s *AuthServiceImpl) IssueOAuthNonce() (string, time.Time, error) {
+	if len(s.idTokenVerifiers) == 0 {
+		return "", time.Time{}, errors.New("OAuth sign-in is not configured")
+	}
+
+	nonce, token := entities.NewOneTimeToken("", entities.TokenTypeOAuthNonce, oauthNonceTTL)
+	if err := s.tokenRepo.Save(token); err != nil {
+		return "", time.Time{}, err
+	}
+	return nonce, token.ExpiresAt, nil
+}
+
+// consumeOAuthNonce spends a nonce from IssueOAuthNonce. It is spent before
+// the ID token is checked, so a nonce never serves two attempts.
+func (s *AuthServiceImpl) consumeOAuthNonce(nonce string) error {
+	if nonce == "" {
+		return errors.New("a nonce from this server is required")
+	}
+
+	token, err := s.tokenRepo.FindByValue(entities.HashTokenValue(nonce))
+	if err != nil || token.Type != entities.TokenTypeOAuthNonce {
+		return errors.New("invalid or expired nonce")
+	}
+	if err := s.tokenRepo.Delete(token.Value); err != nil {
+		return err
+	}
+	if token.IsExpired() {
+		return errors.New("invalid or expired nonce")
+	}
+	return nil
+}
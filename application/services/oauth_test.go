@@ -0,0 +1,161 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"ambassador/infrastructure/oauth"
+	"strings"
+	"testing"
+)
+
+// fakeVerifier accepts any ID token and reports identity, standing in for a
+// provider whose signature checks pass.
+type fakeVerifier struct {
+	identity oauth.Identity
+}
+
+func (f *fakeVerifier) Verify(rawIDToken, nonce string) (*oauth.Identity, error) {
+	identity := f.identity
+	return &identity, nil
+}
+
+func newOAuthTestEnv(t *testing.T, identity oauth.Identity, opts ...AuthServiceOption) *testEnv {
+	t.Helper()
+	verifiers := map[entities.RegistrationMethod]oauth.IDTokenVerifier{
+		entities.RegMethodGoogle: &fakeVerifier{identity: identity},
+	}
+	// newTestEnv already wires the env's identity repository.
+	withGoogle := func(s *AuthServiceImpl) {
+		s.idTokenVerifiers = verifiers
+	}
+	return newTestEnv(t, append([]AuthServiceOption{withGoogle}, opts...)...)
+}
+
+// oauthLogin signs in with Google using a freshly issued nonce.
+func (e *testEnv) oauthLogin(t *testing.T, password string) (*entities.User, *entities.TokenPair, *entities.Challenge, error) {
+	t.Helper()
+	nonce, _, err := e.svc.IssueOAuthNonce()
+	if err != nil {
+		t.Fatalf("IssueOAuthNonce: %v", err)
+	}
+	return e.svc.OAuthLogin(&dto.OAuthLoginRequest{
+		Provider:    entities.RegMethodGoogle,
+		IDToken:     "id-token",
+		Nonce:       nonce,
+		Password:    password,
+		Gender:      entities.GenderFemale,
+		DateOfBirth: "1990-01-02",
+	})
+}
+
+var googleIdentity = oauth.Identity{
+	Subject:       "google-subject",
+	Email:         "ann@example.com",
+	EmailVerified: true,
+	Name:          "Ann Lee",
+}
+
+func TestOAuthLoginRequiresIssuedNonce(t *testing.T) {
+	env := newOAuthTestEnv(t, googleIdentity)
+
+	for _, nonce := range []string{"", "chosen-by-the-client"} {
+		_, _, _, err := env.svc.OAuthLogin(&dto.OAuthLoginRequest{
+			Provider:    entities.RegMethodGoogle,
+			IDToken:     "id-token",
+			Nonce:       nonce,
+			Gender:      entities.GenderFemale,
+			DateOfBirth: "1990-01-02",
+		})
+		if err == nil || !strings.Contains(err.Error(), "nonce") {
+			t.Errorf("OAuthLogin with nonce %q returned %v", nonce, err)
+		}
+	}
+}
+
+func TestOAuthNonceIsSingleUse(t *testing.T) {
+	env := newOAuthTestEnv(t, googleIdentity)
+	nonce, _, err := env.svc.IssueOAuthNonce()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &dto.OAuthLoginRequest{
+		Provider:    entities.RegMethodGoogle,
+		IDToken:     "id-token",
+		Nonce:       nonce,
+		Gender:      entities.GenderFemale,
+		DateOfBirth: "1990-01-02",
+	}
+
+	if _, _, _, err := env.svc.OAuthLogin(req); err != nil {
+		t.Fatalf("OAuthLogin: %v", err)
+	}
+	if _, _, _, err := env.svc.OAuthLogin(req); err == nil {
+		t.Error("a replayed ID token and nonce signed in again")
+	}
+}
+
+func TestOAuthLoginLinksUnverifiedAccountOnlyWithPassword(t *testing.T) {
+	env := newOAuthTestEnv(t, googleIdentity)
+	owner, _ := env.register(t, googleIdentity.Email)
+
+	_, _, _, err := env.oauthLogin(t, "")
+	if err == nil || !strings.Contains(err.Error(), "password required") {
+		t.Fatalf("linking without a password returned %v", err)
+	}
+	_, _, _, err = env.oauthLogin(t, "wrong-password")
+	if err == nil || !strings.Contains(err.Error(), "invalid credentials") {
+		t.Fatalf("linking with a wrong password returned %v", err)
+	}
+	if _, err := env.repos.Identities.FindByProviderSubject(entities.RegMethodGoogle, googleIdentity.Subject); err == nil {
+		t.Fatal("identity was linked without the account password")
+	}
+
+	user, _, _, err := env.oauthLogin(t, testPassword)
+	if err != nil {
+		t.Fatalf("linking with the account password: %v", err)
+	}
+	if user.ID != owner.ID {
+		t.Errorf("signed in as %s, want the existing account %s", user.ID, owner.ID)
+	}
+}
+
+func TestOAuthLoginLinksVerifiedAccountWithoutPassword(t *testing.T) {
+	env := newOAuthTestEnv(t, googleIdentity)
+	owner, _ := env.register(t, googleIdentity.Email)
+	owner.MarkEmailVerified()
+	if err := env.repos.Users.Save(owner); err != nil {
+		t.Fatal(err)
+	}
+
+	user, _, _, err := env.oauthLogin(t, "")
+	if err != nil {
+		t.Fatalf("OAuthLogin: %v", err)
+	}
+	if user.ID != owner.ID {
+		t.Errorf("signed in as %s, want the existing account %s", user.ID, owner.ID)
+	}
+}
+
+func TestOAuthLoginMarksOnlyCreatedAccountsVerified(t *testing.T) {
+	env := newOAuthTestEnv(t, googleIdentity)
+	created, _, _, err := env.oauthLogin(t, "")
+	if err != nil {
+		t.Fatalf("OAuthLogin: %v", err)
+	}
+	if stored, _ := env.repos.Users.FindByID(created.ID); stored == nil || !stored.EmailVerified {
+		t.Error("account created from a provider-verified email is not verified")
+	}
+
+	env = newOAuthTestEnv(t, googleIdentity)
+	owner, _ := env.register(t, googleIdentity.Email)
+	if _, _, _, err := env.oauthLogin(t, testPassword); err != nil {
+		t.Fatalf("OAuthLogin: %v", err)
+	}
+	stored, err := env.repos.Users.FindByID(owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.EmailVerified {
+		t.Error("linking a provider identity marked the existing account verified")
+	}
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"ambassador/infrastructure/passkey"
+	"errors"
+	"strings"
+)
+
+// WithPasskeys enables WebAuthn registration and passwordless login.
+func WithPasskeys(webAuthnRepo repositories.WebAuthnRepository, relyingParty *passkey.RelyingParty) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.webAuthnRepo = webAuthnRepo
+		s.passkeys = relyingParty
+	}
+}
+
+// BeginPasskeyRegistration starts adding a passkey to the signed-in user's
+// account and returns the ceremony ID with the creation options.
+func (s *AuthServiceImpl) BeginPasskeyRegistration(userID string) (string, interface{}, error) {
+	if s.passkeys == nil {
+		return "", nil, errors.New("passkeys are not configured")
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return "", nil, errors.New("user not found")
+	}
+	existing, err := s.webAuthnRepo.FindCredentialsByUserID(userID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	options, data, err := s.passkeys.BeginRegistration(user, existing)
+	if err != nil {
+		return "", nil, err
+	}
+
+	ceremonyID, session := entities.NewWebAuthnSession(userID, entities.WebAuthnCeremonyRegistration, data, s.passkeys.Timeout())
+	if err := s.webAuthnRepo.SaveSession(session); err != nil {
+		return "", nil, err
+	}
+
+	return ceremonyID, options, nil
+}
+
+func (s *AuthServiceImpl) FinishPasskeyRegistration(userID string, req *dto.FinishPasskeyRegistrationRequest) (*entities.WebAuthnCredential, error) {
+	if s.passkeys == nil {
+		return nil, errors.New("passkeys are not configured")
+	}
+
+	session, err := s.takeWebAuthnSession(req.CeremonyID, entities.WebAuthnCeremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID != userID {
+		return nil, errors.New("invalid or expired passkey ceremony")
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	existing, err := s.webAuthnRepo.FindCredentialsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.passkeys.FinishRegistration(user, existing, session.Data, req.Credential)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.webAuthnRepo.FindCredential(credential.ID); err == nil {
+		return nil, errors.New("passkey is already registered")
+	}
+
+	credential.Name = strings.TrimSpace(req.Name)
+	if credential.Name == "" {
+		credential.Name = "Passkey"
+	}
+	if err := s.webAuthnRepo.SaveCredential(credential); err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(entities.NewAuditEvent(entities.AuditPasskeyAdded, userID, req.Client, map[string]string{
+		"credentialId": credential.ID,
+	}))
+
+	return credential, nil
+}
+
+// BeginPasskeyLogin starts a passwordless login. The ceremony is always
+// discoverable, so the authenticator offers whichever passkeys it holds for
+// this site and the response is the same for every address: naming an
+// account neither reveals whether it exists nor lists its credential IDs.
+func (s *AuthServiceImpl) BeginPasskeyLogin(req *dto.BeginPasskeyLoginRequest) (string, interface{}, error) {
+	if s.passkeys == nil {
+		return "", nil, errors.New("passkeys are not configured")
+	}
+
+	options, data, err := s.passkeys.BeginLogin(nil, nil)
+	if err != nil {
+		return "", nil, err
+	}
+
+	ceremonyID, session := entities.NewWebAuthnSession("", entities.WebAuthnCeremonyLogin, data, s.passkeys.Timeout())
+	if err := s.webAuthnRepo.SaveSession(session); err != nil {
+		return "", nil, err
+	}
+
+	return ceremonyID, options, nil
+}
+
+// FinishPasskeyLogin verifies the assertion and signs the user in. A passkey
+// proves possession and, with user verification, presence, so no further
+// MFA challenge is issued.
+func (s *AuthServiceImpl) FinishPasskeyLogin(req *dto.FinishPasskeyLoginRequest) (*entities.User, *entities.TokenPair, error) {
+	if s.passkeys == nil {
+		return nil, nil, errors.New("passkeys are not configured")
+	}
+
+	session, err := s.takeWebAuthnSession(req.CeremonyID, entities.WebAuthnCeremonyLogin)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, credential, err := s.passkeys.FinishLogin(session.Data, req.Credential, func(userID string) (*entities.User, []*entities.WebAuthnCredential, error) {
+		user, err := s.userRepo.FindByID(userID)
+		if err != nil {
+			return nil, nil, err
+		}
+		credentials, err := s.webAuthnRepo.FindCredentialsByUserID(userID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return user, credentials, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !user.IsActive {
+		return nil, nil, errors.New("account is deactivated")
+	}
+
+	if err := s.checkEmailVerified(user); err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.webAuthnRepo.SaveCredential(credential); err != nil {
+		return nil, nil, err
+	}
+
+	tokenPair, err := s.startSession(user, req.DeviceName, req.Client)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.recordLoginSucceeded(user.ID, "passkey", tokenPair, req.Client)
+
+	return user, tokenPair, nil
+}
+
+func (s *AuthServiceImpl) ListPasskeys(userID string) ([]*entities.WebAuthnCredential, error) {
+	if s.passkeys == nil {
+		return nil, errors.New("passkeys are not configured")
+	}
+	return s.webAuthnRepo.FindCredentialsByUserID(userID)
+}
+
+func (s *AuthServiceImpl) DeletePasskey(userID, credentialID string, client entities.ClientInfo) error {
+	if s.passkeys == nil {
+		return errors.New("passkeys are not configured")
+	}
+	if err := s.webAuthnRepo.DeleteCredential(userID, credentialID); err != nil {
+		return errors.New("passkey not found")
+	}
+
+	s.audit.Record(entities.NewAuditEvent(entities.AuditPasskeyRemoved, userID, client, map[string]string{
+		"credentialId": credentialID,
+	}))
+
+	return nil
+}
+
+func (s *AuthServiceImpl) takeWebAuthnSession(ceremonyID string, ceremony entities.WebAuthnCeremony) (*entities.WebAuthnSession, error) {
+	session, err := s.webAuthnRepo.TakeSession(entities.HashTokenValue(ceremonyID))
+	if err != nil || session.Ceremony != ceremony || session.IsExpired() {
+		return nil, errors.New("invalid or expired passkey ceremony")
+	}
+	return session, nil
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	domainrepos "ambassador/domain/repositories"
+	"ambassador/infrastructure/passkey"
+	"ambassador/infrastructure/passkey/passkeytest"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+)
+
+func newPasskeyTestEnv(t *testing.T) (*testEnv, domainrepos.WebAuthnRepository) {
+	t.Helper()
+	rp, err := passkey.NewRelyingParty(passkey.Config{RPID: "example.com", RPDisplayName: "Example", Origins: []string{"https://example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := newTestEnv(t)
+	WithPasskeys(env.repos.WebAuthn, rp)(env.svc)
+	return env, env.repos.WebAuthn
+}
+
+// registerPasskey runs a registration ceremony for userID with a new
+// software authenticator and returns it.
+func (e *testEnv) registerPasskey(t *testing.T, userID string) *passkeytest.Authenticator {
+	t.Helper()
+	authenticator, err := passkeytest.NewAuthenticator("example.com", "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ceremonyID, options, err := e.svc.BeginPasskeyRegistration(userID)
+	if err != nil {
+		t.Fatalf("BeginPasskeyRegistration: %v", err)
+	}
+	response, err := authenticator.Register(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.svc.FinishPasskeyRegistration(userID, &dto.FinishPasskeyRegistrationRequest{CeremonyID: ceremonyID, Credential: response}); err != nil {
+		t.Fatalf("FinishPasskeyRegistration: %v", err)
+	}
+	return authenticator
+}
+
+func TestPasskeyRegistrationAndLoginIssueTokenPair(t *testing.T) {
+	env, webAuthnRepo := newPasskeyTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+	authenticator := env.registerPasskey(t, user.ID)
+
+	authenticator.SignCount = 7
+	ceremonyID, options, err := env.svc.BeginPasskeyLogin(&dto.BeginPasskeyLoginRequest{})
+	if err != nil {
+		t.Fatalf("BeginPasskeyLogin: %v", err)
+	}
+	response, err := authenticator.Login(options, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loggedIn, tokenPair, err := env.svc.FinishPasskeyLogin(&dto.FinishPasskeyLoginRequest{CeremonyID: ceremonyID, Credential: response})
+	if err != nil {
+		t.Fatalf("FinishPasskeyLogin: %v", err)
+	}
+	if loggedIn.ID != user.ID || tokenPair == nil || tokenPair.AccessToken == nil {
+		t.Fatalf("FinishPasskeyLogin signed in %s with %v", loggedIn.ID, tokenPair)
+	}
+
+	credentials, err := webAuthnRepo.FindCredentialsByUserID(user.ID)
+	if err != nil || len(credentials) != 1 {
+		t.Fatalf("stored credentials: %v %v", credentials, err)
+	}
+	if credentials[0].SignCount != 7 {
+		t.Errorf("stored sign count = %d, want 7", credentials[0].SignCount)
+	}
+
+	// The ceremony is single use.
+	if _, _, err := env.svc.FinishPasskeyLogin(&dto.FinishPasskeyLoginRequest{CeremonyID: ceremonyID, Credential: response}); err == nil {
+		t.Error("a finished login ceremony was accepted again")
+	}
+}
+
+func TestBeginPasskeyLoginDoesNotRevealAccounts(t *testing.T) {
+	env, _ := newPasskeyTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+	authenticator := env.registerPasskey(t, user.ID)
+
+	for _, email := range []string{"ann@example.com", "nobody@example.com", ""} {
+		_, options, err := env.svc.BeginPasskeyLogin(&dto.BeginPasskeyLoginRequest{Email: email})
+		if err != nil {
+			t.Fatalf("BeginPasskeyLogin for %q: %v", email, err)
+		}
+		assertion, ok := options.(*protocol.CredentialAssertion)
+		if !ok {
+			t.Fatalf("options for %q are %T", email, options)
+		}
+		if len(assertion.Response.AllowedCredentials) != 0 {
+			t.Errorf("options for %q list %d credentials", email, len(assertion.Response.AllowedCredentials))
+		}
+		data, err := json.Marshal(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), authenticator.CredentialID()) {
+			t.Errorf("options for %q contain the credential ID", email)
+		}
+	}
+
+	// Naming the account still signs in with its passkey.
+	ceremonyID, options, err := env.svc.BeginPasskeyLogin(&dto.BeginPasskeyLoginRequest{Email: "ann@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := authenticator.Login(options, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := env.svc.FinishPasskeyLogin(&dto.FinishPasskeyLoginRequest{CeremonyID: ceremonyID, Credential: response}); err != nil {
+		t.Errorf("FinishPasskeyLogin: %v", err)
+	}
+}
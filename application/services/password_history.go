@@ -0,0 +1,196 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// passwordExpiredTTL is how long a user whose password has expired has to
+// choose a new one before logging in again.
+const passwordExpiredTTL = 10 * time.Minute
+
+// WithPasswordHistory keeps the hashes of replaced passwords so that they
+// cannot be reused, and makes passwords expire maxAge after they were set.
+// How many are kept is the password policy's HistoryDepth; a zero maxAge
+// never expires passwords.
+func WithPasswordHistory(historyRepo repositories.PasswordHistoryRepository, maxAge time.Duration) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.passwordHistory = historyRepo
+		s.passwordMaxAge = maxAge
+	}
+}
+
+// validateNewPassword is validatePassword for a password replacing the
+// user's current one. It also refuses the current password and the
+// remembered previous ones, reported as a PasswordRuleReused violation
+// beside any other rule the password fails.
+func (s *AuthServiceImpl) validateNewPassword(user *entities.User, password string) error {
+	err := s.validatePassword(password, user.Email.String(), user.FullName)
+
+	reused, historyErr := s.passwordReused(user, password)
+	if historyErr != nil {
+		return historyErr
+	}
+	if !reused {
+		return err
+	}
+
+	violation := entities.PasswordViolation{
+		Rule:    entities.PasswordRuleReused,
+		Message: fmt.Sprintf("password must not be your current password or one of your last %d", s.passwordPolicy.Requirements().HistoryDepth),
+	}
+	var policyErr *entities.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		policyErr.Violations = append(policyErr.Violations, violation)
+		return policyErr
+	}
+	if err != nil {
+		return err
+	}
+	return &entities.PasswordPolicyError{Violations: []entities.PasswordViolation{violation}}
+}
+
+// passwordReused reports whether password matches the user's current hash
+// or one of the last HistoryDepth hashes it replaced. Nothing is refused
+// when the depth is zero.
+func (s *AuthServiceImpl) passwordReused(user *entities.User, password string) (bool, error) {
+	depth := s.passwordPolicy.Requirements().HistoryDepth
+	if depth <= 0 {
+		return false, nil
+	}
+	if user.PasswordHash != "" && s.hasher.CheckPassword(password, user.PasswordHash) {
+		return true, nil
+	}
+	if s.passwordHistory == nil {
+		return false, nil
+	}
+
+	entries, err := s.passwordHistory.FindRecent(user.ID, depth)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if s.hasher.CheckPassword(password, entry.PasswordHash) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// rememberPassword adds a replaced password hash to the user's history and
+// forgets entries beyond the history depth. It writes through tx so the
+// history only changes if the password change itself is committed.
+func (s *AuthServiceImpl) rememberPassword(tx *repositories.TxRepositories, userID, oldHash string) error {
+	if s.passwordHistory == nil || tx.PasswordHistory == nil || oldHash == "" {
+		return nil
+	}
+
+	depth := s.passwordPolicy.Requirements().HistoryDepth
+	if depth > 0 {
+		err := tx.PasswordHistory.Add(&entities.PasswordHistoryEntry{
+			ID:           uuid.New().String(),
+			UserID:       userID,
+			PasswordHash: oldHash,
+			CreatedAt:    time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tx.PasswordHistory.Prune(userID, depth)
+}
+
+// newPasswordExpiredChallenge holds back a login whose password has expired.
+// method is how the user signed in, recorded once the login completes.
+func (s *AuthServiceImpl) newPasswordExpiredChallenge(tokenRepo repositories.TokenRepository, userID, method string) (*entities.Challenge, error) {
+	secret, token := entities.NewOneTimeToken(userID, entities.TokenTypePasswordExpired, passwordExpiredTTL)
+	token.Subject = method
+	if err := tokenRepo.Save(token); err != nil {
+		return nil, err
+	}
+	return &entities.Challenge{
+		Type:      entities.TokenTypePasswordExpired,
+		Token:     secret,
+		ExpiresAt: token.ExpiresAt,
+	}, nil
+}
+
+// ChangeExpiredPassword exchanges a password_expired challenge and a new
+// password for a TokenPair. Every existing session is signed out, as after a
+// password reset.
+func (s *AuthServiceImpl) ChangeExpiredPassword(req *dto.ExpiredPasswordChangeRequest) (*entities.User, *entities.TokenPair, error) {
+	challenge, err := s.tokenRepo.FindByValue(entities.HashTokenValue(req.PasswordChangeToken))
+	if err != nil || challenge.Type != entities.TokenTypePasswordExpired {
+		return nil, nil, errors.New("invalid or expired password change token")
+	}
+
+	if challenge.IsExpired() {
+		s.tokenRepo.Delete(challenge.Value)
+		return nil, nil, errors.New("invalid or expired password change token")
+	}
+
+	user, err := s.userRepo.FindByID(challenge.UserID)
+	if err != nil {
+		return nil, nil, errors.New("invalid or expired password change token")
+	}
+
+	if !user.IsActive {
+		return nil, nil, errors.New("account is deactivated")
+	}
+
+	if err := s.validateNewPassword(user, req.NewPassword); err != nil {
+		return nil, nil, err
+	}
+
+	passwordHash, err := s.hasher.HashPassword(req.NewPassword)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	oldHash := user.PasswordHash
+	now := time.Now()
+	user.PasswordHash = passwordHash
+	user.PasswordChangedAt = now
+	user.UpdatedAt = now
+
+	tokenPair, session, err := s.newSession(user, req.DeviceName, req.Client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		if err := tx.Users.Save(user); err != nil {
+			return err
+		}
+		if err := s.rememberPassword(tx, user.ID, oldHash); err != nil {
+			return err
+		}
+		if err := tx.Tokens.Delete(challenge.Value); err != nil {
+			return err
+		}
+		if err := tx.Tokens.DeleteAllUserTokens(user.ID, entities.TokenTypePasswordReset); err != nil {
+			return err
+		}
+		if err := revokeAllSessions(tx, user.ID); err != nil {
+			return err
+		}
+		return s.saveSession(tx, tokenPair, session)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.audit.Record(entities.NewAuditEvent(entities.AuditPasswordChanged, user.ID, req.Client, map[string]string{
+		"reason": "expired",
+	}))
+	s.recordLoginSucceeded(user.ID, challenge.Subject, tokenPair, req.Client)
+
+	return user, tokenPair, nil
+}
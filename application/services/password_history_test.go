@@ -0,0 +1,32 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"errors"
+	"testing"
+)
+
+func TestChangePasswordRefusesRememberedPassword(t *testing.T) {
+	env := newTestEnv(t)
+	user, _ := env.register(t, "ann@example.com")
+
+	current := testPassword
+	for _, next := range []string{"Second-passw0rd", "Third-passw0rd"} {
+		if err := env.svc.ChangePassword(user.ID, "", &dto.ChangePasswordRequest{CurrentPassword: current, NewPassword: next}); err != nil {
+			t.Fatalf("ChangePassword to %s: %v", next, err)
+		}
+		current = next
+	}
+
+	entries, err := env.repos.PasswordHistory.FindRecent(user.ID, 5)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("history = %d entries, %v; want 2", len(entries), err)
+	}
+
+	err = env.svc.ChangePassword(user.ID, "", &dto.ChangePasswordRequest{CurrentPassword: current, NewPassword: testPassword})
+	var policyErr *entities.PasswordPolicyError
+	if !errors.As(err, &policyErr) || policyErr.Violations[len(policyErr.Violations)-1].Rule != entities.PasswordRuleReused {
+		t.Errorf("reusing the first password returned %v", err)
+	}
+}
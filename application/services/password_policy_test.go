@@ -0,0 +1,154 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"ambassador/infrastructure/security"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeBreachCorpus lays out a k-anonymity range directory in which each of
+// passwords has been seen count times, and returns it.
+func writeBreachCorpus(t *testing.T, count string, passwords ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for i, password := range passwords {
+		sum := sha1.Sum([]byte(password))
+		digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+		// Range files are found by either spelling of their name.
+		name := digest[:5]
+		if i%2 == 1 {
+			name = strings.ToLower(name) + ".txt"
+		}
+		line := digest[5:]
+		if count != "" {
+			line += ":" + count
+		}
+		data := "0000000000000000000000000000000000A:3\n" + line + "\n"
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// violatedRules returns the rules err reports, or fails the test when err
+// is not a password policy error.
+func violatedRules(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var policyErr *entities.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("got %v, want a password policy error", err)
+	}
+	rules := make([]string, 0, len(policyErr.Violations))
+	for _, violation := range policyErr.Violations {
+		rules = append(rules, violation.Rule)
+	}
+	return rules
+}
+
+func TestPasswordScreeningRules(t *testing.T) {
+	corpus := writeBreachCorpus(t, "12", "Tr0ub4dor&3-x", "Zebra-Cactus-42")
+	env := newTestEnv(t, WithPasswordPolicy(security.NewPasswordPolicy(
+		entities.PasswordRequirements{MinLength: 8, MaxLength: 128},
+		security.PersonalInfoRule{},
+		security.DefaultCommonPasswordRule(),
+		security.NewBreachedPasswordRule(corpus, 10),
+	)))
+
+	cases := map[string]struct {
+		password string
+		want     []string
+	}{
+		"acceptable":             {"Quiet-Lantern-88", nil},
+		"breached":               {"Tr0ub4dor&3-x", []string{entities.PasswordRuleBreached}},
+		"breached, other file":   {"Zebra-Cactus-42", []string{entities.PasswordRuleBreached}},
+		"common":                 {"password", []string{entities.PasswordRuleCommon}},
+		"common, other case":     {"LetMeIn", []string{entities.PasswordRuleMinLength, entities.PasswordRuleCommon}},
+		"common with suffix":     {"Password123!", []string{entities.PasswordRuleCommon}},
+		"contains email":         {"x-ann.lee@example.com", []string{entities.PasswordRulePersonalInfo}},
+		"contains email local":   {"Secret-ANN.LEE-7", []string{entities.PasswordRulePersonalInfo}},
+		"contains name":          {"Gorgeous-Rosalind-1", []string{entities.PasswordRulePersonalInfo}},
+		"contains initials only": {"RQ-Quiet-Lantern", nil},
+		"several, in order":      {"ann.lee", []string{entities.PasswordRuleMinLength, entities.PasswordRulePersonalInfo}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := env.svc.validatePassword(tc.password, "ann.lee@example.com", "Rosalind R. Quimby-Lee")
+			if got := violatedRules(t, err); strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("validatePassword(%q) violated %v, want %v", tc.password, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBreachedPasswordRuleThreshold(t *testing.T) {
+	cases := map[string]struct {
+		count    string
+		minCount int
+		want     bool
+	}{
+		"seen often enough": {"12", 10, true},
+		"seen too rarely":   {"3", 10, false},
+		"no count":          {"", 1, true},
+		"no count, higher":  {"", 2, false},
+		"zero threshold":    {"1", 0, true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rule := security.NewBreachedPasswordRule(writeBreachCorpus(t, tc.count, "Tr0ub4dor&3-x"), tc.minCount)
+			violation := rule.Check(security.PasswordCandidate{Password: "Tr0ub4dor&3-x"})
+			if got := violation != nil; got != tc.want {
+				t.Errorf("Check = %v, want a violation: %v", violation, tc.want)
+			}
+		})
+	}
+}
+
+func TestBreachedPasswordRuleFailsOpen(t *testing.T) {
+	sum := sha1.Sum([]byte("Tr0ub4dor&3-x"))
+	prefix := strings.ToUpper(hex.EncodeToString(sum[:]))[:5]
+	corpus := t.TempDir()
+	// A range entry that cannot be read, and a missing corpus.
+	if err := os.Mkdir(filepath.Join(corpus, prefix), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []string{corpus, filepath.Join(corpus, "missing")} {
+		rule := security.NewBreachedPasswordRule(dir, 1)
+		if violation := rule.Check(security.PasswordCandidate{Password: "Tr0ub4dor&3-x"}); violation != nil {
+			t.Errorf("corpus %s: Check = %v, want the password let through", dir, violation)
+		}
+	}
+}
+
+func TestRegisterReportsEveryViolation(t *testing.T) {
+	env := newTestEnv(t)
+
+	_, _, err := env.svc.Register(&dto.RegisterRequest{
+		Email:              "ann@example.com",
+		FullName:           "Ann Lee",
+		Gender:             entities.GenderFemale,
+		DateOfBirth:        "1990-01-02",
+		RegistrationMethod: entities.RegMethodEmail,
+		Password:           "password",
+	})
+	got := violatedRules(t, err)
+	for _, want := range []string{entities.PasswordRuleUppercase, entities.PasswordRuleDigit, entities.PasswordRuleSpecial, entities.PasswordRuleCommon} {
+		if !strings.Contains(","+strings.Join(got, ",")+",", ","+want+",") {
+			t.Errorf("Register violated %v, want %s among them", got, want)
+		}
+	}
+	if _, err := env.repos.Users.FindByEmail("ann@example.com"); err == nil {
+		t.Error("the account was created")
+	}
+}
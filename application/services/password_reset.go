@@ -0,0 +1,112 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"ambassador/infrastructure/mail"
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// ForgotPassword emails a reset link to the account behind req.Email. It
+// returns nil whether or not such an account exists, so callers cannot use
+// it to find out which addresses are registered.
+func (s *AuthServiceImpl) ForgotPassword(req *dto.ForgotPasswordRequest) error {
+	user, err := s.userRepo.FindByEmail(req.Email)
+	if err != nil || !user.IsActive || user.RegistrationMethod != entities.RegMethodEmail {
+		return nil
+	}
+
+	if err := s.sendPasswordReset(user); err != nil {
+		log.Printf("password reset: failed to store token for user %s: %v", user.ID, err)
+	}
+	return nil
+}
+
+// sendPasswordReset replaces any outstanding reset link for user with a new
+// one and emails it.
+func (s *AuthServiceImpl) sendPasswordReset(user *entities.User) error {
+	secret, resetToken := entities.NewOneTimeToken(user.ID, entities.TokenTypePasswordReset, s.passwordResetTTL)
+
+	err := s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		if err := tx.Tokens.DeleteAllUserTokens(user.ID, entities.TokenTypePasswordReset); err != nil {
+			return err
+		}
+		return tx.Tokens.Save(resetToken)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.sendMail(user, mail.TemplatePasswordReset, map[string]interface{}{
+		"Link":             s.link("/reset-password", secret),
+		"ExpiresInMinutes": int(s.passwordResetTTL.Minutes()),
+	})
+	return nil
+}
+
+// ResetPassword consumes a reset token, sets the new password and signs the
+// user out everywhere.
+func (s *AuthServiceImpl) ResetPassword(req *dto.ResetPasswordRequest) error {
+	resetToken, err := s.tokenRepo.FindByValue(entities.HashTokenValue(req.Token))
+	if err != nil || resetToken.Type != entities.TokenTypePasswordReset {
+		return errors.New("invalid or expired reset token")
+	}
+
+	if resetToken.IsExpired() {
+		s.tokenRepo.Delete(resetToken.Value)
+		return errors.New("invalid or expired reset token")
+	}
+
+	user, err := s.userRepo.FindByID(resetToken.UserID)
+	if err != nil {
+		return errors.New("invalid or expired reset token")
+	}
+
+	if !user.IsActive {
+		return errors.New("account is deactivated")
+	}
+
+	if err := s.validateNewPassword(user, req.NewPassword); err != nil {
+		return err
+	}
+
+	passwordHash, err := s.hasher.HashPassword(req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	oldHash := user.PasswordHash
+	now := time.Now()
+	user.PasswordHash = passwordHash
+	user.PasswordChangedAt = now
+	user.UpdatedAt = now
+	// The reset link was delivered to the address, which proves ownership.
+	if !user.EmailVerified {
+		user.MarkEmailVerified()
+	}
+
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		if err := tx.Users.Save(user); err != nil {
+			return err
+		}
+		if err := s.rememberPassword(tx, user.ID, oldHash); err != nil {
+			return err
+		}
+		if err := tx.Tokens.Delete(resetToken.Value); err != nil {
+			return err
+		}
+		return revokeAllSessions(tx, user.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.audit.Record(entities.NewAuditEvent(entities.AuditPasswordReset, user.ID, req.Client, nil))
+	s.resetLoginThrottle(user)
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"errors"
+	"testing"
+)
+
+const newTestPassword = "Fresh-passw0rd"
+
+// forgotPassword asks for a reset link for email and returns its token.
+func (e *testEnv) forgotPassword(t *testing.T, email string) string {
+	t.Helper()
+	if err := e.svc.ForgotPassword(&dto.ForgotPasswordRequest{Email: email}); err != nil {
+		t.Fatalf("ForgotPassword: %v", err)
+	}
+	return e.mailedToken(t, email, "/reset-password")
+}
+
+func TestPasswordResetTokenIsHashedAndSingleUse(t *testing.T) {
+	env := newTestEnv(t)
+	env.register(t, "ann@example.com")
+	secret := env.forgotPassword(t, "ann@example.com")
+
+	if _, err := env.repos.Tokens.FindByValue(secret); err == nil {
+		t.Error("the reset token is stored in the clear")
+	}
+	if _, err := env.repos.Tokens.FindByValue(entities.HashTokenValue(secret)); err != nil {
+		t.Errorf("the reset token is not stored by its hash: %v", err)
+	}
+
+	if err := env.svc.ResetPassword(&dto.ResetPasswordRequest{Token: secret, NewPassword: newTestPassword}); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	if _, _, err := env.svc.Login(&dto.LoginRequest{Email: "ann@example.com", Password: newTestPassword}); err != nil {
+		t.Errorf("Login with the new password: %v", err)
+	}
+	if err := env.svc.ResetPassword(&dto.ResetPasswordRequest{Token: secret, NewPassword: "Other-passw0rd"}); err == nil {
+		t.Error("a used reset token was accepted again")
+	}
+}
+
+func TestPasswordResetReplacesEarlierLinks(t *testing.T) {
+	env := newTestEnv(t)
+	env.register(t, "ann@example.com")
+	first := env.forgotPassword(t, "ann@example.com")
+	env.forgotPassword(t, "ann@example.com")
+
+	if err := env.svc.ResetPassword(&dto.ResetPasswordRequest{Token: first, NewPassword: newTestPassword}); err == nil {
+		t.Error("a superseded reset link was accepted")
+	}
+}
+
+func TestPasswordResetRejectsExpiredToken(t *testing.T) {
+	env := newTestEnv(t)
+	env.register(t, "ann@example.com")
+	secret := env.forgotPassword(t, "ann@example.com")
+	env.expireToken(t, secret)
+
+	err := env.svc.ResetPassword(&dto.ResetPasswordRequest{Token: secret, NewPassword: newTestPassword})
+	if err == nil || err.Error() != "invalid or expired reset token" {
+		t.Errorf("ResetPassword with an expired token returned %v", err)
+	}
+}
+
+func TestPasswordResetRevokesSessionsAndRemembersPassword(t *testing.T) {
+	env := newTestEnv(t)
+	user, tokenPair := env.register(t, "ann@example.com")
+	oldHash := user.PasswordHash
+	secret := env.forgotPassword(t, "ann@example.com")
+
+	if err := env.svc.ResetPassword(&dto.ResetPasswordRequest{Token: secret, NewPassword: newTestPassword}); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+
+	if sessions, _ := env.svc.ListSessions(user.ID); len(sessions) != 0 {
+		t.Errorf("%d sessions survived the reset", len(sessions))
+	}
+	if _, err := env.svc.RefreshToken(&dto.RefreshTokenRequest{RefreshToken: tokenPair.RefreshToken.Value}); err == nil {
+		t.Error("a refresh token from before the reset still works")
+	}
+	entries, err := env.repos.PasswordHistory.FindRecent(user.ID, 5)
+	if err != nil || len(entries) != 1 || entries[0].PasswordHash != oldHash {
+		t.Errorf("password history = %v, %v; want the replaced hash", entries, err)
+	}
+}
+
+func TestPasswordResetIsOneUnitOfWork(t *testing.T) {
+	env := newTestEnv(t)
+	user, tokenPair := env.register(t, "ann@example.com")
+	secret := env.forgotPassword(t, "ann@example.com")
+	env.svc.uow = failingUnitOfWork{env.svc.uow}
+
+	if err := env.svc.ResetPassword(&dto.ResetPasswordRequest{Token: secret, NewPassword: newTestPassword}); !errors.Is(err, errCommitFailed) {
+		t.Fatalf("ResetPassword returned %v, want the commit failure", err)
+	}
+
+	stored, err := env.repos.Users.FindByID(user.ID)
+	if err != nil || stored.PasswordHash != user.PasswordHash {
+		t.Error("the password changed although the reset failed")
+	}
+	if entries, _ := env.repos.PasswordHistory.FindRecent(user.ID, 5); len(entries) != 0 {
+		t.Error("password history was written although the reset failed")
+	}
+	if _, err := env.repos.Sessions.FindByID(tokenPair.RefreshToken.FamilyID); err != nil {
+		t.Error("the session was revoked although the reset failed")
+	}
+	if _, err := env.repos.Tokens.FindByValue(entities.HashTokenValue(secret)); err != nil {
+		t.Error("the reset token was spent although the reset failed")
+	}
+}
+
+func TestForgotPasswordForUnknownAddressSendsNothing(t *testing.T) {
+	env := newTestEnv(t)
+
+	if err := env.svc.ForgotPassword(&dto.ForgotPasswordRequest{Email: "nobody@example.com"}); err != nil {
+		t.Errorf("ForgotPassword returned %v, want nil like for a known address", err)
+	}
+	if len(env.mailer.Messages()) != 0 {
+		t.Error("a reset link was mailed for an unknown address")
+	}
+}
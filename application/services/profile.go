@@ -0,0 +1,130 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UpdateProfile changes the personal details that are set in req, using the
+// same rules as registration.
+func (s *AuthServiceImpl) UpdateProfile(userID string, req *dto.UpdateProfileRequest) (*entities.User, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	var changed []string
+	if req.FullName != "" {
+		fullName, err := entities.NormalizeFullName(req.FullName)
+		if err != nil {
+			return nil, err
+		}
+		if fullName != user.FullName {
+			user.FullName = fullName
+			changed = append(changed, "fullName")
+		}
+	}
+	if req.Gender != "" && req.Gender != user.Gender {
+		user.Gender = req.Gender
+		changed = append(changed, "gender")
+	}
+	if req.DateOfBirth != "" {
+		if err := ValidateDateOfBirth(req.DateOfBirth); err != nil {
+			return nil, err
+		}
+		dob, _ := time.Parse("2006-01-02", req.DateOfBirth)
+		if !dob.Equal(user.DateOfBirth) {
+			user.DateOfBirth = dob
+			changed = append(changed, "dateOfBirth")
+		}
+	}
+
+	if len(changed) == 0 {
+		return user, nil
+	}
+
+	user.UpdatedAt = time.Now()
+	if err := s.userRepo.Save(user); err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(entities.NewAuditEvent(entities.AuditProfileUpdated, user.ID, req.Client, map[string]string{
+		"fields": strings.Join(changed, " "),
+	}))
+	return user, nil
+}
+
+// ChangePassword replaces the password of a signed-in user after checking
+// the current one. Every other session is signed out; currentSessionID
+// stays signed in. Wrong current passwords count against the account's
+// login throttle, so a stolen session cannot be used to guess it.
+func (s *AuthServiceImpl) ChangePassword(userID, currentSessionID string, req *dto.ChangePasswordRequest) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+
+	if user.RegistrationMethod != entities.RegMethodEmail {
+		return errors.New("account does not use a password")
+	}
+
+	throttleKey := entities.LoginThrottleKey(user.Email.String())
+	if err := s.checkLoginThrottle(throttleKey); err != nil {
+		return err
+	}
+	if !s.hasher.CheckPassword(req.CurrentPassword, user.PasswordHash) {
+		s.recordLoginFailure(throttleKey, user, req.Client)
+		return errors.New("current password is incorrect")
+	}
+	s.resetLoginThrottle(user)
+
+	if req.NewPassword == req.CurrentPassword {
+		return errors.New("new password must be different from the current password")
+	}
+
+	if err := s.validateNewPassword(user, req.NewPassword); err != nil {
+		return err
+	}
+
+	passwordHash, err := s.hasher.HashPassword(req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	oldHash := user.PasswordHash
+	now := time.Now()
+	user.PasswordHash = passwordHash
+	user.PasswordChangedAt = now
+	user.UpdatedAt = now
+
+	var revoked int
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		if err := tx.Users.Save(user); err != nil {
+			return err
+		}
+		if err := s.rememberPassword(tx, user.ID, oldHash); err != nil {
+			return err
+		}
+		// An outstanding reset link would let someone undo the change.
+		if err := tx.Tokens.DeleteAllUserTokens(user.ID, entities.TokenTypePasswordReset); err != nil {
+			return err
+		}
+		var err error
+		revoked, err = revokeOtherSessions(tx, user.ID, currentSessionID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.audit.Record(entities.NewAuditEvent(entities.AuditPasswordChanged, user.ID, req.Client, map[string]string{
+		"revokedSessions": strconv.Itoa(revoked),
+	}))
+	return nil
+}
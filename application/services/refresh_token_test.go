@@ -0,0 +1,99 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"testing"
+	"time"
+)
+
+func TestRefreshTokenRotatesWithinFamily(t *testing.T) {
+	env := newTestEnv(t)
+	_, first := env.register(t, "ann@example.com")
+
+	second, err := env.svc.RefreshToken(&dto.RefreshTokenRequest{RefreshToken: first.RefreshToken.Value})
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	if second.RefreshToken.Value == first.RefreshToken.Value {
+		t.Fatal("refresh token was not rotated")
+	}
+	if second.RefreshToken.FamilyID != first.RefreshToken.FamilyID {
+		t.Errorf("rotated token left the family: %s != %s", second.RefreshToken.FamilyID, first.RefreshToken.FamilyID)
+	}
+
+	rotated, err := env.repos.Tokens.FindByValue(first.RefreshToken.Value)
+	if err != nil {
+		t.Fatalf("rotated token was not kept for reuse detection: %v", err)
+	}
+	if !rotated.IsRotated() {
+		t.Error("exchanged token is not marked as rotated")
+	}
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	env := newTestEnv(t, WithRefreshReuseGrace(0))
+	user, first := env.register(t, "ann@example.com")
+
+	second, err := env.svc.RefreshToken(&dto.RefreshTokenRequest{RefreshToken: first.RefreshToken.Value})
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+
+	_, err = env.svc.RefreshToken(&dto.RefreshTokenRequest{RefreshToken: first.RefreshToken.Value})
+	if err == nil || err.Error() != "refresh token reuse detected" {
+		t.Fatalf("replaying a rotated token returned %v", err)
+	}
+
+	if _, err := env.svc.RefreshToken(&dto.RefreshTokenRequest{RefreshToken: second.RefreshToken.Value}); err == nil {
+		t.Error("the newest token of a replayed family still refreshes")
+	}
+	if _, err := env.repos.Sessions.FindByID(first.RefreshToken.FamilyID); err == nil {
+		t.Error("the session of a replayed family was not revoked")
+	}
+	if len(env.auditEvents(t, user.ID, entities.AuditRefreshTokenReuse)) != 1 {
+		t.Error("reuse was not audited")
+	}
+}
+
+func TestRefreshTokenReuseWithinGraceIsAllowed(t *testing.T) {
+	env := newTestEnv(t, WithRefreshReuseGrace(time.Minute))
+	_, first := env.register(t, "ann@example.com")
+
+	if _, err := env.svc.RefreshToken(&dto.RefreshTokenRequest{RefreshToken: first.RefreshToken.Value}); err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	// A client racing two refreshes sends the same token twice.
+	if _, err := env.svc.RefreshToken(&dto.RefreshTokenRequest{RefreshToken: first.RefreshToken.Value}); err != nil {
+		t.Errorf("second refresh within the grace period failed: %v", err)
+	}
+}
+
+func TestPurgeExpiredTokensRemovesRotatedTokens(t *testing.T) {
+	env := newTestEnv(t)
+	_, first := env.register(t, "ann@example.com")
+
+	second, err := env.svc.RefreshToken(&dto.RefreshTokenRequest{RefreshToken: first.RefreshToken.Value})
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+
+	rotated, err := env.repos.Tokens.FindByValue(first.RefreshToken.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated.ExpiresAt = time.Now().Add(-time.Second)
+	if err := env.repos.Tokens.Save(rotated); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := env.svc.PurgeExpiredTokens(); err != nil {
+		t.Fatalf("PurgeExpiredTokens: %v", err)
+	}
+	if _, err := env.repos.Tokens.FindByValue(rotated.Value); err == nil {
+		t.Error("expired rotated token was not purged")
+	}
+	if _, err := env.repos.Tokens.FindByValue(second.RefreshToken.Value); err != nil {
+		t.Errorf("live refresh token was purged: %v", err)
+	}
+}
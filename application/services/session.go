@@ -0,0 +1,172 @@
+package services
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"context"
+	"errors"
+	"strconv"
+)
+
+// newSession issues the TokenPair for a completed login together with the
+// Session it starts. Other sessions of the user are left alone.
+func (s *AuthServiceImpl) newSession(user *entities.User, deviceName string, client entities.ClientInfo) (*entities.TokenPair, *entities.Session, error) {
+	tokenPair, err := s.newTokenPair(user, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	return tokenPair, entities.NewSession(tokenPair.RefreshToken, deviceName, client), nil
+}
+
+func (s *AuthServiceImpl) saveSession(tx *repositories.TxRepositories, tokenPair *entities.TokenPair, session *entities.Session) error {
+	if err := s.saveTokenPair(tx.Tokens, tokenPair); err != nil {
+		return err
+	}
+	return tx.Sessions.Save(session)
+}
+
+// startSession signs user in on a new session.
+func (s *AuthServiceImpl) startSession(user *entities.User, deviceName string, client entities.ClientInfo) (*entities.TokenPair, error) {
+	tokenPair, session, err := s.newSession(user, deviceName, client)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		return s.saveSession(tx, tokenPair, session)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tokenPair, nil
+}
+
+// revokeSession deletes a session along with every token in its family.
+func revokeSession(tx *repositories.TxRepositories, sessionID string) error {
+	if err := tx.Tokens.DeleteByFamily(sessionID); err != nil {
+		return err
+	}
+	return tx.Sessions.Delete(sessionID)
+}
+
+// revokeAllSessions signs the user out on every device.
+func revokeAllSessions(tx *repositories.TxRepositories, userID string) error {
+	if err := tx.Tokens.DeleteAllUserTokens(userID, entities.TokenTypeAccess); err != nil {
+		return err
+	}
+	if err := tx.Tokens.DeleteAllUserTokens(userID, entities.TokenTypeRefresh); err != nil {
+		return err
+	}
+	return tx.Sessions.DeleteByUserID(userID)
+}
+
+// ListSessions returns the user's active sessions, most recently used first.
+func (s *AuthServiceImpl) ListSessions(userID string) ([]*entities.Session, error) {
+	sessions, err := s.sessionRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	active := make([]*entities.Session, 0, len(sessions))
+	for _, session := range sessions {
+		if !session.IsExpired() {
+			active = append(active, session)
+		}
+	}
+	return active, nil
+}
+
+// PurgeExpiredSessions deletes sessions that can no longer be resumed. Their
+// refresh tokens have expired as well and go with PurgeExpiredTokens.
+func (s *AuthServiceImpl) PurgeExpiredSessions() error {
+	return s.sessionRepo.DeleteExpired()
+}
+
+func (s *AuthServiceImpl) RevokeSession(userID, sessionID string, client entities.ClientInfo) error {
+	session, err := s.sessionRepo.FindByID(sessionID)
+	if err != nil || session.UserID != userID {
+		return errors.New("session not found")
+	}
+
+	err = s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		return revokeSession(tx, session.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.audit.Record(entities.NewAuditEvent(entities.AuditSessionRevoked, userID, client, map[string]string{
+		"sessionId": session.ID,
+	}))
+
+	return nil
+}
+
+// RevokeOtherSessions logs the user out everywhere except currentSessionID
+// and returns how many sessions were ended.
+func (s *AuthServiceImpl) RevokeOtherSessions(userID, currentSessionID string, client entities.ClientInfo) (int, error) {
+	if currentSessionID == "" {
+		return 0, errors.New("current session is unknown, please log in again")
+	}
+
+	var revoked int
+	err := s.uow.Do(context.Background(), func(tx *repositories.TxRepositories) error {
+		var err error
+		revoked, err = revokeOtherSessions(tx, userID, currentSessionID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if revoked > 0 {
+		s.audit.Record(entities.NewAuditEvent(entities.AuditSessionRevoked, userID, client, map[string]string{
+			"keptSessionId": currentSessionID,
+			"count":         strconv.Itoa(revoked),
+		}))
+	}
+
+	return revoked, nil
+}
+
+// revokeOtherSessions ends every session of the user but currentSessionID
+// and returns how many were ended.
+func revokeOtherSessions(tx *repositories.TxRepositories, userID, currentSessionID string) (int, error) {
+	sessions, err := tx.Sessions.FindByUserID(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	revoked := 0
+	for _, session := range sessions {
+		if session.ID == currentSessionID {
+			continue
+		}
+		if err := revokeSession(tx, session.ID); err != nil {
+			return 0, err
+		}
+		revoked++
+	}
+
+	// Refresh tokens from before sessions were tracked may not have one.
+	refreshTokens, err := tx.Tokens.FindByUserID(userID, entities.TokenTypeRefresh)
+	if err != nil {
+		return 0, err
+	}
+	for _, token := range refreshTokens {
+		if token.FamilyID == currentSessionID {
+			continue
+		}
+		if token.FamilyID == "" {
+			if err := tx.Tokens.Delete(token.Value); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if err := tx.Tokens.DeleteByFamily(token.FamilyID); err != nil {
+			return 0, err
+		}
+	}
+	return revoked, nil
+}
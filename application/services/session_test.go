@@ -0,0 +1,151 @@
+package services
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"testing"
+	"time"
+)
+
+func TestListSessionsReturnsOnlyActiveSessions(t *testing.T) {
+	env := newTestEnv(t)
+	user, first := env.register(t, "ann@example.com")
+	second, err := env.svc.startSession(user, "laptop", entities.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := env.register(t, "bob@example.com")
+	if _, err := env.svc.startSession(other, "", entities.ClientInfo{}); err != nil {
+		t.Fatal(err)
+	}
+
+	expired, err := env.repos.Sessions.FindByID(first.RefreshToken.FamilyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired.ExpiresAt = time.Now().Add(-time.Second)
+	if err := env.repos.Sessions.Save(expired); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := env.svc.ListSessions(user.ID)
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != second.RefreshToken.FamilyID || sessions[0].DeviceName != "laptop" {
+		t.Errorf("ListSessions = %v, want only the live laptop session", sessions)
+	}
+}
+
+func TestRevokeSessionEndsOnlyThatSession(t *testing.T) {
+	env := newTestEnv(t)
+	user, revoked := env.register(t, "ann@example.com")
+	kept, err := env.svc.startSession(user, "", entities.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := env.svc.RevokeSession(user.ID, revoked.RefreshToken.FamilyID, entities.ClientInfo{}); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	if _, err := env.repos.Sessions.FindByID(revoked.RefreshToken.FamilyID); err == nil {
+		t.Error("revoked session is still listed")
+	}
+	if _, err := env.svc.RefreshToken(&dto.RefreshTokenRequest{RefreshToken: revoked.RefreshToken.Value}); err == nil {
+		t.Error("refresh token of the revoked session still works")
+	}
+	if _, err := env.svc.RefreshToken(&dto.RefreshTokenRequest{RefreshToken: kept.RefreshToken.Value}); err != nil {
+		t.Errorf("refresh token of the other session: %v", err)
+	}
+	if events := env.auditEvents(t, user.ID, entities.AuditSessionRevoked); len(events) != 1 {
+		t.Errorf("recorded %d session revocations, want 1", len(events))
+	}
+}
+
+func TestRevokeSessionOfAnotherUserIsNotFound(t *testing.T) {
+	env := newTestEnv(t)
+	ann, _ := env.register(t, "ann@example.com")
+	_, bobs := env.register(t, "bob@example.com")
+
+	for name, sessionID := range map[string]string{"another user's session": bobs.RefreshToken.FamilyID, "unknown session": "missing"} {
+		err := env.svc.RevokeSession(ann.ID, sessionID, entities.ClientInfo{})
+		if err == nil || err.Error() != "session not found" {
+			t.Errorf("revoking %s returned %v, want session not found", name, err)
+		}
+	}
+	if _, err := env.repos.Sessions.FindByID(bobs.RefreshToken.FamilyID); err != nil {
+		t.Errorf("another user's session was revoked: %v", err)
+	}
+}
+
+func TestRevokeOtherSessionsKeepsCurrentSession(t *testing.T) {
+	env := newTestEnv(t)
+	user, current := env.register(t, "ann@example.com")
+	var others []*entities.TokenPair
+	for i := 0; i < 2; i++ {
+		other, err := env.svc.startSession(user, "", entities.ClientInfo{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		others = append(others, other)
+	}
+	_, bobs := env.register(t, "bob@example.com")
+
+	revoked, err := env.svc.RevokeOtherSessions(user.ID, current.RefreshToken.FamilyID, entities.ClientInfo{})
+	if err != nil {
+		t.Fatalf("RevokeOtherSessions: %v", err)
+	}
+	if revoked != len(others) {
+		t.Errorf("revoked %d sessions, want %d", revoked, len(others))
+	}
+	for _, other := range others {
+		if _, err := env.svc.RefreshToken(&dto.RefreshTokenRequest{RefreshToken: other.RefreshToken.Value}); err == nil {
+			t.Error("refresh token of another session still works")
+		}
+	}
+	for name, kept := range map[string]*entities.TokenPair{"current session": current, "another user's session": bobs} {
+		if _, err := env.repos.Sessions.FindByID(kept.RefreshToken.FamilyID); err != nil {
+			t.Errorf("%s was revoked: %v", name, err)
+		}
+	}
+}
+
+func TestRevokeOtherSessionsNeedsCurrentSession(t *testing.T) {
+	env := newTestEnv(t)
+	user, tokenPair := env.register(t, "ann@example.com")
+
+	if _, err := env.svc.RevokeOtherSessions(user.ID, "", entities.ClientInfo{}); err == nil {
+		t.Fatal("RevokeOtherSessions without a current session succeeded")
+	}
+	if _, err := env.repos.Sessions.FindByID(tokenPair.RefreshToken.FamilyID); err != nil {
+		t.Errorf("session was revoked: %v", err)
+	}
+}
+
+func TestPurgeExpiredSessions(t *testing.T) {
+	env := newTestEnv(t)
+	user, expired := env.register(t, "ann@example.com")
+	live, err := env.svc.startSession(user, "", entities.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := env.repos.Sessions.FindByID(expired.RefreshToken.FamilyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.ExpiresAt = time.Now().Add(-time.Second)
+	if err := env.repos.Sessions.Save(session); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := env.svc.PurgeExpiredSessions(); err != nil {
+		t.Fatalf("PurgeExpiredSessions: %v", err)
+	}
+	if _, err := env.repos.Sessions.FindByID(session.ID); err == nil {
+		t.Error("expired session was not purged")
+	}
+	if _, err := env.repos.Sessions.FindByID(live.RefreshToken.FamilyID); err != nil {
+		t.Errorf("live session was purged: %v", err)
+	}
+}
@@ -1,32 +1,258 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"time"
 	"ambassador/application/services"
+	domainservices "ambassador/domain/services"
+	"ambassador/domain/entities"
+	domainrepos "ambassador/domain/repositories"
+	"ambassador/infrastructure/audit"
+	"ambassador/infrastructure/config"
+	"ambassador/infrastructure/mail"
+	"ambassador/infrastructure/oauth"
+	"ambassador/infrastructure/passkey"
 	"ambassador/infrastructure/repositories"
 	"ambassador/infrastructure/security"
 	"ambassador/interfaces/http/handlers"
 	"ambassador/interfaces/http/middleware"
+	"ambassador/internal/shared/database"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 )
 
 func main() {
+	cfg := config.Load()
+
 	// Initialize repositories and services
-	userRepo := repositories.NewMemoryUserRepository()
-	tokenRepo := repositories.NewMemoryTokenRepository()
+	var (
+		userRepo     domainrepos.UserRepository
+		tokenRepo    domainrepos.TokenRepository
+		sessionRepo  domainrepos.SessionRepository
+		identityRepo domainrepos.IdentityRepository
+		mfaRepo      domainrepos.MFARepository
+		webAuthnRepo domainrepos.WebAuthnRepository
+		throttleRepo domainrepos.LoginThrottleRepository
+		auditRepo    domainrepos.AuditRepository
+		historyRepo  domainrepos.PasswordHistoryRepository
+		uow          domainrepos.UnitOfWork
+	)
+	switch cfg.Database.Driver {
+	case "memory":
+		userRepo = repositories.NewMemoryUserRepository()
+		tokenRepo = repositories.NewMemoryTokenRepository()
+		sessionRepo = repositories.NewMemorySessionRepository()
+		identityRepo = repositories.NewMemoryIdentityRepository()
+		mfaRepo = repositories.NewMemoryMFARepository()
+		webAuthnRepo = repositories.NewMemoryWebAuthnRepository()
+		throttleRepo = repositories.NewMemoryLoginThrottleRepository()
+		auditRepo = repositories.NewMemoryAuditRepository()
+		historyRepo = repositories.NewMemoryPasswordHistoryRepository()
+		uow = repositories.NewMemoryUnitOfWork(domainrepos.TxRepositories{
+			Users:           userRepo,
+			Tokens:          tokenRepo,
+			Identities:      identityRepo,
+			MFA:             mfaRepo,
+			Sessions:        sessionRepo,
+			PasswordHistory: historyRepo,
+			WebAuthn:        webAuthnRepo,
+		})
+	default:
+		driver := cfg.Database.Driver
+		if driver == "postgres" {
+			driver = database.DriverPostgres
+		}
+
+		db, err := database.Open(driver, cfg.Database.DSN, database.PoolConfig{
+			MaxOpenConns:    cfg.Database.MaxOpenConns,
+			MaxIdleConns:    cfg.Database.MaxIdleConns,
+			ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+			ConnMaxIdleTime: cfg.Database.ConnMaxIdleTime,
+		})
+		if err != nil {
+			log.Fatalf("failed to open database: %v", err)
+		}
+		defer db.Close()
+
+		if err := database.Migrate(db, driver, database.AuthMigrations); err != nil {
+			log.Fatalf("failed to run migrations: %v", err)
+		}
+
+		timeout := cfg.Database.QueryTimeout
+		userRepo = repositories.NewSQLUserRepository(db, driver, timeout)
+		tokenRepo = repositories.NewSQLTokenRepository(db, driver, timeout)
+		sessionRepo = repositories.NewSQLSessionRepository(db, driver, timeout)
+		identityRepo = repositories.NewSQLIdentityRepository(db, driver, timeout)
+		mfaRepo = repositories.NewSQLMFARepository(db, driver, timeout)
+		webAuthnRepo = repositories.NewSQLWebAuthnRepository(db, driver, timeout)
+		throttleRepo = repositories.NewSQLLoginThrottleRepository(db, driver, timeout)
+		auditRepo = repositories.NewSQLAuditRepository(db, driver, timeout)
+		historyRepo = repositories.NewSQLPasswordHistoryRepository(db, driver, timeout)
+		uow = repositories.NewSQLUnitOfWork(db, driver, timeout)
+	}
 	// expenseRepo := repositories.NewMemoryExpenseRepository()
 	// groupRepo := repositories.NewMemoryGroupRepository()
-	hasher := security.NewBcryptHasher()
+	hasher, err := loadPasswordHasher(cfg.Password)
+	if err != nil {
+		log.Fatalf("failed to configure password hashing: %v", err)
+	}
 	validator := middleware.NewValidator()
 	rateLimiter := middleware.NewRateLimiter(100, time.Minute, tokenRepo)
 
-	authService := services.NewAuthService(userRepo, tokenRepo, hasher)
+	authOpts := []services.AuthServiceOption{
+		services.WithRefreshReuseGrace(cfg.Token.RefreshReuseGrace),
+		services.WithLinkBaseURL(cfg.App.BaseURL),
+		services.WithPasswordResetTTL(cfg.App.PasswordResetTTL),
+		services.WithEmailChangeTTL(cfg.App.EmailChangeTTL, cfg.App.EmailRevertTTL),
+		services.WithAccountDeletion(cfg.App.AccountDeletionGrace),
+	}
+	passwordPolicy, err := loadPasswordPolicy(cfg.Password)
+	if err != nil {
+		log.Fatalf("failed to configure password policy: %v", err)
+	}
+	authOpts = append(authOpts, services.WithPasswordPolicy(passwordPolicy), services.WithPasswordHistory(historyRepo, cfg.Password.MaxAge))
+	switch policy := services.UnverifiedLoginPolicy(cfg.App.UnverifiedLoginPolicy); policy {
+	case services.UnverifiedLoginAllow, services.UnverifiedLoginLimited, services.UnverifiedLoginBlock:
+		authOpts = append(authOpts, services.WithEmailVerification(policy, cfg.App.EmailVerificationTTL))
+	default:
+		log.Fatalf("unknown unverified login policy %q", policy)
+	}
+	mailer, mailTemplates, err := loadMailer(cfg.Mail)
+	if err != nil {
+		log.Fatalf("failed to configure mail: %v", err)
+	}
+	authOpts = append(authOpts, services.WithMailer(mailer), services.WithMailTemplates(mailTemplates))
+
+	auditLogger, auditQueryable, err := loadAuditLogger(cfg.Audit, auditRepo)
+	if err != nil {
+		log.Fatalf("failed to configure audit log: %v", err)
+	}
+	authOpts = append(authOpts, services.WithAuditLogger(auditLogger))
+	if auditQueryable {
+		authOpts = append(authOpts, services.WithAuditHistory(auditRepo))
+	}
+
+	var secretBox *security.SecretBox
+	if cfg.MFA.SecretKey != "" {
+		secretBox, err = security.NewSecretBox(cfg.MFA.SecretKey)
+		if err != nil {
+			log.Fatalf("invalid MFA_SECRET_KEY: %v", err)
+		}
+	} else {
+		log.Println("MFA_SECRET_KEY is not set, TOTP secrets will be stored unencrypted")
+	}
+	authOpts = append(authOpts, services.WithMFA(mfaRepo, cfg.MFA.Issuer, secretBox, cfg.MFA.ChallengeTTL))
+
+	if cfg.MagicLink.Enabled {
+		var linkSigner *security.LinkSigner
+		if cfg.MagicLink.SigningKey != "" {
+			linkSigner, err = security.NewLinkSigner(cfg.MagicLink.SigningKey)
+			if err != nil {
+				log.Fatalf("invalid MAGIC_LINK_SIGNING_KEY: %v", err)
+			}
+		} else {
+			log.Println("MAGIC_LINK_SIGNING_KEY is not set, magic links will stop working when the server restarts")
+			linkSigner, err = security.NewEphemeralLinkSigner()
+			if err != nil {
+				log.Fatalf("failed to create magic link signing key: %v", err)
+			}
+		}
+		authOpts = append(authOpts, services.WithMagicLinks(linkSigner, cfg.MagicLink.TTL))
+	}
+
+	authOpts = append(authOpts, services.WithLockout(throttleRepo, services.LockoutPolicy{
+		Threshold:    cfg.Lockout.Threshold,
+		Duration:     cfg.Lockout.Duration,
+		BackoffAfter: cfg.Lockout.BackoffAfter,
+		BackoffBase:  cfg.Lockout.BackoffBase,
+		BackoffMax:   cfg.Lockout.BackoffMax,
+		ResetAfter:   cfg.Lockout.ResetAfter,
+		UnlockTTL:    cfg.Lockout.UnlockTTL,
+	}))
+
+	if cfg.WebAuthn.RPID != "" {
+		relyingParty, err := passkey.NewRelyingParty(passkey.Config{
+			RPID:          cfg.WebAuthn.RPID,
+			RPDisplayName: cfg.WebAuthn.RPName,
+			Origins:       cfg.WebAuthn.Origins,
+			Timeout:       cfg.WebAuthn.Timeout,
+		})
+		if err != nil {
+			log.Fatalf("failed to configure passkeys: %v", err)
+		}
+		authOpts = append(authOpts, services.WithPasskeys(webAuthnRepo, relyingParty))
+	}
+
+	verifiers, err := loadIDTokenVerifiers(cfg.OAuth)
+	if err != nil {
+		log.Fatalf("failed to configure OAuth providers: %v", err)
+	}
+	authOpts = append(authOpts, services.WithIdentityProviders(identityRepo, verifiers))
+
+	var (
+		keyManager *security.KeyManager
+		jwtIssuer  *security.JWTIssuer
+	)
+	if cfg.Token.Format == "jwt" {
+		var err error
+		keyManager, err = loadSigningKeys(cfg.Token)
+		if err != nil {
+			log.Fatalf("failed to load signing keys: %v", err)
+		}
+		jwtIssuer = security.NewJWTIssuer(keyManager, security.JWTConfig{
+			Issuer:   cfg.Token.Issuer,
+			Audience: cfg.Token.Audience,
+			TTL:      cfg.Token.AccessTTL,
+		})
+		authOpts = append(authOpts, services.WithAccessTokenIssuer(jwtIssuer))
+	}
+
+	policy, err := loadPolicy(cfg.RBAC)
+	if err != nil {
+		log.Fatalf("failed to load access policy: %v", err)
+	}
+	authOpts = append(authOpts, services.WithAccessPolicy(policy))
+
+	authService := services.NewAuthService(userRepo, tokenRepo, sessionRepo, uow, hasher, authOpts...)
 	// expenseService := services.NewExpenseService(expenseRepo, groupRepo, userRepo, tokenRepo)
 	// groupService := services.NewGroupService(groupRepo, userRepo, tokenRepo)
 
-	authHandler := handlers.NewAuthHandler(authService, validator)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var purges sync.WaitGroup
+	purges.Add(2)
+	go func() {
+		defer purges.Done()
+		purgeDeletedAccounts(ctx, authService, cfg.App.AccountPurgeInterval)
+	}()
+	go func() {
+		defer purges.Done()
+		purgeExpired(ctx, authService, cfg.App.TokenPurgeInterval)
+	}()
+
+	authz := middleware.NewAuthorizer(authService, policy, cfg.Admin.APIToken)
+
+	sessionCookies, err := loadSessionCookies(cfg.Cookies)
+	if err != nil {
+		log.Fatalf("failed to configure session cookies: %v", err)
+	}
+	if sessionCookies != nil && len(cfg.Server.AllowedOrigins) == 0 {
+		log.Println("CORS_ALLOWED_ORIGINS is empty, session cookies only work for same-origin browser clients")
+	}
+	authHandler := handlers.NewAuthHandler(authService, validator, sessionCookies)
+	mfaHandler := handlers.NewMFAHandler(authService, validator, sessionCookies)
+	passkeyHandler := handlers.NewPasskeyHandler(authService, validator, sessionCookies)
+	sessionHandler := handlers.NewSessionHandler(authService)
 	// expenseHandler := handlers.NewExpenseHandler(expenseService, validator)
 	// groupHandler := handlers.NewGroupHandler(groupService, validator)
 
@@ -34,21 +260,90 @@ func main() {
 	r := gin.New()
 
 	// Apply global middleware
-	r.Use(middleware.CORS())
+	r.Use(middleware.CORS(cfg.Server.AllowedOrigins))
 	r.Use(middleware.RequestID())
+	r.Use(middleware.CSRF(sessionCookies))
 	r.Use(gin.Recovery())
 
+	if jwtIssuer != nil {
+		r.GET("/.well-known/jwks.json", handlers.NewJWKSHandler(jwtIssuer).JWKS)
+	}
+
 	// Define route group for API
 	api := r.Group("/api/v1")
+	{
+		admin := api.Group("/admin")
+		if keyManager != nil {
+			keyHandler := handlers.NewKeyHandler(keyManager, validator)
+			admin.GET("/keys", authz.RequirePermission(entities.PermissionKeysManage), keyHandler.List)
+			admin.POST("/keys", authz.RequirePermission(entities.PermissionKeysManage), keyHandler.Add)
+			admin.POST("/keys/:kid/promote", authz.RequirePermission(entities.PermissionKeysManage), keyHandler.Promote)
+			admin.POST("/keys/:kid/retire", authz.RequirePermission(entities.PermissionKeysManage), keyHandler.Retire)
+		}
+
+		adminUserHandler := handlers.NewAdminUserHandler(authService, validator)
+		admin.GET("/users", authz.RequirePermission(entities.PermissionUsersRead), adminUserHandler.List)
+		admin.GET("/users/:id", authz.RequirePermission(entities.PermissionUsersRead), adminUserHandler.Get)
+		admin.POST("/users/:id/deactivate", authz.RequirePermission(entities.PermissionUsersWrite), adminUserHandler.Deactivate)
+		admin.POST("/users/:id/reactivate", authz.RequirePermission(entities.PermissionUsersWrite), adminUserHandler.Reactivate)
+		admin.POST("/users/:id/logout", authz.RequirePermission(entities.PermissionUsersWrite), adminUserHandler.ForceLogout)
+		admin.POST("/users/:id/password-reset", authz.RequirePermission(entities.PermissionUsersWrite), adminUserHandler.ForcePasswordReset)
+		admin.PUT("/users/:id/roles", authz.RequirePermission(entities.PermissionUsersWrite), adminUserHandler.SetRoles)
+		admin.POST("/users/:id/unlock", authz.RequirePermission(entities.PermissionUsersWrite), adminUserHandler.Unlock)
+
+		if auditQueryable {
+			auditHandler := handlers.NewAuditHandler(authService)
+			admin.GET("/audit", authz.RequirePermission(entities.PermissionAuditRead), auditHandler.List)
+		}
+	}
 	{
 		// Public routes
 		api.POST("/auth/register", rateLimiter.Middleware(), authHandler.Register)
 		api.POST("/auth/login", rateLimiter.Middleware(), authHandler.Login)
+		api.POST("/auth/oauth/nonce", rateLimiter.Middleware(), authHandler.OAuthNonce)
+		api.POST("/auth/oauth/login", rateLimiter.Middleware(), authHandler.OAuthLogin)
 		api.POST("/auth/refresh", authHandler.RefreshToken)
+		api.POST("/auth/password/forgot", rateLimiter.Middleware(), authHandler.ForgotPassword)
+		api.POST("/auth/password/reset", rateLimiter.Middleware(), authHandler.ResetPassword)
+		api.POST("/auth/password/expired", rateLimiter.Middleware(), authHandler.ChangeExpiredPassword)
+		api.POST("/auth/email/verify", rateLimiter.Middleware(), authHandler.VerifyEmail)
+		api.POST("/auth/email/resend", rateLimiter.Middleware(), authHandler.ResendVerification)
+		api.POST("/auth/email/change/confirm", rateLimiter.Middleware(), authHandler.ConfirmEmailChange)
+		api.POST("/auth/email/change/revert", rateLimiter.Middleware(), authHandler.RevertEmailChange)
+		api.POST("/auth/account/restore", rateLimiter.Middleware(), authHandler.RestoreAccount)
+		api.GET("/auth/password/policy", rateLimiter.Middleware(), authHandler.PasswordPolicy)
+		api.POST("/auth/unlock", rateLimiter.Middleware(), authHandler.UnlockAccount)
+		api.POST("/auth/magic-link", rateLimiter.Middleware(), authHandler.RequestMagicLink)
+		api.POST("/auth/magic-link/consume", rateLimiter.Middleware(), authHandler.ConsumeMagicLink)
+		api.POST("/auth/mfa/verify", rateLimiter.Middleware(), mfaHandler.Verify)
+		api.POST("/auth/passkeys/login/begin", rateLimiter.Middleware(), passkeyHandler.BeginLogin)
+		api.POST("/auth/passkeys/login/finish", rateLimiter.Middleware(), passkeyHandler.FinishLogin)
 
 		// Protected routes
-		api.GET("/auth/me", rateLimiter.UserAccessTokenMiddleware(authService), authHandler.Profile)
-		api.POST("/auth/logout", rateLimiter.UserRefreshTokenMiddleware(authService), authHandler.Logout)
+		api.GET("/auth/me", rateLimiter.UserAccessTokenMiddleware(authService), middleware.RequireScope(authService, entities.ScopeProfileRead), authHandler.Profile)
+		api.PATCH("/auth/me", rateLimiter.UserAccessTokenMiddleware(authService), middleware.RequireScope(authService, entities.ScopeAccountWrite), authHandler.UpdateProfile)
+		api.DELETE("/auth/me", rateLimiter.UserAccessTokenMiddleware(authService), middleware.RequireScope(authService, entities.ScopeAccountWrite), authHandler.DeleteAccount)
+		api.GET("/auth/me/export", rateLimiter.UserAccessTokenMiddleware(authService), middleware.RequireScope(authService, entities.ScopeAccountWrite), authHandler.ExportAccount)
+		api.POST("/auth/password/change", rateLimiter.UserAccessTokenMiddleware(authService), middleware.RequireScope(authService, entities.ScopeAccountWrite), authHandler.ChangePassword)
+		api.POST("/auth/email/change", rateLimiter.UserAccessTokenMiddleware(authService), middleware.RequireScope(authService, entities.ScopeAccountWrite), authHandler.RequestEmailChange)
+		api.POST("/auth/logout", rateLimiter.UserRefreshTokenMiddleware(authService, sessionCookies), authHandler.Logout)
+
+		mfa := api.Group("/auth/mfa", rateLimiter.UserAccessTokenMiddleware(authService), middleware.RequireScope(authService, entities.ScopeAccountWrite))
+		mfa.POST("/totp/enroll", mfaHandler.EnrollTOTP)
+		mfa.POST("/totp/confirm", mfaHandler.ConfirmTOTP)
+		mfa.POST("/totp/disable", mfaHandler.DisableTOTP)
+		mfa.POST("/recovery-codes/regenerate", mfaHandler.RegenerateRecoveryCodes)
+
+		passkeys := api.Group("/auth/passkeys", rateLimiter.UserAccessTokenMiddleware(authService), middleware.RequireScope(authService, entities.ScopeAccountWrite))
+		passkeys.GET("", passkeyHandler.List)
+		passkeys.POST("/register/begin", passkeyHandler.BeginRegistration)
+		passkeys.POST("/register/finish", passkeyHandler.FinishRegistration)
+		passkeys.DELETE("/:id", passkeyHandler.Delete)
+
+		sessions := api.Group("/auth/sessions", rateLimiter.UserAccessTokenMiddleware(authService), middleware.RequireScope(authService, entities.ScopeAccountWrite))
+		sessions.GET("", sessionHandler.List)
+		sessions.DELETE("/:id", sessionHandler.Revoke)
+		sessions.POST("/revoke-others", sessionHandler.RevokeOthers)
 		// api.POST("/expense/add", middleware.GinUserAccessTokenMiddleware(authService), expenseHandler.GinAddExpense)
 		// api.PUT("/expense/update", middleware.GinUserAccessTokenMiddleware(authService), expenseHandler.GinUpdateExpense)
 		// api.DELETE("/expense/delete", middleware.GinUserAccessTokenMiddleware(authService), expenseHandler.GinDeleteExpense)
@@ -57,15 +352,358 @@ func main() {
 
 	// Create HTTP server
 	server := &http.Server{
-		Addr:         ":9090",
+		Addr:         cfg.Server.Addr,
 		Handler:      r,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
 
-	log.Println("Server running on :9090")
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatal(err)
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+	log.Printf("Server running on %s", cfg.Server.Addr)
+
+	var listenErr error
+	select {
+	case listenErr = <-serverErr:
+	case <-ctx.Done():
+		log.Printf("shutting down")
+	}
+	stop()
+
+	// Finish in-flight requests and purges before draining the mail queue,
+	// so nothing queues mail behind the drain; the database is closed last.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("failed to finish in-flight requests: %v", err)
+	}
+	purges.Wait()
+	if err := mailer.Close(shutdownCtx); err != nil {
+		log.Printf("failed to deliver queued mail: %v", err)
+	}
+	if listenErr != nil {
+		log.Fatal(listenErr)
+	}
+}
+
+// purgeDeletedAccounts removes accounts whose deletion grace period has
+// passed, once at startup and then every interval until ctx is done.
+func purgeDeletedAccounts(ctx context.Context, authService *services.AuthServiceImpl, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		purged, err := authService.PurgeDeletedAccounts()
+		if err != nil {
+			log.Printf("account purge failed: %v", err)
+		} else if purged > 0 {
+			log.Printf("purged %d deleted accounts", purged)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// purgeExpired deletes expired tokens and sessions, once at startup and then
+// every interval until ctx is done.
+func purgeExpired(ctx context.Context, authService *services.AuthServiceImpl, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := authService.PurgeExpiredTokens(); err != nil {
+			log.Printf("token purge failed: %v", err)
+		}
+		if err := authService.PurgeExpiredSessions(); err != nil {
+			log.Printf("session purge failed: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
 	}
-}
\ No newline at end of file
+}
+
+// loadPasswordHasher hashes new passwords with the configured algorithm and
+// still verifies hashes made by the others.
+func loadPasswordHasher(cfg config.PasswordConfig) (security.PasswordHasher, error) {
+	if cfg.Argon2Memory <= 0 || cfg.Argon2Iterations <= 0 || cfg.Argon2Parallelism <= 0 || cfg.Argon2Parallelism > 255 {
+		return nil, errors.New("argon2 memory, iterations and parallelism (at most 255) must be positive")
+	}
+	argon2id := security.NewArgon2idHasher(security.Argon2Params{
+		Memory:      uint32(cfg.Argon2Memory),
+		Iterations:  uint32(cfg.Argon2Iterations),
+		Parallelism: uint8(cfg.Argon2Parallelism),
+	})
+	bcrypt := security.NewBcryptHasher()
+
+	switch cfg.HashAlgorithm {
+	case "argon2id":
+		return security.NewMultiHasher(argon2id, bcrypt), nil
+	case "bcrypt":
+		return security.NewMultiHasher(bcrypt, argon2id), nil
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm %q", cfg.HashAlgorithm)
+	}
+}
+
+// loadPasswordPolicy builds the configured requirements and screens new
+// passwords against the common password blocklist and, when configured,
+// the local breach corpus.
+func loadPasswordPolicy(cfg config.PasswordConfig) (*security.PasswordPolicy, error) {
+	requirements := entities.PasswordRequirements{
+		MinLength:        cfg.MinLength,
+		MaxLength:        cfg.MaxLength,
+		RequireUppercase: cfg.RequireUppercase,
+		RequireLowercase: cfg.RequireLowercase,
+		RequireDigit:     cfg.RequireDigit,
+		RequireSpecial:   cfg.RequireSpecial,
+		SpecialChars:     entities.PasswordSpecialCharacters,
+		MinEntropyBits:   cfg.MinEntropyBits,
+		HistoryDepth:     cfg.HistoryDepth,
+	}
+	if requirements.MaxLength == 0 {
+		requirements.MaxLength = 128
+		if cfg.HashAlgorithm == "bcrypt" {
+			requirements.MaxLength = security.BcryptMaxPasswordBytes
+		}
+	}
+	if requirements.MinLength < 1 || requirements.MaxLength < requirements.MinLength {
+		return nil, fmt.Errorf("password length limits %d to %d are invalid", requirements.MinLength, requirements.MaxLength)
+	}
+	// bcrypt's limit is in bytes, so 72 characters can still be too long.
+	if cfg.HashAlgorithm == "bcrypt" {
+		if requirements.MaxLength > security.BcryptMaxPasswordBytes {
+			return nil, fmt.Errorf("the maximum password length must be at most %d with bcrypt", security.BcryptMaxPasswordBytes)
+		}
+		requirements.MaxBytes = security.BcryptMaxPasswordBytes
+	}
+	if requirements.MinEntropyBits < 0 || requirements.HistoryDepth < 0 {
+		return nil, errors.New("password entropy and history depth must not be negative")
+	}
+
+	commonPasswords := security.DefaultCommonPasswordRule()
+	if cfg.BlocklistFile != "" {
+		var err error
+		commonPasswords, err = security.LoadCommonPasswordRule(cfg.BlocklistFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rules := []security.PasswordRule{security.PersonalInfoRule{}, commonPasswords}
+	if cfg.BreachCorpusDir != "" {
+		info, err := os.Stat(cfg.BreachCorpusDir)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("breach corpus %s is not a directory", cfg.BreachCorpusDir)
+		}
+		rules = append(rules, security.NewBreachedPasswordRule(cfg.BreachCorpusDir, cfg.BreachMinCount))
+	}
+	return security.NewPasswordPolicy(requirements, rules...), nil
+}
+
+// loadSessionCookies returns the cookie settings for browser clients, or
+// nil when cookie mode is disabled.
+func loadSessionCookies(cfg config.CookieConfig) (*middleware.SessionCookies, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	cookies := &middleware.SessionCookies{
+		RefreshName: cfg.RefreshName,
+		CSRFName:    cfg.CSRFName,
+		Domain:      cfg.Domain,
+		RefreshPath: cfg.RefreshPath,
+		Secure:      cfg.Secure,
+	}
+	switch strings.ToLower(cfg.SameSite) {
+	case "strict":
+		cookies.SameSite = http.SameSiteStrictMode
+	case "lax":
+		cookies.SameSite = http.SameSiteLaxMode
+	case "none":
+		// Browsers drop SameSite=None cookies that are not Secure.
+		if !cfg.Secure {
+			return nil, errors.New("SameSite=None session cookies must be Secure")
+		}
+		cookies.SameSite = http.SameSiteNoneMode
+	default:
+		return nil, fmt.Errorf("unknown SameSite mode %q", cfg.SameSite)
+	}
+	if cfg.RefreshName == "" || cfg.CSRFName == "" || cfg.RefreshName == cfg.CSRFName {
+		return nil, errors.New("the refresh and CSRF cookies need two different names")
+	}
+	if !cfg.Secure {
+		log.Println("SESSION_COOKIE_SECURE is off, session cookies will be sent over plain HTTP")
+	}
+	return cookies, nil
+}
+
+// loadMailer builds the configured transport behind a retrying queue, so
+// request handlers never wait on delivery.
+func loadMailer(cfg config.MailConfig) (*mail.Queue, *mail.Renderer, error) {
+	var (
+		transport mail.Mailer
+		err       error
+	)
+	switch cfg.Transport {
+	case "log":
+		transport = mail.NewLogMailer()
+	case "file":
+		transport, err = mail.NewFileMailer(cfg.OutboxDir, cfg.From)
+	case "smtp":
+		transport, err = mail.NewSMTPMailer(mail.SMTPConfig{
+			Host:     cfg.SMTP.Host,
+			Port:     cfg.SMTP.Port,
+			Username: cfg.SMTP.Username,
+			Password: cfg.SMTP.Password,
+			From:     cfg.From,
+			Security: cfg.SMTP.Security,
+			Timeout:  cfg.SMTP.Timeout,
+		})
+	default:
+		err = fmt.Errorf("unknown mail transport %q", cfg.Transport)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	templates := mail.DefaultTemplates()
+	if cfg.TemplateDir != "" {
+		templates = os.DirFS(cfg.TemplateDir)
+	}
+	renderer, err := mail.NewRenderer(templates, cfg.DefaultLocale)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	queue := mail.NewQueue(transport, mail.QueueConfig{
+		Size:        cfg.QueueSize,
+		Workers:     cfg.QueueWorkers,
+		MaxAttempts: cfg.MaxAttempts,
+		Backoff:     cfg.RetryBackoff,
+	})
+	return queue, renderer, nil
+}
+
+// loadAuditLogger fans audit events out to every configured sink. The
+// returned flag reports whether events are stored where /admin/audit can
+// query them.
+func loadAuditLogger(cfg config.AuditConfig, repo domainrepos.AuditRepository) (domainservices.AuditLogger, bool, error) {
+	var (
+		loggers   []domainservices.AuditLogger
+		queryable bool
+	)
+	for _, sink := range cfg.Sinks {
+		switch sink {
+		case "log":
+			loggers = append(loggers, audit.NewLogAuditLogger())
+		case "file":
+			fileLogger, err := audit.NewFileAuditLogger(cfg.File)
+			if err != nil {
+				return nil, false, err
+			}
+			loggers = append(loggers, fileLogger)
+		case "database":
+			loggers = append(loggers, audit.NewRepositoryAuditLogger(repo))
+			queryable = true
+		default:
+			return nil, false, fmt.Errorf("unknown audit sink %q", sink)
+		}
+	}
+	return audit.NewMultiAuditLogger(loggers...), queryable, nil
+}
+
+// loadPolicy reads the role to permissions table, falling back to the
+// built-in policy when no file is configured.
+func loadPolicy(cfg config.RBACConfig) (entities.Policy, error) {
+	if cfg.PolicyFile == "" {
+		return entities.DefaultPolicy(), nil
+	}
+
+	data, err := os.ReadFile(cfg.PolicyFile)
+	if err != nil {
+		return nil, err
+	}
+	var policy entities.Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("%s: %w", cfg.PolicyFile, err)
+	}
+	if !policy.HasRole(entities.RoleUser) {
+		return nil, fmt.Errorf("%s: the %q role must be defined", cfg.PolicyFile, entities.RoleUser)
+	}
+	return policy, nil
+}
+
+func loadSigningKeys(cfg config.TokenConfig) (*security.KeyManager, error) {
+	keys := security.NewKeyManager(cfg.KeyGracePeriod, cfg.KeyDir)
+
+	if cfg.KeyDir != "" {
+		if err := keys.LoadDir(cfg.KeyDir); err != nil {
+			return nil, err
+		}
+	}
+	if err := keys.LoadFiles(cfg.PrivateKeyFiles, cfg.SigningAlg); err != nil {
+		return nil, err
+	}
+
+	if _, err := keys.SigningKey(); err != nil {
+		if cfg.KeyDir == "" {
+			log.Println("no JWT signing keys configured, generating an ephemeral key")
+		}
+		key, err := security.GenerateSigningKey(cfg.SigningAlg)
+		if err != nil {
+			return nil, err
+		}
+		if err := keys.Add(key, true); err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
+
+func loadIDTokenVerifiers(cfg config.OAuthConfig) (map[entities.RegistrationMethod]oauth.IDTokenVerifier, error) {
+	verifiers := make(map[entities.RegistrationMethod]oauth.IDTokenVerifier)
+
+	providers := []struct {
+		method   entities.RegistrationMethod
+		cfg      config.OAuthProviderConfig
+		defaults func([]string) oauth.ProviderConfig
+	}{
+		{entities.RegMethodGoogle, cfg.Google, oauth.GoogleProviderConfig},
+		{entities.RegMethodApple, cfg.Apple, oauth.AppleProviderConfig},
+	}
+	for _, p := range providers {
+		if len(p.cfg.ClientIDs) == 0 {
+			continue
+		}
+
+		providerCfg := p.defaults(p.cfg.ClientIDs)
+		if len(p.cfg.Issuers) > 0 {
+			// A custom issuer usually means a mock; let discovery find its keys
+			// unless a JWKS URL is given too.
+			providerCfg.Issuers = p.cfg.Issuers
+			providerCfg.JWKSURL = ""
+		}
+		if p.cfg.JWKSURL != "" {
+			providerCfg.JWKSURL = p.cfg.JWKSURL
+		}
+
+		verifier, err := oauth.NewOIDCVerifier(providerCfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p.method, err)
+		}
+		verifiers[p.method] = verifier
+	}
+
+	return verifiers, nil
+}
@@ -0,0 +1,77 @@
+package entities
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+type AuditEventType string
+
+const (
+	AuditUserRegistered      AuditEventType = "user_registered"
+	AuditLoginSucceeded      AuditEventType = "login_succeeded"
+	AuditLoginFailed         AuditEventType = "login_failed"
+	AuditTokenRefreshed      AuditEventType = "token_refreshed"
+	AuditLogout              AuditEventType = "logout"
+	AuditRefreshTokenReuse   AuditEventType = "refresh_token_reuse_detected"
+	AuditPasswordReset       AuditEventType = "password_reset"
+	AuditPasswordChanged     AuditEventType = "password_changed"
+	AuditProfileUpdated      AuditEventType = "profile_updated"
+	AuditEmailVerified       AuditEventType = "email_verified"
+	AuditEmailChangeStarted  AuditEventType = "email_change_requested"
+	AuditEmailChanged        AuditEventType = "email_changed"
+	AuditEmailChangeReverted AuditEventType = "email_change_reverted"
+	AuditMFAEnabled          AuditEventType = "mfa_enabled"
+	AuditMFADisabled         AuditEventType = "mfa_disabled"
+	AuditMFARecoveryReset    AuditEventType = "mfa_recovery_codes_regenerated"
+	AuditMFARecoveryUsed     AuditEventType = "mfa_recovery_code_used"
+	AuditPasskeyAdded        AuditEventType = "passkey_added"
+	AuditPasskeyRemoved      AuditEventType = "passkey_removed"
+	AuditSessionRevoked      AuditEventType = "session_revoked"
+	AuditAccountLocked       AuditEventType = "account_locked"
+	AuditAccountUnlocked     AuditEventType = "account_unlocked"
+	AuditUserDeactivated     AuditEventType = "user_deactivated"
+	AuditUserReactivated     AuditEventType = "user_reactivated"
+	AuditUserForcedLogout    AuditEventType = "user_forced_logout"
+	AuditPasswordResetForced AuditEventType = "password_reset_forced"
+	AuditRolesChanged        AuditEventType = "roles_changed"
+	AuditAccountDeleted      AuditEventType = "account_deletion_requested"
+	AuditAccountRestored     AuditEventType = "account_restored"
+	AuditAccountPurged       AuditEventType = "account_purged"
+	AuditAccountExported     AuditEventType = "account_exported"
+	AuditMagicLinkRequested  AuditEventType = "magic_link_requested"
+)
+
+// AuditEvent records a security relevant action taken by or against a user,
+// along with the client and request it came from. ActorID is the signed-in
+// user who made the request, which differs from UserID when an
+// administrator acted on the account.
+type AuditEvent struct {
+	ID        string            `json:"id"`
+	Type      AuditEventType    `json:"type"`
+	UserID    string            `json:"userId,omitempty"`
+	ActorID   string            `json:"actorId,omitempty"`
+	IPAddress string            `json:"ipAddress,omitempty"`
+	UserAgent string            `json:"userAgent,omitempty"`
+	RequestID string            `json:"requestId,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+func NewAuditEvent(eventType AuditEventType, userID string, client ClientInfo, details map[string]string) *AuditEvent {
+	idBytes := make([]byte, 16)
+	rand.Read(idBytes)
+
+	return &AuditEvent{
+		ID:        hex.EncodeToString(idBytes),
+		Type:      eventType,
+		UserID:    userID,
+		ActorID:   client.ActorID,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		RequestID: client.RequestID,
+		Details:   details,
+		CreatedAt: time.Now().UTC(),
+	}
+}
@@ -0,0 +1,12 @@
+package entities
+
+import "time"
+
+// Challenge is returned by Login instead of a TokenPair when the user has to
+// complete another step first. Token is the plaintext secret the client
+// presents to finish the step; only its hash is stored.
+type Challenge struct {
+	Type      TokenType `json:"type"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,23 @@
+package entities
+
+import "time"
+
+// ExternalIdentity links a user to an account at an OAuth provider, keyed by
+// the provider's stable subject identifier rather than the email address.
+type ExternalIdentity struct {
+	Provider  RegistrationMethod `json:"provider"`
+	Subject   string             `json:"subject"`
+	UserID    string             `json:"userId"`
+	Email     string             `json:"email"`
+	CreatedAt time.Time          `json:"createdAt"`
+}
+
+func NewExternalIdentity(provider RegistrationMethod, subject, userID, email string) *ExternalIdentity {
+	return &ExternalIdentity{
+		Provider:  provider,
+		Subject:   subject,
+		UserID:    userID,
+		Email:     email,
+		CreatedAt: time.Now(),
+	}
+}
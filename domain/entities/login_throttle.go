@@ -0,0 +1,34 @@
+package entities
+
+import (
+	"strings"
+	"time"
+)
+
+// LoginThrottle tracks failed password logins for one email address. It is
+// keyed by the address rather than the user so that unknown addresses are
+// throttled exactly like real accounts.
+type LoginThrottle struct {
+	Key          string    `json:"key"`
+	FailedCount  int       `json:"failedCount"`
+	LastFailedAt time.Time `json:"lastFailedAt"`
+	LockedUntil  time.Time `json:"lockedUntil,omitempty"`
+}
+
+func LoginThrottleKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (t *LoginThrottle) IsLocked() bool {
+	return time.Now().Before(t.LockedUntil)
+}
+
+// LoginThrottledError is returned when a login is refused without checking
+// the password.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return "too many failed login attempts, please try again later"
+}
@@ -0,0 +1,82 @@
+package entities
+
+import (
+	"crypto/rand"
+	"strings"
+	"time"
+)
+
+const RecoveryCodeCount = 10
+
+// TOTPCredential is a user's authenticator app enrollment. It only counts as
+// enabled once ConfirmedAt is set, i.e. after the user has proven they can
+// produce a code. Secret may be sealed at rest by the service.
+type TOTPCredential struct {
+	UserID      string    `json:"userId"`
+	Secret      string    `json:"-"`
+	ConfirmedAt time.Time `json:"confirmedAt,omitempty"`
+	// LastUsedStep is the time step of the last accepted code, so the same
+	// code cannot be replayed within its validity window.
+	LastUsedStep int64     `json:"-"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+func NewTOTPCredential(userID, secret string) *TOTPCredential {
+	return &TOTPCredential{
+		UserID:    userID,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}
+}
+
+func (c *TOTPCredential) IsConfirmed() bool {
+	return !c.ConfirmedAt.IsZero()
+}
+
+// RecoveryCode is a single-use fallback for a lost authenticator. Only the
+// hash of the code is stored.
+type RecoveryCode struct {
+	UserID    string    `json:"userId"`
+	CodeHash  string    `json:"-"`
+	UsedAt    time.Time `json:"usedAt,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (c *RecoveryCode) IsUsed() bool {
+	return !c.UsedAt.IsZero()
+}
+
+// recoveryCodeAlphabet leaves out characters that are easily confused when
+// read off paper, such as 0/O and 1/I.
+const recoveryCodeAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+// NewRecoveryCodes returns RecoveryCodeCount codes formatted as XXXXX-XXXXX
+// alongside the records to store for them.
+func NewRecoveryCodes(userID string) ([]string, []*RecoveryCode) {
+	now := time.Now()
+	codes := make([]string, RecoveryCodeCount)
+	records := make([]*RecoveryCode, RecoveryCodeCount)
+
+	for i := range codes {
+		raw := make([]byte, 10)
+		rand.Read(raw)
+		for j, b := range raw {
+			raw[j] = recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)]
+		}
+		codes[i] = string(raw[:5]) + "-" + string(raw[5:])
+		records[i] = &RecoveryCode{
+			UserID:    userID,
+			CodeHash:  HashRecoveryCode(codes[i]),
+			CreatedAt: now,
+		}
+	}
+
+	return codes, records
+}
+
+// HashRecoveryCode normalises case and separators before hashing, so users
+// can type a code however they like.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return HashTokenValue(normalized)
+}
@@ -0,0 +1,12 @@
+package entities
+
+import "time"
+
+// PasswordHistoryEntry is a password hash a user has stopped using. Entries
+// are kept so that the password cannot be changed back to a recent one.
+type PasswordHistoryEntry struct {
+	ID           string    `json:"id"`
+	UserID       string    `json:"userId"`
+	PasswordHash string    `json:"-"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
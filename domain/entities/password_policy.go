@@ -0,0 +1,181 @@
+package entities
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PasswordSpecialCharacters are the characters that count as special.
+const PasswordSpecialCharacters = `!@#$%^&*()_+-=[]{}|;:,.<>?`
+
+// Password rule identifiers reported in PasswordViolation.Rule.
+const (
+	PasswordRuleMinLength    = "min_length"
+	PasswordRuleMaxLength    = "max_length"
+	PasswordRuleUppercase    = "uppercase"
+	PasswordRuleLowercase    = "lowercase"
+	PasswordRuleDigit        = "digit"
+	PasswordRuleSpecial      = "special"
+	PasswordRuleEntropy      = "entropy"
+	PasswordRulePersonalInfo = "personal_info"
+	PasswordRuleCommon       = "common"
+	PasswordRuleBreached     = "breached"
+	PasswordRuleReused       = "reused"
+)
+
+// PasswordRequirements are the composition rules a new password must meet.
+// They are served as they are to clients that render the rules.
+type PasswordRequirements struct {
+	MinLength        int    `json:"minLength"`
+	MaxLength        int    `json:"maxLength"`
+	RequireUppercase bool   `json:"requireUppercase"`
+	RequireLowercase bool   `json:"requireLowercase"`
+	RequireDigit     bool   `json:"requireDigit"`
+	RequireSpecial   bool   `json:"requireSpecial"`
+	SpecialChars     string `json:"specialCharacters"`
+	// MinEntropyBits is the least strength PasswordEntropy may estimate;
+	// zero disables the check.
+	MinEntropyBits int `json:"minEntropyBits"`
+	// HistoryDepth is how many previous passwords may not be reused.
+	HistoryDepth int `json:"historyDepth"`
+	// MaxBytes caps the UTF-8 encoded length for hashes that only read so
+	// many bytes, such as bcrypt; zero means no cap.
+	MaxBytes int `json:"maxBytes,omitempty"`
+}
+
+func DefaultPasswordRequirements() PasswordRequirements {
+	return PasswordRequirements{
+		MinLength:        8,
+		MaxLength:        128,
+		RequireUppercase: true,
+		RequireLowercase: true,
+		RequireDigit:     true,
+		RequireSpecial:   true,
+		SpecialChars:     PasswordSpecialCharacters,
+		MinEntropyBits:   40,
+		HistoryDepth:     5,
+	}
+}
+
+// PasswordViolation is one rule a password fails.
+type PasswordViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError lists every rule a password fails.
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		messages = append(messages, violation.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Check returns every requirement password does not meet. Lengths count
+// characters, not bytes, except for MaxBytes.
+func (r PasswordRequirements) Check(password string) []PasswordViolation {
+	var violations []PasswordViolation
+	add := func(rule, message string) {
+		violations = append(violations, PasswordViolation{Rule: rule, Message: message})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < r.MinLength {
+		add(PasswordRuleMinLength, fmt.Sprintf("password must be at least %d characters long", r.MinLength))
+	}
+	if r.MaxLength > 0 && length > r.MaxLength {
+		add(PasswordRuleMaxLength, fmt.Sprintf("password must be at most %d characters long", r.MaxLength))
+	} else if r.MaxBytes > 0 && len(password) > r.MaxBytes {
+		add(PasswordRuleMaxLength, fmt.Sprintf("password must be at most %d bytes long, and characters outside ASCII take several", r.MaxBytes))
+	}
+	if r.RequireUppercase && !strings.ContainsFunc(password, unicode.IsUpper) {
+		add(PasswordRuleUppercase, "password must contain at least one uppercase letter")
+	}
+	if r.RequireLowercase && !strings.ContainsFunc(password, unicode.IsLower) {
+		add(PasswordRuleLowercase, "password must contain at least one lowercase letter")
+	}
+	if r.RequireDigit && !strings.ContainsFunc(password, unicode.IsDigit) {
+		add(PasswordRuleDigit, "password must contain at least one number")
+	}
+	if r.RequireSpecial && !strings.ContainsAny(password, r.specialChars()) {
+		add(PasswordRuleSpecial, "password must contain at least one special character")
+	}
+	if r.MinEntropyBits > 0 && PasswordEntropy(password) < float64(r.MinEntropyBits) {
+		add(PasswordRuleEntropy, "password is too easy to guess, make it longer or less repetitive")
+	}
+	return violations
+}
+
+func (r PasswordRequirements) specialChars() string {
+	if r.SpecialChars == "" {
+		return PasswordSpecialCharacters
+	}
+	return r.SpecialChars
+}
+
+// PasswordEntropy estimates the strength of a password in bits. Each
+// character is worth log2 of the alphabet the password draws from, except
+// that repeating the previous character or continuing a run such as "abc"
+// or "321" is worth a single bit.
+func PasswordEntropy(password string) float64 {
+	var lower, upper, digit, other bool
+	for _, c := range password {
+		switch {
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsDigit(c):
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	pool := 0
+	if lower {
+		pool += 26
+	}
+	if upper {
+		pool += 26
+	}
+	if digit {
+		pool += 10
+	}
+	if other {
+		pool += len(PasswordSpecialCharacters)
+	}
+	if pool == 0 {
+		return 0
+	}
+	bitsPerChar := math.Log2(float64(pool))
+
+	var (
+		bits float64
+		prev rune
+		step rune
+	)
+	for i, c := range []rune(password) {
+		switch {
+		case i > 0 && c == prev:
+			bits++
+			step = 0
+		case i > 0 && (c-prev == 1 || c-prev == -1) && (step == 0 || step == c-prev):
+			bits++
+			step = c - prev
+		default:
+			bits += bitsPerChar
+			step = 0
+		}
+		prev = c
+	}
+	return bits
+}
@@ -0,0 +1,60 @@
+package entities
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPasswordRequirementsMaxBytesCountsEncodedLength(t *testing.T) {
+	r := PasswordRequirements{MinLength: 8, MaxLength: 72, MaxBytes: 72}
+
+	// 40 characters, 80 bytes: within MaxLength but past what bcrypt reads.
+	password := strings.Repeat("é", 40)
+	violations := r.Check(password)
+	if len(violations) != 1 || violations[0].Rule != PasswordRuleMaxLength {
+		t.Errorf("Check(%d bytes) = %v, want a max_length violation", len(password), violations)
+	}
+
+	if violations := r.Check(strings.Repeat("é", 36)); len(violations) != 0 {
+		t.Errorf("Check(72 bytes) = %v", violations)
+	}
+}
+
+func TestPasswordRequirementsCollectsEveryViolation(t *testing.T) {
+	r := DefaultPasswordRequirements()
+
+	cases := map[string]struct {
+		password string
+		want     []string
+	}{
+		"acceptable":           {"Tr0ub4dor&3-x", nil},
+		"empty":                {"", []string{PasswordRuleMinLength, PasswordRuleUppercase, PasswordRuleLowercase, PasswordRuleDigit, PasswordRuleSpecial, PasswordRuleEntropy}},
+		"short":                {"Ab1!", []string{PasswordRuleMinLength, PasswordRuleEntropy}},
+		"too long":             {"Ab1!" + strings.Repeat("xq7Z", 32), []string{PasswordRuleMaxLength}},
+		"lowercase only":       {"correcthorse", []string{PasswordRuleUppercase, PasswordRuleDigit, PasswordRuleSpecial}},
+		"no special character": {"CorrectHorse7", []string{PasswordRuleSpecial}},
+		"repetitive":           {"Aaaaaaaa1!", []string{PasswordRuleEntropy}},
+		"run of characters":    {"Abcdefgh1!", []string{PasswordRuleEntropy}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			for _, violation := range r.Check(tc.password) {
+				got = append(got, violation.Rule)
+			}
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("Check(%q) violated %v, want %v", tc.password, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyErrorListsEveryMessage(t *testing.T) {
+	err := &PasswordPolicyError{Violations: []PasswordViolation{
+		{Rule: PasswordRuleCommon, Message: "password is too common"},
+		{Rule: PasswordRuleDigit, Message: "password must contain at least one number"},
+	}}
+	if got, want := err.Error(), "password is too common; password must contain at least one number"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,50 @@
+package entities
+
+import "strings"
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+const (
+	PermissionUsersRead  = "users:read"
+	PermissionUsersWrite = "users:write"
+	PermissionAuditRead  = "audit:read"
+	PermissionKeysManage = "keys:manage"
+)
+
+// DefaultRoles is what every new account starts with.
+var DefaultRoles = []string{RoleUser}
+
+// Policy maps each role to the permissions it grants. "*" grants every
+// permission and "users:*" every permission on users.
+type Policy map[string][]string
+
+// DefaultPolicy is used when no policy is configured: admins may do
+// anything and ordinary users hold no extra permissions.
+func DefaultPolicy() Policy {
+	return Policy{
+		RoleAdmin: {"*"},
+		RoleUser:  {},
+	}
+}
+
+// Allows reports whether any of roles grants permission.
+func (p Policy) Allows(roles []string, permission string) bool {
+	resource, _, _ := strings.Cut(permission, ":")
+	for _, role := range roles {
+		for _, granted := range p[role] {
+			if granted == "*" || granted == permission || granted == resource+":*" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasRole reports whether the policy defines role.
+func (p Policy) HasRole(role string) bool {
+	_, ok := p[role]
+	return ok
+}
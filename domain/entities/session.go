@@ -0,0 +1,61 @@
+package entities
+
+import "time"
+
+// ClientInfo describes the client a request came from.
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+	// RequestID correlates audit events with the request logs.
+	RequestID string
+	// ActorID is the signed-in user making the request, which differs from
+	// the account acted on when an administrator is at work. It is empty
+	// for anonymous requests and ones made with the admin API token.
+	ActorID string
+}
+
+// Session is one signed-in device. Its ID is the FamilyID shared by the
+// refresh tokens rotated from the login that started it, so revoking a
+// session is a matter of deleting that token family.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"userId"`
+	DeviceName string    `json:"deviceName,omitempty"`
+	UserAgent  string    `json:"userAgent,omitempty"`
+	IPAddress  string    `json:"ipAddress,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	// ExpiresAt follows the newest refresh token, after which the session
+	// can no longer be resumed.
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func NewSession(refreshToken *Token, deviceName string, client ClientInfo) *Session {
+	return &Session{
+		ID:         refreshToken.FamilyID,
+		UserID:     refreshToken.UserID,
+		DeviceName: deviceName,
+		UserAgent:  client.UserAgent,
+		IPAddress:  client.IPAddress,
+		CreatedAt:  refreshToken.CreatedAt,
+		LastUsedAt: refreshToken.CreatedAt,
+		ExpiresAt:  refreshToken.ExpiresAt,
+	}
+}
+
+// Touch records that the session was refreshed from client and now lasts
+// until the new refresh token expires.
+func (s *Session) Touch(refreshToken *Token, client ClientInfo) {
+	s.LastUsedAt = refreshToken.CreatedAt
+	s.ExpiresAt = refreshToken.ExpiresAt
+	if client.UserAgent != "" {
+		s.UserAgent = client.UserAgent
+	}
+	if client.IPAddress != "" {
+		s.IPAddress = client.IPAddress
+	}
+}
+
+func (s *Session) IsExpired() bool {
+	return time.Now().After(s.ExpiresAt)
+}
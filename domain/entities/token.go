@@ -2,6 +2,7 @@ package entities
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"time"
 )
@@ -9,16 +10,58 @@ import (
 type TokenType string
 
 const (
-	TokenTypeAccess  TokenType = "access"
-	TokenTypeRefresh TokenType = "refresh"
+	TokenTypeAccess          TokenType = "access"
+	TokenTypeRefresh         TokenType = "refresh"
+	TokenTypePasswordReset   TokenType = "password_reset"
+	TokenTypeEmailVerify     TokenType = "email_verification"
+	TokenTypeMFAPending      TokenType = "mfa_pending"
+	TokenTypeAccountUnlock   TokenType = "account_unlock"
+	TokenTypeEmailChange     TokenType = "email_change"
+	TokenTypeEmailRevert     TokenType = "email_change_revert"
+	TokenTypeAccountRestore  TokenType = "account_restore"
+	TokenTypePasswordExpired TokenType = "password_expired"
+	TokenTypeMagicLink       TokenType = "magic_link"
+	TokenTypeOAuthNonce      TokenType = "oauth_nonce"
 )
 
+const (
+	ScopeProfileRead = "profile:read"
+	ScopeEmailVerify = "email:verify"
+	// ScopeAccountWrite covers changes to the account's own security
+	// settings, such as enrolling an authenticator.
+	ScopeAccountWrite = "account:write"
+)
+
+// LimitedScopes is what an access token grants to a user who has not yet
+// verified their email address when the policy allows a restricted login.
+var LimitedScopes = []string{ScopeProfileRead, ScopeEmailVerify}
+
 type Token struct {
 	Value     string    `json:"token"`
 	UserID    string    `json:"userId"`
 	Type      TokenType `json:"type"`
 	ExpiresAt time.Time `json:"expiresAt"`
 	CreatedAt time.Time `json:"createdAt"`
+	// FamilyID links every refresh token descended from the same login, along
+	// with the access tokens issued beside them.
+	FamilyID string `json:"familyId,omitempty"`
+	// RotatedAt is set once a refresh token has been exchanged. Rotated tokens
+	// are kept until they expire so that a replay can be recognised.
+	RotatedAt time.Time `json:"rotatedAt,omitempty"`
+	// Scopes restricts what an access token may be used for. A nil slice
+	// means the token is unrestricted.
+	Scopes []string `json:"scopes,omitempty"`
+	// Roles are the owner's roles when an access token was issued.
+	Roles []string `json:"roles,omitempty"`
+	// Subject is what a one-time token acts on, such as the address an
+	// email change confirms.
+	Subject string `json:"subject,omitempty"`
+}
+
+func NewTokenFamilyID() string {
+	familyBytes := make([]byte, 16)
+	rand.Read(familyBytes)
+	return hex.EncodeToString(familyBytes)
 }
 
 func NewAccessToken(userID string) *Token {
@@ -36,6 +79,10 @@ func NewAccessToken(userID string) *Token {
 }
 
 func NewRefreshToken(userID string) *Token {
+	return NewRefreshTokenInFamily(userID, NewTokenFamilyID())
+}
+
+func NewRefreshTokenInFamily(userID, familyID string) *Token {
 	tokenBytes := make([]byte, 32)
 	rand.Read(tokenBytes)
 	tokenValue := hex.EncodeToString(tokenBytes)
@@ -46,9 +93,48 @@ func NewRefreshToken(userID string) *Token {
 		Type:      TokenTypeRefresh,
 		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
 		CreatedAt: time.Now(),
+		FamilyID:  familyID,
+	}
+}
+
+// NewOneTimeToken creates a single-use token whose secret is handed to the
+// user out of band. Only the SHA-256 of the secret is kept in Value, so a
+// leaked token table cannot be used to take over accounts.
+func NewOneTimeToken(userID string, tokenType TokenType, ttl time.Duration) (string, *Token) {
+	secretBytes := make([]byte, 32)
+	rand.Read(secretBytes)
+	secret := hex.EncodeToString(secretBytes)
+
+	return secret, &Token{
+		Value:     HashTokenValue(secret),
+		UserID:    userID,
+		Type:      tokenType,
+		ExpiresAt: time.Now().Add(ttl),
+		CreatedAt: time.Now(),
 	}
 }
 
+func HashTokenValue(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
 func (t *Token) IsExpired() bool {
 	return time.Now().After(t.ExpiresAt)
+}
+
+func (t *Token) HasScope(scope string) bool {
+	if t.Scopes == nil {
+		return true
+	}
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Token) IsRotated() bool {
+	return !t.RotatedAt.IsZero()
 }
\ No newline at end of file
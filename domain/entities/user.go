@@ -51,6 +51,52 @@ type User struct {
 	CreatedAt          time.Time          `json:"created_at"`
 	UpdatedAt          time.Time          `json:"updated_at"`
 	IsActive           bool               `json:"is_active"`
+	EmailVerified      bool               `json:"email_verified"`
+	EmailVerifiedAt    time.Time          `json:"email_verified_at"`
+	// Locale selects the language of emails sent to the user, e.g. "en" or
+	// "pt-br". Empty means the service default.
+	Locale             string             `json:"locale"`
+	// Roles decide which permissions the user holds under the access policy.
+	Roles              []string           `json:"roles"`
+	// DeletedAt is when the user asked for the account to be deleted. It
+	// stays restorable until it is purged; zero means it is not deleted.
+	DeletedAt          time.Time          `json:"deleted_at"`
+	// PasswordChangedAt is when the password was last set. Zero means it
+	// has not changed since the account was created.
+	PasswordChangedAt  time.Time          `json:"password_changed_at"`
+}
+
+func (u *User) IsDeleted() bool {
+	return !u.DeletedAt.IsZero()
+}
+
+// PasswordExpired reports whether the password is older than maxAge. A zero
+// maxAge means passwords never expire.
+func (u *User) PasswordExpired(maxAge time.Duration) bool {
+	if maxAge <= 0 || u.PasswordHash == "" {
+		return false
+	}
+	changedAt := u.PasswordChangedAt
+	if changedAt.IsZero() {
+		changedAt = u.CreatedAt
+	}
+	return time.Since(changedAt) > maxAge
+}
+
+func (u *User) HasRole(role string) bool {
+	for _, r := range u.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *User) MarkEmailVerified() {
+	now := time.Now()
+	u.EmailVerified = true
+	u.EmailVerifiedAt = now
+	u.UpdatedAt = now
 }
 
 func NewUser(email, fullName string, gender Gender, dateOfBirth time.Time, regMethod RegistrationMethod, passwordHash string) (*User, error) {
@@ -59,13 +105,9 @@ func NewUser(email, fullName string, gender Gender, dateOfBirth time.Time, regMe
 		return nil, err
 	}
 
-	cleanedFullName := strings.TrimSpace(fullName)
-	if cleanedFullName == "" {
-		return nil, errors.New("full name is required")
-	}
-	nameRegex := regexp.MustCompile(`^[a-zA-Z\s\-\']+$`)
-	if !nameRegex.MatchString(cleanedFullName) {
-		return nil, errors.New("full name contains invalid characters")
+	cleanedFullName, err := NormalizeFullName(fullName)
+	if err != nil {
+		return nil, err
 	}
 
 	now := time.Now()
@@ -79,5 +121,20 @@ func NewUser(email, fullName string, gender Gender, dateOfBirth time.Time, regMe
 		CreatedAt:          now,
 		UpdatedAt:          now,
 		IsActive:           true,
+		Roles:              append([]string(nil), DefaultRoles...),
 	}, nil
+}
+
+// NormalizeFullName trims a full name and checks it only contains letters,
+// spaces, hyphens and apostrophes.
+func NormalizeFullName(fullName string) (string, error) {
+	cleaned := strings.TrimSpace(fullName)
+	if cleaned == "" {
+		return "", errors.New("full name is required")
+	}
+	nameRegex := regexp.MustCompile(`^[a-zA-Z\s\-\']+$`)
+	if !nameRegex.MatchString(cleaned) {
+		return "", errors.New("full name contains invalid characters")
+	}
+	return cleaned, nil
 }
\ No newline at end of file
@@ -0,0 +1,65 @@
+package entities
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// WebAuthnCredential is a passkey or security key registered to a user. ID
+// is the authenticator's credential ID in unpadded base64url.
+type WebAuthnCredential struct {
+	ID              string    `json:"id"`
+	UserID          string    `json:"userId"`
+	Name            string    `json:"name"`
+	PublicKey       []byte    `json:"-"`
+	AttestationType string    `json:"attestationType"`
+	AAGUID          []byte    `json:"-"`
+	SignCount       uint32    `json:"-"`
+	Transports      []string  `json:"transports,omitempty"`
+	BackupEligible  bool      `json:"backupEligible"`
+	BackupState     bool      `json:"backupState"`
+	CreatedAt       time.Time `json:"createdAt"`
+	LastUsedAt      time.Time `json:"lastUsedAt,omitempty"`
+}
+
+type WebAuthnCeremony string
+
+const (
+	WebAuthnCeremonyRegistration WebAuthnCeremony = "registration"
+	WebAuthnCeremonyLogin        WebAuthnCeremony = "login"
+)
+
+// WebAuthnSession holds the server side state of a ceremony between its
+// begin and finish calls. ID is the hash of the ceremony ID handed to the
+// client, and Data is opaque to the domain.
+type WebAuthnSession struct {
+	ID        string           `json:"-"`
+	UserID    string           `json:"userId,omitempty"`
+	Ceremony  WebAuthnCeremony `json:"ceremony"`
+	Data      []byte           `json:"-"`
+	ExpiresAt time.Time        `json:"expiresAt"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
+
+// NewWebAuthnSession returns the ceremony ID for the client alongside the
+// session to store. Sessions are single use.
+func NewWebAuthnSession(userID string, ceremony WebAuthnCeremony, data []byte, ttl time.Duration) (string, *WebAuthnSession) {
+	idBytes := make([]byte, 32)
+	rand.Read(idBytes)
+	ceremonyID := hex.EncodeToString(idBytes)
+
+	now := time.Now()
+	return ceremonyID, &WebAuthnSession{
+		ID:        HashTokenValue(ceremonyID),
+		UserID:    userID,
+		Ceremony:  ceremony,
+		Data:      data,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+}
+
+func (s *WebAuthnSession) IsExpired() bool {
+	return time.Now().After(s.ExpiresAt)
+}
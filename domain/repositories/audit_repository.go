@@ -0,0 +1,28 @@
+package repositories
+
+import (
+	"ambassador/domain/entities"
+	"time"
+)
+
+// AuditEventFilter narrows an audit query. Zero fields match everything.
+// BeforeTime and BeforeID form the keyset cursor: only events older than
+// that position are returned.
+type AuditEventFilter struct {
+	UserID     string
+	ActorID    string
+	Type       entities.AuditEventType
+	IPAddress  string
+	RequestID  string
+	Since      time.Time
+	Until      time.Time
+	BeforeTime time.Time
+	BeforeID   string
+	Limit      int
+}
+
+type AuditRepository interface {
+	Save(event *entities.AuditEvent) error
+	// Find returns matching events, newest first.
+	Find(filter AuditEventFilter) ([]*entities.AuditEvent, error)
+}
@@ -0,0 +1,10 @@
+package repositories
+
+import "ambassador/domain/entities"
+
+type IdentityRepository interface {
+	Save(identity *entities.ExternalIdentity) error
+	FindByProviderSubject(provider entities.RegistrationMethod, subject string) (*entities.ExternalIdentity, error)
+	FindByUserID(userID string) ([]*entities.ExternalIdentity, error)
+	DeleteByUserID(userID string) error
+}
@@ -0,0 +1,16 @@
+package repositories
+
+import (
+	"ambassador/domain/entities"
+	"time"
+)
+
+type LoginThrottleRepository interface {
+	Find(key string) (*entities.LoginThrottle, error)
+	// RecordFailure atomically counts a failed login at the given time and
+	// returns the updated record. Failures before since are forgotten.
+	RecordFailure(key string, at, since time.Time) (*entities.LoginThrottle, error)
+	// Lock blocks logins until the given time and clears the failure count.
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+}
@@ -0,0 +1,16 @@
+package repositories
+
+import "ambassador/domain/entities"
+
+type MFARepository interface {
+	SaveTOTP(credential *entities.TOTPCredential) error
+	FindTOTP(userID string) (*entities.TOTPCredential, error)
+	DeleteTOTP(userID string) error
+	// ReplaceRecoveryCodes removes every existing code for userID and stores
+	// codes in their place.
+	ReplaceRecoveryCodes(userID string, codes []*entities.RecoveryCode) error
+	FindRecoveryCodes(userID string) ([]*entities.RecoveryCode, error)
+	// ConsumeRecoveryCode marks an unused code as used and reports whether
+	// one was found.
+	ConsumeRecoveryCode(userID, codeHash string) (bool, error)
+}
@@ -0,0 +1,12 @@
+package repositories
+
+import "ambassador/domain/entities"
+
+type PasswordHistoryRepository interface {
+	Add(entry *entities.PasswordHistoryEntry) error
+	// FindRecent returns the newest limit entries of a user, newest first.
+	FindRecent(userID string, limit int) ([]*entities.PasswordHistoryEntry, error)
+	// Prune deletes all but the newest keep entries of a user.
+	Prune(userID string, keep int) error
+	DeleteByUserID(userID string) error
+}
@@ -0,0 +1,13 @@
+package repositories
+
+import "ambassador/domain/entities"
+
+type SessionRepository interface {
+	Save(session *entities.Session) error
+	FindByID(id string) (*entities.Session, error)
+	// FindByUserID returns the user's sessions, most recently used first.
+	FindByUserID(userID string) ([]*entities.Session, error)
+	Delete(id string) error
+	DeleteByUserID(userID string) error
+	DeleteExpired() error
+}
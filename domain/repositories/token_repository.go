@@ -9,4 +9,6 @@ type TokenRepository interface {
 	DeleteExpired() error
 	DeleteAllUserTokens(userID string, tokenType entities.TokenType) error
 	FindByUserID(userID string, tokenType entities.TokenType) ([]*entities.Token, error)
+	DeleteByFamily(familyID string) error
+	DeleteByUserID(userID string) error
 }
\ No newline at end of file
@@ -0,0 +1,23 @@
+package repositories
+
+import "context"
+
+// TxRepositories exposes repositories that all participate in the same
+// transaction for the duration of a UnitOfWork.
+type TxRepositories struct {
+	Users      UserRepository
+	Tokens     TokenRepository
+	Identities IdentityRepository
+	MFA        MFARepository
+	Sessions   SessionRepository
+	// PasswordHistory is nil when the backend keeps no password history.
+	PasswordHistory PasswordHistoryRepository
+	// WebAuthn is nil when the backend stores no passkeys.
+	WebAuthn WebAuthnRepository
+}
+
+// UnitOfWork runs fn atomically: every write made through the provided
+// repositories is committed when fn returns nil and discarded otherwise.
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(repos *TxRepositories) error) error
+}
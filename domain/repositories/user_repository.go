@@ -1,10 +1,30 @@
 package repositories
 
-import "ambassador/domain/entities"
+import (
+	"ambassador/domain/entities"
+	"time"
+)
+
+// UserFilter narrows a user listing. Query matches part of the email
+// address or full name, ignoring case. BeforeTime and BeforeID form the
+// keyset cursor: only users created before that position are returned.
+type UserFilter struct {
+	Query      string
+	Active     *bool
+	BeforeTime time.Time
+	BeforeID   string
+	// DeletedBefore only matches users whose deletion was requested before
+	// this time.
+	DeletedBefore time.Time
+	Limit         int
+}
 
 type UserRepository interface {
 	Save(user *entities.User) error
 	FindByEmail(email string) (*entities.User, error)
 	FindByID(id string) (*entities.User, error)
 	ExistsByEmail(email string) (bool, error)
-}
\ No newline at end of file
+	// List returns matching users, newest first.
+	List(filter UserFilter) ([]*entities.User, error)
+	Delete(id string) error
+}
@@ -0,0 +1,17 @@
+package repositories
+
+import "ambassador/domain/entities"
+
+type WebAuthnRepository interface {
+	SaveCredential(credential *entities.WebAuthnCredential) error
+	FindCredential(credentialID string) (*entities.WebAuthnCredential, error)
+	FindCredentialsByUserID(userID string) ([]*entities.WebAuthnCredential, error)
+	DeleteCredential(userID, credentialID string) error
+	SaveSession(session *entities.WebAuthnSession) error
+	// TakeSession returns and deletes a session, so each ceremony can only
+	// be finished once.
+	TakeSession(id string) (*entities.WebAuthnSession, error)
+	// DeleteByUserID deletes the user's credentials along with their
+	// unfinished ceremonies.
+	DeleteByUserID(userID string) error
+}
@@ -0,0 +1,10 @@
+package services
+
+import "ambassador/domain/entities"
+
+// AuditLogger receives security events. Implementations must not block the
+// caller for long and should swallow their own failures; losing an audit
+// record must never fail the request that produced it.
+type AuditLogger interface {
+	Record(event *entities.AuditEvent)
+}
@@ -3,12 +3,56 @@ package services
 import (
 	"ambassador/application/dto"
 	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"time"
 )
 
 type AuthService interface {
 	Register(req *dto.RegisterRequest) (*entities.User, *entities.TokenPair, error)
-	Login(req *dto.LoginRequest) (*entities.TokenPair, error)
-	RefreshToken(refreshToken string) (*entities.TokenPair, error)
+	Login(req *dto.LoginRequest) (*entities.TokenPair, *entities.Challenge, error)
+	VerifyMFA(req *dto.MFAVerifyRequest) (*entities.User, *entities.TokenPair, *entities.Challenge, error)
+	ChangeExpiredPassword(req *dto.ExpiredPasswordChangeRequest) (*entities.User, *entities.TokenPair, error)
+	RequestMagicLink(req *dto.MagicLinkRequest) (nonce string, expiresAt time.Time, err error)
+	ConsumeMagicLink(req *dto.ConsumeMagicLinkRequest) (*entities.User, *entities.TokenPair, *entities.Challenge, error)
+	IssueOAuthNonce() (nonce string, expiresAt time.Time, err error)
+	OAuthLogin(req *dto.OAuthLoginRequest) (*entities.User, *entities.TokenPair, *entities.Challenge, error)
+	RefreshToken(req *dto.RefreshTokenRequest) (*entities.TokenPair, error)
 	GetProfile(accessToken string) (*entities.User, error)
-	Logout(refreshToken string) error
+	UpdateProfile(userID string, req *dto.UpdateProfileRequest) (*entities.User, error)
+	ChangePassword(userID, currentSessionID string, req *dto.ChangePasswordRequest) error
+	PasswordPolicy() *dto.PasswordPolicyResponse
+	DeleteAccount(userID string, req *dto.DeleteAccountRequest) (time.Time, error)
+	RestoreAccount(req *dto.RestoreAccountRequest) error
+	ExportAccount(userID string, client entities.ClientInfo) (*dto.AccountExport, error)
+	Authenticate(accessToken string) (*entities.User, *entities.Token, error)
+	Logout(refreshToken string, client entities.ClientInfo) error
+	ForgotPassword(req *dto.ForgotPasswordRequest) error
+	ResetPassword(req *dto.ResetPasswordRequest) error
+	VerifyEmail(req *dto.VerifyEmailRequest) error
+	ResendVerification(req *dto.ResendVerificationRequest) error
+	RequestEmailChange(userID string, req *dto.ChangeEmailRequest) error
+	ConfirmEmailChange(req *dto.EmailChangeTokenRequest) error
+	RevertEmailChange(req *dto.EmailChangeTokenRequest) error
+	UnlockAccount(req *dto.UnlockAccountRequest) error
+	AdminUnlockUser(userID string, client entities.ClientInfo) error
+	AdminListUsers(filter repositories.UserFilter) ([]*entities.User, error)
+	AdminGetUser(userID string) (*dto.AdminUserDetail, error)
+	AdminSetUserActive(userID string, active bool, client entities.ClientInfo) error
+	AdminForceLogout(userID string, client entities.ClientInfo) (int, error)
+	AdminForcePasswordReset(userID string, client entities.ClientInfo) error
+	AdminSetUserRoles(userID string, req *dto.SetUserRolesRequest) (*entities.User, error)
+	AdminListAuditEvents(filter repositories.AuditEventFilter) ([]*entities.AuditEvent, error)
+	EnrollTOTP(userID string) (secret string, otpauthURI string, err error)
+	ConfirmTOTP(userID string, req *dto.MFACodeRequest) ([]string, error)
+	DisableTOTP(userID string, req *dto.MFACodeRequest) error
+	RegenerateRecoveryCodes(userID string, req *dto.MFACodeRequest) ([]string, error)
+	BeginPasskeyRegistration(userID string) (ceremonyID string, options interface{}, err error)
+	FinishPasskeyRegistration(userID string, req *dto.FinishPasskeyRegistrationRequest) (*entities.WebAuthnCredential, error)
+	BeginPasskeyLogin(req *dto.BeginPasskeyLoginRequest) (ceremonyID string, options interface{}, err error)
+	FinishPasskeyLogin(req *dto.FinishPasskeyLoginRequest) (*entities.User, *entities.TokenPair, error)
+	ListPasskeys(userID string) ([]*entities.WebAuthnCredential, error)
+	DeletePasskey(userID, credentialID string, client entities.ClientInfo) error
+	ListSessions(userID string) ([]*entities.Session, error)
+	RevokeSession(userID, sessionID string, client entities.ClientInfo) error
+	RevokeOtherSessions(userID, currentSessionID string, client entities.ClientInfo) (int, error)
 }
\ No newline at end of file
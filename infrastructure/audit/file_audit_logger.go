@@ -0,0 +1,45 @@
+package audit
+
+import (
+	"ambassador/domain/entities"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+)
+
+// FileAuditLogger appends events to a file as JSON lines, one event per
+// line, for shipping to an external log pipeline.
+type FileAuditLogger struct {
+	file *os.File
+	mu   sync.Mutex
+}
+
+func NewFileAuditLogger(path string) (*FileAuditLogger, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileAuditLogger{file: file}, nil
+}
+
+func (l *FileAuditLogger) Record(event *entities.AuditEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("audit: failed to encode %s event: %v", event.Type, err)
+		return
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(data); err != nil {
+		log.Printf("audit: failed to write %s event: %v", event.Type, err)
+	}
+}
+
+func (l *FileAuditLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
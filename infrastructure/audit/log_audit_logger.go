@@ -0,0 +1,23 @@
+package audit
+
+import (
+	"ambassador/domain/entities"
+	"encoding/json"
+	"log"
+)
+
+// LogAuditLogger writes events to the standard logger.
+type LogAuditLogger struct{}
+
+func NewLogAuditLogger() *LogAuditLogger {
+	return &LogAuditLogger{}
+}
+
+func (l *LogAuditLogger) Record(event *entities.AuditEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("audit: failed to encode %s event: %v", event.Type, err)
+		return
+	}
+	log.Printf("audit: %s", data)
+}
@@ -0,0 +1,21 @@
+package audit
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/domain/services"
+)
+
+// MultiAuditLogger fans every event out to several sinks.
+type MultiAuditLogger struct {
+	loggers []services.AuditLogger
+}
+
+func NewMultiAuditLogger(loggers ...services.AuditLogger) *MultiAuditLogger {
+	return &MultiAuditLogger{loggers: loggers}
+}
+
+func (l *MultiAuditLogger) Record(event *entities.AuditEvent) {
+	for _, logger := range l.loggers {
+		logger.Record(event)
+	}
+}
@@ -0,0 +1,23 @@
+package audit
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"log"
+)
+
+// RepositoryAuditLogger stores events so they can be queried through the
+// admin API.
+type RepositoryAuditLogger struct {
+	repo repositories.AuditRepository
+}
+
+func NewRepositoryAuditLogger(repo repositories.AuditRepository) *RepositoryAuditLogger {
+	return &RepositoryAuditLogger{repo: repo}
+}
+
+func (l *RepositoryAuditLogger) Record(event *entities.AuditEvent) {
+	if err := l.repo.Save(event); err != nil {
+		log.Printf("audit: failed to store %s event: %v", event.Type, err)
+	}
+}
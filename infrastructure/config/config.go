@@ -0,0 +1,424 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	App       AppConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	Token     TokenConfig
+	Admin     AdminConfig
+	OAuth     OAuthConfig
+	Mail      MailConfig
+	MFA       MFAConfig
+	MagicLink MagicLinkConfig
+	Cookies   CookieConfig
+	WebAuthn  WebAuthnConfig
+	Lockout   LockoutConfig
+	Password  PasswordConfig
+	Audit     AuditConfig
+	RBAC      RBACConfig
+}
+
+type AppConfig struct {
+	// BaseURL is the front-end that links in outgoing emails point at.
+	BaseURL          string
+	PasswordResetTTL time.Duration
+	// UnverifiedLoginPolicy decides whether users with an unverified email
+	// may sign in: "allow", "limited" (restricted scopes) or "block".
+	UnverifiedLoginPolicy string
+	EmailVerificationTTL  time.Duration
+	// EmailChangeTTL is how long the confirmation link sent to a new address
+	// is valid; EmailRevertTTL how long the old address can undo the change.
+	EmailChangeTTL time.Duration
+	EmailRevertTTL time.Duration
+	// AccountDeletionGrace is how long a deleted account can be restored;
+	// AccountPurgeInterval how often accounts past it are purged.
+	AccountDeletionGrace time.Duration
+	AccountPurgeInterval time.Duration
+	// TokenPurgeInterval is how often expired tokens and sessions are
+	// deleted.
+	TokenPurgeInterval time.Duration
+}
+
+type ServerConfig struct {
+	Addr string
+	// AllowedOrigins lists the browser origins that may call the API with
+	// cookies, e.g. "https://app.example.com". Empty lets any origin call
+	// it, but only without cookies.
+	AllowedOrigins []string
+	// ShutdownTimeout bounds how long shutdown waits for in-flight requests
+	// and queued mail.
+	ShutdownTimeout time.Duration
+}
+
+type DatabaseConfig struct {
+	// Driver selects the storage backend: "memory", "sqlite" or "postgres".
+	Driver          string
+	DSN             string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	QueryTimeout    time.Duration
+}
+
+type TokenConfig struct {
+	// Format selects the access token format: "opaque" or "jwt".
+	Format            string
+	AccessTTL         time.Duration
+	RefreshReuseGrace time.Duration
+	SigningAlg        string
+	PrivateKeyFiles   []string
+	KeyDir            string
+	KeyGracePeriod    time.Duration
+	Issuer            string
+	Audience          []string
+}
+
+type AdminConfig struct {
+	// APIToken grants every permission on the /admin routes to requests that
+	// send it in X-Admin-Token. Only role based access is possible when it is
+	// empty.
+	APIToken string
+}
+
+// OAuthProviderConfig configures ID token verification for one provider. A
+// provider is disabled while ClientIDs is empty. Issuer and JWKSURL default
+// to the provider's production endpoints and can be pointed at a mock OIDC
+// issuer for testing.
+type OAuthProviderConfig struct {
+	ClientIDs []string
+	Issuers   []string
+	JWKSURL   string
+}
+
+type OAuthConfig struct {
+	Google OAuthProviderConfig
+	Apple  OAuthProviderConfig
+}
+
+type MailConfig struct {
+	// Transport selects how email is delivered: "log", "file" or "smtp".
+	Transport string
+	From      string
+	// OutboxDir is where the file transport writes .eml files.
+	OutboxDir string
+	// TemplateDir overrides the built-in templates when set.
+	TemplateDir   string
+	DefaultLocale string
+	SMTP          SMTPConfig
+	QueueSize     int
+	QueueWorkers  int
+	MaxAttempts   int
+	RetryBackoff  time.Duration
+}
+
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	// Security is "starttls", "tls" or "none".
+	Security string
+	Timeout  time.Duration
+}
+
+// CookieConfig controls the cookie mode for browser clients, where the
+// refresh token is kept in an HttpOnly cookie and state-changing requests
+// must pass a double-submit CSRF check.
+type CookieConfig struct {
+	Enabled     bool
+	RefreshName string
+	CSRFName    string
+	Domain      string
+	// RefreshPath limits which routes the browser sends the refresh cookie
+	// to.
+	RefreshPath string
+	Secure      bool
+	// SameSite is "strict", "lax" or "none"; "none" requires Secure.
+	SameSite string
+}
+
+// MagicLinkConfig controls passwordless login through emailed links.
+type MagicLinkConfig struct {
+	Enabled bool
+	TTL     time.Duration
+	// SigningKey is a base64 encoded key of at least 32 bytes that signs the
+	// links. A random key is used when it is empty, so links stop working on
+	// restart and are not shared between instances.
+	SigningKey string
+}
+
+type MFAConfig struct {
+	// Issuer is the name authenticator apps show next to the account.
+	Issuer string
+	// SecretKey is a base64 encoded 32 byte key used to encrypt TOTP secrets
+	// at rest. Secrets are stored in plain text when it is empty.
+	SecretKey    string
+	ChallengeTTL time.Duration
+}
+
+type WebAuthnConfig struct {
+	// RPID is the domain passkeys are scoped to. Passkeys are disabled when
+	// it is empty.
+	RPID   string
+	RPName string
+	// Origins lists the exact origins allowed to run ceremonies. Defaults to
+	// the app base URL.
+	Origins []string
+	Timeout time.Duration
+}
+
+// LockoutConfig throttles failed password logins per account.
+type LockoutConfig struct {
+	// Threshold failed attempts lock the account for Duration; zero disables
+	// locking.
+	Threshold int
+	Duration  time.Duration
+	// BackoffAfter failed attempts, further attempts must wait BackoffBase,
+	// doubling per failure up to BackoffMax; zero disables the backoff.
+	BackoffAfter int
+	BackoffBase  time.Duration
+	BackoffMax   time.Duration
+	// ResetAfter is how long failed attempts are remembered.
+	ResetAfter time.Duration
+	UnlockTTL  time.Duration
+}
+
+// PasswordConfig holds the rules new passwords must meet and how they are
+// hashed. Hashes made by any supported algorithm keep verifying and are
+// upgraded at login.
+type PasswordConfig struct {
+	MinLength int
+	// MaxLength of zero picks a limit suited to the hash algorithm.
+	MaxLength        int
+	RequireUppercase bool
+	RequireLowercase bool
+	RequireDigit     bool
+	RequireSpecial   bool
+	// MinEntropyBits is the least estimated strength; zero disables it.
+	MinEntropyBits int
+	// HistoryDepth is how many previous passwords may not be reused.
+	HistoryDepth int
+	// MaxAge is how long a password lasts before the user must change it
+	// at login; zero means passwords never expire.
+	MaxAge time.Duration
+
+	// HashAlgorithm is "argon2id" or "bcrypt".
+	HashAlgorithm string
+	// Argon2Memory is in KiB.
+	Argon2Memory      int
+	Argon2Iterations  int
+	Argon2Parallelism int
+	// BlocklistFile lists common passwords to refuse, one per line. A small
+	// built-in list is used when it is empty.
+	BlocklistFile string
+	// BreachCorpusDir holds SHA-1 range files of breached passwords, named
+	// after the five hex digit prefix; empty disables the check.
+	// BreachMinCount ignores passwords seen fewer times than this.
+	BreachCorpusDir string
+	BreachMinCount  int
+}
+
+type AuditConfig struct {
+	// Sinks lists where audit events go: "log", "file" and/or "database".
+	// Only events in the database sink can be queried through /admin/audit.
+	Sinks []string
+	// File is the JSON lines file the file sink appends to.
+	File string
+}
+
+type RBACConfig struct {
+	// PolicyFile is a JSON object mapping each role to the permissions it
+	// grants. The built-in policy is used when it is empty.
+	PolicyFile string
+}
+
+func Load() *Config {
+	return &Config{
+		App: AppConfig{
+			BaseURL:               getEnv("APP_BASE_URL", "http://localhost:3000"),
+			PasswordResetTTL:      getEnvDuration("PASSWORD_RESET_TTL", 30*time.Minute),
+			UnverifiedLoginPolicy: getEnv("UNVERIFIED_LOGIN_POLICY", "allow"),
+			EmailVerificationTTL:  getEnvDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+			EmailChangeTTL:        getEnvDuration("EMAIL_CHANGE_TTL", 24*time.Hour),
+			EmailRevertTTL:        getEnvDuration("EMAIL_CHANGE_REVERT_TTL", 7*24*time.Hour),
+			AccountDeletionGrace:  getEnvDuration("ACCOUNT_DELETION_GRACE", 30*24*time.Hour),
+			AccountPurgeInterval:  getEnvDuration("ACCOUNT_PURGE_INTERVAL", time.Hour),
+			TokenPurgeInterval:    getEnvDuration("TOKEN_PURGE_INTERVAL", time.Hour),
+		},
+		Server: ServerConfig{
+			Addr:            getEnv("SERVER_ADDR", ":9090"),
+			AllowedOrigins:  getEnvList("CORS_ALLOWED_ORIGINS", nil),
+			ShutdownTimeout: getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		},
+		Database: DatabaseConfig{
+			Driver:          getEnv("DB_DRIVER", "sqlite"),
+			DSN:             getEnv("DB_DSN", "file:ambassador.db?_pragma=busy_timeout(5000)"),
+			MaxOpenConns:    getEnvInt("DB_MAX_OPEN_CONNS", 25),
+			MaxIdleConns:    getEnvInt("DB_MAX_IDLE_CONNS", 5),
+			ConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+			ConnMaxIdleTime: getEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+			QueryTimeout:    getEnvDuration("DB_QUERY_TIMEOUT", 5*time.Second),
+		},
+		Token: TokenConfig{
+			Format:            getEnv("ACCESS_TOKEN_FORMAT", "opaque"),
+			AccessTTL:         getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+			RefreshReuseGrace: getEnvDuration("REFRESH_TOKEN_REUSE_GRACE", 10*time.Second),
+			SigningAlg:        getEnv("JWT_SIGNING_ALG", "RS256"),
+			PrivateKeyFiles:   getEnvList("JWT_PRIVATE_KEY_FILES", nil),
+			KeyDir:            getEnv("JWT_KEY_DIR", ""),
+			KeyGracePeriod:    getEnvDuration("JWT_KEY_GRACE_PERIOD", time.Hour),
+			Issuer:            getEnv("JWT_ISSUER", "ambassador"),
+			Audience:          getEnvList("JWT_AUDIENCE", nil),
+		},
+		Admin: AdminConfig{
+			APIToken: getEnv("ADMIN_API_TOKEN", ""),
+		},
+		OAuth: OAuthConfig{
+			Google: OAuthProviderConfig{
+				ClientIDs: getEnvList("OAUTH_GOOGLE_CLIENT_IDS", nil),
+				Issuers:   getEnvList("OAUTH_GOOGLE_ISSUERS", nil),
+				JWKSURL:   getEnv("OAUTH_GOOGLE_JWKS_URL", ""),
+			},
+			Apple: OAuthProviderConfig{
+				ClientIDs: getEnvList("OAUTH_APPLE_CLIENT_IDS", nil),
+				Issuers:   getEnvList("OAUTH_APPLE_ISSUERS", nil),
+				JWKSURL:   getEnv("OAUTH_APPLE_JWKS_URL", ""),
+			},
+		},
+		Mail: MailConfig{
+			Transport:     getEnv("MAIL_TRANSPORT", "log"),
+			From:          getEnv("MAIL_FROM", "Ambassador <no-reply@localhost>"),
+			OutboxDir:     getEnv("MAIL_OUTBOX_DIR", "outbox"),
+			TemplateDir:   getEnv("MAIL_TEMPLATE_DIR", ""),
+			DefaultLocale: getEnv("MAIL_DEFAULT_LOCALE", "en"),
+			SMTP: SMTPConfig{
+				Host:     getEnv("SMTP_HOST", ""),
+				Port:     getEnvInt("SMTP_PORT", 587),
+				Username: getEnv("SMTP_USERNAME", ""),
+				Password: getEnv("SMTP_PASSWORD", ""),
+				Security: getEnv("SMTP_SECURITY", "starttls"),
+				Timeout:  getEnvDuration("SMTP_TIMEOUT", 10*time.Second),
+			},
+			QueueSize:    getEnvInt("MAIL_QUEUE_SIZE", 100),
+			QueueWorkers: getEnvInt("MAIL_QUEUE_WORKERS", 2),
+			MaxAttempts:  getEnvInt("MAIL_MAX_ATTEMPTS", 5),
+			RetryBackoff: getEnvDuration("MAIL_RETRY_BACKOFF", 2*time.Second),
+		},
+		MFA: MFAConfig{
+			Issuer:       getEnv("MFA_ISSUER", "Ambassador"),
+			SecretKey:    getEnv("MFA_SECRET_KEY", ""),
+			ChallengeTTL: getEnvDuration("MFA_CHALLENGE_TTL", 5*time.Minute),
+		},
+		Cookies: CookieConfig{
+			Enabled:     getEnvBool("SESSION_COOKIES_ENABLED", false),
+			RefreshName: getEnv("SESSION_COOKIE_NAME", "refresh_token"),
+			CSRFName:    getEnv("CSRF_COOKIE_NAME", "csrf_token"),
+			Domain:      getEnv("SESSION_COOKIE_DOMAIN", ""),
+			RefreshPath: getEnv("SESSION_COOKIE_PATH", "/api/v1/auth"),
+			Secure:      getEnvBool("SESSION_COOKIE_SECURE", true),
+			SameSite:    getEnv("SESSION_COOKIE_SAMESITE", "strict"),
+		},
+		MagicLink: MagicLinkConfig{
+			Enabled:    getEnvBool("MAGIC_LINK_ENABLED", true),
+			TTL:        getEnvDuration("MAGIC_LINK_TTL", 15*time.Minute),
+			SigningKey: getEnv("MAGIC_LINK_SIGNING_KEY", ""),
+		},
+		WebAuthn: WebAuthnConfig{
+			RPID:    getEnv("WEBAUTHN_RP_ID", ""),
+			RPName:  getEnv("WEBAUTHN_RP_NAME", "Ambassador"),
+			Origins: getEnvList("WEBAUTHN_ORIGINS", []string{getEnv("APP_BASE_URL", "http://localhost:3000")}),
+			Timeout: getEnvDuration("WEBAUTHN_TIMEOUT", 5*time.Minute),
+		},
+		Lockout: LockoutConfig{
+			Threshold:    getEnvInt("LOCKOUT_THRESHOLD", 10),
+			Duration:     getEnvDuration("LOCKOUT_DURATION", 30*time.Minute),
+			BackoffAfter: getEnvInt("LOCKOUT_BACKOFF_AFTER", 3),
+			BackoffBase:  getEnvDuration("LOCKOUT_BACKOFF_BASE", time.Second),
+			BackoffMax:   getEnvDuration("LOCKOUT_BACKOFF_MAX", time.Minute),
+			ResetAfter:   getEnvDuration("LOCKOUT_RESET_AFTER", time.Hour),
+			UnlockTTL:    getEnvDuration("LOCKOUT_UNLOCK_TTL", 24*time.Hour),
+		},
+		Password: PasswordConfig{
+			MinLength:         getEnvInt("PASSWORD_MIN_LENGTH", 8),
+			MaxLength:         getEnvInt("PASSWORD_MAX_LENGTH", 0),
+			RequireUppercase:  getEnvBool("PASSWORD_REQUIRE_UPPERCASE", true),
+			RequireLowercase:  getEnvBool("PASSWORD_REQUIRE_LOWERCASE", true),
+			RequireDigit:      getEnvBool("PASSWORD_REQUIRE_DIGIT", true),
+			RequireSpecial:    getEnvBool("PASSWORD_REQUIRE_SPECIAL", true),
+			MinEntropyBits:    getEnvInt("PASSWORD_MIN_ENTROPY_BITS", 40),
+			HistoryDepth:      getEnvInt("PASSWORD_HISTORY_DEPTH", 5),
+			MaxAge:            getEnvDuration("PASSWORD_MAX_AGE", 0),
+			HashAlgorithm:     getEnv("PASSWORD_HASH_ALGORITHM", "argon2id"),
+			Argon2Memory:      getEnvInt("PASSWORD_ARGON2_MEMORY", 19*1024),
+			Argon2Iterations:  getEnvInt("PASSWORD_ARGON2_ITERATIONS", 2),
+			Argon2Parallelism: getEnvInt("PASSWORD_ARGON2_PARALLELISM", 1),
+			BlocklistFile:     getEnv("PASSWORD_BLOCKLIST_FILE", ""),
+			BreachCorpusDir:   getEnv("PASSWORD_BREACH_CORPUS_DIR", ""),
+			BreachMinCount:    getEnvInt("PASSWORD_BREACH_MIN_COUNT", 1),
+		},
+		Audit: AuditConfig{
+			Sinks: getEnvList("AUDIT_SINKS", []string{"log", "database"}),
+			File:  getEnv("AUDIT_FILE", "audit.log"),
+		},
+		RBAC: RBACConfig{
+			PolicyFile: getEnv("RBAC_POLICY_FILE", ""),
+		},
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	if value, ok := os.LookupEnv(key); ok {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func getEnvList(key string, fallback []string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
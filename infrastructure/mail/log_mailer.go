@@ -0,0 +1,16 @@
+package mail
+
+import "log"
+
+// LogMailer prints messages instead of delivering them. It is meant for
+// local development only, since links in the body are logged verbatim.
+type LogMailer struct{}
+
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+func (m *LogMailer) Send(msg *Message) error {
+	log.Printf("mail: to=%s subject=%q\n%s", msg.To, msg.Subject, msg.Text)
+	return nil
+}
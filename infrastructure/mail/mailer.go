@@ -0,0 +1,14 @@
+package mail
+
+// Message is a rendered email. HTML is optional; transports that support it
+// send both bodies as multipart/alternative.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type Mailer interface {
+	Send(msg *Message) error
+}
@@ -0,0 +1,51 @@
+package mail
+
+import "sync"
+
+// MemoryMailer keeps every message it is asked to send so tests can inspect
+// what would have been delivered.
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []*Message
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(msg *Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	copied := *msg
+	m.messages = append(m.messages, &copied)
+	return nil
+}
+
+// Messages returns the captured messages in the order they were sent.
+func (m *MemoryMailer) Messages() []*Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	messages := make([]*Message, len(m.messages))
+	copy(messages, m.messages)
+	return messages
+}
+
+// SentTo returns the captured messages addressed to to.
+func (m *MemoryMailer) SentTo(to string) []*Message {
+	var matched []*Message
+	for _, msg := range m.Messages() {
+		if msg.To == to {
+			matched = append(matched, msg)
+		}
+	}
+	return matched
+}
+
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = nil
+}
@@ -0,0 +1,138 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+type QueueConfig struct {
+	Size        int
+	Workers     int
+	MaxAttempts int
+	// Backoff is the delay before the first retry; it doubles on every
+	// further attempt up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// Queue is a Mailer that hands messages to background workers, which
+// deliver them through the wrapped transport and retry failures with
+// exponential backoff. Send only fails when the queue is full or closed.
+type Queue struct {
+	transport Mailer
+	config    QueueConfig
+	jobs      chan *Message
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	abortOnce sync.Once
+	mu        sync.RWMutex
+	closed    bool
+}
+
+func NewQueue(transport Mailer, config QueueConfig) *Queue {
+	if config.Size <= 0 {
+		config.Size = 100
+	}
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 5
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = time.Second
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = time.Minute
+	}
+
+	q := &Queue{
+		transport: transport,
+		config:    config,
+		jobs:      make(chan *Message, config.Size),
+		done:      make(chan struct{}),
+	}
+	for i := 0; i < config.Workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+func (q *Queue) Send(msg *Message) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return errors.New("mail queue is closed")
+	}
+
+	select {
+	case q.jobs <- msg:
+		return nil
+	default:
+		return errors.New("mail queue is full")
+	}
+}
+
+// Close stops accepting messages and waits for the queued ones to be
+// delivered, giving up when ctx is done. Pending retries are abandoned at
+// that point.
+func (q *Queue) Close(ctx context.Context) error {
+	q.closeOnce.Do(func() {
+		q.mu.Lock()
+		q.closed = true
+		close(q.jobs)
+		q.mu.Unlock()
+	})
+
+	finished := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		q.abortOnce.Do(func() { close(q.done) })
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for msg := range q.jobs {
+		q.deliver(msg)
+	}
+}
+
+func (q *Queue) deliver(msg *Message) {
+	backoff := q.config.Backoff
+	for attempt := 1; ; attempt++ {
+		err := q.transport.Send(msg)
+		if err == nil {
+			return
+		}
+		if attempt >= q.config.MaxAttempts {
+			log.Printf("mail: giving up on %q to %s after %d attempts: %v", msg.Subject, msg.To, attempt, err)
+			return
+		}
+		log.Printf("mail: attempt %d for %q to %s failed, retrying in %s: %v", attempt, msg.Subject, msg.To, backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-q.done:
+			return
+		}
+		backoff *= 2
+		if backoff > q.config.MaxBackoff {
+			backoff = q.config.MaxBackoff
+		}
+	}
+}
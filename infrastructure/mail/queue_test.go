@@ -0,0 +1,163 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyMailer fails the first failures sends and records when each attempt
+// was made.
+type flakyMailer struct {
+	*MemoryMailer
+	mu       sync.Mutex
+	failures int
+	attempts []time.Time
+}
+
+func (m *flakyMailer) Send(msg *Message) error {
+	m.mu.Lock()
+	m.attempts = append(m.attempts, time.Now())
+	fail := len(m.attempts) <= m.failures
+	m.mu.Unlock()
+	if fail {
+		return errors.New("connection refused")
+	}
+	return m.MemoryMailer.Send(msg)
+}
+
+func (m *flakyMailer) attemptTimes() []time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]time.Time(nil), m.attempts...)
+}
+
+func closeQueue(t *testing.T, q *Queue) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := q.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func TestQueueRetriesWithCappedBackoff(t *testing.T) {
+	transport := &flakyMailer{MemoryMailer: NewMemoryMailer(), failures: 4}
+	q := NewQueue(transport, QueueConfig{MaxAttempts: 5, Backoff: 15 * time.Millisecond, MaxBackoff: 30 * time.Millisecond})
+
+	if err := q.Send(&Message{To: "ann@example.com", Subject: "Hello"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	closeQueue(t, q)
+
+	if len(transport.Messages()) != 1 {
+		t.Fatalf("delivered %d messages, want 1 after the retries", len(transport.Messages()))
+	}
+	attempts := transport.attemptTimes()
+	if len(attempts) != 5 {
+		t.Fatalf("made %d attempts, want 5", len(attempts))
+	}
+	// Uncapped, the delays would be 15, 30, 60 and 120ms.
+	for i, want := range []time.Duration{15, 30, 30, 30} {
+		gap := attempts[i+1].Sub(attempts[i])
+		if gap < want*time.Millisecond || gap >= 3*want*time.Millisecond {
+			t.Errorf("delay before attempt %d = %v, want about %v", i+2, gap, want*time.Millisecond)
+		}
+	}
+}
+
+func TestQueueGivesUpAfterMaxAttempts(t *testing.T) {
+	transport := &flakyMailer{MemoryMailer: NewMemoryMailer(), failures: 10}
+	q := NewQueue(transport, QueueConfig{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond})
+
+	if err := q.Send(&Message{To: "ann@example.com", Subject: "Hello"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	closeQueue(t, q)
+
+	if got := len(transport.attemptTimes()); got != 3 {
+		t.Errorf("made %d attempts, want 3", got)
+	}
+}
+
+func TestQueueCloseDrainsQueuedMessages(t *testing.T) {
+	transport := NewMemoryMailer()
+	q := NewQueue(transport, QueueConfig{Size: 20, Workers: 2})
+
+	for i := 0; i < 20; i++ {
+		if err := q.Send(&Message{To: "ann@example.com", Subject: "Hello"}); err != nil {
+			t.Fatalf("Send %d: %v", i, err)
+		}
+	}
+	closeQueue(t, q)
+
+	if got := len(transport.Messages()); got != 20 {
+		t.Errorf("delivered %d messages before Close returned, want 20", got)
+	}
+}
+
+func TestQueueRefusesMessagesAfterClose(t *testing.T) {
+	q := NewQueue(NewMemoryMailer(), QueueConfig{})
+	closeQueue(t, q)
+
+	if err := q.Send(&Message{To: "ann@example.com"}); err == nil {
+		t.Error("Send after Close succeeded")
+	}
+}
+
+// blockingMailer holds every Send until release is closed.
+type blockingMailer struct {
+	*MemoryMailer
+	release chan struct{}
+}
+
+func (m *blockingMailer) Send(msg *Message) error {
+	<-m.release
+	return m.MemoryMailer.Send(msg)
+}
+
+func TestQueueCloseCanBeRetriedAfterContextEnds(t *testing.T) {
+	transport := &blockingMailer{MemoryMailer: NewMemoryMailer(), release: make(chan struct{})}
+	q := NewQueue(transport, QueueConfig{})
+	if err := q.Send(&Message{To: "ann@example.com"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 2; i++ {
+		if err := q.Close(ctx); !errors.Is(err, context.Canceled) {
+			t.Fatalf("Close %d returned %v, want the context error", i+1, err)
+		}
+	}
+
+	close(transport.release)
+	closeQueue(t, q)
+	if len(transport.Messages()) != 1 {
+		t.Error("the message in flight was not delivered")
+	}
+}
+
+func TestQueueCloseAbandonsRetriesWhenContextEnds(t *testing.T) {
+	transport := &flakyMailer{MemoryMailer: NewMemoryMailer(), failures: 10}
+	q := NewQueue(transport, QueueConfig{MaxAttempts: 10, Backoff: time.Hour, MaxBackoff: time.Hour})
+	if err := q.Send(&Message{To: "ann@example.com"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	for len(transport.attemptTimes()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Close returned %v, want the deadline error", err)
+	}
+	// The worker stops waiting for its hour-long backoff.
+	closeQueue(t, q)
+	if got := len(transport.attemptTimes()); got != 1 {
+		t.Errorf("made %d attempts, want the retry abandoned", got)
+	}
+}
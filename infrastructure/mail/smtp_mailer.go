@@ -0,0 +1,145 @@
+package mail
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+const (
+	SMTPSecurityStartTLS = "starttls"
+	SMTPSecurityTLS      = "tls"
+	SMTPSecurityNone     = "none"
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	// Security is "starttls" (default), "tls" for implicit TLS on port 465,
+	// or "none" for a local relay.
+	Security string
+	Timeout  time.Duration
+}
+
+// SMTPMailer delivers each message over a fresh connection to the configured
+// relay. Retries are left to Queue.
+type SMTPMailer struct {
+	config       SMTPConfig
+	envelopeFrom string
+}
+
+func NewSMTPMailer(config SMTPConfig) (*SMTPMailer, error) {
+	if config.Host == "" {
+		return nil, errors.New("smtp host is required")
+	}
+	if config.From == "" {
+		return nil, errors.New("mail sender address is required")
+	}
+	from, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address: %w", err)
+	}
+	if config.Port == 0 {
+		config.Port = 587
+	}
+	if config.Security == "" {
+		config.Security = SMTPSecurityStartTLS
+	}
+	switch config.Security {
+	case SMTPSecurityStartTLS, SMTPSecurityTLS, SMTPSecurityNone:
+	default:
+		return nil, fmt.Errorf("unknown smtp security mode %q", config.Security)
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 10 * time.Second
+	}
+	return &SMTPMailer{config: config, envelopeFrom: from.Address}, nil
+}
+
+func (m *SMTPMailer) Send(msg *Message) error {
+	body, err := buildMIME(m.config.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	client, err := m.dial()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if m.config.Username != "" {
+		auth := smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+
+	if err := client.Mail(m.envelopeFrom); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+func (m *SMTPMailer) dial() (*smtp.Client, error) {
+	addr := net.JoinHostPort(m.config.Host, fmt.Sprint(m.config.Port))
+	tlsConfig := &tls.Config{ServerName: m.config.Host}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	dialer := &net.Dialer{Timeout: m.config.Timeout}
+	if m.config.Security == SMTPSecurityTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Bound the whole conversation so a stalled relay cannot hold a queue
+	// worker forever.
+	conn.SetDeadline(time.Now().Add(3 * m.config.Timeout))
+
+	client, err := smtp.NewClient(conn, m.config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if m.config.Security == SMTPSecurityStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, errors.New("smtp server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
@@ -0,0 +1,151 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"regexp"
+	"strings"
+	texttemplate "text/template"
+)
+
+// defaultTemplates are the built-in email templates. Each locale has its own
+// lower-case directory, e.g. "en" or "pt-br", holding <name>.subject.txt,
+// <name>.txt and optionally <name>.html.
+//
+//go:embed templates
+var defaultTemplates embed.FS
+
+const (
+	TemplatePasswordReset     = "password_reset"
+	TemplateEmailVerification = "email_verification"
+	TemplateAccountUnlock     = "account_unlock"
+	TemplateEmailChange       = "email_change_confirm"
+	TemplateEmailChangeNotice = "email_change_notice"
+	TemplateAccountDeletion   = "account_deletion"
+	TemplateMagicLink         = "magic_link"
+)
+
+// DefaultTemplates returns the embedded templates rooted at the locale
+// directories.
+func DefaultTemplates() fs.FS {
+	sub, _ := fs.Sub(defaultTemplates, "templates")
+	return sub
+}
+
+// Renderer turns a named template and its data into a Message, picking the
+// closest available locale: "pt-BR" falls back to "pt", then to the default
+// locale.
+type Renderer struct {
+	fsys          fs.FS
+	defaultLocale string
+}
+
+func NewRenderer(fsys fs.FS, defaultLocale string) (*Renderer, error) {
+	r := &Renderer{fsys: fsys, defaultLocale: NormalizeLocale(defaultLocale)}
+	if r.defaultLocale == "" {
+		r.defaultLocale = "en"
+	}
+	if _, err := fs.Stat(fsys, r.defaultLocale); err != nil {
+		return nil, fmt.Errorf("templates for default locale %q: %w", r.defaultLocale, err)
+	}
+	return r, nil
+}
+
+// Render builds the message for template name addressed to to.
+func (r *Renderer) Render(name, locale, to string, data interface{}) (*Message, error) {
+	dir, err := r.resolve(name, locale)
+	if err != nil {
+		return nil, err
+	}
+	base := dir + "/" + name
+
+	subject, err := r.renderText(base+".subject.txt", data)
+	if err != nil {
+		return nil, err
+	}
+	text, err := r.renderText(base+".txt", data)
+	if err != nil {
+		return nil, err
+	}
+	html, err := r.renderHTML(base+".html", data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject),
+		Text:    text,
+		HTML:    html,
+	}, nil
+}
+
+func (r *Renderer) resolve(name, locale string) (string, error) {
+	for _, candidate := range localeChain(NormalizeLocale(locale), r.defaultLocale) {
+		if _, err := fs.Stat(r.fsys, candidate+"/"+name+".txt"); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("mail template %q not found", name)
+}
+
+func (r *Renderer) renderText(path string, data interface{}) (string, error) {
+	src, err := fs.ReadFile(r.fsys, path)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := texttemplate.New(path).Option("missingkey=error").Parse(string(src))
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// renderHTML returns an empty body when the template has no HTML variant.
+func (r *Renderer) renderHTML(path string, data interface{}) (string, error) {
+	src, err := fs.ReadFile(r.fsys, path)
+	if err != nil {
+		return "", nil
+	}
+	tmpl, err := htmltemplate.New(path).Option("missingkey=error").Parse(string(src))
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// NormalizeLocale lower-cases a BCP 47 style tag such as "pt_BR" into
+// "pt-br" and returns "" for anything that does not look like one.
+func NormalizeLocale(locale string) string {
+	locale = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+	if !localePattern.MatchString(locale) {
+		return ""
+	}
+	return locale
+}
+
+func localeChain(locale, fallback string) []string {
+	var chain []string
+	for locale != "" {
+		chain = append(chain, locale)
+		i := strings.LastIndex(locale, "-")
+		if i < 0 {
+			break
+		}
+		locale = locale[:i]
+	}
+	return append(chain, fallback)
+}
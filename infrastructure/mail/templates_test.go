@@ -0,0 +1,91 @@
+package mail
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRendererFallsBackToClosestLocale(t *testing.T) {
+	fsys := fstest.MapFS{
+		"en/greeting.subject.txt": {Data: []byte("Hello")},
+		"en/greeting.txt":         {Data: []byte("Hello {{.Name}}")},
+		"en/greeting.html":        {Data: []byte("<p>Hello {{.Name}}</p>")},
+		"pt/greeting.subject.txt": {Data: []byte("Olá")},
+		"pt/greeting.txt":         {Data: []byte("Olá {{.Name}}")},
+	}
+	r, err := NewRenderer(fsys, "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"pt_BR": "Olá",
+		"pt":    "Olá",
+		"de-DE": "Hello",
+		"":      "Hello",
+		"../pt": "Hello",
+	}
+	for locale, want := range cases {
+		msg, err := r.Render("greeting", locale, "ann@example.com", map[string]interface{}{"Name": "Ann <Lee>"})
+		if err != nil {
+			t.Errorf("Render for %q: %v", locale, err)
+			continue
+		}
+		if msg.Subject != want {
+			t.Errorf("subject for %q = %q, want %q", locale, msg.Subject, want)
+		}
+	}
+
+	msg, err := r.Render("greeting", "en", "ann@example.com", map[string]interface{}{"Name": "Ann <Lee>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Text != "Hello Ann <Lee>" || !strings.Contains(msg.HTML, "Ann &lt;Lee&gt;") {
+		t.Errorf("rendered text %q and HTML %q", msg.Text, msg.HTML)
+	}
+}
+
+func TestRendererRejectsMissingTemplateAndData(t *testing.T) {
+	r, err := NewRenderer(DefaultTemplates(), "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Render("no_such_template", "en", "ann@example.com", map[string]interface{}{}); err == nil {
+		t.Error("rendering an unknown template succeeded")
+	}
+	if _, err := r.Render(TemplateMagicLink, "en", "ann@example.com", map[string]interface{}{"Name": "Ann"}); err == nil {
+		t.Error("rendering without the link succeeded")
+	}
+}
+
+func TestDefaultTemplatesRenderInEveryLocale(t *testing.T) {
+	r, err := NewRenderer(DefaultTemplates(), "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{
+		"Name":             "Ann",
+		"Link":             "https://example.com/link",
+		"ExpiresInMinutes": 15,
+		"ExpiresInHours":   24,
+		"ExpiresInDays":    7,
+		"LockedMinutes":    15,
+		"NewEmail":         "new@example.com",
+		"PurgeDate":        "1 January 2026",
+	}
+	templates := []string{TemplatePasswordReset, TemplateEmailVerification, TemplateAccountUnlock, TemplateEmailChange, TemplateEmailChangeNotice, TemplateAccountDeletion, TemplateMagicLink}
+	for _, locale := range []string{"en", "es"} {
+		for _, name := range templates {
+			msg, err := r.Render(name, locale, "ann@example.com", data)
+			if err != nil {
+				t.Errorf("%s/%s: %v", locale, name, err)
+				continue
+			}
+			if msg.Subject == "" || msg.Text == "" {
+				t.Errorf("%s/%s rendered an empty subject or body", locale, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Identity is what a verified ID token tells us about the signed-in user.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type IDTokenVerifier interface {
+	// Verify checks rawIDToken and that it carries nonce, which must be
+	// one the caller issued for this sign-in.
+	Verify(rawIDToken, nonce string) (*Identity, error)
+}
+
+type ProviderConfig struct {
+	// Issuers lists every accepted iss value; Google uses two spellings.
+	Issuers   []string
+	ClientIDs []string
+	// JWKSURL may be left empty to resolve it through OIDC discovery on the
+	// first issuer.
+	JWKSURL string
+}
+
+// OIDCVerifier checks ID tokens issued by an OpenID Connect provider: the
+// signature against the provider's JWKS, then iss, aud, exp and nonce.
+type OIDCVerifier struct {
+	config ProviderConfig
+	keys   *RemoteKeySet
+}
+
+func NewOIDCVerifier(config ProviderConfig) (*OIDCVerifier, error) {
+	if len(config.Issuers) == 0 || len(config.ClientIDs) == 0 {
+		return nil, errors.New("issuer and client ID are required")
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	jwksURL := config.JWKSURL
+	if jwksURL == "" {
+		discovered, err := discoverJWKSURL(client, config.Issuers[0])
+		if err != nil {
+			return nil, err
+		}
+		jwksURL = discovered
+	}
+
+	return &OIDCVerifier{
+		config: config,
+		keys:   NewRemoteKeySet(jwksURL, client),
+	}, nil
+}
+
+func GoogleProviderConfig(clientIDs []string) ProviderConfig {
+	return ProviderConfig{
+		Issuers:   []string{"https://accounts.google.com", "accounts.google.com"},
+		ClientIDs: clientIDs,
+		JWKSURL:   "https://www.googleapis.com/oauth2/v3/certs",
+	}
+}
+
+func AppleProviderConfig(clientIDs []string) ProviderConfig {
+	return ProviderConfig{
+		Issuers:   []string{"https://appleid.apple.com"},
+		ClientIDs: clientIDs,
+		JWKSURL:   "https://appleid.apple.com/auth/keys",
+	}
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string      `json:"nonce,omitempty"`
+	Email         string      `json:"email,omitempty"`
+	EmailVerified interface{} `json:"email_verified,omitempty"`
+	Name          string      `json:"name,omitempty"`
+}
+
+func (v *OIDCVerifier) Verify(rawIDToken, nonce string) (*Identity, error) {
+	var claims idTokenClaims
+	_, err := jwt.ParseWithClaims(rawIDToken, &claims, v.keyFunc,
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+
+	if !contains(v.config.Issuers, claims.Issuer) {
+		return nil, errors.New("invalid ID token: unexpected issuer")
+	}
+	if !audienceMatches(claims.Audience, v.config.ClientIDs) {
+		return nil, errors.New("invalid ID token: unexpected audience")
+	}
+	// Without a nonce a token captured from any earlier sign-in could be
+	// replayed until it expires.
+	if nonce == "" || claims.Nonce != nonce {
+		return nil, errors.New("invalid ID token: nonce mismatch")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("invalid ID token: missing subject")
+	}
+
+	return &Identity{
+		Subject:       claims.Subject,
+		Email:         strings.ToLower(strings.TrimSpace(claims.Email)),
+		EmailVerified: isTrue(claims.EmailVerified),
+		Name:          claims.Name,
+	}, nil
+}
+
+func (v *OIDCVerifier) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	return v.keys.Key(kid, token.Method.Alg())
+}
+
+func discoverJWKSURL(client *http.Client, issuer string) (string, error) {
+	res, err := client.Get(strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("OIDC discovery: unexpected status %d", res.StatusCode)
+	}
+
+	var doc struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		return "", err
+	}
+	if doc.JWKSURI == "" {
+		return "", errors.New("OIDC discovery: jwks_uri missing")
+	}
+	return doc.JWKSURI, nil
+}
+
+func audienceMatches(audience jwt.ClaimStrings, clientIDs []string) bool {
+	for _, aud := range audience {
+		if contains(clientIDs, aud) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTrue accepts both the boolean Google sends and the "true" string Apple
+// sends for email_verified.
+func isTrue(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
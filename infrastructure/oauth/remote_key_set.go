@@ -0,0 +1,96 @@
+package oauth
+
+import (
+	"ambassador/infrastructure/security"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type remoteKey struct {
+	algorithm string
+	key       crypto.PublicKey
+}
+
+// RemoteKeySet caches a provider's published JWKS. It refreshes when the
+// cache is older than ttl, or when a token names a kid it has not seen yet
+// (providers rotate keys without notice), but never more than once per
+// minRefresh so a flood of bogus kids cannot hammer the provider.
+type RemoteKeySet struct {
+	url        string
+	client     *http.Client
+	ttl        time.Duration
+	minRefresh time.Duration
+	keys       map[string]remoteKey
+	fetchedAt  time.Time
+	mu         sync.Mutex
+}
+
+func NewRemoteKeySet(url string, client *http.Client) *RemoteKeySet {
+	return &RemoteKeySet{
+		url:        url,
+		client:     client,
+		ttl:        time.Hour,
+		minRefresh: time.Minute,
+		keys:       make(map[string]remoteKey),
+	}
+}
+
+func (s *RemoteKeySet) Key(kid, alg string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[kid]
+	stale := time.Since(s.fetchedAt) > s.ttl
+	if (!ok || stale) && time.Since(s.fetchedAt) > s.minRefresh {
+		if err := s.refresh(); err != nil && !ok {
+			return nil, err
+		}
+		key, ok = s.keys[kid]
+	}
+
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	if key.algorithm != "" && key.algorithm != alg {
+		return nil, errors.New("signing algorithm mismatch")
+	}
+	return key.key, nil
+}
+
+func (s *RemoteKeySet) refresh() error {
+	res, err := s.client.Get(s.url)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch JWKS: unexpected status %d", res.StatusCode)
+	}
+
+	var set security.JWKSet
+	if err := json.NewDecoder(res.Body).Decode(&set); err != nil {
+		return err
+	}
+
+	keys := make(map[string]remoteKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.KeyID] = remoteKey{algorithm: jwk.Algorithm, key: key}
+	}
+
+	s.keys = keys
+	s.fetchedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,168 @@
+// Package passkeytest provides a software authenticator for driving WebAuthn
+// ceremonies in tests.
+package passkeytest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+)
+
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+// Authenticator holds a single ES256 credential and answers ceremonies with
+// attestation "none", the way a platform authenticator would.
+type Authenticator struct {
+	RPID   string
+	Origin string
+	// SkipUserVerification leaves the UV flag unset, as an authenticator
+	// does when only user presence was checked.
+	SkipUserVerification bool
+	// SignCount is sent with the next assertion and then incremented.
+	SignCount uint32
+
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+}
+
+func NewAuthenticator(rpID, origin string) (*Authenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		return nil, err
+	}
+	return &Authenticator{RPID: rpID, Origin: origin, SignCount: 1, key: key, credentialID: credentialID}, nil
+}
+
+// CredentialID is the credential's ID as stored by the relying party.
+func (a *Authenticator) CredentialID() string {
+	return encode(a.credentialID)
+}
+
+// Register answers the options from navigator.credentials.create and returns
+// the JSON a browser would post back.
+func (a *Authenticator) Register(options interface{}) ([]byte, error) {
+	creation, ok := options.(*protocol.CredentialCreation)
+	if !ok {
+		return nil, errors.New("not credential creation options")
+	}
+
+	clientData, err := a.clientData(protocol.CreateCeremony, creation.Response.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	authData := a.authData(flagAttestedData, 0)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialID)))
+	authData = append(authData, a.credentialID...)
+	authData = append(authData, publicKey...)
+
+	attestation, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authData,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"id":    encode(a.credentialID),
+		"rawId": encode(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    encode(clientData),
+			"attestationObject": encode(attestation),
+		},
+	})
+}
+
+// Login answers the options from navigator.credentials.get for the account
+// userID and returns the JSON a browser would post back.
+func (a *Authenticator) Login(options interface{}, userID string) ([]byte, error) {
+	assertion, ok := options.(*protocol.CredentialAssertion)
+	if !ok {
+		return nil, errors.New("not credential assertion options")
+	}
+
+	clientData, err := a.clientData(protocol.AssertCeremony, assertion.Response.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	authData := a.authData(0, a.SignCount)
+	a.SignCount++
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"id":    encode(a.credentialID),
+		"rawId": encode(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    encode(clientData),
+			"authenticatorData": encode(authData),
+			"signature":         encode(signature),
+			"userHandle":        encode([]byte(userID)),
+		},
+	})
+}
+
+func (a *Authenticator) clientData(ceremony protocol.CeremonyType, challenge protocol.URLEncodedBase64) ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"type":      string(ceremony),
+		"challenge": encode(challenge),
+		"origin":    a.Origin,
+	})
+}
+
+// authData is the fixed part of the authenticator data: RP ID hash, flags
+// and sign count.
+func (a *Authenticator) authData(flags byte, signCount uint32) []byte {
+	flags |= flagUserPresent
+	if !a.SkipUserVerification {
+		flags |= flagUserVerified
+	}
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, signCount)
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,275 @@
+package passkey
+
+import (
+	"ambassador/domain/entities"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+type Config struct {
+	// RPID is the relying party ID, normally the site's registrable domain
+	// such as "example.com".
+	RPID          string
+	RPDisplayName string
+	// Origins lists the fully qualified origins allowed to run ceremonies,
+	// e.g. "https://app.example.com".
+	Origins []string
+	Timeout time.Duration
+}
+
+// RelyingParty runs WebAuthn ceremonies. Ceremony state is returned as
+// opaque bytes for the caller to store until the matching finish call.
+type RelyingParty struct {
+	webauthn *webauthn.WebAuthn
+	timeout  time.Duration
+}
+
+func NewRelyingParty(config Config) (*RelyingParty, error) {
+	if config.Timeout == 0 {
+		config.Timeout = 5 * time.Minute
+	}
+
+	timeout := webauthn.TimeoutConfig{Enforce: true, Timeout: config.Timeout, TimeoutUVD: config.Timeout}
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          config.RPID,
+		RPDisplayName: config.RPDisplayName,
+		RPOrigins:     config.Origins,
+		// Attestation is not used to make trust decisions, so ask for none
+		// and accept whatever the authenticator sends.
+		AttestationPreference: protocol.PreferNoAttestation,
+		// A passkey login is a complete login with no second factor after
+		// it, so the authenticator must have verified the user, not just
+		// seen a touch.
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: protocol.VerificationRequired,
+		},
+		Timeouts: webauthn.TimeoutsConfig{Login: timeout, Registration: timeout},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RelyingParty{webauthn: w, timeout: config.Timeout}, nil
+}
+
+func (rp *RelyingParty) Timeout() time.Duration {
+	return rp.timeout
+}
+
+// BeginRegistration returns the options for navigator.credentials.create and
+// the session state. Credentials the user already has are excluded so the
+// same authenticator is not registered twice.
+func (rp *RelyingParty) BeginRegistration(user *entities.User, existing []*entities.WebAuthnCredential) (interface{}, []byte, error) {
+	u := newUser(user, existing)
+
+	var exclusions []protocol.CredentialDescriptor
+	for _, credential := range u.credentials {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	options, session, err := rp.webauthn.BeginRegistration(u, webauthn.WithExclusions(exclusions))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return nil, nil, err
+	}
+	return options, data, nil
+}
+
+// FinishRegistration verifies the authenticator's attestation response and
+// returns the credential to store.
+func (rp *RelyingParty) FinishRegistration(user *entities.User, existing []*entities.WebAuthnCredential, sessionData, response []byte) (*entities.WebAuthnCredential, error) {
+	var session webauthn.SessionData
+	if err := json.Unmarshal(sessionData, &session); err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return nil, errors.New("invalid passkey response")
+	}
+
+	credential, err := rp.webauthn.CreateCredential(newUser(user, existing), session, parsed)
+	if err != nil {
+		return nil, errors.New("passkey verification failed")
+	}
+
+	return toEntity(user.ID, credential), nil
+}
+
+// BeginLogin returns the options for navigator.credentials.get. With a nil
+// user the ceremony is discoverable: the authenticator picks the account.
+func (rp *RelyingParty) BeginLogin(user *entities.User, credentials []*entities.WebAuthnCredential) (interface{}, []byte, error) {
+	var (
+		options *protocol.CredentialAssertion
+		session *webauthn.SessionData
+		err     error
+	)
+	if user == nil {
+		options, session, err = rp.webauthn.BeginDiscoverableLogin()
+	} else {
+		options, session, err = rp.webauthn.BeginLogin(newUser(user, credentials))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return nil, nil, err
+	}
+	return options, data, nil
+}
+
+// UserLookup loads a user and their credentials by user ID.
+type UserLookup func(userID string) (*entities.User, []*entities.WebAuthnCredential, error)
+
+// FinishLogin verifies an assertion and returns the user together with the
+// credential, whose sign count and backup state have been updated and need
+// saving.
+func (rp *RelyingParty) FinishLogin(sessionData, response []byte, lookup UserLookup) (*entities.User, *entities.WebAuthnCredential, error) {
+	var session webauthn.SessionData
+	if err := json.Unmarshal(sessionData, &session); err != nil {
+		return nil, nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return nil, nil, errors.New("invalid passkey response")
+	}
+
+	var found *user
+	load := func(userHandle []byte) (*user, error) {
+		account, credentials, err := lookup(string(userHandle))
+		if err != nil {
+			return nil, err
+		}
+		found = newUser(account, credentials)
+		return found, nil
+	}
+
+	var credential *webauthn.Credential
+	if len(session.UserID) > 0 {
+		u, err := load(session.UserID)
+		if err != nil {
+			return nil, nil, errors.New("passkey verification failed")
+		}
+		// A non-discoverable assertion may omit the user handle; if it is
+		// present it has to match the account the ceremony was started for.
+		if len(parsed.Response.UserHandle) > 0 && !bytes.Equal(parsed.Response.UserHandle, session.UserID) {
+			return nil, nil, errors.New("passkey verification failed")
+		}
+		credential, err = rp.webauthn.ValidateLogin(u, session, parsed)
+	} else {
+		credential, err = rp.webauthn.ValidateDiscoverableLogin(func(_, userHandle []byte) (webauthn.User, error) {
+			return load(userHandle)
+		}, session, parsed)
+	}
+	if err != nil {
+		return nil, nil, errors.New("passkey verification failed")
+	}
+
+	if credential.Authenticator.CloneWarning {
+		return nil, nil, errors.New("passkey sign count went backwards, the authenticator may have been cloned")
+	}
+
+	stored := found.stored[encodeID(credential.ID)]
+	if stored == nil {
+		return nil, nil, errors.New("passkey verification failed")
+	}
+	stored.SignCount = credential.Authenticator.SignCount
+	stored.BackupState = credential.Flags.BackupState
+	stored.LastUsedAt = time.Now()
+
+	return found.account, stored, nil
+}
+
+// user adapts an entities.User and its credentials to webauthn.User.
+type user struct {
+	account     *entities.User
+	credentials []webauthn.Credential
+	stored      map[string]*entities.WebAuthnCredential
+}
+
+func newUser(account *entities.User, credentials []*entities.WebAuthnCredential) *user {
+	u := &user{account: account, stored: make(map[string]*entities.WebAuthnCredential)}
+	for _, credential := range credentials {
+		u.credentials = append(u.credentials, fromEntity(credential))
+		u.stored[credential.ID] = credential
+	}
+	return u
+}
+
+func (u *user) WebAuthnID() []byte {
+	return []byte(u.account.ID)
+}
+
+func (u *user) WebAuthnName() string {
+	return u.account.Email.String()
+}
+
+func (u *user) WebAuthnDisplayName() string {
+	return u.account.FullName
+}
+
+func (u *user) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+func encodeID(id []byte) string {
+	return base64.RawURLEncoding.EncodeToString(id)
+}
+
+func toEntity(userID string, credential *webauthn.Credential) *entities.WebAuthnCredential {
+	transports := make([]string, len(credential.Transport))
+	for i, transport := range credential.Transport {
+		transports[i] = string(transport)
+	}
+
+	return &entities.WebAuthnCredential{
+		ID:              encodeID(credential.ID),
+		UserID:          userID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		Transports:      transports,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+		CreatedAt:       time.Now(),
+	}
+}
+
+func fromEntity(credential *entities.WebAuthnCredential) webauthn.Credential {
+	id, _ := base64.RawURLEncoding.DecodeString(credential.ID)
+
+	transports := make([]protocol.AuthenticatorTransport, len(credential.Transports))
+	for i, transport := range credential.Transports {
+		transports[i] = protocol.AuthenticatorTransport(transport)
+	}
+
+	return webauthn.Credential{
+		ID:              id,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transport:       transports,
+		Flags: webauthn.CredentialFlags{
+			BackupEligible: credential.BackupEligible,
+			BackupState:    credential.BackupState,
+		},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:    credential.AAGUID,
+			SignCount: credential.SignCount,
+		},
+	}
+}
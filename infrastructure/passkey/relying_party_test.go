@@ -0,0 +1,136 @@
+package passkey
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/infrastructure/passkey/passkeytest"
+	"testing"
+	"time"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://example.com"
+)
+
+func newTestRelyingParty(t *testing.T) *RelyingParty {
+	t.Helper()
+	rp, err := NewRelyingParty(Config{RPID: testRPID, RPDisplayName: "Example", Origins: []string{testOrigin}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rp
+}
+
+func newTestUser(t *testing.T) *entities.User {
+	t.Helper()
+	user, err := entities.NewUser("ann@example.com", "Ann Lee", entities.GenderFemale,
+		time.Date(1990, 1, 2, 0, 0, 0, 0, time.UTC), entities.RegMethodEmail, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.ID = "user-1"
+	return user
+}
+
+func newTestAuthenticator(t *testing.T) *passkeytest.Authenticator {
+	t.Helper()
+	authenticator, err := passkeytest.NewAuthenticator(testRPID, testOrigin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authenticator
+}
+
+// register runs a registration ceremony for user with authenticator.
+func register(t *testing.T, rp *RelyingParty, user *entities.User, authenticator *passkeytest.Authenticator) (*entities.WebAuthnCredential, error) {
+	t.Helper()
+	options, session, err := rp.BeginRegistration(user, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := authenticator.Register(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rp.FinishRegistration(user, nil, session, response)
+}
+
+// login runs a discoverable login ceremony with authenticator against the
+// stored credential.
+func login(t *testing.T, rp *RelyingParty, user *entities.User, credential *entities.WebAuthnCredential, authenticator *passkeytest.Authenticator) (*entities.WebAuthnCredential, error) {
+	t.Helper()
+	options, session, err := rp.BeginLogin(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := authenticator.Login(options, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, updated, err := rp.FinishLogin(session, response, func(string) (*entities.User, []*entities.WebAuthnCredential, error) {
+		return user, []*entities.WebAuthnCredential{credential}, nil
+	})
+	return updated, err
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	user := newTestUser(t)
+	authenticator := newTestAuthenticator(t)
+
+	credential, err := register(t, rp, user, authenticator)
+	if err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+	if credential.ID != authenticator.CredentialID() || credential.AttestationType != "none" {
+		t.Errorf("stored credential %s with attestation %q", credential.ID, credential.AttestationType)
+	}
+
+	authenticator.SignCount = 5
+	updated, err := login(t, rp, user, credential, authenticator)
+	if err != nil {
+		t.Fatalf("FinishLogin: %v", err)
+	}
+	if updated.SignCount != 5 {
+		t.Errorf("sign count = %d, want 5", updated.SignCount)
+	}
+}
+
+func TestPasskeyLoginRejectsSignCountGoingBackwards(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	user := newTestUser(t)
+	authenticator := newTestAuthenticator(t)
+
+	credential, err := register(t, rp, user, authenticator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential.SignCount = 10
+
+	authenticator.SignCount = 3
+	if _, err := login(t, rp, user, credential, authenticator); err == nil {
+		t.Error("login with a lower sign count than stored succeeded")
+	}
+}
+
+func TestPasskeyRequiresUserVerification(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	user := newTestUser(t)
+	authenticator := newTestAuthenticator(t)
+
+	credential, err := register(t, rp, user, authenticator)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator.SkipUserVerification = true
+	if _, err := login(t, rp, user, credential, authenticator); err == nil {
+		t.Error("login without user verification succeeded")
+	}
+
+	unverified := newTestAuthenticator(t)
+	unverified.SkipUserVerification = true
+	if _, err := register(t, rp, user, unverified); err == nil {
+		t.Error("registration without user verification succeeded")
+	}
+}
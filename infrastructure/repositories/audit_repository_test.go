@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"ambassador/domain/entities"
+	domainrepos "ambassador/domain/repositories"
+	"ambassador/internal/shared/database"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func auditBackends(t *testing.T) map[string]domainrepos.AuditRepository {
+	t.Helper()
+	db, err := database.Open(database.DriverSQLite, filepath.Join(t.TempDir(), "ambassador.db"), database.PoolConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.Migrate(db, database.DriverSQLite, database.AuthMigrations); err != nil {
+		t.Fatal(err)
+	}
+	return map[string]domainrepos.AuditRepository{
+		"memory": NewMemoryAuditRepository(),
+		"sqlite": NewSQLAuditRepository(db, database.DriverSQLite, 5*time.Second),
+	}
+}
+
+func TestAuditRepositoryFiltersByActor(t *testing.T) {
+	for name, repo := range auditBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			byAdmin := entities.NewAuditEvent(entities.AuditUserForcedLogout, "user-1", entities.ClientInfo{ActorID: "admin-1", RequestID: "req-1"}, map[string]string{"count": "2"})
+			bySelf := entities.NewAuditEvent(entities.AuditLogout, "user-1", entities.ClientInfo{ActorID: "user-1"}, nil)
+			for _, event := range []*entities.AuditEvent{byAdmin, bySelf} {
+				if err := repo.Save(event); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			events, err := repo.Find(domainrepos.AuditEventFilter{ActorID: "admin-1"})
+			if err != nil {
+				t.Fatalf("Find: %v", err)
+			}
+			if len(events) != 1 || events[0].ID != byAdmin.ID {
+				t.Fatalf("Find by actor = %v, want only the admin's event", events)
+			}
+			if got := events[0]; got.ActorID != "admin-1" || got.UserID != "user-1" || got.RequestID != "req-1" || got.Details["count"] != "2" {
+				t.Errorf("stored event = %+v, want it as saved", got)
+			}
+
+			if events, err := repo.Find(domainrepos.AuditEventFilter{UserID: "user-1"}); err != nil || len(events) != 2 {
+				t.Errorf("Find by user = %d events, %v; want both", len(events), err)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"sort"
+	"sync"
+)
+
+type MemoryAuditRepository struct {
+	events []*entities.AuditEvent
+	mu     sync.RWMutex
+}
+
+func NewMemoryAuditRepository() repositories.AuditRepository {
+	return &MemoryAuditRepository{}
+}
+
+func (r *MemoryAuditRepository) Save(event *entities.AuditEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *MemoryAuditRepository) Find(filter repositories.AuditEventFilter) ([]*entities.AuditEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var events []*entities.AuditEvent
+	for _, event := range r.events {
+		if matchesAuditFilter(event, filter) {
+			events = append(events, event)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].CreatedAt.Equal(events[j].CreatedAt) {
+			return events[i].CreatedAt.After(events[j].CreatedAt)
+		}
+		return events[i].ID > events[j].ID
+	})
+	if filter.Limit > 0 && len(events) > filter.Limit {
+		events = events[:filter.Limit]
+	}
+	return events, nil
+}
+
+func matchesAuditFilter(event *entities.AuditEvent, filter repositories.AuditEventFilter) bool {
+	switch {
+	case filter.UserID != "" && event.UserID != filter.UserID:
+		return false
+	case filter.ActorID != "" && event.ActorID != filter.ActorID:
+		return false
+	case filter.Type != "" && event.Type != filter.Type:
+		return false
+	case filter.IPAddress != "" && event.IPAddress != filter.IPAddress:
+		return false
+	case filter.RequestID != "" && event.RequestID != filter.RequestID:
+		return false
+	case !filter.Since.IsZero() && event.CreatedAt.Before(filter.Since):
+		return false
+	case !filter.Until.IsZero() && !event.CreatedAt.Before(filter.Until):
+		return false
+	}
+
+	if !filter.BeforeTime.IsZero() {
+		if event.CreatedAt.After(filter.BeforeTime) {
+			return false
+		}
+		if event.CreatedAt.Equal(filter.BeforeTime) && event.ID >= filter.BeforeID {
+			return false
+		}
+	}
+	return true
+}
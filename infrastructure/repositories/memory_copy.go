@@ -0,0 +1,61 @@
+package repositories
+
+import "ambassador/domain/entities"
+
+// The memory repositories store and hand out copies, as a database would.
+// Sharing pointers would let a caller's changes leak into storage without a
+// Save, including changes made ahead of a unit of work that then fails.
+
+func copyUser(user *entities.User) *entities.User {
+	copied := *user
+	copied.Roles = copyStrings(user.Roles)
+	return &copied
+}
+
+func copyToken(token *entities.Token) *entities.Token {
+	copied := *token
+	copied.Scopes = copyStrings(token.Scopes)
+	copied.Roles = copyStrings(token.Roles)
+	return &copied
+}
+
+func copySession(session *entities.Session) *entities.Session {
+	copied := *session
+	return &copied
+}
+
+func copyIdentity(identity *entities.ExternalIdentity) *entities.ExternalIdentity {
+	copied := *identity
+	return &copied
+}
+
+func copyTOTPCredential(credential *entities.TOTPCredential) *entities.TOTPCredential {
+	copied := *credential
+	return &copied
+}
+
+func copyRecoveryCodes(codes []*entities.RecoveryCode) []*entities.RecoveryCode {
+	var copied []*entities.RecoveryCode
+	for _, code := range codes {
+		c := *code
+		copied = append(copied, &c)
+	}
+	return copied
+}
+
+func copyWebAuthnCredential(credential *entities.WebAuthnCredential) *entities.WebAuthnCredential {
+	copied := *credential
+	copied.PublicKey = append([]byte(nil), credential.PublicKey...)
+	copied.AAGUID = append([]byte(nil), credential.AAGUID...)
+	copied.Transports = copyStrings(credential.Transports)
+	return &copied
+}
+
+// copyStrings keeps nil apart from empty: a nil Token.Scopes means the
+// token is unrestricted.
+func copyStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	return append([]string{}, values...)
+}
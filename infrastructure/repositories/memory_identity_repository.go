@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"errors"
+	"sync"
+)
+
+type MemoryIdentityRepository struct {
+	identities map[string]*entities.ExternalIdentity
+	mu         sync.RWMutex
+}
+
+func NewMemoryIdentityRepository() repositories.IdentityRepository {
+	return &MemoryIdentityRepository{
+		identities: make(map[string]*entities.ExternalIdentity),
+	}
+}
+
+func identityKey(provider entities.RegistrationMethod, subject string) string {
+	return string(provider) + ":" + subject
+}
+
+func (r *MemoryIdentityRepository) Save(identity *entities.ExternalIdentity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.identities[identityKey(identity.Provider, identity.Subject)] = copyIdentity(identity)
+	return nil
+}
+
+func (r *MemoryIdentityRepository) FindByProviderSubject(provider entities.RegistrationMethod, subject string) (*entities.ExternalIdentity, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	identity, exists := r.identities[identityKey(provider, subject)]
+	if !exists {
+		return nil, errors.New("identity not found")
+	}
+	return copyIdentity(identity), nil
+}
+
+func (r *MemoryIdentityRepository) FindByUserID(userID string) ([]*entities.ExternalIdentity, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var identities []*entities.ExternalIdentity
+	for _, identity := range r.identities {
+		if identity.UserID == userID {
+			identities = append(identities, copyIdentity(identity))
+		}
+	}
+	return identities, nil
+}
+
+func (r *MemoryIdentityRepository) DeleteByUserID(userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, identity := range r.identities {
+		if identity.UserID == userID {
+			delete(r.identities, key)
+		}
+	}
+	return nil
+}
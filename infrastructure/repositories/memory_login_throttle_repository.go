@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"errors"
+	"sync"
+	"time"
+)
+
+type MemoryLoginThrottleRepository struct {
+	throttles map[string]*entities.LoginThrottle
+	mu        sync.Mutex
+}
+
+func NewMemoryLoginThrottleRepository() repositories.LoginThrottleRepository {
+	return &MemoryLoginThrottleRepository{
+		throttles: make(map[string]*entities.LoginThrottle),
+	}
+}
+
+func (r *MemoryLoginThrottleRepository) Find(key string) (*entities.LoginThrottle, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	throttle, exists := r.throttles[key]
+	if !exists {
+		return nil, errors.New("login throttle not found")
+	}
+	copied := *throttle
+	return &copied, nil
+}
+
+func (r *MemoryLoginThrottleRepository) RecordFailure(key string, at, since time.Time) (*entities.LoginThrottle, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	throttle, exists := r.throttles[key]
+	if !exists {
+		throttle = &entities.LoginThrottle{Key: key}
+		r.throttles[key] = throttle
+	}
+	if throttle.LastFailedAt.Before(since) {
+		throttle.FailedCount = 0
+	}
+	throttle.FailedCount++
+	throttle.LastFailedAt = at
+
+	copied := *throttle
+	return &copied, nil
+}
+
+func (r *MemoryLoginThrottleRepository) Lock(key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	throttle, exists := r.throttles[key]
+	if !exists {
+		throttle = &entities.LoginThrottle{Key: key}
+		r.throttles[key] = throttle
+	}
+	throttle.FailedCount = 0
+	throttle.LockedUntil = until
+	return nil
+}
+
+func (r *MemoryLoginThrottleRepository) Reset(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.throttles, key)
+	return nil
+}
@@ -0,0 +1,77 @@
+package repositories
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"errors"
+	"sync"
+	"time"
+)
+
+type MemoryMFARepository struct {
+	totp          map[string]*entities.TOTPCredential
+	recoveryCodes map[string][]*entities.RecoveryCode
+	mu            sync.RWMutex
+}
+
+func NewMemoryMFARepository() repositories.MFARepository {
+	return &MemoryMFARepository{
+		totp:          make(map[string]*entities.TOTPCredential),
+		recoveryCodes: make(map[string][]*entities.RecoveryCode),
+	}
+}
+
+func (r *MemoryMFARepository) SaveTOTP(credential *entities.TOTPCredential) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.totp[credential.UserID] = copyTOTPCredential(credential)
+	return nil
+}
+
+func (r *MemoryMFARepository) FindTOTP(userID string) (*entities.TOTPCredential, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	credential, exists := r.totp[userID]
+	if !exists {
+		return nil, errors.New("totp credential not found")
+	}
+	return copyTOTPCredential(credential), nil
+}
+
+func (r *MemoryMFARepository) DeleteTOTP(userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.totp, userID)
+	return nil
+}
+
+func (r *MemoryMFARepository) ReplaceRecoveryCodes(userID string, codes []*entities.RecoveryCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(codes) == 0 {
+		delete(r.recoveryCodes, userID)
+		return nil
+	}
+	r.recoveryCodes[userID] = copyRecoveryCodes(codes)
+	return nil
+}
+
+func (r *MemoryMFARepository) FindRecoveryCodes(userID string) ([]*entities.RecoveryCode, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return copyRecoveryCodes(r.recoveryCodes[userID]), nil
+}
+
+func (r *MemoryMFARepository) ConsumeRecoveryCode(userID, codeHash string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, code := range r.recoveryCodes[userID] {
+		if code.CodeHash == codeHash && !code.IsUsed() {
+			code.UsedAt = time.Now()
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"sort"
+	"sync"
+)
+
+type MemoryPasswordHistoryRepository struct {
+	entries map[string][]*entities.PasswordHistoryEntry
+	mu      sync.RWMutex
+}
+
+func NewMemoryPasswordHistoryRepository() repositories.PasswordHistoryRepository {
+	return &MemoryPasswordHistoryRepository{
+		entries: make(map[string][]*entities.PasswordHistoryEntry),
+	}
+}
+
+func (r *MemoryPasswordHistoryRepository) Add(entry *entities.PasswordHistoryEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := append(r.entries[entry.UserID], entry)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.After(entries[j].CreatedAt)
+	})
+	r.entries[entry.UserID] = entries
+	return nil
+}
+
+func (r *MemoryPasswordHistoryRepository) FindRecent(userID string, limit int) ([]*entities.PasswordHistoryEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := r.entries[userID]
+	if limit < len(entries) {
+		entries = entries[:limit]
+	}
+	return append([]*entities.PasswordHistoryEntry(nil), entries...), nil
+}
+
+func (r *MemoryPasswordHistoryRepository) Prune(userID string, keep int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := r.entries[userID]
+	if keep <= 0 {
+		delete(r.entries, userID)
+	} else if keep < len(entries) {
+		r.entries[userID] = entries[:keep:keep]
+	}
+	return nil
+}
+
+func (r *MemoryPasswordHistoryRepository) DeleteByUserID(userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.entries, userID)
+	return nil
+}
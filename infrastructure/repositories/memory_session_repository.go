@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+type MemorySessionRepository struct {
+	sessions map[string]*entities.Session
+	mu       sync.RWMutex
+}
+
+func NewMemorySessionRepository() repositories.SessionRepository {
+	return &MemorySessionRepository{
+		sessions: make(map[string]*entities.Session),
+	}
+}
+
+func (r *MemorySessionRepository) Save(session *entities.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[session.ID] = copySession(session)
+	return nil
+}
+
+func (r *MemorySessionRepository) FindByID(id string) (*entities.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	session, exists := r.sessions[id]
+	if !exists {
+		return nil, errors.New("session not found")
+	}
+	return copySession(session), nil
+}
+
+func (r *MemorySessionRepository) FindByUserID(userID string) ([]*entities.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var sessions []*entities.Session
+	for _, session := range r.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, copySession(session))
+		}
+	}
+	sortSessions(sessions)
+	return sessions, nil
+}
+
+func (r *MemorySessionRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, id)
+	return nil
+}
+
+func (r *MemorySessionRepository) DeleteByUserID(userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, session := range r.sessions {
+		if session.UserID == userID {
+			delete(r.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (r *MemorySessionRepository) DeleteExpired() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, session := range r.sessions {
+		if session.ExpiresAt.Before(now) {
+			delete(r.sessions, id)
+		}
+	}
+	return nil
+}
+
+func sortSessions(sessions []*entities.Session) {
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+}
@@ -23,7 +23,7 @@ func NewMemoryTokenRepository() repositories.TokenRepository {
 func (r *MemoryTokenRepository) Save(token *entities.Token) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.tokens[token.Value] = token
+	r.tokens[token.Value] = copyToken(token)
 	return nil
 }
 
@@ -35,7 +35,7 @@ func (r *MemoryTokenRepository) FindByValue(value string) (*entities.Token, erro
 	if !exists {
 		return nil, errors.New("token not found")
 	}
-	return token, nil
+	return copyToken(token), nil
 }
 
 func (r *MemoryTokenRepository) Delete(value string) error {
@@ -77,8 +77,32 @@ func (r *MemoryTokenRepository) FindByUserID(userID string, tokenType entities.T
 	var tokens []*entities.Token
 	for _, token := range r.tokens {
 		if token.UserID == userID && token.Type == tokenType {
-			tokens = append(tokens, token)
+			tokens = append(tokens, copyToken(token))
 		}
 	}
 	return tokens, nil
+}
+
+func (r *MemoryTokenRepository) DeleteByUserID(userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for value, token := range r.tokens {
+		if token.UserID == userID {
+			delete(r.tokens, value)
+		}
+	}
+	return nil
+}
+
+func (r *MemoryTokenRepository) DeleteByFamily(familyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for value, token := range r.tokens {
+		if token.FamilyID == familyID {
+			delete(r.tokens, value)
+		}
+	}
+	return nil
 }
\ No newline at end of file
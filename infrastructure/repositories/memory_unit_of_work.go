@@ -0,0 +1,661 @@
+package repositories
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// MemoryUnitOfWork stages writes in overlay repositories and only applies
+// them to the underlying repositories once fn has succeeded, which gives the
+// in-memory backend the same all-or-nothing behaviour as a SQL transaction.
+// Staged entities are copies, so nothing fn changes is visible outside it
+// before the commit, and the memory repositories it wraps cannot fail a
+// write part way through applying them.
+type MemoryUnitOfWork struct {
+	base repositories.TxRepositories
+	mu   sync.Mutex
+}
+
+func NewMemoryUnitOfWork(base repositories.TxRepositories) repositories.UnitOfWork {
+	return &MemoryUnitOfWork{base: base}
+}
+
+func (u *MemoryUnitOfWork) Do(ctx context.Context, fn func(repos *repositories.TxRepositories) error) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var ops []func() error
+	users := &stagedUserRepository{base: u.base.Users, saved: make(map[string]*entities.User), ops: &ops}
+	tokens := &stagedTokenRepository{
+		base:            u.base.Tokens,
+		saved:           make(map[string]*entities.Token),
+		deleted:         make(map[string]bool),
+		deletedFamilies: make(map[string]bool),
+		ops:             &ops,
+	}
+
+	identities := &stagedIdentityRepository{base: u.base.Identities, saved: make(map[string]*entities.ExternalIdentity), ops: &ops}
+	mfa := &stagedMFARepository{
+		base:          u.base.MFA,
+		totp:          make(map[string]*entities.TOTPCredential),
+		recoveryCodes: make(map[string][]*entities.RecoveryCode),
+		ops:           &ops,
+	}
+	sessions := &stagedSessionRepository{base: u.base.Sessions, saved: make(map[string]*entities.Session), ops: &ops}
+
+	repos := &repositories.TxRepositories{Users: users, Tokens: tokens, Identities: identities, MFA: mfa, Sessions: sessions}
+	if u.base.PasswordHistory != nil {
+		repos.PasswordHistory = &stagedPasswordHistoryRepository{
+			base:  u.base.PasswordHistory,
+			added: make(map[string][]*entities.PasswordHistoryEntry),
+			keep:  make(map[string]int),
+			ops:   &ops,
+		}
+	}
+	if u.base.WebAuthn != nil {
+		repos.WebAuthn = &stagedWebAuthnRepository{base: u.base.WebAuthn, saved: make(map[string]*entities.WebAuthnCredential), ops: &ops}
+	}
+
+	if err := fn(repos); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for _, op := range ops {
+		if err := op(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stagedUserRepository keeps staged users by ID; a nil entry marks a
+// staged deletion.
+type stagedUserRepository struct {
+	base  repositories.UserRepository
+	saved map[string]*entities.User
+	ops   *[]func() error
+}
+
+func (r *stagedUserRepository) Save(user *entities.User) error {
+	user = copyUser(user)
+	r.saved[user.ID] = user
+	*r.ops = append(*r.ops, func() error { return r.base.Save(user) })
+	return nil
+}
+
+func (r *stagedUserRepository) FindByEmail(email string) (*entities.User, error) {
+	for _, user := range r.saved {
+		if user != nil && user.Email.String() == email {
+			return copyUser(user), nil
+		}
+	}
+
+	user, err := r.base.FindByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if staged, ok := r.saved[user.ID]; ok && staged == nil {
+		return nil, errors.New("user not found")
+	}
+	return user, nil
+}
+
+func (r *stagedUserRepository) FindByID(id string) (*entities.User, error) {
+	if user, ok := r.saved[id]; ok {
+		if user == nil {
+			return nil, errors.New("user not found")
+		}
+		return copyUser(user), nil
+	}
+	return r.base.FindByID(id)
+}
+
+func (r *stagedUserRepository) ExistsByEmail(email string) (bool, error) {
+	for _, user := range r.saved {
+		if user != nil && user.Email.String() == email {
+			return true, nil
+		}
+	}
+
+	exists, err := r.base.ExistsByEmail(email)
+	if err != nil || !exists {
+		return exists, err
+	}
+	// A user deleted in this transaction no longer holds the address.
+	user, err := r.base.FindByEmail(email)
+	if err != nil {
+		return false, err
+	}
+	if staged, ok := r.saved[user.ID]; ok && staged == nil {
+		return false, nil
+	}
+	return true, nil
+}
+
+// List only sees committed users; nothing inside a transaction lists users.
+func (r *stagedUserRepository) List(filter repositories.UserFilter) ([]*entities.User, error) {
+	return r.base.List(filter)
+}
+
+func (r *stagedUserRepository) Delete(id string) error {
+	r.saved[id] = nil
+	*r.ops = append(*r.ops, func() error { return r.base.Delete(id) })
+	return nil
+}
+
+type stagedTokenRepository struct {
+	base            repositories.TokenRepository
+	saved           map[string]*entities.Token
+	deleted         map[string]bool
+	deletedFamilies map[string]bool
+	deletedUsers    map[string]bool
+	ops             *[]func() error
+}
+
+func (r *stagedTokenRepository) Save(token *entities.Token) error {
+	token = copyToken(token)
+	r.saved[token.Value] = token
+	delete(r.deleted, token.Value)
+	*r.ops = append(*r.ops, func() error { return r.base.Save(token) })
+	return nil
+}
+
+func (r *stagedTokenRepository) FindByValue(value string) (*entities.Token, error) {
+	if token, ok := r.saved[value]; ok {
+		return copyToken(token), nil
+	}
+	if r.deleted[value] {
+		return nil, errors.New("token not found")
+	}
+
+	token, err := r.base.FindByValue(value)
+	if err != nil {
+		return nil, err
+	}
+	if r.deletedFamilies[token.FamilyID] || r.deletedUsers[token.UserID] {
+		return nil, errors.New("token not found")
+	}
+	return token, nil
+}
+
+func (r *stagedTokenRepository) Delete(value string) error {
+	delete(r.saved, value)
+	r.deleted[value] = true
+	*r.ops = append(*r.ops, func() error { return r.base.Delete(value) })
+	return nil
+}
+
+func (r *stagedTokenRepository) DeleteExpired() error {
+	now := time.Now()
+	for value, token := range r.saved {
+		if token.ExpiresAt.Before(now) {
+			delete(r.saved, value)
+		}
+	}
+	*r.ops = append(*r.ops, r.base.DeleteExpired)
+	return nil
+}
+
+func (r *stagedTokenRepository) DeleteAllUserTokens(userID string, tokenType entities.TokenType) error {
+	existing, err := r.base.FindByUserID(userID, tokenType)
+	if err != nil {
+		return err
+	}
+	for _, token := range existing {
+		r.deleted[token.Value] = true
+	}
+	for value, token := range r.saved {
+		if token.UserID == userID && token.Type == tokenType {
+			delete(r.saved, value)
+		}
+	}
+	*r.ops = append(*r.ops, func() error { return r.base.DeleteAllUserTokens(userID, tokenType) })
+	return nil
+}
+
+func (r *stagedTokenRepository) FindByUserID(userID string, tokenType entities.TokenType) ([]*entities.Token, error) {
+	existing, err := r.base.FindByUserID(userID, tokenType)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens []*entities.Token
+	for _, token := range existing {
+		if _, staged := r.saved[token.Value]; !staged && !r.deleted[token.Value] && !r.deletedFamilies[token.FamilyID] && !r.deletedUsers[token.UserID] {
+			tokens = append(tokens, token)
+		}
+	}
+	for _, token := range r.saved {
+		if token.UserID == userID && token.Type == tokenType {
+			tokens = append(tokens, copyToken(token))
+		}
+	}
+	return tokens, nil
+}
+
+func (r *stagedTokenRepository) DeleteByFamily(familyID string) error {
+	r.deletedFamilies[familyID] = true
+	for value, token := range r.saved {
+		if token.FamilyID == familyID {
+			delete(r.saved, value)
+		}
+	}
+	*r.ops = append(*r.ops, func() error { return r.base.DeleteByFamily(familyID) })
+	return nil
+}
+
+func (r *stagedTokenRepository) DeleteByUserID(userID string) error {
+	if r.deletedUsers == nil {
+		r.deletedUsers = make(map[string]bool)
+	}
+	r.deletedUsers[userID] = true
+	for value, token := range r.saved {
+		if token.UserID == userID {
+			delete(r.saved, value)
+		}
+	}
+	*r.ops = append(*r.ops, func() error { return r.base.DeleteByUserID(userID) })
+	return nil
+}
+
+type stagedIdentityRepository struct {
+	base         repositories.IdentityRepository
+	saved        map[string]*entities.ExternalIdentity
+	deletedUsers map[string]bool
+	ops          *[]func() error
+}
+
+func (r *stagedIdentityRepository) Save(identity *entities.ExternalIdentity) error {
+	identity = copyIdentity(identity)
+	r.saved[identityKey(identity.Provider, identity.Subject)] = identity
+	*r.ops = append(*r.ops, func() error { return r.base.Save(identity) })
+	return nil
+}
+
+func (r *stagedIdentityRepository) FindByProviderSubject(provider entities.RegistrationMethod, subject string) (*entities.ExternalIdentity, error) {
+	if identity, ok := r.saved[identityKey(provider, subject)]; ok {
+		return copyIdentity(identity), nil
+	}
+
+	identity, err := r.base.FindByProviderSubject(provider, subject)
+	if err != nil {
+		return nil, err
+	}
+	if r.deletedUsers[identity.UserID] {
+		return nil, errors.New("identity not found")
+	}
+	return identity, nil
+}
+
+func (r *stagedIdentityRepository) FindByUserID(userID string) ([]*entities.ExternalIdentity, error) {
+	var identities []*entities.ExternalIdentity
+	if !r.deletedUsers[userID] {
+		existing, err := r.base.FindByUserID(userID)
+		if err != nil {
+			return nil, err
+		}
+		identities = existing
+	}
+	for key, identity := range r.saved {
+		if identity.UserID != userID {
+			continue
+		}
+		replaced := false
+		for i, existing := range identities {
+			if identityKey(existing.Provider, existing.Subject) == key {
+				identities[i] = copyIdentity(identity)
+				replaced = true
+			}
+		}
+		if !replaced {
+			identities = append(identities, copyIdentity(identity))
+		}
+	}
+	return identities, nil
+}
+
+func (r *stagedIdentityRepository) DeleteByUserID(userID string) error {
+	if r.deletedUsers == nil {
+		r.deletedUsers = make(map[string]bool)
+	}
+	r.deletedUsers[userID] = true
+	for key, identity := range r.saved {
+		if identity.UserID == userID {
+			delete(r.saved, key)
+		}
+	}
+	*r.ops = append(*r.ops, func() error { return r.base.DeleteByUserID(userID) })
+	return nil
+}
+
+// stagedMFARepository keeps staged TOTP credentials and recovery code sets
+// per user; a nil entry marks a staged deletion.
+type stagedMFARepository struct {
+	base          repositories.MFARepository
+	totp          map[string]*entities.TOTPCredential
+	recoveryCodes map[string][]*entities.RecoveryCode
+	ops           *[]func() error
+}
+
+func (r *stagedMFARepository) SaveTOTP(credential *entities.TOTPCredential) error {
+	credential = copyTOTPCredential(credential)
+	r.totp[credential.UserID] = credential
+	*r.ops = append(*r.ops, func() error { return r.base.SaveTOTP(credential) })
+	return nil
+}
+
+func (r *stagedMFARepository) FindTOTP(userID string) (*entities.TOTPCredential, error) {
+	if credential, ok := r.totp[userID]; ok {
+		if credential == nil {
+			return nil, errors.New("totp credential not found")
+		}
+		return copyTOTPCredential(credential), nil
+	}
+	return r.base.FindTOTP(userID)
+}
+
+func (r *stagedMFARepository) DeleteTOTP(userID string) error {
+	r.totp[userID] = nil
+	*r.ops = append(*r.ops, func() error { return r.base.DeleteTOTP(userID) })
+	return nil
+}
+
+func (r *stagedMFARepository) ReplaceRecoveryCodes(userID string, codes []*entities.RecoveryCode) error {
+	codes = copyRecoveryCodes(codes)
+	r.recoveryCodes[userID] = append([]*entities.RecoveryCode{}, codes...)
+	*r.ops = append(*r.ops, func() error { return r.base.ReplaceRecoveryCodes(userID, codes) })
+	return nil
+}
+
+func (r *stagedMFARepository) FindRecoveryCodes(userID string) ([]*entities.RecoveryCode, error) {
+	if codes, ok := r.recoveryCodes[userID]; ok {
+		return copyRecoveryCodes(codes), nil
+	}
+	return r.base.FindRecoveryCodes(userID)
+}
+
+func (r *stagedMFARepository) ConsumeRecoveryCode(userID, codeHash string) (bool, error) {
+	if codes, ok := r.recoveryCodes[userID]; ok {
+		for _, code := range codes {
+			if code.CodeHash == codeHash && !code.IsUsed() {
+				code.UsedAt = time.Now()
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	// The base repository consumes atomically, so the code is only spent
+	// when the unit of work commits; until then check it is still unused.
+	// Another request may spend it first, which is the one write that can
+	// fail on commit, so it goes ahead of every other write.
+	codes, err := r.base.FindRecoveryCodes(userID)
+	if err != nil {
+		return false, err
+	}
+	for _, code := range codes {
+		if code.CodeHash == codeHash && !code.IsUsed() {
+			consume := func() error {
+				consumed, err := r.base.ConsumeRecoveryCode(userID, codeHash)
+				if err == nil && !consumed {
+					err = errors.New("recovery code already used")
+				}
+				return err
+			}
+			*r.ops = append([]func() error{consume}, *r.ops...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// stagedSessionRepository keeps staged sessions by ID; a nil entry marks a
+// staged deletion.
+type stagedSessionRepository struct {
+	base         repositories.SessionRepository
+	saved        map[string]*entities.Session
+	deletedUsers map[string]bool
+	ops          *[]func() error
+}
+
+func (r *stagedSessionRepository) Save(session *entities.Session) error {
+	session = copySession(session)
+	r.saved[session.ID] = session
+	*r.ops = append(*r.ops, func() error { return r.base.Save(session) })
+	return nil
+}
+
+func (r *stagedSessionRepository) FindByID(id string) (*entities.Session, error) {
+	if session, ok := r.saved[id]; ok {
+		if session == nil {
+			return nil, errors.New("session not found")
+		}
+		return copySession(session), nil
+	}
+
+	session, err := r.base.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if r.deletedUsers[session.UserID] {
+		return nil, errors.New("session not found")
+	}
+	return session, nil
+}
+
+func (r *stagedSessionRepository) FindByUserID(userID string) ([]*entities.Session, error) {
+	var sessions []*entities.Session
+	if !r.deletedUsers[userID] {
+		existing, err := r.base.FindByUserID(userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, session := range existing {
+			if _, staged := r.saved[session.ID]; !staged {
+				sessions = append(sessions, session)
+			}
+		}
+	}
+	for _, session := range r.saved {
+		if session != nil && session.UserID == userID {
+			sessions = append(sessions, copySession(session))
+		}
+	}
+	sortSessions(sessions)
+	return sessions, nil
+}
+
+func (r *stagedSessionRepository) Delete(id string) error {
+	r.saved[id] = nil
+	*r.ops = append(*r.ops, func() error { return r.base.Delete(id) })
+	return nil
+}
+
+func (r *stagedSessionRepository) DeleteByUserID(userID string) error {
+	if r.deletedUsers == nil {
+		r.deletedUsers = make(map[string]bool)
+	}
+	r.deletedUsers[userID] = true
+	for id, session := range r.saved {
+		if session != nil && session.UserID == userID {
+			delete(r.saved, id)
+		}
+	}
+	*r.ops = append(*r.ops, func() error { return r.base.DeleteByUserID(userID) })
+	return nil
+}
+
+func (r *stagedSessionRepository) DeleteExpired() error {
+	now := time.Now()
+	for id, session := range r.saved {
+		if session != nil && session.ExpiresAt.Before(now) {
+			delete(r.saved, id)
+		}
+	}
+	*r.ops = append(*r.ops, r.base.DeleteExpired)
+	return nil
+}
+
+// stagedPasswordHistoryRepository keeps staged entries per user, newest
+// first, in front of the base entries; keep records a staged prune and
+// deletedUsers a staged deletion.
+type stagedPasswordHistoryRepository struct {
+	base         repositories.PasswordHistoryRepository
+	added        map[string][]*entities.PasswordHistoryEntry
+	keep         map[string]int
+	deletedUsers map[string]bool
+	ops          *[]func() error
+}
+
+func (r *stagedPasswordHistoryRepository) Add(entry *entities.PasswordHistoryEntry) error {
+	copied := *entry
+	entry = &copied
+	r.added[entry.UserID] = append([]*entities.PasswordHistoryEntry{entry}, r.added[entry.UserID]...)
+	if _, ok := r.keep[entry.UserID]; ok {
+		r.keep[entry.UserID]++
+	}
+	*r.ops = append(*r.ops, func() error { return r.base.Add(entry) })
+	return nil
+}
+
+func (r *stagedPasswordHistoryRepository) FindRecent(userID string, limit int) ([]*entities.PasswordHistoryEntry, error) {
+	if keep, ok := r.keep[userID]; ok && keep < limit {
+		limit = keep
+	}
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	entries := append([]*entities.PasswordHistoryEntry(nil), r.added[userID]...)
+	if !r.deletedUsers[userID] && len(entries) < limit {
+		existing, err := r.base.FindRecent(userID, limit-len(entries))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, existing...)
+	}
+	if limit < len(entries) {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+func (r *stagedPasswordHistoryRepository) Prune(userID string, keep int) error {
+	if current, ok := r.keep[userID]; !ok || keep < current {
+		r.keep[userID] = keep
+	}
+	*r.ops = append(*r.ops, func() error { return r.base.Prune(userID, keep) })
+	return nil
+}
+
+func (r *stagedPasswordHistoryRepository) DeleteByUserID(userID string) error {
+	if r.deletedUsers == nil {
+		r.deletedUsers = make(map[string]bool)
+	}
+	r.deletedUsers[userID] = true
+	delete(r.added, userID)
+	delete(r.keep, userID)
+	*r.ops = append(*r.ops, func() error { return r.base.DeleteByUserID(userID) })
+	return nil
+}
+
+// stagedWebAuthnRepository keeps staged credentials by ID; a nil entry
+// marks a staged deletion. Ceremony sessions are not staged: saving one
+// waits for the commit, but taking one spends it straight away, as a
+// ceremony that was looked at must not be finished twice either way.
+type stagedWebAuthnRepository struct {
+	base         repositories.WebAuthnRepository
+	saved        map[string]*entities.WebAuthnCredential
+	deletedUsers map[string]bool
+	ops          *[]func() error
+}
+
+func (r *stagedWebAuthnRepository) SaveCredential(credential *entities.WebAuthnCredential) error {
+	credential = copyWebAuthnCredential(credential)
+	r.saved[credential.ID] = credential
+	*r.ops = append(*r.ops, func() error { return r.base.SaveCredential(credential) })
+	return nil
+}
+
+func (r *stagedWebAuthnRepository) FindCredential(credentialID string) (*entities.WebAuthnCredential, error) {
+	if credential, ok := r.saved[credentialID]; ok {
+		if credential == nil {
+			return nil, errors.New("credential not found")
+		}
+		return copyWebAuthnCredential(credential), nil
+	}
+
+	credential, err := r.base.FindCredential(credentialID)
+	if err != nil {
+		return nil, err
+	}
+	if r.deletedUsers[credential.UserID] {
+		return nil, errors.New("credential not found")
+	}
+	return credential, nil
+}
+
+func (r *stagedWebAuthnRepository) FindCredentialsByUserID(userID string) ([]*entities.WebAuthnCredential, error) {
+	var credentials []*entities.WebAuthnCredential
+	if !r.deletedUsers[userID] {
+		existing, err := r.base.FindCredentialsByUserID(userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, credential := range existing {
+			if _, staged := r.saved[credential.ID]; !staged {
+				credentials = append(credentials, credential)
+			}
+		}
+	}
+	for _, credential := range r.saved {
+		if credential != nil && credential.UserID == userID {
+			credentials = append(credentials, copyWebAuthnCredential(credential))
+		}
+	}
+	return credentials, nil
+}
+
+func (r *stagedWebAuthnRepository) DeleteCredential(userID, credentialID string) error {
+	credential, err := r.FindCredential(credentialID)
+	if err != nil || credential.UserID != userID {
+		return errors.New("credential not found")
+	}
+	r.saved[credentialID] = nil
+	*r.ops = append(*r.ops, func() error { return r.base.DeleteCredential(userID, credentialID) })
+	return nil
+}
+
+func (r *stagedWebAuthnRepository) SaveSession(session *entities.WebAuthnSession) error {
+	*r.ops = append(*r.ops, func() error { return r.base.SaveSession(session) })
+	return nil
+}
+
+func (r *stagedWebAuthnRepository) TakeSession(id string) (*entities.WebAuthnSession, error) {
+	return r.base.TakeSession(id)
+}
+
+func (r *stagedWebAuthnRepository) DeleteByUserID(userID string) error {
+	if r.deletedUsers == nil {
+		r.deletedUsers = make(map[string]bool)
+	}
+	r.deletedUsers[userID] = true
+	for id, credential := range r.saved {
+		if credential != nil && credential.UserID == userID {
+			delete(r.saved, id)
+		}
+	}
+	*r.ops = append(*r.ops, func() error { return r.base.DeleteByUserID(userID) })
+	return nil
+}
@@ -2,6 +2,8 @@ package repositories
 
 import (
 	"errors"
+	"sort"
+	"strings"
 	"sync"
 	"ambassador/domain/entities"
 	"ambassador/domain/repositories"
@@ -21,7 +23,7 @@ func NewMemoryUserRepository() repositories.UserRepository {
 func (r *MemoryUserRepository) Save(user *entities.User) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.users[user.ID] = user
+	r.users[user.ID] = copyUser(user)
 	return nil
 }
 
@@ -31,7 +33,7 @@ func (r *MemoryUserRepository) FindByEmail(email string) (*entities.User, error)
 
 	for _, user := range r.users {
 		if user.Email.String() == email {
-			return user, nil
+			return copyUser(user), nil
 		}
 	}
 	return nil, errors.New("user not found")
@@ -45,7 +47,7 @@ func (r *MemoryUserRepository) FindByID(id string) (*entities.User, error) {
 	if !exists {
 		return nil, errors.New("user not found")
 	}
-	return user, nil
+	return copyUser(user), nil
 }
 
 func (r *MemoryUserRepository) ExistsByEmail(email string) (bool, error) {
@@ -58,4 +60,50 @@ func (r *MemoryUserRepository) ExistsByEmail(email string) (bool, error) {
 		}
 	}
 	return false, nil
-}
\ No newline at end of file
+}
+
+func (r *MemoryUserRepository) List(filter repositories.UserFilter) ([]*entities.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	query := strings.ToLower(strings.TrimSpace(filter.Query))
+	var users []*entities.User
+	for _, user := range r.users {
+		if query != "" && !strings.Contains(user.Email.String(), query) && !strings.Contains(strings.ToLower(user.FullName), query) {
+			continue
+		}
+		if filter.Active != nil && user.IsActive != *filter.Active {
+			continue
+		}
+		if !filter.DeletedBefore.IsZero() && (!user.IsDeleted() || !user.DeletedAt.Before(filter.DeletedBefore)) {
+			continue
+		}
+		if !filter.BeforeTime.IsZero() {
+			if user.CreatedAt.After(filter.BeforeTime) {
+				continue
+			}
+			if user.CreatedAt.Equal(filter.BeforeTime) && user.ID >= filter.BeforeID {
+				continue
+			}
+		}
+		users = append(users, copyUser(user))
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		if !users[i].CreatedAt.Equal(users[j].CreatedAt) {
+			return users[i].CreatedAt.After(users[j].CreatedAt)
+		}
+		return users[i].ID > users[j].ID
+	})
+	if filter.Limit > 0 && len(users) > filter.Limit {
+		users = users[:filter.Limit]
+	}
+	return users, nil
+}
+
+func (r *MemoryUserRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.users, id)
+	return nil
+}
@@ -0,0 +1,108 @@
+package repositories
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"errors"
+	"sync"
+	"time"
+)
+
+type MemoryWebAuthnRepository struct {
+	credentials map[string]*entities.WebAuthnCredential
+	sessions    map[string]*entities.WebAuthnSession
+	mu          sync.RWMutex
+}
+
+func NewMemoryWebAuthnRepository() repositories.WebAuthnRepository {
+	return &MemoryWebAuthnRepository{
+		credentials: make(map[string]*entities.WebAuthnCredential),
+		sessions:    make(map[string]*entities.WebAuthnSession),
+	}
+}
+
+func (r *MemoryWebAuthnRepository) SaveCredential(credential *entities.WebAuthnCredential) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.credentials[credential.ID] = copyWebAuthnCredential(credential)
+	return nil
+}
+
+func (r *MemoryWebAuthnRepository) FindCredential(credentialID string) (*entities.WebAuthnCredential, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	credential, exists := r.credentials[credentialID]
+	if !exists {
+		return nil, errors.New("credential not found")
+	}
+	return copyWebAuthnCredential(credential), nil
+}
+
+func (r *MemoryWebAuthnRepository) FindCredentialsByUserID(userID string) ([]*entities.WebAuthnCredential, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var credentials []*entities.WebAuthnCredential
+	for _, credential := range r.credentials {
+		if credential.UserID == userID {
+			credentials = append(credentials, copyWebAuthnCredential(credential))
+		}
+	}
+	return credentials, nil
+}
+
+func (r *MemoryWebAuthnRepository) DeleteCredential(userID, credentialID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	credential, exists := r.credentials[credentialID]
+	if !exists || credential.UserID != userID {
+		return errors.New("credential not found")
+	}
+	delete(r.credentials, credentialID)
+	return nil
+}
+
+func (r *MemoryWebAuthnRepository) SaveSession(session *entities.WebAuthnSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, existing := range r.sessions {
+		if existing.ExpiresAt.Before(now) {
+			delete(r.sessions, id)
+		}
+	}
+	r.sessions[session.ID] = session
+	return nil
+}
+
+func (r *MemoryWebAuthnRepository) TakeSession(id string) (*entities.WebAuthnSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, exists := r.sessions[id]
+	if !exists {
+		return nil, errors.New("session not found")
+	}
+	delete(r.sessions, id)
+	return session, nil
+}
+
+func (r *MemoryWebAuthnRepository) DeleteByUserID(userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, credential := range r.credentials {
+		if credential.UserID == userID {
+			delete(r.credentials, id)
+		}
+	}
+	for id, session := range r.sessions {
+		if session.UserID == userID {
+			delete(r.sessions, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+type SQLAuditRepository struct {
+	conn sqlConn
+}
+
+func NewSQLAuditRepository(db *sql.DB, driver string, timeout time.Duration) repositories.AuditRepository {
+	return &SQLAuditRepository{conn: newSQLConn(db, driver, timeout)}
+}
+
+const auditEventColumns = `id, type, user_id, actor_id, ip_address, user_agent, request_id, details, created_at`
+
+func (r *SQLAuditRepository) Save(event *entities.AuditEvent) error {
+	details, err := json.Marshal(event.Details)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.conn.exec(`
+		INSERT INTO audit_events (`+auditEventColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		event.ID,
+		string(event.Type),
+		event.UserID,
+		event.ActorID,
+		event.IPAddress,
+		event.UserAgent,
+		event.RequestID,
+		string(details),
+		event.CreatedAt.UTC(),
+	)
+	return err
+}
+
+func (r *SQLAuditRepository) Find(filter repositories.AuditEventFilter) ([]*entities.AuditEvent, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	add := func(clause string, values ...interface{}) {
+		where = append(where, clause)
+		args = append(args, values...)
+	}
+	if filter.UserID != "" {
+		add(`user_id = ?`, filter.UserID)
+	}
+	if filter.ActorID != "" {
+		add(`actor_id = ?`, filter.ActorID)
+	}
+	if filter.Type != "" {
+		add(`type = ?`, string(filter.Type))
+	}
+	if filter.IPAddress != "" {
+		add(`ip_address = ?`, filter.IPAddress)
+	}
+	if filter.RequestID != "" {
+		add(`request_id = ?`, filter.RequestID)
+	}
+	if !filter.Since.IsZero() {
+		add(`created_at >= ?`, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		add(`created_at < ?`, filter.Until.UTC())
+	}
+	if !filter.BeforeTime.IsZero() {
+		before := filter.BeforeTime.UTC()
+		add(`(created_at < ? OR (created_at = ? AND id < ?))`, before, before, filter.BeforeID)
+	}
+
+	query := `SELECT ` + auditEventColumns + ` FROM audit_events`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += ` ORDER BY created_at DESC, id DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	var events []*entities.AuditEvent
+	err := r.conn.query(query, func(row rowScanner) error {
+		event, err := scanAuditEvent(row)
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+		return nil
+	}, args...)
+	return events, err
+}
+
+func scanAuditEvent(row rowScanner) (*entities.AuditEvent, error) {
+	var (
+		event     entities.AuditEvent
+		eventType string
+		details   string
+	)
+	err := row.Scan(
+		&event.ID,
+		&eventType,
+		&event.UserID,
+		&event.ActorID,
+		&event.IPAddress,
+		&event.UserAgent,
+		&event.RequestID,
+		&details,
+		&event.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	event.Type = entities.AuditEventType(eventType)
+	if details != "" && details != "null" {
+		if err := json.Unmarshal([]byte(details), &event.Details); err != nil {
+			return nil, err
+		}
+	}
+	return &event, nil
+}
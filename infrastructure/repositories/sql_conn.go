@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"ambassador/internal/shared/database"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// sqlConn bundles what every SQL repository needs: something to run queries
+// against (the pool or an open transaction), the driver for placeholder
+// rebinding, and the context each call derives its deadline from.
+type sqlConn struct {
+	q       database.Querier
+	driver  string
+	timeout time.Duration
+	parent  context.Context
+}
+
+func newSQLConn(db *sql.DB, driver string, timeout time.Duration) sqlConn {
+	return sqlConn{q: db, driver: driver, timeout: timeout, parent: context.Background()}
+}
+
+func (c sqlConn) context() (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return context.WithCancel(c.parent)
+	}
+	return context.WithTimeout(c.parent, c.timeout)
+}
+
+func (c sqlConn) exec(query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := c.context()
+	defer cancel()
+	return c.q.ExecContext(ctx, database.Rebind(c.driver, query), args...)
+}
+
+func (c sqlConn) queryRow(query string, scan func(rowScanner) error, args ...interface{}) error {
+	ctx, cancel := c.context()
+	defer cancel()
+	return scan(c.q.QueryRowContext(ctx, database.Rebind(c.driver, query), args...))
+}
+
+func (c sqlConn) query(query string, scan func(rowScanner) error, args ...interface{}) error {
+	ctx, cancel := c.context()
+	defer cancel()
+
+	rows, err := c.q.QueryContext(ctx, database.Rebind(c.driver, query), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// nullTime stores the zero time as NULL so optional timestamps stay optional
+// in the schema.
+func nullTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
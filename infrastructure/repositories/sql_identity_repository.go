@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type SQLIdentityRepository struct {
+	conn sqlConn
+}
+
+func NewSQLIdentityRepository(db *sql.DB, driver string, timeout time.Duration) repositories.IdentityRepository {
+	return &SQLIdentityRepository{conn: newSQLConn(db, driver, timeout)}
+}
+
+const identityColumns = `provider, subject, user_id, email, created_at`
+
+func (r *SQLIdentityRepository) Save(identity *entities.ExternalIdentity) error {
+	_, err := r.conn.exec(`
+		INSERT INTO external_identities (`+identityColumns+`)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (provider, subject) DO UPDATE SET
+			user_id = excluded.user_id,
+			email = excluded.email`,
+		string(identity.Provider),
+		identity.Subject,
+		identity.UserID,
+		identity.Email,
+		identity.CreatedAt.UTC(),
+	)
+	return err
+}
+
+func (r *SQLIdentityRepository) FindByProviderSubject(provider entities.RegistrationMethod, subject string) (*entities.ExternalIdentity, error) {
+	var identity *entities.ExternalIdentity
+	err := r.conn.queryRow(`SELECT `+identityColumns+` FROM external_identities WHERE provider = ? AND subject = ?`, func(row rowScanner) error {
+		var err error
+		identity, err = scanIdentity(row)
+		return err
+	}, string(provider), subject)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("identity not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+func (r *SQLIdentityRepository) FindByUserID(userID string) ([]*entities.ExternalIdentity, error) {
+	var identities []*entities.ExternalIdentity
+	err := r.conn.query(`SELECT `+identityColumns+` FROM external_identities WHERE user_id = ?`, func(row rowScanner) error {
+		identity, err := scanIdentity(row)
+		if err != nil {
+			return err
+		}
+		identities = append(identities, identity)
+		return nil
+	}, userID)
+	return identities, err
+}
+
+func (r *SQLIdentityRepository) DeleteByUserID(userID string) error {
+	_, err := r.conn.exec(`DELETE FROM external_identities WHERE user_id = ?`, userID)
+	return err
+}
+
+func scanIdentity(row rowScanner) (*entities.ExternalIdentity, error) {
+	var (
+		identity entities.ExternalIdentity
+		provider string
+	)
+
+	if err := row.Scan(&provider, &identity.Subject, &identity.UserID, &identity.Email, &identity.CreatedAt); err != nil {
+		return nil, err
+	}
+	identity.Provider = entities.RegistrationMethod(provider)
+
+	return &identity, nil
+}
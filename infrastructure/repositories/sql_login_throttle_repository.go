@@ -0,0 +1,95 @@
+package repositories
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type SQLLoginThrottleRepository struct {
+	conn sqlConn
+}
+
+func NewSQLLoginThrottleRepository(db *sql.DB, driver string, timeout time.Duration) repositories.LoginThrottleRepository {
+	return &SQLLoginThrottleRepository{conn: newSQLConn(db, driver, timeout)}
+}
+
+const loginThrottleColumns = `throttle_key, failed_count, last_failed_at, locked_until`
+
+func (r *SQLLoginThrottleRepository) Find(key string) (*entities.LoginThrottle, error) {
+	var throttle *entities.LoginThrottle
+	err := r.conn.queryRow(`SELECT `+loginThrottleColumns+` FROM login_throttles WHERE throttle_key = ?`, func(row rowScanner) error {
+		var err error
+		throttle, err = scanLoginThrottle(row)
+		return err
+	}, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("login throttle not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return throttle, nil
+}
+
+// RecordFailure increments in a single statement so that concurrent
+// failures against the same account are all counted.
+func (r *SQLLoginThrottleRepository) RecordFailure(key string, at, since time.Time) (*entities.LoginThrottle, error) {
+	var throttle *entities.LoginThrottle
+	err := r.conn.queryRow(`
+		INSERT INTO login_throttles (`+loginThrottleColumns+`)
+		VALUES (?, 1, ?, NULL)
+		ON CONFLICT (throttle_key) DO UPDATE SET
+			failed_count = CASE
+				WHEN login_throttles.last_failed_at < ? THEN 1
+				ELSE login_throttles.failed_count + 1
+			END,
+			last_failed_at = excluded.last_failed_at
+		RETURNING `+loginThrottleColumns,
+		func(row rowScanner) error {
+			var err error
+			throttle, err = scanLoginThrottle(row)
+			return err
+		},
+		key,
+		at.UTC(),
+		since.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return throttle, nil
+}
+
+func (r *SQLLoginThrottleRepository) Lock(key string, until time.Time) error {
+	_, err := r.conn.exec(`
+		INSERT INTO login_throttles (`+loginThrottleColumns+`)
+		VALUES (?, 0, ?, ?)
+		ON CONFLICT (throttle_key) DO UPDATE SET
+			failed_count = 0,
+			locked_until = excluded.locked_until`,
+		key,
+		time.Now().UTC(),
+		until.UTC(),
+	)
+	return err
+}
+
+func (r *SQLLoginThrottleRepository) Reset(key string) error {
+	_, err := r.conn.exec(`DELETE FROM login_throttles WHERE throttle_key = ?`, key)
+	return err
+}
+
+func scanLoginThrottle(row rowScanner) (*entities.LoginThrottle, error) {
+	var (
+		throttle    entities.LoginThrottle
+		lockedUntil sql.NullTime
+	)
+	if err := row.Scan(&throttle.Key, &throttle.FailedCount, &throttle.LastFailedAt, &lockedUntil); err != nil {
+		return nil, err
+	}
+	throttle.LockedUntil = lockedUntil.Time
+	return &throttle, nil
+}
@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type SQLMFARepository struct {
+	conn sqlConn
+}
+
+func NewSQLMFARepository(db *sql.DB, driver string, timeout time.Duration) repositories.MFARepository {
+	return &SQLMFARepository{conn: newSQLConn(db, driver, timeout)}
+}
+
+const totpColumns = `user_id, secret, confirmed_at, last_used_step, created_at`
+
+func (r *SQLMFARepository) SaveTOTP(credential *entities.TOTPCredential) error {
+	_, err := r.conn.exec(`
+		INSERT INTO totp_credentials (`+totpColumns+`)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			secret = excluded.secret,
+			confirmed_at = excluded.confirmed_at,
+			last_used_step = excluded.last_used_step,
+			created_at = excluded.created_at`,
+		credential.UserID,
+		credential.Secret,
+		nullTime(credential.ConfirmedAt),
+		credential.LastUsedStep,
+		credential.CreatedAt.UTC(),
+	)
+	return err
+}
+
+func (r *SQLMFARepository) FindTOTP(userID string) (*entities.TOTPCredential, error) {
+	var credential entities.TOTPCredential
+	err := r.conn.queryRow(`SELECT `+totpColumns+` FROM totp_credentials WHERE user_id = ?`, func(row rowScanner) error {
+		var confirmedAt sql.NullTime
+		if err := row.Scan(&credential.UserID, &credential.Secret, &confirmedAt, &credential.LastUsedStep, &credential.CreatedAt); err != nil {
+			return err
+		}
+		credential.ConfirmedAt = confirmedAt.Time
+		return nil
+	}, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("totp credential not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func (r *SQLMFARepository) DeleteTOTP(userID string) error {
+	_, err := r.conn.exec(`DELETE FROM totp_credentials WHERE user_id = ?`, userID)
+	return err
+}
+
+func (r *SQLMFARepository) ReplaceRecoveryCodes(userID string, codes []*entities.RecoveryCode) error {
+	if _, err := r.conn.exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	for _, code := range codes {
+		_, err := r.conn.exec(`
+			INSERT INTO recovery_codes (user_id, code_hash, used_at, created_at)
+			VALUES (?, ?, ?, ?)`,
+			code.UserID,
+			code.CodeHash,
+			nullTime(code.UsedAt),
+			code.CreatedAt.UTC(),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *SQLMFARepository) FindRecoveryCodes(userID string) ([]*entities.RecoveryCode, error) {
+	var codes []*entities.RecoveryCode
+	err := r.conn.query(`SELECT user_id, code_hash, used_at, created_at FROM recovery_codes WHERE user_id = ?`, func(row rowScanner) error {
+		var (
+			code   entities.RecoveryCode
+			usedAt sql.NullTime
+		)
+		if err := row.Scan(&code.UserID, &code.CodeHash, &usedAt, &code.CreatedAt); err != nil {
+			return err
+		}
+		code.UsedAt = usedAt.Time
+		codes = append(codes, &code)
+		return nil
+	}, userID)
+	return codes, err
+}
+
+func (r *SQLMFARepository) ConsumeRecoveryCode(userID, codeHash string) (bool, error) {
+	// The used_at guard makes this a compare-and-set, so two concurrent
+	// logins cannot both spend the same code.
+	result, err := r.conn.exec(
+		`UPDATE recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`,
+		time.Now().UTC(), userID, codeHash,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
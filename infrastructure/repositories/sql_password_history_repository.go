@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"database/sql"
+	"time"
+)
+
+type SQLPasswordHistoryRepository struct {
+	conn sqlConn
+}
+
+func NewSQLPasswordHistoryRepository(db *sql.DB, driver string, timeout time.Duration) repositories.PasswordHistoryRepository {
+	return &SQLPasswordHistoryRepository{conn: newSQLConn(db, driver, timeout)}
+}
+
+const passwordHistoryColumns = `id, user_id, password_hash, created_at`
+
+func (r *SQLPasswordHistoryRepository) Add(entry *entities.PasswordHistoryEntry) error {
+	_, err := r.conn.exec(`INSERT INTO password_history (`+passwordHistoryColumns+`) VALUES (?, ?, ?, ?)`,
+		entry.ID,
+		entry.UserID,
+		entry.PasswordHash,
+		entry.CreatedAt.UTC(),
+	)
+	return err
+}
+
+func (r *SQLPasswordHistoryRepository) FindRecent(userID string, limit int) ([]*entities.PasswordHistoryEntry, error) {
+	var entries []*entities.PasswordHistoryEntry
+	err := r.conn.query(`
+		SELECT `+passwordHistoryColumns+` FROM password_history
+		WHERE user_id = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?`,
+		func(row rowScanner) error {
+			var entry entities.PasswordHistoryEntry
+			if err := row.Scan(&entry.ID, &entry.UserID, &entry.PasswordHash, &entry.CreatedAt); err != nil {
+				return err
+			}
+			entries = append(entries, &entry)
+			return nil
+		},
+		userID,
+		limit,
+	)
+	return entries, err
+}
+
+func (r *SQLPasswordHistoryRepository) Prune(userID string, keep int) error {
+	_, err := r.conn.exec(`
+		DELETE FROM password_history
+		WHERE user_id = ? AND id NOT IN (
+			SELECT id FROM password_history
+			WHERE user_id = ?
+			ORDER BY created_at DESC, id DESC
+			LIMIT ?
+		)`,
+		userID,
+		userID,
+		keep,
+	)
+	return err
+}
+
+func (r *SQLPasswordHistoryRepository) DeleteByUserID(userID string) error {
+	_, err := r.conn.exec(`DELETE FROM password_history WHERE user_id = ?`, userID)
+	return err
+}
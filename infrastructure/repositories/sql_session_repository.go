@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type SQLSessionRepository struct {
+	conn sqlConn
+}
+
+func NewSQLSessionRepository(db *sql.DB, driver string, timeout time.Duration) repositories.SessionRepository {
+	return &SQLSessionRepository{conn: newSQLConn(db, driver, timeout)}
+}
+
+const sessionColumns = `id, user_id, device_name, user_agent, ip_address, created_at, last_used_at, expires_at`
+
+func (r *SQLSessionRepository) Save(session *entities.Session) error {
+	_, err := r.conn.exec(`
+		INSERT INTO sessions (`+sessionColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			device_name = excluded.device_name,
+			user_agent = excluded.user_agent,
+			ip_address = excluded.ip_address,
+			last_used_at = excluded.last_used_at,
+			expires_at = excluded.expires_at`,
+		session.ID,
+		session.UserID,
+		session.DeviceName,
+		session.UserAgent,
+		session.IPAddress,
+		session.CreatedAt.UTC(),
+		session.LastUsedAt.UTC(),
+		session.ExpiresAt.UTC(),
+	)
+	return err
+}
+
+func (r *SQLSessionRepository) FindByID(id string) (*entities.Session, error) {
+	var session *entities.Session
+	err := r.conn.queryRow(`SELECT `+sessionColumns+` FROM sessions WHERE id = ?`, func(row rowScanner) error {
+		var err error
+		session, err = scanSession(row)
+		return err
+	}, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("session not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (r *SQLSessionRepository) FindByUserID(userID string) ([]*entities.Session, error) {
+	var sessions []*entities.Session
+	err := r.conn.query(`SELECT `+sessionColumns+` FROM sessions WHERE user_id = ? ORDER BY last_used_at DESC`, func(row rowScanner) error {
+		session, err := scanSession(row)
+		if err != nil {
+			return err
+		}
+		sessions = append(sessions, session)
+		return nil
+	}, userID)
+	return sessions, err
+}
+
+func (r *SQLSessionRepository) Delete(id string) error {
+	_, err := r.conn.exec(`DELETE FROM sessions WHERE id = ?`, id)
+	return err
+}
+
+func (r *SQLSessionRepository) DeleteByUserID(userID string) error {
+	_, err := r.conn.exec(`DELETE FROM sessions WHERE user_id = ?`, userID)
+	return err
+}
+
+func (r *SQLSessionRepository) DeleteExpired() error {
+	_, err := r.conn.exec(`DELETE FROM sessions WHERE expires_at < ?`, time.Now().UTC())
+	return err
+}
+
+func scanSession(row rowScanner) (*entities.Session, error) {
+	var session entities.Session
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.DeviceName,
+		&session.UserAgent,
+		&session.IPAddress,
+		&session.CreatedAt,
+		&session.LastUsedAt,
+		&session.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
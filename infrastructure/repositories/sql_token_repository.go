@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+type SQLTokenRepository struct {
+	conn sqlConn
+}
+
+func NewSQLTokenRepository(db *sql.DB, driver string, timeout time.Duration) repositories.TokenRepository {
+	return &SQLTokenRepository{conn: newSQLConn(db, driver, timeout)}
+}
+
+const tokenColumns = `value, user_id, type, expires_at, created_at, family_id, rotated_at, scopes, roles, subject`
+
+func (r *SQLTokenRepository) Save(token *entities.Token) error {
+	_, err := r.conn.exec(`
+		INSERT INTO tokens (`+tokenColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (value) DO UPDATE SET
+			user_id = excluded.user_id,
+			type = excluded.type,
+			expires_at = excluded.expires_at,
+			family_id = excluded.family_id,
+			rotated_at = excluded.rotated_at,
+			scopes = excluded.scopes,
+			roles = excluded.roles,
+			subject = excluded.subject`,
+		token.Value,
+		token.UserID,
+		string(token.Type),
+		token.ExpiresAt.UTC(),
+		token.CreatedAt.UTC(),
+		token.FamilyID,
+		nullTime(token.RotatedAt),
+		nullScopes(token.Scopes),
+		strings.Join(token.Roles, " "),
+		token.Subject,
+	)
+	return err
+}
+
+func (r *SQLTokenRepository) FindByValue(value string) (*entities.Token, error) {
+	var token *entities.Token
+	err := r.conn.queryRow(`SELECT `+tokenColumns+` FROM tokens WHERE value = ?`, func(row rowScanner) error {
+		var err error
+		token, err = scanToken(row)
+		return err
+	}, value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("token not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (r *SQLTokenRepository) Delete(value string) error {
+	_, err := r.conn.exec(`DELETE FROM tokens WHERE value = ?`, value)
+	return err
+}
+
+func (r *SQLTokenRepository) DeleteExpired() error {
+	_, err := r.conn.exec(`DELETE FROM tokens WHERE expires_at < ?`, time.Now().UTC())
+	return err
+}
+
+func (r *SQLTokenRepository) DeleteAllUserTokens(userID string, tokenType entities.TokenType) error {
+	_, err := r.conn.exec(`DELETE FROM tokens WHERE user_id = ? AND type = ?`, userID, string(tokenType))
+	return err
+}
+
+func (r *SQLTokenRepository) FindByUserID(userID string, tokenType entities.TokenType) ([]*entities.Token, error) {
+	var tokens []*entities.Token
+	err := r.conn.query(`SELECT `+tokenColumns+` FROM tokens WHERE user_id = ? AND type = ?`, func(row rowScanner) error {
+		token, err := scanToken(row)
+		if err != nil {
+			return err
+		}
+		tokens = append(tokens, token)
+		return nil
+	}, userID, string(tokenType))
+	return tokens, err
+}
+
+func (r *SQLTokenRepository) DeleteByFamily(familyID string) error {
+	_, err := r.conn.exec(`DELETE FROM tokens WHERE family_id = ?`, familyID)
+	return err
+}
+
+func (r *SQLTokenRepository) DeleteByUserID(userID string) error {
+	_, err := r.conn.exec(`DELETE FROM tokens WHERE user_id = ?`, userID)
+	return err
+}
+
+func scanToken(row rowScanner) (*entities.Token, error) {
+	var (
+		token     entities.Token
+		tokenType string
+		rotatedAt sql.NullTime
+		scopes    sql.NullString
+		roles     string
+	)
+
+	if err := row.Scan(&token.Value, &token.UserID, &tokenType, &token.ExpiresAt, &token.CreatedAt, &token.FamilyID, &rotatedAt, &scopes, &roles, &token.Subject); err != nil {
+		return nil, err
+	}
+	token.Type = entities.TokenType(tokenType)
+	token.RotatedAt = rotatedAt.Time
+	if scopes.Valid {
+		token.Scopes = strings.Fields(scopes.String)
+	}
+	token.Roles = strings.Fields(roles)
+
+	return &token, nil
+}
+
+// nullScopes stores unrestricted tokens as NULL so they can be told apart
+// from a token that was deliberately issued with no scopes at all.
+func nullScopes(scopes []string) sql.NullString {
+	if scopes == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: strings.Join(scopes, " "), Valid: true}
+}
@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"ambassador/domain/repositories"
+	"context"
+	"database/sql"
+	"time"
+)
+
+type SQLUnitOfWork struct {
+	db      *sql.DB
+	driver  string
+	timeout time.Duration
+}
+
+func NewSQLUnitOfWork(db *sql.DB, driver string, timeout time.Duration) repositories.UnitOfWork {
+	return &SQLUnitOfWork{db: db, driver: driver, timeout: timeout}
+}
+
+func (u *SQLUnitOfWork) Do(ctx context.Context, fn func(repos *repositories.TxRepositories) error) error {
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	conn := sqlConn{q: tx, driver: u.driver, timeout: u.timeout, parent: ctx}
+	repos := &repositories.TxRepositories{
+		Users:           &SQLUserRepository{conn: conn},
+		Tokens:          &SQLTokenRepository{conn: conn},
+		Identities:      &SQLIdentityRepository{conn: conn},
+		MFA:             &SQLMFARepository{conn: conn},
+		Sessions:        &SQLSessionRepository{conn: conn},
+		PasswordHistory: &SQLPasswordHistoryRepository{conn: conn},
+		WebAuthn:        &SQLWebAuthnRepository{conn: conn},
+	}
+
+	if err := fn(repos); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,197 @@
+package repositories
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+type SQLUserRepository struct {
+	conn sqlConn
+}
+
+func NewSQLUserRepository(db *sql.DB, driver string, timeout time.Duration) repositories.UserRepository {
+	return &SQLUserRepository{conn: newSQLConn(db, driver, timeout)}
+}
+
+const userColumns = `id, email, full_name, gender, date_of_birth, registration_method, password_hash, created_at, updated_at, is_active, email_verified, email_verified_at, locale, roles, deleted_at, password_changed_at`
+
+func (r *SQLUserRepository) Save(user *entities.User) error {
+	_, err := r.conn.exec(`
+		INSERT INTO users (`+userColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			email = excluded.email,
+			full_name = excluded.full_name,
+			gender = excluded.gender,
+			date_of_birth = excluded.date_of_birth,
+			registration_method = excluded.registration_method,
+			password_hash = excluded.password_hash,
+			updated_at = excluded.updated_at,
+			is_active = excluded.is_active,
+			email_verified = excluded.email_verified,
+			email_verified_at = excluded.email_verified_at,
+			locale = excluded.locale,
+			roles = excluded.roles,
+			deleted_at = excluded.deleted_at,
+			password_changed_at = excluded.password_changed_at`,
+		user.ID,
+		user.Email.String(),
+		user.FullName,
+		string(user.Gender),
+		user.DateOfBirth.UTC(),
+		string(user.RegistrationMethod),
+		user.PasswordHash,
+		user.CreatedAt.UTC(),
+		user.UpdatedAt.UTC(),
+		user.IsActive,
+		user.EmailVerified,
+		nullTime(user.EmailVerifiedAt),
+		user.Locale,
+		strings.Join(user.Roles, " "),
+		nullTime(user.DeletedAt),
+		nullTime(user.PasswordChangedAt),
+	)
+	return err
+}
+
+func (r *SQLUserRepository) FindByEmail(email string) (*entities.User, error) {
+	return r.findOne(`SELECT `+userColumns+` FROM users WHERE email = ?`, email)
+}
+
+func (r *SQLUserRepository) FindByID(id string) (*entities.User, error) {
+	return r.findOne(`SELECT `+userColumns+` FROM users WHERE id = ?`, id)
+}
+
+func (r *SQLUserRepository) ExistsByEmail(email string) (bool, error) {
+	var count int
+	err := r.conn.queryRow(`SELECT COUNT(1) FROM users WHERE email = ?`, func(row rowScanner) error {
+		return row.Scan(&count)
+	}, email)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *SQLUserRepository) List(filter repositories.UserFilter) ([]*entities.User, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	if query := strings.ToLower(strings.TrimSpace(filter.Query)); query != "" {
+		pattern := "%" + likeEscaper.Replace(query) + "%"
+		where = append(where, `(email LIKE ? ESCAPE '\' OR LOWER(full_name) LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern)
+	}
+	if filter.Active != nil {
+		where = append(where, `is_active = ?`)
+		args = append(args, *filter.Active)
+	}
+	if !filter.DeletedBefore.IsZero() {
+		where = append(where, `deleted_at < ?`)
+		args = append(args, filter.DeletedBefore.UTC())
+	}
+	if !filter.BeforeTime.IsZero() {
+		before := filter.BeforeTime.UTC()
+		where = append(where, `(created_at < ? OR (created_at = ? AND id < ?))`)
+		args = append(args, before, before, filter.BeforeID)
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += ` ORDER BY created_at DESC, id DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	var users []*entities.User
+	err := r.conn.query(query, func(row rowScanner) error {
+		user, err := scanUser(row)
+		if err != nil {
+			return err
+		}
+		users = append(users, user)
+		return nil
+	}, args...)
+	return users, err
+}
+
+func (r *SQLUserRepository) Delete(id string) error {
+	_, err := r.conn.exec(`DELETE FROM users WHERE id = ?`, id)
+	return err
+}
+
+// likeEscaper makes user input match literally inside a LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (r *SQLUserRepository) findOne(query string, args ...interface{}) (*entities.User, error) {
+	var user *entities.User
+	err := r.conn.queryRow(query, func(row rowScanner) error {
+		var err error
+		user, err = scanUser(row)
+		return err
+	}, args...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("user not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func scanUser(row rowScanner) (*entities.User, error) {
+	var (
+		user      entities.User
+		email     string
+		gender    string
+		regMethod string
+		verified  sql.NullTime
+		roles     string
+		deleted   sql.NullTime
+		changed   sql.NullTime
+	)
+
+	err := row.Scan(
+		&user.ID,
+		&email,
+		&user.FullName,
+		&gender,
+		&user.DateOfBirth,
+		&regMethod,
+		&user.PasswordHash,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.IsActive,
+		&user.EmailVerified,
+		&verified,
+		&user.Locale,
+		&roles,
+		&deleted,
+		&changed,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	emailVO, err := entities.NewEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	user.Email = emailVO
+	user.Gender = entities.Gender(gender)
+	user.RegistrationMethod = entities.RegistrationMethod(regMethod)
+	user.EmailVerifiedAt = verified.Time
+	user.Roles = strings.Fields(roles)
+	user.DeletedAt = deleted.Time
+	user.PasswordChangedAt = changed.Time
+
+	return &user, nil
+}
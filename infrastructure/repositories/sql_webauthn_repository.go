@@ -0,0 +1,185 @@
+package repositories
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+type SQLWebAuthnRepository struct {
+	conn sqlConn
+}
+
+func NewSQLWebAuthnRepository(db *sql.DB, driver string, timeout time.Duration) repositories.WebAuthnRepository {
+	return &SQLWebAuthnRepository{conn: newSQLConn(db, driver, timeout)}
+}
+
+const webAuthnCredentialColumns = `id, user_id, name, public_key, attestation_type, aaguid, sign_count, transports, backup_eligible, backup_state, created_at, last_used_at`
+
+// Binary fields are stored as base64 text so the schema is the same on
+// SQLite and PostgreSQL.
+func (r *SQLWebAuthnRepository) SaveCredential(credential *entities.WebAuthnCredential) error {
+	_, err := r.conn.exec(`
+		INSERT INTO webauthn_credentials (`+webAuthnCredentialColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			name = excluded.name,
+			sign_count = excluded.sign_count,
+			backup_state = excluded.backup_state,
+			last_used_at = excluded.last_used_at`,
+		credential.ID,
+		credential.UserID,
+		credential.Name,
+		base64.StdEncoding.EncodeToString(credential.PublicKey),
+		credential.AttestationType,
+		base64.StdEncoding.EncodeToString(credential.AAGUID),
+		int64(credential.SignCount),
+		strings.Join(credential.Transports, " "),
+		credential.BackupEligible,
+		credential.BackupState,
+		credential.CreatedAt.UTC(),
+		nullTime(credential.LastUsedAt),
+	)
+	return err
+}
+
+func (r *SQLWebAuthnRepository) FindCredential(credentialID string) (*entities.WebAuthnCredential, error) {
+	var credential *entities.WebAuthnCredential
+	err := r.conn.queryRow(`SELECT `+webAuthnCredentialColumns+` FROM webauthn_credentials WHERE id = ?`, func(row rowScanner) error {
+		var err error
+		credential, err = scanWebAuthnCredential(row)
+		return err
+	}, credentialID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("credential not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return credential, nil
+}
+
+func (r *SQLWebAuthnRepository) FindCredentialsByUserID(userID string) ([]*entities.WebAuthnCredential, error) {
+	var credentials []*entities.WebAuthnCredential
+	err := r.conn.query(`SELECT `+webAuthnCredentialColumns+` FROM webauthn_credentials WHERE user_id = ? ORDER BY created_at`, func(row rowScanner) error {
+		credential, err := scanWebAuthnCredential(row)
+		if err != nil {
+			return err
+		}
+		credentials = append(credentials, credential)
+		return nil
+	}, userID)
+	return credentials, err
+}
+
+func (r *SQLWebAuthnRepository) DeleteCredential(userID, credentialID string) error {
+	result, err := r.conn.exec(`DELETE FROM webauthn_credentials WHERE id = ? AND user_id = ?`, credentialID, userID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return errors.New("credential not found")
+	}
+	return nil
+}
+
+func (r *SQLWebAuthnRepository) SaveSession(session *entities.WebAuthnSession) error {
+	if _, err := r.conn.exec(`DELETE FROM webauthn_sessions WHERE expires_at < ?`, time.Now().UTC()); err != nil {
+		return err
+	}
+	_, err := r.conn.exec(`
+		INSERT INTO webauthn_sessions (id, user_id, ceremony, data, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		session.ID,
+		session.UserID,
+		string(session.Ceremony),
+		string(session.Data),
+		session.ExpiresAt.UTC(),
+		session.CreatedAt.UTC(),
+	)
+	return err
+}
+
+func (r *SQLWebAuthnRepository) TakeSession(id string) (*entities.WebAuthnSession, error) {
+	var (
+		session  entities.WebAuthnSession
+		ceremony string
+		data     string
+	)
+	err := r.conn.queryRow(`SELECT id, user_id, ceremony, data, expires_at, created_at FROM webauthn_sessions WHERE id = ?`, func(row rowScanner) error {
+		return row.Scan(&session.ID, &session.UserID, &ceremony, &data, &session.ExpiresAt, &session.CreatedAt)
+	}, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("session not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the caller whose delete succeeds gets the session, so two
+	// concurrent finish calls cannot both use it.
+	result, err := r.conn.exec(`DELETE FROM webauthn_sessions WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return nil, errors.New("session not found")
+	}
+
+	session.Ceremony = entities.WebAuthnCeremony(ceremony)
+	session.Data = []byte(data)
+	return &session, nil
+}
+
+func (r *SQLWebAuthnRepository) DeleteByUserID(userID string) error {
+	if _, err := r.conn.exec(`DELETE FROM webauthn_sessions WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	_, err := r.conn.exec(`DELETE FROM webauthn_credentials WHERE user_id = ?`, userID)
+	return err
+}
+
+func scanWebAuthnCredential(row rowScanner) (*entities.WebAuthnCredential, error) {
+	var (
+		credential entities.WebAuthnCredential
+		publicKey  string
+		aaguid     string
+		signCount  int64
+		transports string
+		lastUsedAt sql.NullTime
+	)
+
+	err := row.Scan(
+		&credential.ID,
+		&credential.UserID,
+		&credential.Name,
+		&publicKey,
+		&credential.AttestationType,
+		&aaguid,
+		&signCount,
+		&transports,
+		&credential.BackupEligible,
+		&credential.BackupState,
+		&credential.CreatedAt,
+		&lastUsedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if credential.PublicKey, err = base64.StdEncoding.DecodeString(publicKey); err != nil {
+		return nil, err
+	}
+	if credential.AAGUID, err = base64.StdEncoding.DecodeString(aaguid); err != nil {
+		return nil, err
+	}
+	credential.SignCount = uint32(signCount)
+	credential.Transports = strings.Fields(transports)
+	credential.LastUsedAt = lastUsedAt.Time
+
+	return &credential, nil
+}
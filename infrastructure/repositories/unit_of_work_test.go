@@ -0,0 +1,325 @@
+package repositories
+
+import (
+	"ambassador/domain/entities"
+	domainrepos "ambassador/domain/repositories"
+	"ambassador/internal/shared/database"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// uowBackend is a unit of work together with the repositories it commits
+// to, so tests can look at what was stored afterwards.
+type uowBackend struct {
+	uow   domainrepos.UnitOfWork
+	repos domainrepos.TxRepositories
+}
+
+// uowBackends returns the in-memory stand-in and SQLite, plus PostgreSQL
+// when TEST_POSTGRES_DSN points at a database the tests may migrate, e.g. a
+// local container.
+func uowBackends(t *testing.T) map[string]func(t *testing.T) uowBackend {
+	t.Helper()
+	return map[string]func(t *testing.T) uowBackend{
+		"memory": func(t *testing.T) uowBackend {
+			repos := domainrepos.TxRepositories{
+				Users:           NewMemoryUserRepository(),
+				Tokens:          NewMemoryTokenRepository(),
+				Identities:      NewMemoryIdentityRepository(),
+				MFA:             NewMemoryMFARepository(),
+				Sessions:        NewMemorySessionRepository(),
+				PasswordHistory: NewMemoryPasswordHistoryRepository(),
+				WebAuthn:        NewMemoryWebAuthnRepository(),
+			}
+			return uowBackend{uow: NewMemoryUnitOfWork(repos), repos: repos}
+		},
+		"sqlite": func(t *testing.T) uowBackend {
+			return openSQLBackend(t, database.DriverSQLite, filepath.Join(t.TempDir(), "ambassador.db"))
+		},
+		"postgres": func(t *testing.T) uowBackend {
+			dsn := os.Getenv("TEST_POSTGRES_DSN")
+			if dsn == "" {
+				t.Skip("TEST_POSTGRES_DSN is not set")
+			}
+			return openSQLBackend(t, database.DriverPostgres, dsn)
+		},
+	}
+}
+
+func openSQLBackend(t *testing.T, driver, dsn string) uowBackend {
+	t.Helper()
+	db, err := database.Open(driver, dsn, database.PoolConfig{MaxOpenConns: 4, MaxIdleConns: 2})
+	if err != nil {
+		t.Fatalf("open %s: %v", driver, err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.Migrate(db, driver, database.AuthMigrations); err != nil {
+		t.Fatalf("migrate %s: %v", driver, err)
+	}
+
+	timeout := 5 * time.Second
+	return uowBackend{
+		uow: NewSQLUnitOfWork(db, driver, timeout),
+		repos: domainrepos.TxRepositories{
+			Users:           NewSQLUserRepository(db, driver, timeout),
+			Tokens:          NewSQLTokenRepository(db, driver, timeout),
+			Identities:      NewSQLIdentityRepository(db, driver, timeout),
+			MFA:             NewSQLMFARepository(db, driver, timeout),
+			Sessions:        NewSQLSessionRepository(db, driver, timeout),
+			PasswordHistory: NewSQLPasswordHistoryRepository(db, driver, timeout),
+			WebAuthn:        NewSQLWebAuthnRepository(db, driver, timeout),
+		},
+	}
+}
+
+func newTestUser(t *testing.T) *entities.User {
+	t.Helper()
+	user, err := entities.NewUser(uuid.New().String()+"@example.com", "Ann Lee", entities.GenderFemale,
+		time.Date(1990, 1, 2, 0, 0, 0, 0, time.UTC), entities.RegMethodEmail, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.ID = uuid.New().String()
+	return user
+}
+
+func TestUnitOfWorkCommitsEveryWrite(t *testing.T) {
+	for name, open := range uowBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			b := open(t)
+			user := newTestUser(t)
+			refresh := entities.NewRefreshToken(user.ID)
+			access := entities.NewAccessToken(user.ID)
+
+			err := b.uow.Do(context.Background(), func(tx *domainrepos.TxRepositories) error {
+				if err := tx.Users.Save(user); err != nil {
+					return err
+				}
+				if err := tx.Tokens.Save(access); err != nil {
+					return err
+				}
+				return tx.Tokens.Save(refresh)
+			})
+			if err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+
+			if _, err := b.repos.Users.FindByID(user.ID); err != nil {
+				t.Errorf("user not committed: %v", err)
+			}
+			for _, token := range []*entities.Token{access, refresh} {
+				if _, err := b.repos.Tokens.FindByValue(token.Value); err != nil {
+					t.Errorf("%s token not committed: %v", token.Type, err)
+				}
+			}
+		})
+	}
+}
+
+func TestUnitOfWorkRollsBackOnError(t *testing.T) {
+	for name, open := range uowBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			b := open(t)
+			user := newTestUser(t)
+			access := entities.NewAccessToken(user.ID)
+			failure := errors.New("saving the refresh token failed")
+
+			err := b.uow.Do(context.Background(), func(tx *domainrepos.TxRepositories) error {
+				if err := tx.Users.Save(user); err != nil {
+					return err
+				}
+				if err := tx.Tokens.Save(access); err != nil {
+					return err
+				}
+				return failure
+			})
+			if !errors.Is(err, failure) {
+				t.Fatalf("Do returned %v, want %v", err, failure)
+			}
+
+			if _, err := b.repos.Users.FindByID(user.ID); err == nil {
+				t.Error("user was stored although the unit of work failed")
+			}
+			if _, err := b.repos.Tokens.FindByValue(access.Value); err == nil {
+				t.Error("access token was stored although the unit of work failed")
+			}
+		})
+	}
+}
+
+func TestUnitOfWorkDeletesPasskeysOnlyOnCommit(t *testing.T) {
+	for name, open := range uowBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			b := open(t)
+			user := newTestUser(t)
+			if err := b.repos.Users.Save(user); err != nil {
+				t.Fatal(err)
+			}
+			credential := &entities.WebAuthnCredential{ID: uuid.New().String(), UserID: user.ID, PublicKey: []byte{1, 2, 3}, CreatedAt: time.Now()}
+			if err := b.repos.WebAuthn.SaveCredential(credential); err != nil {
+				t.Fatal(err)
+			}
+			ceremonyID, session := entities.NewWebAuthnSession(user.ID, entities.WebAuthnCeremonyRegistration, []byte("{}"), time.Minute)
+			if err := b.repos.WebAuthn.SaveSession(session); err != nil {
+				t.Fatal(err)
+			}
+
+			deletePasskeys := func(fail error) error {
+				return b.uow.Do(context.Background(), func(tx *domainrepos.TxRepositories) error {
+					if err := tx.WebAuthn.DeleteByUserID(user.ID); err != nil {
+						return err
+					}
+					if credentials, err := tx.WebAuthn.FindCredentialsByUserID(user.ID); err != nil || len(credentials) != 0 {
+						t.Errorf("inside the unit of work found %d credentials, %v; want none", len(credentials), err)
+					}
+					return fail
+				})
+			}
+
+			failure := errors.New("deleting the user failed")
+			if err := deletePasskeys(failure); !errors.Is(err, failure) {
+				t.Fatalf("Do returned %v, want %v", err, failure)
+			}
+			if _, err := b.repos.WebAuthn.FindCredential(credential.ID); err != nil {
+				t.Errorf("credential was deleted although the unit of work failed: %v", err)
+			}
+
+			if err := deletePasskeys(nil); err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+			if _, err := b.repos.WebAuthn.FindCredential(credential.ID); err == nil {
+				t.Error("credential was not deleted")
+			}
+			if _, err := b.repos.WebAuthn.TakeSession(entities.HashTokenValue(ceremonyID)); err == nil {
+				t.Error("unfinished ceremony was not deleted")
+			}
+		})
+	}
+}
+
+func TestUnitOfWorkPasswordHistoryFollowsThePasswordChange(t *testing.T) {
+	for name, open := range uowBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			b := open(t)
+			user := newTestUser(t)
+			if err := b.repos.Users.Save(user); err != nil {
+				t.Fatal(err)
+			}
+			failure := errors.New("revoking the sessions failed")
+
+			change := func(result error) error {
+				return b.uow.Do(context.Background(), func(tx *domainrepos.TxRepositories) error {
+					user.PasswordHash = "new-hash"
+					if err := tx.Users.Save(user); err != nil {
+						return err
+					}
+					err := tx.PasswordHistory.Add(&entities.PasswordHistoryEntry{
+						ID:           uuid.New().String(),
+						UserID:       user.ID,
+						PasswordHash: "hash",
+						CreatedAt:    time.Now(),
+					})
+					if err != nil {
+						return err
+					}
+					if err := tx.PasswordHistory.Prune(user.ID, 5); err != nil {
+						return err
+					}
+					return result
+				})
+			}
+
+			if err := change(failure); !errors.Is(err, failure) {
+				t.Fatalf("Do returned %v, want %v", err, failure)
+			}
+			if entries, err := b.repos.PasswordHistory.FindRecent(user.ID, 5); err != nil || len(entries) != 0 {
+				t.Errorf("history after a failed change = %d entries, %v; want none", len(entries), err)
+			}
+
+			if err := change(nil); err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+			entries, err := b.repos.PasswordHistory.FindRecent(user.ID, 5)
+			if err != nil || len(entries) != 1 || entries[0].PasswordHash != "hash" {
+				t.Errorf("history after the change = %v, %v; want the old hash", entries, err)
+			}
+		})
+	}
+}
+
+func TestUnitOfWorkRollsBackChangesToStoredEntities(t *testing.T) {
+	for name, open := range uowBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			b := open(t)
+			user := newTestUser(t)
+			if err := b.repos.Users.Save(user); err != nil {
+				t.Fatal(err)
+			}
+
+			loaded, err := b.repos.Users.FindByID(user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Callers change the entity before the unit of work runs.
+			loaded.MarkEmailVerified()
+			loaded.Roles = append(loaded.Roles, entities.RoleAdmin)
+
+			err = b.uow.Do(context.Background(), func(tx *domainrepos.TxRepositories) error {
+				if err := tx.Users.Save(loaded); err != nil {
+					return err
+				}
+				return errors.New("fail")
+			})
+			if err == nil {
+				t.Fatal("Do succeeded")
+			}
+
+			stored, err := b.repos.Users.FindByID(user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.EmailVerified || stored.HasRole(entities.RoleAdmin) {
+				t.Errorf("stored user changed by a failed unit of work: verified=%v roles=%v", stored.EmailVerified, stored.Roles)
+			}
+		})
+	}
+}
+
+// SQL backends lock the recovery code row instead, so this only concerns
+// the stand-in, which checks the code while staging and spends it on commit.
+func TestMemoryUnitOfWorkRecoveryCodeSpentElsewhereFailsWholeCommit(t *testing.T) {
+	b := uowBackends(t)["memory"](t)
+	user := newTestUser(t)
+	codes, records := entities.NewRecoveryCodes(user.ID)
+	if err := b.repos.MFA.ReplaceRecoveryCodes(user.ID, records); err != nil {
+		t.Fatal(err)
+	}
+	hash := entities.HashRecoveryCode(codes[0])
+	refresh := entities.NewRefreshToken(user.ID)
+
+	err := b.uow.Do(context.Background(), func(tx *domainrepos.TxRepositories) error {
+		if err := tx.Tokens.Save(refresh); err != nil {
+			return err
+		}
+		if consumed, err := tx.MFA.ConsumeRecoveryCode(user.ID, hash); err != nil || !consumed {
+			t.Fatalf("staged consume: %v %v", consumed, err)
+		}
+		// A concurrent request spends the same code first.
+		if consumed, err := b.repos.MFA.ConsumeRecoveryCode(user.ID, hash); err != nil || !consumed {
+			t.Fatalf("concurrent consume: %v %v", consumed, err)
+		}
+		return nil
+	})
+	if err == nil {
+		t.Fatal("Do succeeded although the recovery code had been spent")
+	}
+	if _, err := b.repos.Tokens.FindByValue(refresh.Value); err == nil {
+		t.Error("refresh token was stored although the recovery code had been spent")
+	}
+}
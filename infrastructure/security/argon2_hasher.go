@@ -0,0 +1,130 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2Params tunes Argon2id. Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follows the OWASP recommendation of 19 MiB of memory
+// and two passes.
+var DefaultArgon2Params = Argon2Params{
+	Memory:      19 * 1024,
+	Iterations:  2,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+const argon2Prefix = "$argon2id$"
+
+// Argon2idHasher stores hashes in the PHC string format, e.g.
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>, so the parameters a hash
+// was made with travel with it.
+type Argon2idHasher struct {
+	params Argon2Params
+}
+
+func NewArgon2idHasher(params Argon2Params) *Argon2idHasher {
+	if params.SaltLength == 0 {
+		params.SaltLength = DefaultArgon2Params.SaltLength
+	}
+	if params.KeyLength == 0 {
+		params.KeyLength = DefaultArgon2Params.KeyLength
+	}
+	return &Argon2idHasher{params: params}
+}
+
+func (h *Argon2idHasher) HashPassword(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2Prefix,
+		argon2.Version,
+		h.params.Memory,
+		h.params.Iterations,
+		h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Argon2idHasher) CheckPassword(password, hash string) bool {
+	decoded, err := decodeArgon2Hash(hash)
+	if err != nil || decoded.version != argon2.Version {
+		return false
+	}
+
+	key := argon2.IDKey([]byte(password), decoded.salt, decoded.params.Iterations, decoded.params.Memory, decoded.params.Parallelism, uint32(len(decoded.key)))
+	return subtle.ConstantTimeCompare(key, decoded.key) == 1
+}
+
+func (h *Argon2idHasher) NeedsRehash(hash string) bool {
+	decoded, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return true
+	}
+	return decoded.version != argon2.Version ||
+		decoded.params.Memory < h.params.Memory ||
+		decoded.params.Iterations < h.params.Iterations ||
+		decoded.params.Parallelism < h.params.Parallelism ||
+		uint32(len(decoded.salt)) < h.params.SaltLength ||
+		uint32(len(decoded.key)) < h.params.KeyLength
+}
+
+func (h *Argon2idHasher) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, argon2Prefix)
+}
+
+type argon2Hash struct {
+	version int
+	params  Argon2Params
+	salt    []byte
+	key     []byte
+}
+
+func decodeArgon2Hash(hash string) (*argon2Hash, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, errors.New("not an argon2id hash")
+	}
+
+	var decoded argon2Hash
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &decoded.version); err != nil {
+		return nil, errors.New("invalid argon2id version")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &decoded.params.Memory, &decoded.params.Iterations, &decoded.params.Parallelism); err != nil {
+		return nil, errors.New("invalid argon2id parameters")
+	}
+	if decoded.params.Iterations < 1 || decoded.params.Parallelism < 1 {
+		return nil, errors.New("invalid argon2id parameters")
+	}
+
+	var err error
+	if decoded.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, errors.New("invalid argon2id salt")
+	}
+	if decoded.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(decoded.key) == 0 {
+		return nil, errors.New("invalid argon2id hash")
+	}
+	return &decoded, nil
+}
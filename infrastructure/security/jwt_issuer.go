@@ -0,0 +1,145 @@
+package security
+
+import (
+	"ambassador/domain/entities"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+type JWTConfig struct {
+	Issuer   string
+	Audience []string
+	TTL      time.Duration
+}
+
+// JWTIssuer issues signed access tokens that other services can verify on
+// their own using the keys published by JWKS.
+type JWTIssuer struct {
+	keys   *KeyManager
+	config JWTConfig
+}
+
+type accessClaims struct {
+	jwt.RegisteredClaims
+	Scope     string   `json:"scope,omitempty"`
+	SessionID string   `json:"sid,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+}
+
+func NewJWTIssuer(keys *KeyManager, config JWTConfig) *JWTIssuer {
+	return &JWTIssuer{keys: keys, config: config}
+}
+
+func (i *JWTIssuer) Issue(userID, sessionID string, roles, scopes []string) (*entities.Token, error) {
+	key, err := i.keys.SigningKey()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(i.config.TTL)
+
+	claims := accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			Issuer:    i.config.Issuer,
+			Audience:  i.config.Audience,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			ID:        uuid.New().String(),
+		},
+		Scope:     strings.Join(scopes, " "),
+		SessionID: sessionID,
+		Roles:     roles,
+	}
+
+	token := jwt.NewWithClaims(key.SigningMethod(), claims)
+	token.Header["kid"] = key.ID
+
+	signed, err := token.SignedString(key.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entities.Token{
+		Value:     signed,
+		UserID:    userID,
+		Type:      entities.TokenTypeAccess,
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+		FamilyID:  sessionID,
+		Scopes:    scopes,
+		Roles:     roles,
+	}, nil
+}
+
+func (i *JWTIssuer) Validate(value string) (*entities.Token, error) {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{AlgRS256, AlgES256, AlgEdDSA}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+	if i.config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(i.config.Issuer))
+	}
+	if len(i.config.Audience) > 0 {
+		options = append(options, jwt.WithAudience(i.config.Audience[0]))
+	}
+
+	var claims accessClaims
+	_, err := jwt.ParseWithClaims(value, &claims, i.keyFunc, options...)
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return nil, errors.New("access token expired")
+	}
+	if err != nil {
+		return nil, errors.New("invalid access token")
+	}
+
+	return &entities.Token{
+		Value:     value,
+		UserID:    claims.Subject,
+		Type:      entities.TokenTypeAccess,
+		ExpiresAt: claims.ExpiresAt.Time,
+		CreatedAt: claims.IssuedAt.Time,
+		FamilyID:  claims.SessionID,
+		Scopes:    parseScopes(claims.Scope),
+		Roles:     claims.Roles,
+	}, nil
+}
+
+func (i *JWTIssuer) Stateless() bool {
+	return true
+}
+
+// JWKS returns the public keys that verify tokens from this issuer.
+func (i *JWTIssuer) JWKS() JWKSet {
+	return i.keys.JWKS()
+}
+
+// keyFunc resolves the verification key from the kid header and refuses
+// tokens whose alg does not match that key, which rules out algorithm
+// substitution.
+func (i *JWTIssuer) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, err := i.keys.VerificationKey(kid)
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, errors.New("signing algorithm mismatch")
+	}
+	return key.PublicKey(), nil
+}
+
+// parseScopes is the inverse of the space-joined scope claim; an absent
+// claim yields nil, which means unrestricted.
+func parseScopes(scope string) []string {
+	if scope == "" {
+		return nil
+	}
+	return strings.Fields(scope)
+}
@@ -0,0 +1,239 @@
+package security
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testJWTConfig = JWTConfig{Issuer: "https://auth.example.com", Audience: []string{"api"}, TTL: time.Minute}
+
+func newTestJWTIssuer(t *testing.T, config JWTConfig) (*JWTIssuer, *KeyManager, *SigningKey) {
+	t.Helper()
+	keys := NewKeyManager(time.Hour, t.TempDir())
+	key := generateTestKey(t)
+	if err := keys.Add(key, true); err != nil {
+		t.Fatal(err)
+	}
+	return NewJWTIssuer(keys, config), keys, key
+}
+
+// signTestToken signs claims with key under kid, bypassing the issuer.
+func signTestToken(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func validTestClaims() jwt.RegisteredClaims {
+	now := time.Now()
+	return jwt.RegisteredClaims{
+		Subject:   "user-1",
+		Issuer:    testJWTConfig.Issuer,
+		Audience:  testJWTConfig.Audience,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+	}
+}
+
+func TestJWTIssuerRoundTrip(t *testing.T) {
+	issuer, _, key := newTestJWTIssuer(t, testJWTConfig)
+
+	issued, err := issuer.Issue("user-1", "session-1", []string{"admin"}, []string{"profile:read", "sessions:write"})
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if header := strings.Split(issued.Value, ".")[0]; !strings.Contains(decodeSegment(t, header), `"kid":"`+key.ID+`"`) {
+		t.Errorf("token header %s does not name the signing key", decodeSegment(t, header))
+	}
+
+	token, err := issuer.Validate(issued.Value)
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if token.UserID != "user-1" || token.FamilyID != "session-1" {
+		t.Errorf("validated subject %q, session %q", token.UserID, token.FamilyID)
+	}
+	if strings.Join(token.Roles, ",") != "admin" || strings.Join(token.Scopes, ",") != "profile:read,sessions:write" {
+		t.Errorf("validated roles %v, scopes %v", token.Roles, token.Scopes)
+	}
+	if !token.ExpiresAt.Equal(issued.ExpiresAt.Truncate(time.Second)) {
+		t.Errorf("expires at %v, want %v", token.ExpiresAt, issued.ExpiresAt)
+	}
+}
+
+func TestJWTIssuerRejectsAlgorithmMismatch(t *testing.T) {
+	issuer, keys, key := newTestJWTIssuer(t, testJWTConfig)
+	edKey, err := GenerateSigningKey(AlgEdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.Add(edKey, false); err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := map[string]string{
+		// An ES256 signature presented as coming from the EdDSA key.
+		"alg of another key": signTestToken(t, jwt.SigningMethodES256, key.PrivateKey, edKey.ID, validTestClaims()),
+		// The published public key used as an HMAC secret.
+		"symmetric alg": signTestToken(t, jwt.SigningMethodHS256, []byte(key.JWK().X), key.ID, validTestClaims()),
+	}
+	for name, value := range tokens {
+		t.Run(name, func(t *testing.T) {
+			if _, err := issuer.Validate(value); err == nil {
+				t.Error("token was accepted")
+			}
+		})
+	}
+}
+
+func TestJWTIssuerRejectsUnknownKeyID(t *testing.T) {
+	issuer, _, _ := newTestJWTIssuer(t, testJWTConfig)
+	stranger := generateTestKey(t)
+
+	value := signTestToken(t, jwt.SigningMethodES256, stranger.PrivateKey, stranger.ID, validTestClaims())
+	if _, err := issuer.Validate(value); err == nil {
+		t.Error("token signed by an unknown key was accepted")
+	}
+}
+
+func TestJWTIssuerChecksRegisteredClaims(t *testing.T) {
+	issuer, _, key := newTestJWTIssuer(t, testJWTConfig)
+
+	cases := map[string]struct {
+		modify  func(claims *jwt.RegisteredClaims)
+		wantErr string
+	}{
+		"other issuer":   {func(c *jwt.RegisteredClaims) { c.Issuer = "https://evil.example" }, "invalid access token"},
+		"other audience": {func(c *jwt.RegisteredClaims) { c.Audience = jwt.ClaimStrings{"billing"} }, "invalid access token"},
+		"no expiry":      {func(c *jwt.RegisteredClaims) { c.ExpiresAt = nil }, "invalid access token"},
+		"expired": {func(c *jwt.RegisteredClaims) {
+			c.IssuedAt = jwt.NewNumericDate(time.Now().Add(-2 * time.Minute))
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+		}, "access token expired"},
+		"issued in the future": {func(c *jwt.RegisteredClaims) {
+			c.IssuedAt = jwt.NewNumericDate(time.Now().Add(time.Hour))
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(2 * time.Hour))
+		}, "invalid access token"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			claims := validTestClaims()
+			tc.modify(&claims)
+			value := signTestToken(t, jwt.SigningMethodES256, key.PrivateKey, key.ID, claims)
+			if _, err := issuer.Validate(value); err == nil || err.Error() != tc.wantErr {
+				t.Errorf("Validate returned %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestJWTIssuerVerifiesWithRotatedKey(t *testing.T) {
+	issuer, keys, old := newTestJWTIssuer(t, testJWTConfig)
+	before, err := issuer.Issue("user-1", "session-1", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current := generateTestKey(t)
+	if err := keys.Add(current, true); err != nil {
+		t.Fatal(err)
+	}
+	after, err := issuer.Issue("user-1", "session-1", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(decodeSegment(t, strings.Split(after.Value, ".")[0]), current.ID) {
+		t.Error("token issued after the rotation is not signed by the new primary key")
+	}
+
+	for name, value := range map[string]string{"before rotation": before.Value, "after rotation": after.Value} {
+		if _, err := issuer.Validate(value); err != nil {
+			t.Errorf("token issued %s: %v", name, err)
+		}
+	}
+
+	if err := keys.Retire(old.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := issuer.Validate(before.Value); err == nil {
+		t.Error("token signed by a retired key was accepted")
+	}
+}
+
+func TestJWTIssuerJWKSVerifiesIssuedTokens(t *testing.T) {
+	issuer, keys, key := newTestJWTIssuer(t, testJWTConfig)
+	retired := generateTestKey(t)
+	if err := keys.Add(retired, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.Retire(retired.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(issuer.JWKS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var set struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Keys) != 1 {
+		t.Fatalf("JWKS = %s, want only the key that can still verify", data)
+	}
+	published := set.Keys[0]
+	want := map[string]string{"kty": "EC", "kid": key.ID, "use": "sig", "alg": AlgES256, "crv": "P-256"}
+	for field, value := range want {
+		if published[field] != value {
+			t.Errorf("JWKS %s = %q, want %q", field, published[field], value)
+		}
+	}
+	if _, ok := published["d"]; ok {
+		t.Error("JWKS publishes the private key")
+	}
+
+	var jwk JWK
+	if err := json.Unmarshal(mustMarshal(t, published), &jwk); err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := jwk.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	issued, err := issuer.Issue("user-1", "session-1", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(issued.Value, func(*jwt.Token) (interface{}, error) { return publicKey, nil }); err != nil {
+		t.Errorf("published key does not verify an issued token: %v", err)
+	}
+}
+
+func decodeSegment(t *testing.T, segment string) string {
+	t.Helper()
+	data, err := jwt.NewParser().DecodeSegment(segment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
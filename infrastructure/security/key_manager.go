@@ -0,0 +1,306 @@
+package security
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// keyStateFile is where a KeyManager with a directory records which key is
+// primary and when the others retire, since neither can be told from the
+// PEM files themselves.
+const keyStateFile = "keys.json"
+
+// KeyManager keeps the set of access token signing keys. Exactly one key is
+// primary and signs new tokens; every other key that has not retired is
+// still published and accepted for verification, so tokens signed before a
+// rotation stay valid until they expire.
+type KeyManager struct {
+	keys    map[string]*SigningKey
+	primary string
+	grace   time.Duration
+	dir     string
+	state   *keyState
+	mu      sync.RWMutex
+}
+
+// keyState is the content of keyStateFile.
+type keyState struct {
+	Primary string                   `json:"primary"`
+	Keys    map[string]keyStateEntry `json:"keys"`
+}
+
+type keyStateEntry struct {
+	CreatedAt time.Time `json:"createdAt"`
+	RetiresAt time.Time `json:"retiresAt,omitempty"`
+}
+
+// NewKeyManager creates an empty manager. grace is how long a key keeps
+// verifying after it stops being primary; it should be at least the access
+// token TTL. When dir is set, keys added at runtime are written there, along
+// with which key is primary and when the others retire, so that a restart
+// picks up where the previous process left off.
+func NewKeyManager(grace time.Duration, dir string) *KeyManager {
+	return &KeyManager{
+		keys:  make(map[string]*SigningKey),
+		grace: grace,
+		dir:   dir,
+	}
+}
+
+// LoadFiles adds the PEM encoded keys at paths. The primary and retirement
+// times recorded in the key directory are restored; without a record, the
+// newest key that has never been primary becomes primary unless one has
+// already been chosen.
+func (m *KeyManager) LoadFiles(paths []string, alg string) error {
+	loaded := make([]*SigningKey, 0, len(paths))
+	for _, path := range paths {
+		key, err := LoadSigningKeyFile(path, alg)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if info, err := os.Stat(path); err == nil {
+			key.CreatedAt = info.ModTime()
+		}
+		loaded = append(loaded, key)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.state == nil {
+		state, err := m.readState()
+		if err != nil {
+			return err
+		}
+		m.state = state
+	}
+
+	for _, key := range loaded {
+		if entry, ok := m.state.Keys[key.ID]; ok {
+			key.CreatedAt = entry.CreatedAt
+			key.RetiresAt = entry.RetiresAt
+		}
+		m.keys[key.ID] = key
+	}
+	// The recorded primary may be loaded after a fallback was chosen, e.g.
+	// from a file outside the key directory.
+	if key, ok := m.keys[m.state.Primary]; ok && !key.IsRetired(time.Now()) {
+		m.primary = key.ID
+	} else if m.primary == "" {
+		m.promoteNewest()
+	}
+	return nil
+}
+
+// LoadDir loads every *.pem file in dir, inferring the algorithm from each
+// key's type.
+func (m *KeyManager) LoadDir(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	return m.LoadFiles(paths, "")
+}
+
+// Add registers key for verification and publication. If promote is set, or
+// there is no primary yet, it also becomes the signing key.
+func (m *KeyManager) Add(key *SigningKey, promote bool) error {
+	if m.dir != "" {
+		data, err := key.MarshalPEM()
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(m.dir, key.ID+".pem"), data, 0600); err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.keys[key.ID] = key
+	if promote || m.primary == "" {
+		m.promote(key.ID)
+	}
+	return m.saveState()
+}
+
+// Promote makes kid the signing key. The previous primary keeps verifying
+// for the grace period.
+func (m *KeyManager) Promote(kid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, ok := m.keys[kid]
+	if !ok {
+		return errors.New("signing key not found")
+	}
+	if key.IsRetired(time.Now()) {
+		return errors.New("signing key is retired")
+	}
+
+	m.promote(kid)
+	return m.saveState()
+}
+
+// Retire stops kid from verifying immediately. The primary key cannot be
+// retired; promote another key first.
+func (m *KeyManager) Retire(kid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, ok := m.keys[kid]
+	if !ok {
+		return errors.New("signing key not found")
+	}
+	if kid == m.primary {
+		return errors.New("cannot retire the primary signing key")
+	}
+
+	key.RetiresAt = time.Now()
+
+	if m.dir != "" {
+		path := filepath.Join(m.dir, kid+".pem")
+		if err := os.Rename(path, path+".retired"); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return m.saveState()
+}
+
+// SigningKey returns the primary key.
+func (m *KeyManager) SigningKey() (*SigningKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, ok := m.keys[m.primary]
+	if !ok {
+		return nil, errors.New("no signing key configured")
+	}
+	return key, nil
+}
+
+// VerificationKey returns the key with the given kid if it has not retired.
+func (m *KeyManager) VerificationKey(kid string) (*SigningKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, ok := m.keys[kid]
+	if !ok || key.IsRetired(time.Now()) {
+		return nil, errors.New("unknown signing key")
+	}
+	return key, nil
+}
+
+// Keys returns a snapshot of every known key, newest first, together with
+// the primary kid.
+func (m *KeyManager) Keys() ([]*SigningKey, string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]*SigningKey, 0, len(m.keys))
+	for _, key := range m.keys {
+		snapshot := *key
+		keys = append(keys, &snapshot)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+	return keys, m.primary
+}
+
+// JWKS publishes the public half of every key that can still verify.
+func (m *KeyManager) JWKS() JWKSet {
+	keys, _ := m.Keys()
+	now := time.Now()
+
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range keys {
+		if !key.IsRetired(now) {
+			set.Keys = append(set.Keys, key.JWK())
+		}
+	}
+	return set
+}
+
+func (m *KeyManager) promote(kid string) {
+	if previous, ok := m.keys[m.primary]; ok && m.primary != kid {
+		previous.RetiresAt = time.Now().Add(m.grace)
+	}
+	m.keys[kid].RetiresAt = time.Time{}
+	m.primary = kid
+}
+
+// promoteNewest picks a primary when none was recorded. Keys that have
+// stopped being primary have a retirement time and are never picked, even
+// during their grace period.
+func (m *KeyManager) promoteNewest() {
+	var newest *SigningKey
+	for _, key := range m.keys {
+		if !key.RetiresAt.IsZero() {
+			continue
+		}
+		if newest == nil || key.CreatedAt.After(newest.CreatedAt) {
+			newest = key
+		}
+	}
+	if newest != nil {
+		m.promote(newest.ID)
+	}
+}
+
+func (m *KeyManager) readState() (*keyState, error) {
+	state := &keyState{Keys: make(map[string]keyStateEntry)}
+	if m.dir == "" {
+		return state, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(m.dir, keyStateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("%s: %w", keyStateFile, err)
+	}
+	if state.Keys == nil {
+		state.Keys = make(map[string]keyStateEntry)
+	}
+	return state, nil
+}
+
+// saveState records the primary and every key's retirement time in the key
+// directory. The file is replaced by a rename so a crash cannot leave it
+// half written.
+func (m *KeyManager) saveState() error {
+	if m.dir == "" {
+		return nil
+	}
+
+	state := &keyState{Primary: m.primary, Keys: make(map[string]keyStateEntry, len(m.keys))}
+	for kid, key := range m.keys {
+		state.Keys[kid] = keyStateEntry{CreatedAt: key.CreatedAt, RetiresAt: key.RetiresAt}
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(m.dir, keyStateFile)
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	m.state = state
+	return nil
+}
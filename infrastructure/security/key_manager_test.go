@@ -0,0 +1,123 @@
+package security
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func generateTestKey(t *testing.T) *SigningKey {
+	t.Helper()
+	key, err := GenerateSigningKey(AlgES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestKeyManagerRestoresPrimaryAndGraceAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	grace := time.Hour
+
+	m := NewKeyManager(grace, dir)
+	old := generateTestKey(t)
+	if err := m.Add(old, true); err != nil {
+		t.Fatal(err)
+	}
+	// Generated before the rotation and never promoted, so it is the newest
+	// file on disk but must not become primary after a restart.
+	spare := generateTestKey(t)
+	spare.CreatedAt = time.Now().Add(time.Minute)
+	if err := m.Add(spare, false); err != nil {
+		t.Fatal(err)
+	}
+	current := generateTestKey(t)
+	if err := m.Add(current, true); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := NewKeyManager(grace, dir)
+	if err := restarted.LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	primary, err := restarted.SigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if primary.ID != current.ID {
+		t.Errorf("primary after restart = %s, want %s", primary.ID, current.ID)
+	}
+
+	keys, _ := restarted.Keys()
+	for _, key := range keys {
+		if key.ID != old.ID {
+			continue
+		}
+		if key.RetiresAt.IsZero() || key.RetiresAt.After(time.Now().Add(grace)) {
+			t.Errorf("previous primary retires at %v, want within the grace period", key.RetiresAt)
+		}
+	}
+	if _, err := restarted.VerificationKey(old.ID); err != nil {
+		t.Errorf("previous primary stopped verifying during its grace period: %v", err)
+	}
+}
+
+func TestKeyManagerDoesNotRevivePreviousPrimary(t *testing.T) {
+	dir := t.TempDir()
+
+	m := NewKeyManager(time.Hour, dir)
+	old := generateTestKey(t)
+	if err := m.Add(old, true); err != nil {
+		t.Fatal(err)
+	}
+	current := generateTestKey(t)
+	if err := m.Add(current, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// The previous primary's file is touched after the rotation, e.g. by a
+	// backup restore, making it the newest.
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, old.ID+".pem"), future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := NewKeyManager(time.Hour, dir)
+	if err := restarted.LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	primary, err := restarted.SigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if primary.ID != current.ID {
+		t.Errorf("primary after restart = %s, want %s", primary.ID, current.ID)
+	}
+}
+
+func TestKeyManagerRetiredKeyStaysRetired(t *testing.T) {
+	dir := t.TempDir()
+
+	m := NewKeyManager(time.Hour, dir)
+	old := generateTestKey(t)
+	if err := m.Add(old, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Add(generateTestKey(t), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Retire(old.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := NewKeyManager(time.Hour, dir)
+	if err := restarted.LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restarted.VerificationKey(old.ID); err == nil {
+		t.Error("retired key verifies again after a restart")
+	}
+}
@@ -1,12 +1,30 @@
 package security
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
 
 type PasswordHasher interface {
 	HashPassword(password string) (string, error)
 	CheckPassword(password, hash string) bool
+	// NeedsRehash reports whether hash was produced by another algorithm or
+	// with weaker parameters than HashPassword currently uses.
+	NeedsRehash(hash string) bool
+}
+
+// PasswordAlgorithm is a PasswordHasher that can tell its own hashes apart
+// from those of other algorithms, so several can verify side by side.
+type PasswordAlgorithm interface {
+	PasswordHasher
+	Recognizes(hash string) bool
 }
 
+// BcryptMaxPasswordBytes is the longest password bcrypt can hash; it
+// refuses longer ones rather than truncating them.
+const BcryptMaxPasswordBytes = 72
+
 type BcryptHasher struct {
 	cost int
 }
@@ -20,7 +38,56 @@ func (h *BcryptHasher) HashPassword(password string) (string, error) {
 	return string(bytes), err
 }
 
+// CheckPassword refuses passwords longer than BcryptMaxPasswordBytes, which
+// bcrypt would otherwise compare by their first 72 bytes only.
 func (h *BcryptHasher) CheckPassword(password, hash string) bool {
+	if len(password) > BcryptMaxPasswordBytes {
+		return false
+	}
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
-}
\ No newline at end of file
+}
+
+func (h *BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost < h.cost
+}
+
+func (h *BcryptHasher) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// MultiHasher hashes new passwords with its preferred algorithm and checks
+// existing hashes with whichever algorithm produced them. Hashes from any
+// other than the preferred algorithm need a rehash.
+type MultiHasher struct {
+	preferred  PasswordAlgorithm
+	algorithms []PasswordAlgorithm
+}
+
+func NewMultiHasher(preferred PasswordAlgorithm, legacy ...PasswordAlgorithm) *MultiHasher {
+	return &MultiHasher{
+		preferred:  preferred,
+		algorithms: append([]PasswordAlgorithm{preferred}, legacy...),
+	}
+}
+
+func (h *MultiHasher) HashPassword(password string) (string, error) {
+	return h.preferred.HashPassword(password)
+}
+
+func (h *MultiHasher) CheckPassword(password, hash string) bool {
+	for _, algorithm := range h.algorithms {
+		if algorithm.Recognizes(hash) {
+			return algorithm.CheckPassword(password, hash)
+		}
+	}
+	return false
+}
+
+func (h *MultiHasher) NeedsRehash(hash string) bool {
+	if !h.preferred.Recognizes(hash) {
+		return true
+	}
+	return h.preferred.NeedsRehash(hash)
+}
@@ -0,0 +1,172 @@
+package security
+
+import (
+	"strings"
+	"testing"
+)
+
+// testArgon2Params keeps the tests fast; they are far below what is safe.
+var testArgon2Params = Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2idHashRoundTrip(t *testing.T) {
+	hasher := NewArgon2idHasher(testArgon2Params)
+
+	hash, err := hasher.HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("hash %q is not in the PHC format", hash)
+	}
+
+	decoded, err := decodeArgon2Hash(hash)
+	if err != nil {
+		t.Fatalf("decodeArgon2Hash: %v", err)
+	}
+	if decoded.params.Memory != 64 || decoded.params.Iterations != 1 || decoded.params.Parallelism != 1 ||
+		len(decoded.salt) != 16 || len(decoded.key) != 32 {
+		t.Errorf("decoded %+v, want the parameters it was hashed with", decoded)
+	}
+
+	if !hasher.CheckPassword("correct horse", hash) {
+		t.Error("the hashed password does not verify")
+	}
+	if hasher.CheckPassword("correct horse!", hash) {
+		t.Error("another password verifies")
+	}
+	if other, _ := hasher.HashPassword("correct horse"); other == hash {
+		t.Error("two hashes of a password share their salt")
+	}
+}
+
+func TestArgon2idRejectsMalformedHashes(t *testing.T) {
+	hasher := NewArgon2idHasher(testArgon2Params)
+	valid, err := hasher.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(valid, "$")
+	with := func(i int, value string) string {
+		modified := append([]string(nil), parts...)
+		modified[i] = value
+		return strings.Join(modified, "$")
+	}
+
+	hashes := map[string]string{
+		"other variant":    with(1, "argon2i"),
+		"missing part":     strings.Join(parts[:5], "$"),
+		"extra part":       valid + "$x",
+		"bad version":      with(2, "v=x"),
+		"bad params":       with(3, "m=64;t=1;p=1"),
+		"zero iterations":  with(3, "m=64,t=0,p=1"),
+		"zero parallelism": with(3, "m=64,t=1,p=0"),
+		"bad salt":         with(4, "not base64!"),
+		"bad key":          with(5, "not base64!"),
+		"empty key":        with(5, ""),
+		"bcrypt":           "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+		"empty":            "",
+	}
+	for name, hash := range hashes {
+		t.Run(name, func(t *testing.T) {
+			if _, err := decodeArgon2Hash(hash); err == nil {
+				t.Errorf("decodeArgon2Hash(%q) succeeded", hash)
+			}
+			if hasher.CheckPassword("correct horse", hash) {
+				t.Errorf("password verified against %q", hash)
+			}
+			if !hasher.NeedsRehash(hash) {
+				t.Errorf("NeedsRehash(%q) = false", hash)
+			}
+		})
+	}
+
+	// A hash from another Argon2 version parses but never verifies.
+	if hasher.CheckPassword("correct horse", with(2, "v=16")) {
+		t.Error("password verified against a hash of another version")
+	}
+}
+
+func TestArgon2idNeedsRehashWhenParametersChange(t *testing.T) {
+	hash, err := NewArgon2idHasher(testArgon2Params).HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		modify func(p *Argon2Params)
+		want   bool
+	}{
+		"same parameters":  {func(p *Argon2Params) {}, false},
+		"weaker memory":    {func(p *Argon2Params) { p.Memory = 32 }, false},
+		"more memory":      {func(p *Argon2Params) { p.Memory = 128 }, true},
+		"more iterations":  {func(p *Argon2Params) { p.Iterations = 2 }, true},
+		"more parallelism": {func(p *Argon2Params) { p.Parallelism = 2 }, true},
+		"longer salt":      {func(p *Argon2Params) { p.SaltLength = 32 }, true},
+		"longer key":       {func(p *Argon2Params) { p.KeyLength = 64 }, true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			params := testArgon2Params
+			tc.modify(&params)
+			if got := NewArgon2idHasher(params).NeedsRehash(hash); got != tc.want {
+				t.Errorf("NeedsRehash = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBcryptRefusesPasswordsPastItsLimit(t *testing.T) {
+	hasher := NewBcryptHasher()
+	longest := strings.Repeat("a", BcryptMaxPasswordBytes)
+
+	hash, err := hasher.HashPassword(longest)
+	if err != nil {
+		t.Fatalf("HashPassword(%d bytes): %v", len(longest), err)
+	}
+	if !hasher.CheckPassword(longest, hash) {
+		t.Error("the longest password does not verify")
+	}
+	// bcrypt only reads the first 72 bytes; a longer password sharing them
+	// must not verify as if it were the same.
+	if hasher.CheckPassword(longest+"b", hash) {
+		t.Error("a longer password with the same first 72 bytes verifies")
+	}
+	if _, err := hasher.HashPassword(longest + "b"); err == nil {
+		t.Error("a password past the limit was hashed instead of refused")
+	}
+}
+
+func TestMultiHasherVerifiesEveryAlgorithm(t *testing.T) {
+	bcryptHasher := NewBcryptHasher()
+	hasher := NewMultiHasher(NewArgon2idHasher(testArgon2Params), bcryptHasher)
+
+	legacy, err := bcryptHasher.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := hasher.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(current, argon2Prefix) {
+		t.Errorf("new hash %q was not made with the preferred algorithm", current)
+	}
+
+	for name, hash := range map[string]string{"bcrypt": legacy, "argon2id": current} {
+		if !hasher.CheckPassword("correct horse", hash) {
+			t.Errorf("%s hash does not verify", name)
+		}
+		if hasher.CheckPassword("wrong horse", hash) {
+			t.Errorf("%s hash verifies another password", name)
+		}
+	}
+	if !hasher.NeedsRehash(legacy) {
+		t.Error("bcrypt hash does not need a rehash")
+	}
+	if hasher.NeedsRehash(current) {
+		t.Error("current argon2id hash needs a rehash")
+	}
+	if hasher.CheckPassword("correct horse", "$scrypt$whatever") || !hasher.NeedsRehash("$scrypt$whatever") {
+		t.Error("a hash no algorithm recognizes was accepted")
+	}
+}
@@ -0,0 +1,248 @@
+package security
+
+import (
+	"ambassador/domain/entities"
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PasswordCandidate is a proposed password together with what is known
+// about its owner, which the password must not give away.
+type PasswordCandidate struct {
+	Password string
+	Email    string
+	FullName string
+}
+
+// PasswordRule screens a candidate password for one reason beyond the
+// composition requirements, returning nil when it passes.
+type PasswordRule interface {
+	// Name identifies the rule to clients, e.g. "common".
+	Name() string
+	Check(candidate PasswordCandidate) *entities.PasswordViolation
+}
+
+// PasswordPolicy checks a password against the composition requirements and
+// every screening rule before it is accepted at registration, reset or
+// change.
+type PasswordPolicy struct {
+	requirements entities.PasswordRequirements
+	rules        []PasswordRule
+}
+
+func NewPasswordPolicy(requirements entities.PasswordRequirements, rules ...PasswordRule) *PasswordPolicy {
+	return &PasswordPolicy{requirements: requirements, rules: rules}
+}
+
+// DefaultPasswordPolicy applies the default requirements and screens for
+// personal details and the built-in list of common passwords.
+func DefaultPasswordPolicy() *PasswordPolicy {
+	return NewPasswordPolicy(entities.DefaultPasswordRequirements(), PersonalInfoRule{}, DefaultCommonPasswordRule())
+}
+
+func (p *PasswordPolicy) Requirements() entities.PasswordRequirements {
+	return p.requirements
+}
+
+// Screening names the screening rules in the order they run.
+func (p *PasswordPolicy) Screening() []string {
+	names := make([]string, 0, len(p.rules))
+	for _, rule := range p.rules {
+		names = append(names, rule.Name())
+	}
+	return names
+}
+
+// Validate returns a *entities.PasswordPolicyError listing every violated
+// rule, or nil when the password is acceptable.
+func (p *PasswordPolicy) Validate(candidate PasswordCandidate) error {
+	violations := p.requirements.Check(candidate.Password)
+	for _, rule := range p.rules {
+		if violation := rule.Check(candidate); violation != nil {
+			violations = append(violations, *violation)
+		}
+	}
+	if len(violations) > 0 {
+		return &entities.PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// personalTokenMinLength ignores name parts and email local parts too short
+// to be a meaningful giveaway, such as initials.
+const personalTokenMinLength = 3
+
+// PersonalInfoRule rejects passwords containing the owner's email address,
+// its local part or any part of their name, ignoring case.
+type PersonalInfoRule struct{}
+
+func (PersonalInfoRule) Name() string {
+	return entities.PasswordRulePersonalInfo
+}
+
+func (PersonalInfoRule) Check(candidate PasswordCandidate) *entities.PasswordViolation {
+	password := strings.ToLower(candidate.Password)
+
+	email := strings.ToLower(strings.TrimSpace(candidate.Email))
+	if email != "" {
+		local := email
+		if at := strings.LastIndex(email, "@"); at >= 0 {
+			local = email[:at]
+		}
+		if strings.Contains(password, email) || (len(local) >= personalTokenMinLength && strings.Contains(password, local)) {
+			return &entities.PasswordViolation{Rule: entities.PasswordRulePersonalInfo, Message: "password must not contain your email address"}
+		}
+	}
+
+	for _, part := range strings.FieldsFunc(strings.ToLower(candidate.FullName), func(r rune) bool {
+		return r == ' ' || r == '-' || r == '\''
+	}) {
+		if len(part) >= personalTokenMinLength && strings.Contains(password, part) {
+			return &entities.PasswordViolation{Rule: entities.PasswordRulePersonalInfo, Message: "password must not contain your name"}
+		}
+	}
+	return nil
+}
+
+//go:embed common_passwords.txt
+var defaultCommonPasswords string
+
+// CommonPasswordRule rejects passwords found on a blocklist. A password is
+// also rejected when it is a listed one with digits or symbols appended,
+// so "Password1!" is caught by "password".
+type CommonPasswordRule struct {
+	blocked map[string]bool
+}
+
+// DefaultCommonPasswordRule uses the built-in list of common passwords.
+func DefaultCommonPasswordRule() *CommonPasswordRule {
+	rule, _ := newCommonPasswordRule(strings.NewReader(defaultCommonPasswords))
+	return rule
+}
+
+// LoadCommonPasswordRule reads a blocklist with one password per line.
+// Blank lines and lines starting with # are ignored.
+func LoadCommonPasswordRule(path string) (*CommonPasswordRule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return newCommonPasswordRule(file)
+}
+
+func newCommonPasswordRule(r io.Reader) (*CommonPasswordRule, error) {
+	blocked := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		blocked[strings.ToLower(line)] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &CommonPasswordRule{blocked: blocked}, nil
+}
+
+func (r *CommonPasswordRule) Name() string {
+	return entities.PasswordRuleCommon
+}
+
+func (r *CommonPasswordRule) Check(candidate PasswordCandidate) *entities.PasswordViolation {
+	password := strings.ToLower(candidate.Password)
+	base := strings.TrimRightFunc(password, func(r rune) bool {
+		return !('a' <= r && r <= 'z')
+	})
+	if r.blocked[password] || r.blocked[base] {
+		return &entities.PasswordViolation{Rule: entities.PasswordRuleCommon, Message: "password is too common"}
+	}
+	return nil
+}
+
+// BreachedPasswordRule rejects passwords found in a local copy of a breach
+// corpus laid out for k-anonymity lookups: one file per five hex digit
+// SHA-1 prefix, named after the prefix, holding "SUFFIX:COUNT" lines for
+// the remaining 35 digits. Only the matching range file is read.
+type BreachedPasswordRule struct {
+	dir string
+	// minCount ignores passwords seen in fewer breaches than this.
+	minCount int
+}
+
+func NewBreachedPasswordRule(dir string, minCount int) *BreachedPasswordRule {
+	if minCount < 1 {
+		minCount = 1
+	}
+	return &BreachedPasswordRule{dir: dir, minCount: minCount}
+}
+
+func (r *BreachedPasswordRule) Name() string {
+	return entities.PasswordRuleBreached
+}
+
+// Check fails open: a corpus that cannot be read is logged rather than
+// blocking every password change.
+func (r *BreachedPasswordRule) Check(candidate PasswordCandidate) *entities.PasswordViolation {
+	sum := sha1.Sum([]byte(candidate.Password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := digest[:5], digest[5:]
+
+	count, err := r.lookup(prefix, suffix)
+	if err != nil {
+		log.Printf("password breach corpus lookup failed for range %s: %v", prefix, err)
+		return nil
+	}
+	if count >= r.minCount {
+		return &entities.PasswordViolation{Rule: entities.PasswordRuleBreached, Message: "password has appeared in a data breach"}
+	}
+	return nil
+}
+
+func (r *BreachedPasswordRule) lookup(prefix, suffix string) (int, error) {
+	var file *os.File
+	for _, name := range []string{prefix, prefix + ".txt", strings.ToLower(prefix), strings.ToLower(prefix) + ".txt"} {
+		var err error
+		file, err = os.Open(filepath.Join(r.dir, name))
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return 0, err
+		}
+	}
+	if file == nil {
+		// No range file means no breached password has this prefix.
+		return 0, nil
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Lines without a count are treated as seen once.
+		hash, count, found := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !strings.EqualFold(hash, suffix) {
+			continue
+		}
+		if !found {
+			return 1, nil
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(count))
+		if err != nil {
+			return 0, err
+		}
+		return n, nil
+	}
+	return 0, scanner.Err()
+}
@@ -0,0 +1,78 @@
+package security
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+const sealedPrefix = "v1:"
+
+// SecretBox encrypts small secrets, such as TOTP seeds, that have to be
+// stored in a recoverable form. A nil *SecretBox leaves values in plain text
+// so development setups work without a key.
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox takes a base64 encoded 32 byte key.
+func NewSecretBox(encodedKey string) (*SecretBox, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, errors.New("secret key must be base64 encoded")
+	}
+	if len(key) != 32 {
+		return nil, errors.New("secret key must be 32 bytes")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretBox{aead: aead}, nil
+}
+
+func (b *SecretBox) Seal(plaintext string) (string, error) {
+	if b == nil {
+		return plaintext, nil
+	}
+
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open reverses Seal. Values without the sealed prefix are returned as they
+// are, so secrets stored before a key was configured keep working.
+func (b *SecretBox) Open(value string) (string, error) {
+	if !strings.HasPrefix(value, sealedPrefix) {
+		return value, nil
+	}
+	if b == nil {
+		return "", errors.New("secret is encrypted but no key is configured")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, sealedPrefix))
+	if err != nil {
+		return "", err
+	}
+	nonceSize := b.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", errors.New("sealed secret is too short")
+	}
+	plaintext, err := b.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", errors.New("failed to decrypt secret")
+	}
+	return string(plaintext), nil
+}
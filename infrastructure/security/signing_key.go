@@ -0,0 +1,308 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+// SigningKey is an asymmetric key used to sign access tokens. Its ID is the
+// RFC 7638 thumbprint of the public key, so the same key always gets the same
+// kid no matter where it was loaded from.
+type SigningKey struct {
+	ID         string
+	Algorithm  string
+	PrivateKey crypto.Signer
+	CreatedAt  time.Time
+	// RetiresAt is zero while the key is usable for verification. Once set,
+	// tokens carrying this kid are accepted until that instant only.
+	RetiresAt time.Time
+}
+
+// JWK is the public half of a SigningKey in JSON Web Key form.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func GenerateSigningKey(alg string) (*SigningKey, error) {
+	var (
+		signer crypto.Signer
+		err    error
+	)
+
+	switch alg {
+	case AlgRS256:
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgES256:
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSigningKey(alg, signer)
+}
+
+// NewSigningKey wraps signer; an empty alg is inferred from the key type.
+func NewSigningKey(alg string, signer crypto.Signer) (*SigningKey, error) {
+	if alg == "" {
+		alg = inferAlgorithm(signer)
+	}
+	if err := checkKeyMatchesAlg(alg, signer); err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{
+		Algorithm:  alg,
+		PrivateKey: signer,
+		CreatedAt:  time.Now(),
+	}
+
+	kid, err := key.thumbprint()
+	if err != nil {
+		return nil, err
+	}
+	key.ID = kid
+
+	return key, nil
+}
+
+// LoadSigningKeyFile reads a PEM encoded private key (PKCS#8, PKCS#1 or SEC 1).
+func LoadSigningKeyFile(path, alg string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSigningKeyPEM(data, alg)
+}
+
+func ParseSigningKeyPEM(data []byte, alg string) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var (
+		parsed interface{}
+		err    error
+	)
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("key cannot be used for signing")
+	}
+
+	return NewSigningKey(alg, signer)
+}
+
+// MarshalPEM encodes the private key as PKCS#8.
+func (k *SigningKey) MarshalPEM() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func (k *SigningKey) IsRetired(now time.Time) bool {
+	return !k.RetiresAt.IsZero() && !now.Before(k.RetiresAt)
+}
+
+func (k *SigningKey) PublicKey() crypto.PublicKey {
+	return k.PrivateKey.Public()
+}
+
+func (k *SigningKey) SigningMethod() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+func (k *SigningKey) JWK() JWK {
+	jwk := JWK{
+		KeyID:     k.ID,
+		Use:       "sig",
+		Algorithm: k.Algorithm,
+	}
+
+	switch pub := k.PublicKey().(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = b64(pub.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = pub.Curve.Params().Name
+		jwk.X = b64(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = b64(pub)
+	}
+
+	return jwk
+}
+
+// PublicKey decodes the key material of a JWK published by another party.
+func (j JWK) PublicKey() (crypto.PublicKey, error) {
+	switch j.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if j.Curve != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", j.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if j.Curve != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", j.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key length")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", j.KeyType)
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint over the required public
+// members, which must be serialised in lexicographic order.
+func (k *SigningKey) thumbprint() (string, error) {
+	jwk := k.JWK()
+
+	var members interface{}
+	switch jwk.KeyType {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.KeyType, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Curve, jwk.KeyType, jwk.X, jwk.Y}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X}
+	default:
+		return "", errors.New("unsupported key type")
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return b64(sum[:]), nil
+}
+
+func inferAlgorithm(signer crypto.Signer) string {
+	switch signer.Public().(type) {
+	case *rsa.PublicKey:
+		return AlgRS256
+	case *ecdsa.PublicKey:
+		return AlgES256
+	case ed25519.PublicKey:
+		return AlgEdDSA
+	}
+	return ""
+}
+
+func checkKeyMatchesAlg(alg string, signer crypto.Signer) error {
+	switch pub := signer.Public().(type) {
+	case *rsa.PublicKey:
+		if alg == AlgRS256 {
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		if alg == AlgES256 && pub.Curve == elliptic.P256() {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if alg == AlgEdDSA {
+			return nil
+		}
+	}
+	return fmt.Errorf("key type does not match signing algorithm %q", alg)
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,57 @@
+package security
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"errors"
+)
+
+// AccessTokenIssuer mints and validates access tokens. Stateless issuers
+// produce self-contained tokens that are never written to the token
+// repository. A nil scopes slice issues an unrestricted token. sessionID is
+// carried as the token's FamilyID and roles are embedded for authorization.
+type AccessTokenIssuer interface {
+	Issue(userID, sessionID string, roles, scopes []string) (*entities.Token, error)
+	Validate(value string) (*entities.Token, error)
+	Stateless() bool
+}
+
+// OpaqueTokenIssuer issues random access tokens that are only meaningful to
+// this service; validation is a lookup in the token repository.
+type OpaqueTokenIssuer struct {
+	tokenRepo repositories.TokenRepository
+}
+
+func NewOpaqueTokenIssuer(tokenRepo repositories.TokenRepository) *OpaqueTokenIssuer {
+	return &OpaqueTokenIssuer{tokenRepo: tokenRepo}
+}
+
+func (i *OpaqueTokenIssuer) Issue(userID, sessionID string, roles, scopes []string) (*entities.Token, error) {
+	token := entities.NewAccessToken(userID)
+	token.FamilyID = sessionID
+	token.Roles = roles
+	token.Scopes = scopes
+	return token, nil
+}
+
+func (i *OpaqueTokenIssuer) Validate(value string) (*entities.Token, error) {
+	token, err := i.tokenRepo.FindByValue(value)
+	if err != nil {
+		return nil, errors.New("invalid access token")
+	}
+
+	if token.Type != entities.TokenTypeAccess {
+		return nil, errors.New("invalid token type")
+	}
+
+	if token.IsExpired() {
+		i.tokenRepo.Delete(value)
+		return nil, errors.New("access token expired")
+	}
+
+	return token, nil
+}
+
+func (i *OpaqueTokenIssuer) Stateless() bool {
+	return false
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/repositories"
+	"ambassador/domain/services"
+	"ambassador/interfaces/http/middleware"
+	"ambassador/interfaces/http/response"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultUserPageSize = 50
+	maxUserPageSize     = 200
+)
+
+// AdminUserHandler serves the account management operations under /admin.
+type AdminUserHandler struct {
+	authService services.AuthService
+	validator   middleware.Validator
+}
+
+func NewAdminUserHandler(authService services.AuthService, validator middleware.Validator) *AdminUserHandler {
+	return &AdminUserHandler{
+		authService: authService,
+		validator:   validator,
+	}
+}
+
+// List returns users newest first. q searches the email address and name,
+// status is "active" or "inactive", and pages are walked with limit and the
+// returned cursor.
+func (h *AdminUserHandler) List(c *gin.Context) {
+	filter := repositories.UserFilter{
+		Query: c.Query("q"),
+		Limit: defaultUserPageSize,
+	}
+
+	switch status := c.Query("status"); status {
+	case "":
+	case "active", "inactive":
+		active := status == "active"
+		filter.Active = &active
+	default:
+		response.Error(c, http.StatusBadRequest, "INVALID_QUERY", "status must be active or inactive")
+		return
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxUserPageSize {
+			response.Error(c, http.StatusBadRequest, "INVALID_QUERY", "limit must be between 1 and "+strconv.Itoa(maxUserPageSize))
+			return
+		}
+		filter.Limit = limit
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		before, id, err := dto.DecodeCursor(cursor)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, "INVALID_CURSOR", err.Error())
+			return
+		}
+		filter.BeforeTime, filter.BeforeID = before, id
+	}
+
+	// Fetch one extra user to learn whether there is another page.
+	pageSize := filter.Limit
+	filter.Limit++
+	users, err := h.authService.AdminListUsers(filter)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list users")
+		return
+	}
+
+	var nextCursor string
+	if len(users) > pageSize {
+		users = users[:pageSize]
+		nextCursor = dto.EncodeCursor(users[pageSize-1].CreatedAt, users[pageSize-1].ID)
+	}
+	response.Success(c, http.StatusOK, "Users retrieved successfully", dto.ToAdminUsersResponse(users, nextCursor))
+}
+
+func (h *AdminUserHandler) Get(c *gin.Context) {
+	detail, err := h.authService.AdminGetUser(c.Param("id"))
+	if err != nil {
+		h.error(c, err, "Failed to get user")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "User retrieved successfully", dto.ToAdminUserDetailResponse(detail))
+}
+
+func (h *AdminUserHandler) Deactivate(c *gin.Context) {
+	if err := h.authService.AdminSetUserActive(c.Param("id"), false, clientInfo(c)); err != nil {
+		h.error(c, err, "Failed to deactivate user")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "User deactivated", nil)
+}
+
+func (h *AdminUserHandler) Reactivate(c *gin.Context) {
+	if err := h.authService.AdminSetUserActive(c.Param("id"), true, clientInfo(c)); err != nil {
+		h.error(c, err, "Failed to reactivate user")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "User reactivated", nil)
+}
+
+func (h *AdminUserHandler) ForceLogout(c *gin.Context) {
+	revoked, err := h.authService.AdminForceLogout(c.Param("id"), clientInfo(c))
+	if err != nil {
+		h.error(c, err, "Failed to sign user out")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "User signed out everywhere", &dto.RevokeSessionsResponse{Revoked: revoked})
+}
+
+func (h *AdminUserHandler) ForcePasswordReset(c *gin.Context) {
+	if err := h.authService.AdminForcePasswordReset(c.Param("id"), clientInfo(c)); err != nil {
+		if strings.Contains(err.Error(), "password") {
+			response.Error(c, http.StatusBadRequest, "PASSWORD_RESET_NOT_AVAILABLE", err.Error())
+			return
+		}
+		h.error(c, err, "Failed to reset password")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Password reset, a reset link has been sent to the user", nil)
+}
+
+func (h *AdminUserHandler) SetRoles(c *gin.Context) {
+	var req dto.SetUserRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	req.Client = clientInfo(c)
+	user, err := h.authService.AdminSetUserRoles(c.Param("id"), &req)
+	if err != nil {
+		if strings.Contains(err.Error(), "unknown role") {
+			response.Error(c, http.StatusBadRequest, "UNKNOWN_ROLE", err.Error())
+			return
+		}
+		h.error(c, err, "Failed to change roles")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Roles updated", dto.ToAdminUserResponse(user))
+}
+
+func (h *AdminUserHandler) Unlock(c *gin.Context) {
+	if err := h.authService.AdminUnlockUser(c.Param("id"), clientInfo(c)); err != nil {
+		if strings.Contains(err.Error(), "not configured") {
+			response.Error(c, http.StatusBadRequest, "LOCKOUT_NOT_AVAILABLE", err.Error())
+			return
+		}
+		h.error(c, err, "Failed to unlock account")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Account unlocked", nil)
+}
+
+func (h *AdminUserHandler) error(c *gin.Context, err error, message string) {
+	if strings.Contains(err.Error(), "not found") {
+		response.Error(c, http.StatusNotFound, "USER_NOT_FOUND", err.Error())
+		return
+	}
+	if strings.Contains(err.Error(), "scheduled for deletion") {
+		response.Error(c, http.StatusConflict, "ACCOUNT_DELETED", err.Error())
+		return
+	}
+	response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", message)
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"ambassador/domain/services"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeUserLister serves AdminListUsers from a fixed page and records the
+// filter it was asked for.
+type fakeUserLister struct {
+	services.AuthService
+	users  []*entities.User
+	filter *repositories.UserFilter
+}
+
+func (f *fakeUserLister) AdminListUsers(filter repositories.UserFilter) ([]*entities.User, error) {
+	f.filter = &filter
+	return f.users, nil
+}
+
+func listUsersRequest(svc *fakeUserLister, query string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("requestID", "req-1") })
+	r.GET("/admin/users", NewAdminUserHandler(svc, nil).List)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/users?"+query, nil))
+	return w
+}
+
+func TestAdminUserListRejectsTamperedCursor(t *testing.T) {
+	for _, cursor := range []string{"!!", "bm90LWEtY3Vyc29y", dto.EncodeCursor(time.Now(), "user-1") + "%25"} {
+		svc := &fakeUserLister{}
+		w := listUsersRequest(svc, "cursor="+cursor)
+		if code := decodeResponse(t, w, nil); w.Code != http.StatusBadRequest || code != "INVALID_CURSOR" {
+			t.Errorf("cursor %q got %d %s, want 400 INVALID_CURSOR", cursor, w.Code, code)
+		}
+		if svc.filter != nil {
+			t.Errorf("cursor %q reached the service", cursor)
+		}
+	}
+}
+
+func TestAdminUserListPagesWithCursor(t *testing.T) {
+	now := time.Now().UTC()
+	email, err := entities.NewEmail("ann@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := &fakeUserLister{}
+	for i := 0; i < 3; i++ {
+		svc.users = append(svc.users, &entities.User{ID: "user-" + strconv.Itoa(3-i), Email: email, CreatedAt: now.Add(-time.Duration(i) * time.Minute)})
+	}
+
+	w := listUsersRequest(svc, "q=ann&limit=2&cursor="+dto.EncodeCursor(now.Add(time.Hour), "user-9"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s, want 200", w.Code, w.Body)
+	}
+	if f := svc.filter; f.Query != "ann" || f.Limit != 3 || f.BeforeID != "user-9" || !f.BeforeTime.Equal(now.Add(time.Hour)) {
+		t.Errorf("filter = %+v, want the query, cursor and one extra row", f)
+	}
+
+	var page struct {
+		Users []struct {
+			ID string `json:"id"`
+		} `json:"users"`
+		NextCursor string `json:"nextCursor"`
+	}
+	decodeResponse(t, w, &page)
+	if len(page.Users) != 2 {
+		t.Fatalf("returned %d users, want a page of 2", len(page.Users))
+	}
+	before, id, err := dto.DecodeCursor(page.NextCursor)
+	if err != nil || id != "user-2" || !before.Equal(svc.users[1].CreatedAt) {
+		t.Errorf("next cursor points at %v %q (%v), want the last user on the page", before, id, err)
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/entities"
+	"ambassador/domain/repositories"
+	"ambassador/domain/services"
+	"ambassador/interfaces/http/response"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+)
+
+// AuditHandler lets operators search the stored audit trail.
+type AuditHandler struct {
+	authService services.AuthService
+}
+
+func NewAuditHandler(authService services.AuthService) *AuditHandler {
+	return &AuditHandler{authService: authService}
+}
+
+// List returns audit events newest first. Filters are given as query
+// parameters: userId, actorId, type, ip, requestId, and since/until as RFC 3339
+// timestamps. Pages are walked with limit and the returned cursor.
+func (h *AuditHandler) List(c *gin.Context) {
+	filter := repositories.AuditEventFilter{
+		UserID:    c.Query("userId"),
+		ActorID:   c.Query("actorId"),
+		Type:      entities.AuditEventType(c.Query("type")),
+		IPAddress: c.Query("ip"),
+		RequestID: c.Query("requestId"),
+		Limit:     defaultAuditPageSize,
+	}
+
+	for param, dest := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, "INVALID_QUERY", param+" must be an RFC 3339 timestamp")
+			return
+		}
+		*dest = t
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAuditPageSize {
+			response.Error(c, http.StatusBadRequest, "INVALID_QUERY", "limit must be between 1 and "+strconv.Itoa(maxAuditPageSize))
+			return
+		}
+		filter.Limit = limit
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		before, id, err := dto.DecodeCursor(cursor)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, "INVALID_CURSOR", err.Error())
+			return
+		}
+		filter.BeforeTime, filter.BeforeID = before, id
+	}
+
+	// Fetch one extra event to learn whether there is another page.
+	pageSize := filter.Limit
+	filter.Limit++
+	events, err := h.authService.AdminListAuditEvents(filter)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to query audit events")
+		return
+	}
+
+	var nextCursor string
+	if len(events) > pageSize {
+		events = events[:pageSize]
+		nextCursor = dto.EncodeCursor(events[pageSize-1].CreatedAt, events[pageSize-1].ID)
+	}
+	response.Success(c, http.StatusOK, "Audit events retrieved successfully", dto.ToAuditEventsResponse(events, nextCursor))
+}
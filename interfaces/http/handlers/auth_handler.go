@@ -2,12 +2,18 @@ package handlers
 
 import (
 	"ambassador/application/dto"
+	"ambassador/domain/entities"
 	"ambassador/domain/services"
 	"ambassador/interfaces/http/middleware"
 	"ambassador/interfaces/http/response"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,12 +21,16 @@ import (
 type AuthHandler struct {
 	authService services.AuthService
 	validator   middleware.Validator
+	cookies     *middleware.SessionCookies
 }
 
-func NewAuthHandler(authService services.AuthService, validator middleware.Validator) *AuthHandler {
+// NewAuthHandler hands refresh tokens to browser clients in cookies when cookies
+// is non-nil, and in the response body otherwise.
+func NewAuthHandler(authService services.AuthService, validator middleware.Validator, cookies *middleware.SessionCookies) *AuthHandler {
 	return &AuthHandler{
 		authService: authService,
 		validator:   validator,
+		cookies:     cookies,
 	}
 }
 
@@ -36,8 +46,12 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	req.Client = clientInfo(c)
 	user, tokenPair, err := h.authService.Register(&req)
 	if err != nil {
+		if passwordPolicyViolation(c, err) {
+			return
+		}
 		if strings.Contains(err.Error(), "already exists") {
 			response.Error(c, http.StatusConflict, "USER_ALREADY_EXISTS", err.Error())
 			return
@@ -46,7 +60,11 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	authResponse := dto.ToAuthResponse(user, tokenPair)
+	authResponse := sessionResponse(c, h.cookies, user, tokenPair)
+	if tokenPair == nil {
+		response.Success(c, http.StatusCreated, "User registered successfully, please verify your email address to sign in", authResponse)
+		return
+	}
 	response.Success(c, http.StatusCreated, "User registered successfully", authResponse)
 }
 
@@ -63,19 +81,100 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	tokenPair, err := h.authService.Login(&req)
+	req.Client = clientInfo(c)
+	tokenPair, challenge, err := h.authService.Login(&req)
 	if err != nil {
+		if loginThrottled(c, err) {
+			return
+		}
+		if strings.Contains(err.Error(), "not verified") {
+			response.Error(c, http.StatusForbidden, "EMAIL_NOT_VERIFIED", "Please verify your email address before signing in")
+			return
+		}
 		response.Error(c, http.StatusUnauthorized, "INVALID_CREDENTIALS", "Invalid credentials")
 		return
 	}
 
+	if challenge != nil {
+		loginChallenge(c, challenge)
+		return
+	}
+
 	user, err := h.authService.GetProfile(tokenPair.AccessToken.Value)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get user profile")
 		return
 	}
 
-	authResponse := dto.ToAuthResponse(user, tokenPair)
+	authResponse := sessionResponse(c, h.cookies, user, tokenPair)
+	response.Success(c, http.StatusOK, "Login successful", authResponse)
+}
+
+// OAuthNonce hands out the nonce an ID token sent to OAuthLogin must carry.
+func (h *AuthHandler) OAuthNonce(c *gin.Context) {
+	nonce, expiresAt, err := h.authService.IssueOAuthNonce()
+	if err != nil {
+		if strings.Contains(err.Error(), "not configured") {
+			response.Error(c, http.StatusBadRequest, "PROVIDER_NOT_SUPPORTED", err.Error())
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to issue nonce")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Nonce issued", &dto.OAuthNonceResponse{
+		Nonce:     nonce,
+		ExpiresIn: int64(time.Until(expiresAt).Seconds()),
+	})
+}
+
+func (h *AuthHandler) OAuthLogin(c *gin.Context) {
+	var req dto.OAuthLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	req.Client = clientInfo(c)
+	user, tokenPair, challenge, err := h.authService.OAuthLogin(&req)
+	if err != nil {
+		if loginThrottled(c, err) {
+			return
+		}
+		switch {
+		case strings.Contains(err.Error(), "not configured"):
+			response.Error(c, http.StatusBadRequest, "PROVIDER_NOT_SUPPORTED", err.Error())
+		case strings.Contains(err.Error(), "registration details required"):
+			response.Error(c, http.StatusUnprocessableEntity, "REGISTRATION_DETAILS_REQUIRED", "Full name, gender and date of birth are required to create an account")
+		case strings.Contains(err.Error(), "deactivated"):
+			response.Error(c, http.StatusForbidden, "ACCOUNT_DEACTIVATED", err.Error())
+		case strings.Contains(err.Error(), "not verified"):
+			response.Error(c, http.StatusForbidden, "EMAIL_NOT_VERIFIED", "Please verify your email address before signing in")
+		case strings.Contains(err.Error(), "nonce"):
+			response.Error(c, http.StatusUnauthorized, "INVALID_NONCE", err.Error())
+		case strings.Contains(err.Error(), "ID token"), strings.Contains(err.Error(), "verified email"):
+			response.Error(c, http.StatusUnauthorized, "INVALID_ID_TOKEN", err.Error())
+		case strings.Contains(err.Error(), "password required"):
+			response.Error(c, http.StatusConflict, "ACCOUNT_LINK_PASSWORD_REQUIRED", "An account with this email already exists; send its password to link this sign-in method")
+		case strings.Contains(err.Error(), "invalid credentials"):
+			response.Error(c, http.StatusUnauthorized, "INVALID_CREDENTIALS", "Invalid credentials")
+		default:
+			response.Error(c, http.StatusBadRequest, "OAUTH_LOGIN_FAILED", err.Error())
+		}
+		return
+	}
+
+	if challenge != nil {
+		loginChallenge(c, challenge)
+		return
+	}
+
+	authResponse := sessionResponse(c, h.cookies, user, tokenPair)
 	response.Success(c, http.StatusOK, "Login successful", authResponse)
 }
 
@@ -108,8 +207,34 @@ func (h *AuthHandler) Profile(c *gin.Context) {
 	response.Success(c, http.StatusOK, "Profile retrieved successfully", userResponse)
 }
 
-func (h *AuthHandler) Logout(c *gin.Context) {
-	var req dto.RefreshTokenRequest
+func (h *AuthHandler) UpdateProfile(c *gin.Context) {
+	var req dto.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	req.Client = clientInfo(c)
+	user, err := h.authService.UpdateProfile(c.GetString("userID"), &req)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			response.Error(c, http.StatusNotFound, "USER_NOT_FOUND", err.Error())
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "PROFILE_UPDATE_FAILED", err.Error())
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Profile updated successfully", dto.ToUserResponse(user))
+}
+
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var req dto.ChangePasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.Error(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
 		return
@@ -120,7 +245,39 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 		return
 	}
 
-	if err := h.authService.Logout(req.RefreshToken); err != nil {
+	req.Client = clientInfo(c)
+	if err := h.authService.ChangePassword(c.GetString("userID"), c.GetString("sessionID"), &req); err != nil {
+		if loginThrottled(c, err) || passwordPolicyViolation(c, err) {
+			return
+		}
+		switch {
+		case strings.Contains(err.Error(), "current password is incorrect"):
+			response.Error(c, http.StatusUnauthorized, "INVALID_CURRENT_PASSWORD", err.Error())
+		case strings.Contains(err.Error(), "does not use a password"):
+			response.Error(c, http.StatusBadRequest, "PASSWORD_NOT_SUPPORTED", err.Error())
+		default:
+			response.Error(c, http.StatusBadRequest, "PASSWORD_CHANGE_FAILED", err.Error())
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Password changed successfully, other sessions have been signed out", nil)
+}
+
+// Logout expects UserRefreshTokenMiddleware to have read the request body
+// and stored the refresh token in the context.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	req := dto.RefreshTokenRequest{RefreshToken: c.GetString("refreshToken")}
+	if err := h.validator.Validate(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	err := h.authService.Logout(req.RefreshToken, clientInfo(c))
+	// The cookies are useless once logout was asked for, whether or not the
+	// session still existed.
+	h.cookies.ClearSession(c)
+	if err != nil {
 		response.Error(c, http.StatusBadRequest, "LOGOUT_FAILED", err.Error())
 		return
 	}
@@ -128,9 +285,14 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 	response.Success(c, http.StatusOK, "Logout successful", nil)
 }
 
+// RefreshToken takes the refresh token from the session cookie when the
+// request carries one, and from the body otherwise.
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	var req dto.RefreshTokenRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	refreshToken, fromCookie := h.cookies.RefreshToken(c)
+	if fromCookie {
+		req.RefreshToken = refreshToken
+	} else if err := c.ShouldBindJSON(&req); err != nil {
 		response.Error(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
 		return
 	}
@@ -140,13 +302,433 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	tokenPair, err := h.authService.RefreshToken(req.RefreshToken)
-	fmt.Println(req.RefreshToken)
+	req.Client = clientInfo(c)
+	tokenPair, err := h.authService.RefreshToken(&req)
 	if err != nil {
+		if strings.Contains(err.Error(), "not verified") {
+			response.Error(c, http.StatusForbidden, "EMAIL_NOT_VERIFIED", "Please verify your email address before signing in")
+			return
+		}
+		if fromCookie {
+			h.cookies.ClearSession(c)
+		}
 		response.Error(c, http.StatusUnauthorized, "INVALID_REFRESH_TOKEN", err.Error())
 		return
 	}
 
 	refreshResponse := dto.ToRefreshResponse(tokenPair)
+	if h.cookies.SetSession(c, tokenPair.RefreshToken) {
+		refreshResponse.RefreshToken = ""
+	}
 	response.Success(c, http.StatusOK, "Token refreshed successfully", refreshResponse)
 }
+
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req dto.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	h.authService.ForgotPassword(&req)
+
+	response.Success(c, http.StatusAccepted, "If an account exists for this email, a password reset link has been sent", nil)
+}
+
+// RequestMagicLink emails a sign-in link and answers with the nonce the
+// client must present when it consumes the link.
+func (h *AuthHandler) RequestMagicLink(c *gin.Context) {
+	var req dto.MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	req.Client = clientInfo(c)
+	nonce, expiresAt, err := h.authService.RequestMagicLink(&req)
+	if err != nil {
+		if strings.Contains(err.Error(), "not configured") {
+			response.Error(c, http.StatusNotFound, "MAGIC_LINKS_NOT_AVAILABLE", err.Error())
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to send magic link")
+		return
+	}
+
+	response.Success(c, http.StatusAccepted, "If an account exists for this email, a sign-in link has been sent", &dto.MagicLinkResponse{
+		Nonce:     nonce,
+		ExpiresIn: int64(time.Until(expiresAt).Seconds()),
+	})
+}
+
+func (h *AuthHandler) ConsumeMagicLink(c *gin.Context) {
+	var req dto.ConsumeMagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	req.Client = clientInfo(c)
+	user, tokenPair, challenge, err := h.authService.ConsumeMagicLink(&req)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not configured"):
+			response.Error(c, http.StatusNotFound, "MAGIC_LINKS_NOT_AVAILABLE", err.Error())
+		case strings.Contains(err.Error(), "magic link"):
+			response.Error(c, http.StatusUnauthorized, "INVALID_MAGIC_LINK", err.Error())
+		case strings.Contains(err.Error(), "deactivated"), strings.Contains(err.Error(), "scheduled for deletion"):
+			response.Error(c, http.StatusForbidden, "ACCOUNT_DEACTIVATED", err.Error())
+		default:
+			response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to sign in")
+		}
+		return
+	}
+
+	if challenge != nil {
+		loginChallenge(c, challenge)
+		return
+	}
+
+	authResponse := sessionResponse(c, h.cookies, user, tokenPair)
+	response.Success(c, http.StatusOK, "Login successful", authResponse)
+}
+
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req dto.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	req.Client = clientInfo(c)
+	if err := h.authService.ResetPassword(&req); err != nil {
+		if passwordPolicyViolation(c, err) {
+			return
+		}
+		if strings.Contains(err.Error(), "reset token") {
+			response.Error(c, http.StatusBadRequest, "INVALID_RESET_TOKEN", err.Error())
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "PASSWORD_RESET_FAILED", err.Error())
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Password has been reset successfully", nil)
+}
+
+// ChangeExpiredPassword finishes a login that answered with a password
+// change challenge.
+func (h *AuthHandler) ChangeExpiredPassword(c *gin.Context) {
+	var req dto.ExpiredPasswordChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	req.Client = clientInfo(c)
+	user, tokenPair, err := h.authService.ChangeExpiredPassword(&req)
+	if err != nil {
+		if passwordPolicyViolation(c, err) {
+			return
+		}
+		switch {
+		case strings.Contains(err.Error(), "password change token"):
+			response.Error(c, http.StatusUnauthorized, "INVALID_PASSWORD_CHANGE_TOKEN", err.Error())
+		case strings.Contains(err.Error(), "deactivated"):
+			response.Error(c, http.StatusForbidden, "ACCOUNT_DEACTIVATED", err.Error())
+		default:
+			response.Error(c, http.StatusBadRequest, "PASSWORD_CHANGE_FAILED", err.Error())
+		}
+		return
+	}
+
+	authResponse := sessionResponse(c, h.cookies, user, tokenPair)
+	response.Success(c, http.StatusOK, "Password changed, login successful", authResponse)
+}
+
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req dto.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	req.Client = clientInfo(c)
+	if err := h.authService.VerifyEmail(&req); err != nil {
+		if strings.Contains(err.Error(), "verification token") {
+			response.Error(c, http.StatusBadRequest, "INVALID_VERIFICATION_TOKEN", err.Error())
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "EMAIL_VERIFICATION_FAILED", err.Error())
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Email address verified successfully", nil)
+}
+
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var req dto.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	h.authService.ResendVerification(&req)
+
+	response.Success(c, http.StatusAccepted, "If an unverified account exists for this email, a verification link has been sent", nil)
+}
+
+func (h *AuthHandler) UnlockAccount(c *gin.Context) {
+	var req dto.UnlockAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	req.Client = clientInfo(c)
+	if err := h.authService.UnlockAccount(&req); err != nil {
+		if strings.Contains(err.Error(), "unlock token") {
+			response.Error(c, http.StatusBadRequest, "INVALID_UNLOCK_TOKEN", err.Error())
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "ACCOUNT_UNLOCK_FAILED", err.Error())
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Account unlocked, you can sign in again", nil)
+}
+
+func (h *AuthHandler) RequestEmailChange(c *gin.Context) {
+	var req dto.ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	req.Client = clientInfo(c)
+	if err := h.authService.RequestEmailChange(c.GetString("userID"), &req); err != nil {
+		if strings.Contains(err.Error(), "current password is incorrect") {
+			response.Error(c, http.StatusUnauthorized, "INVALID_CURRENT_PASSWORD", err.Error())
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "EMAIL_CHANGE_FAILED", err.Error())
+		return
+	}
+
+	response.Success(c, http.StatusAccepted, "A confirmation link has been sent to the new email address", nil)
+}
+
+func (h *AuthHandler) ConfirmEmailChange(c *gin.Context) {
+	var req dto.EmailChangeTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	req.Client = clientInfo(c)
+	if err := h.authService.ConfirmEmailChange(&req); err != nil {
+		switch {
+		case strings.Contains(err.Error(), "email change token"):
+			response.Error(c, http.StatusBadRequest, "INVALID_EMAIL_CHANGE_TOKEN", err.Error())
+		case strings.Contains(err.Error(), "already in use"):
+			response.Error(c, http.StatusConflict, "EMAIL_ALREADY_IN_USE", err.Error())
+		default:
+			response.Error(c, http.StatusBadRequest, "EMAIL_CHANGE_FAILED", err.Error())
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Email address changed successfully", nil)
+}
+
+func (h *AuthHandler) RevertEmailChange(c *gin.Context) {
+	var req dto.EmailChangeTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	req.Client = clientInfo(c)
+	if err := h.authService.RevertEmailChange(&req); err != nil {
+		switch {
+		case strings.Contains(err.Error(), "email revert token"):
+			response.Error(c, http.StatusBadRequest, "INVALID_EMAIL_REVERT_TOKEN", err.Error())
+		case strings.Contains(err.Error(), "already in use"):
+			response.Error(c, http.StatusConflict, "EMAIL_ALREADY_IN_USE", err.Error())
+		default:
+			response.Error(c, http.StatusBadRequest, "EMAIL_REVERT_FAILED", err.Error())
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Email change reverted, every device has been signed out", nil)
+}
+
+func (h *AuthHandler) DeleteAccount(c *gin.Context) {
+	var req dto.DeleteAccountRequest
+	// Accounts without a password may send no body at all.
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		response.Error(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	req.Client = clientInfo(c)
+	purgeAt, err := h.authService.DeleteAccount(c.GetString("userID"), &req)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "current password is incorrect"):
+			response.Error(c, http.StatusUnauthorized, "INVALID_CURRENT_PASSWORD", err.Error())
+		case strings.Contains(err.Error(), "already scheduled"):
+			response.Error(c, http.StatusConflict, "ACCOUNT_DELETED", err.Error())
+		default:
+			response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete account")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusAccepted, "Account scheduled for deletion", &dto.AccountDeletionResponse{PurgeAt: purgeAt})
+}
+
+func (h *AuthHandler) RestoreAccount(c *gin.Context) {
+	var req dto.RestoreAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	req.Client = clientInfo(c)
+	if err := h.authService.RestoreAccount(&req); err != nil {
+		if strings.Contains(err.Error(), "restore token") {
+			response.Error(c, http.StatusBadRequest, "INVALID_RESTORE_TOKEN", err.Error())
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to restore account")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Account restored, you can sign in again", nil)
+}
+
+// ExportAccount serves the account export as a JSON file download rather
+// than in the usual response envelope, so it can be saved as is.
+func (h *AuthHandler) ExportAccount(c *gin.Context) {
+	export, err := h.authService.ExportAccount(c.GetString("userID"), clientInfo(c))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to export account")
+		return
+	}
+
+	filename := fmt.Sprintf("account-export-%s.json", export.ExportedAt.Format("20060102T150405Z"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, dto.ToAccountExportResponse(export))
+}
+
+func (h *AuthHandler) PasswordPolicy(c *gin.Context) {
+	response.Success(c, http.StatusOK, "Password policy retrieved successfully", h.authService.PasswordPolicy())
+}
+
+// sessionResponse builds the body for a successful sign-in. In cookie mode
+// the refresh token is set in a cookie instead and left out of the body.
+func sessionResponse(c *gin.Context, cookies *middleware.SessionCookies, user *entities.User, tokenPair *entities.TokenPair) *dto.AuthResponse {
+	authResponse := dto.ToAuthResponse(user, tokenPair)
+	if tokenPair != nil && cookies.SetSession(c, tokenPair.RefreshToken) {
+		authResponse.RefreshToken = ""
+	}
+	return authResponse
+}
+
+// loginChallenge answers a login that has another step to complete before
+// tokens are issued.
+func loginChallenge(c *gin.Context, challenge *entities.Challenge) {
+	if challenge.Type == entities.TokenTypePasswordExpired {
+		response.Success(c, http.StatusOK, "Password has expired and must be changed", dto.ToPasswordChangeChallengeResponse(challenge))
+		return
+	}
+	response.Success(c, http.StatusOK, "MFA verification required", dto.ToMFAChallengeResponse(challenge))
+}
+
+// loginThrottled answers with when to try again and reports whether err was
+// the account's login throttle.
+func loginThrottled(c *gin.Context, err error) bool {
+	var throttled *entities.LoginThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+	response.Error(c, http.StatusTooManyRequests, "TOO_MANY_LOGIN_ATTEMPTS", "Too many failed login attempts, please try again later")
+	return true
+}
+
+// passwordPolicyViolation answers with every rule a new password failed and
+// reports whether err was such a failure.
+func passwordPolicyViolation(c *gin.Context, err error) bool {
+	var policyErr *entities.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+	response.ErrorWithData(c, http.StatusUnprocessableEntity, "PASSWORD_POLICY_VIOLATION", "Password does not meet the password policy", &dto.PasswordPolicyViolationsResponse{
+		Violations: policyErr.Violations,
+	})
+	return true
+}
\ No newline at end of file
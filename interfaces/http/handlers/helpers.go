@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"ambassador/domain/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clientInfo describes the client behind the request for session records
+// and audit events.
+func clientInfo(c *gin.Context) entities.ClientInfo {
+	return entities.ClientInfo{
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		RequestID: c.GetString("requestID"),
+		ActorID:   c.GetString("userID"),
+	}
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"ambassador/infrastructure/security"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JWKSProvider interface {
+	JWKS() security.JWKSet
+}
+
+type JWKSHandler struct {
+	provider JWKSProvider
+}
+
+func NewJWKSHandler(provider JWKSProvider) *JWKSHandler {
+	return &JWKSHandler{provider: provider}
+}
+
+// JWKS serves the key set as a bare JSON document rather than the usual API
+// envelope, because JWT libraries expect the RFC 7517 format verbatim.
+func (h *JWKSHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.provider.JWKS())
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"ambassador/application/dto"
+	"ambassador/infrastructure/security"
+	"ambassador/interfaces/http/middleware"
+	"ambassador/interfaces/http/response"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type KeyHandler struct {
+	keys      *security.KeyManager
+	validator middleware.Validator
+}
+
+func NewKeyHandler(keys *security.KeyManager, validator middleware.Validator) *KeyHandler {
+	return &KeyHandler{
+		keys:      keys,
+		validator: validator,
+	}
+}
+
+func (h *KeyHandler) List(c *gin.Context) {
+	keys, primary := h.keys.Keys()
+
+	res := make([]*dto.SigningKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		res = append(res, toSigningKeyResponse(key, primary))
+	}
+	response.Success(c, http.StatusOK, "Signing keys retrieved successfully", res)
+}
+
+func (h *KeyHandler) Add(c *gin.Context) {
+	var req dto.AddSigningKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	var (
+		key *security.SigningKey
+		err error
+	)
+	if strings.TrimSpace(req.PrivateKeyPEM) != "" {
+		key, err = security.ParseSigningKeyPEM([]byte(req.PrivateKeyPEM), req.Algorithm)
+	} else {
+		key, err = security.GenerateSigningKey(req.Algorithm)
+	}
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_KEY", err.Error())
+		return
+	}
+
+	if err := h.keys.Add(key, req.Promote); err != nil {
+		response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to store signing key")
+		return
+	}
+
+	_, primary := h.keys.Keys()
+	response.Success(c, http.StatusCreated, "Signing key added successfully", toSigningKeyResponse(key, primary))
+}
+
+func (h *KeyHandler) Promote(c *gin.Context) {
+	if err := h.keys.Promote(c.Param("kid")); err != nil {
+		response.Error(c, http.StatusBadRequest, "KEY_PROMOTION_FAILED", err.Error())
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Signing key promoted successfully", nil)
+}
+
+func (h *KeyHandler) Retire(c *gin.Context) {
+	if err := h.keys.Retire(c.Param("kid")); err != nil {
+		response.Error(c, http.StatusBadRequest, "KEY_RETIREMENT_FAILED", err.Error())
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Signing key retired successfully", nil)
+}
+
+func toSigningKeyResponse(key *security.SigningKey, primary string) *dto.SigningKeyResponse {
+	res := &dto.SigningKeyResponse{
+		KeyID:     key.ID,
+		Algorithm: key.Algorithm,
+		Primary:   key.ID == primary,
+		CreatedAt: key.CreatedAt,
+	}
+	if !key.RetiresAt.IsZero() {
+		retiresAt := key.RetiresAt
+		res.RetiresAt = &retiresAt
+	}
+	return res
+}
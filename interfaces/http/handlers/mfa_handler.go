@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/services"
+	"ambassador/interfaces/http/middleware"
+	"ambassador/interfaces/http/response"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MFAHandler struct {
+	authService services.AuthService
+	validator   middleware.Validator
+	cookies     *middleware.SessionCookies
+}
+
+// NewMFAHandler hands refresh tokens to browser clients in cookies when cookies
+// is non-nil, and in the response body otherwise.
+func NewMFAHandler(authService services.AuthService, validator middleware.Validator, cookies *middleware.SessionCookies) *MFAHandler {
+	return &MFAHandler{
+		authService: authService,
+		validator:   validator,
+		cookies:     cookies,
+	}
+}
+
+func (h *MFAHandler) Verify(c *gin.Context) {
+	var req dto.MFAVerifyRequest
+	if !h.bind(c, &req) {
+		return
+	}
+
+	req.Client = clientInfo(c)
+	user, tokenPair, challenge, err := h.authService.VerifyMFA(&req)
+	if err != nil {
+		if loginThrottled(c, err) {
+			return
+		}
+		switch {
+		case strings.Contains(err.Error(), "mfa token"), strings.Contains(err.Error(), "too many"):
+			response.Error(c, http.StatusUnauthorized, "INVALID_MFA_TOKEN", err.Error())
+		case strings.Contains(err.Error(), "deactivated"):
+			response.Error(c, http.StatusForbidden, "ACCOUNT_DEACTIVATED", err.Error())
+		default:
+			h.error(c, err)
+		}
+		return
+	}
+
+	if challenge != nil {
+		loginChallenge(c, challenge)
+		return
+	}
+
+	authResponse := sessionResponse(c, h.cookies, user, tokenPair)
+	response.Success(c, http.StatusOK, "Login successful", authResponse)
+}
+
+func (h *MFAHandler) EnrollTOTP(c *gin.Context) {
+	secret, uri, err := h.authService.EnrollTOTP(c.GetString("userID"))
+	if err != nil {
+		h.error(c, err)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Scan the code with your authenticator app, then confirm with a code", &dto.TOTPEnrollmentResponse{
+		Secret:     secret,
+		OTPAuthURI: uri,
+	})
+}
+
+func (h *MFAHandler) ConfirmTOTP(c *gin.Context) {
+	var req dto.MFACodeRequest
+	if !h.bind(c, &req) {
+		return
+	}
+
+	req.Client = clientInfo(c)
+	codes, err := h.authService.ConfirmTOTP(c.GetString("userID"), &req)
+	if err != nil {
+		h.error(c, err)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "MFA enabled, store these recovery codes somewhere safe", &dto.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *MFAHandler) DisableTOTP(c *gin.Context) {
+	var req dto.MFACodeRequest
+	if !h.bind(c, &req) {
+		return
+	}
+
+	req.Client = clientInfo(c)
+	if err := h.authService.DisableTOTP(c.GetString("userID"), &req); err != nil {
+		h.error(c, err)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "MFA disabled", nil)
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req dto.MFACodeRequest
+	if !h.bind(c, &req) {
+		return
+	}
+
+	req.Client = clientInfo(c)
+	codes, err := h.authService.RegenerateRecoveryCodes(c.GetString("userID"), &req)
+	if err != nil {
+		h.error(c, err)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Recovery codes regenerated, previous codes no longer work", &dto.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *MFAHandler) bind(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return false
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return false
+	}
+	return true
+}
+
+func (h *MFAHandler) error(c *gin.Context, err error) {
+	if loginThrottled(c, err) {
+		return
+	}
+	switch {
+	case strings.Contains(err.Error(), "too many"):
+		response.Error(c, http.StatusTooManyRequests, "TOO_MANY_MFA_ATTEMPTS", err.Error())
+	case strings.Contains(err.Error(), "invalid mfa code"):
+		response.Error(c, http.StatusUnauthorized, "INVALID_MFA_CODE", err.Error())
+	case strings.Contains(err.Error(), "already enabled"):
+		response.Error(c, http.StatusConflict, "MFA_ALREADY_ENABLED", err.Error())
+	case strings.Contains(err.Error(), "not enabled"), strings.Contains(err.Error(), "not been started"):
+		response.Error(c, http.StatusConflict, "MFA_NOT_ENABLED", err.Error())
+	case strings.Contains(err.Error(), "only available"), strings.Contains(err.Error(), "not configured"):
+		response.Error(c, http.StatusBadRequest, "MFA_NOT_AVAILABLE", err.Error())
+	default:
+		response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to process MFA request")
+	}
+}
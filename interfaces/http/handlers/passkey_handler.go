@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/services"
+	"ambassador/interfaces/http/middleware"
+	"ambassador/interfaces/http/response"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PasskeyHandler struct {
+	authService services.AuthService
+	validator   middleware.Validator
+	cookies     *middleware.SessionCookies
+}
+
+// NewPasskeyHandler hands refresh tokens to browser clients in cookies when cookies
+// is non-nil, and in the response body otherwise.
+func NewPasskeyHandler(authService services.AuthService, validator middleware.Validator, cookies *middleware.SessionCookies) *PasskeyHandler {
+	return &PasskeyHandler{
+		authService: authService,
+		validator:   validator,
+		cookies:     cookies,
+	}
+}
+
+func (h *PasskeyHandler) BeginRegistration(c *gin.Context) {
+	ceremonyID, options, err := h.authService.BeginPasskeyRegistration(c.GetString("userID"))
+	if err != nil {
+		h.error(c, err)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Passkey registration started", &dto.PasskeyCeremonyResponse{
+		CeremonyID: ceremonyID,
+		Options:    options,
+	})
+}
+
+func (h *PasskeyHandler) FinishRegistration(c *gin.Context) {
+	var req dto.FinishPasskeyRegistrationRequest
+	if !h.bind(c, &req) {
+		return
+	}
+
+	req.Client = clientInfo(c)
+	credential, err := h.authService.FinishPasskeyRegistration(c.GetString("userID"), &req)
+	if err != nil {
+		h.error(c, err)
+		return
+	}
+
+	response.Success(c, http.StatusCreated, "Passkey added", dto.ToPasskeyResponse(credential))
+}
+
+func (h *PasskeyHandler) BeginLogin(c *gin.Context) {
+	var req dto.BeginPasskeyLoginRequest
+	if !h.bind(c, &req) {
+		return
+	}
+
+	ceremonyID, options, err := h.authService.BeginPasskeyLogin(&req)
+	if err != nil {
+		h.error(c, err)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Passkey login started", &dto.PasskeyCeremonyResponse{
+		CeremonyID: ceremonyID,
+		Options:    options,
+	})
+}
+
+func (h *PasskeyHandler) FinishLogin(c *gin.Context) {
+	var req dto.FinishPasskeyLoginRequest
+	if !h.bind(c, &req) {
+		return
+	}
+
+	req.Client = clientInfo(c)
+	user, tokenPair, err := h.authService.FinishPasskeyLogin(&req)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "deactivated"):
+			response.Error(c, http.StatusForbidden, "ACCOUNT_DEACTIVATED", err.Error())
+		case strings.Contains(err.Error(), "not verified"):
+			response.Error(c, http.StatusForbidden, "EMAIL_NOT_VERIFIED", err.Error())
+		default:
+			h.error(c, err)
+		}
+		return
+	}
+
+	authResponse := sessionResponse(c, h.cookies, user, tokenPair)
+	response.Success(c, http.StatusOK, "Login successful", authResponse)
+}
+
+func (h *PasskeyHandler) List(c *gin.Context) {
+	credentials, err := h.authService.ListPasskeys(c.GetString("userID"))
+	if err != nil {
+		h.error(c, err)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Passkeys retrieved", dto.ToPasskeyResponses(credentials))
+}
+
+func (h *PasskeyHandler) Delete(c *gin.Context) {
+	if err := h.authService.DeletePasskey(c.GetString("userID"), c.Param("id"), clientInfo(c)); err != nil {
+		h.error(c, err)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Passkey removed", nil)
+}
+
+func (h *PasskeyHandler) bind(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		return false
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return false
+	}
+	return true
+}
+
+func (h *PasskeyHandler) error(c *gin.Context, err error) {
+	switch {
+	case strings.Contains(err.Error(), "ceremony"):
+		response.Error(c, http.StatusBadRequest, "INVALID_PASSKEY_CEREMONY", err.Error())
+	case strings.Contains(err.Error(), "invalid passkey response"):
+		response.Error(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+	case strings.Contains(err.Error(), "verification failed"), strings.Contains(err.Error(), "cloned"):
+		response.Error(c, http.StatusUnauthorized, "PASSKEY_VERIFICATION_FAILED", err.Error())
+	case strings.Contains(err.Error(), "already registered"):
+		response.Error(c, http.StatusConflict, "PASSKEY_EXISTS", err.Error())
+	case strings.Contains(err.Error(), "not found"):
+		response.Error(c, http.StatusNotFound, "NOT_FOUND", err.Error())
+	case strings.Contains(err.Error(), "not configured"):
+		response.Error(c, http.StatusBadRequest, "PASSKEYS_NOT_AVAILABLE", err.Error())
+	default:
+		response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to process passkey request")
+	}
+}
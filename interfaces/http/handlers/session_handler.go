@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"ambassador/application/dto"
+	"ambassador/domain/services"
+	"ambassador/interfaces/http/response"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionHandler struct {
+	authService services.AuthService
+}
+
+func NewSessionHandler(authService services.AuthService) *SessionHandler {
+	return &SessionHandler{authService: authService}
+}
+
+func (h *SessionHandler) List(c *gin.Context) {
+	sessions, err := h.authService.ListSessions(c.GetString("userID"))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list sessions")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Sessions retrieved", dto.ToSessionResponses(sessions, c.GetString("sessionID")))
+}
+
+func (h *SessionHandler) Revoke(c *gin.Context) {
+	if err := h.authService.RevokeSession(c.GetString("userID"), c.Param("id"), clientInfo(c)); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			response.Error(c, http.StatusNotFound, "SESSION_NOT_FOUND", err.Error())
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to revoke session")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Session revoked", nil)
+}
+
+// RevokeOthers logs the user out on every device but the one making the
+// request.
+func (h *SessionHandler) RevokeOthers(c *gin.Context) {
+	revoked, err := h.authService.RevokeOtherSessions(c.GetString("userID"), c.GetString("sessionID"), clientInfo(c))
+	if err != nil {
+		if strings.Contains(err.Error(), "current session") {
+			response.Error(c, http.StatusConflict, "SESSION_UNKNOWN", err.Error())
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to revoke sessions")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Signed out of other sessions", &dto.RevokeSessionsResponse{Revoked: revoked})
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/domain/services"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeSessionService implements the session methods of services.AuthService
+// and records what the handlers asked for.
+type fakeSessionService struct {
+	services.AuthService
+	sessions []*entities.Session
+	err      error
+
+	userID    string
+	sessionID string
+	client    entities.ClientInfo
+}
+
+func (f *fakeSessionService) ListSessions(userID string) ([]*entities.Session, error) {
+	f.userID = userID
+	return f.sessions, f.err
+}
+
+func (f *fakeSessionService) RevokeSession(userID, sessionID string, client entities.ClientInfo) error {
+	f.userID, f.sessionID, f.client = userID, sessionID, client
+	return f.err
+}
+
+func (f *fakeSessionService) RevokeOtherSessions(userID, currentSessionID string, client entities.ClientInfo) (int, error) {
+	f.userID, f.sessionID, f.client = userID, currentSessionID, client
+	if f.err != nil {
+		return 0, f.err
+	}
+	return 2, nil
+}
+
+// sessionRequest sends method and path to the session routes as user-1 on
+// session-1 and returns the recorder.
+func sessionRequest(svc *fakeSessionService, method, path string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("requestID", "req-1")
+		c.Set("userID", "user-1")
+		c.Set("sessionID", "session-1")
+	})
+	h := NewSessionHandler(svc)
+	r.GET("/sessions", h.List)
+	r.DELETE("/sessions/:id", h.Revoke)
+	r.POST("/sessions/revoke-others", h.RevokeOthers)
+
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("User-Agent", "test-agent")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func decodeResponse(t *testing.T, w *httptest.ResponseRecorder, data interface{}) string {
+	t.Helper()
+	var res struct {
+		ErrorCode string          `json:"errorCode"`
+		Data      json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decode %s: %v", w.Body, err)
+	}
+	if data != nil {
+		if err := json.Unmarshal(res.Data, data); err != nil {
+			t.Fatalf("decode data %s: %v", res.Data, err)
+		}
+	}
+	return res.ErrorCode
+}
+
+func TestSessionListMarksCurrentSession(t *testing.T) {
+	svc := &fakeSessionService{sessions: []*entities.Session{{ID: "session-1"}, {ID: "session-2"}}}
+
+	w := sessionRequest(svc, http.MethodGet, "/sessions")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s, want 200", w.Code, w.Body)
+	}
+	var sessions []struct {
+		ID      string `json:"id"`
+		Current bool   `json:"current"`
+	}
+	decodeResponse(t, w, &sessions)
+	if svc.userID != "user-1" {
+		t.Errorf("listed sessions of %q, want the signed-in user", svc.userID)
+	}
+	if len(sessions) != 2 || !sessions[0].Current || sessions[1].Current {
+		t.Errorf("sessions = %+v, want only session-1 marked current", sessions)
+	}
+}
+
+func TestSessionRevoke(t *testing.T) {
+	svc := &fakeSessionService{}
+
+	w := sessionRequest(svc, http.MethodDelete, "/sessions/session-2")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s, want 200", w.Code, w.Body)
+	}
+	if svc.userID != "user-1" || svc.sessionID != "session-2" {
+		t.Errorf("revoked %q for %q, want session-2 for user-1", svc.sessionID, svc.userID)
+	}
+	if svc.client.ActorID != "user-1" || svc.client.RequestID != "req-1" || svc.client.UserAgent != "test-agent" {
+		t.Errorf("client = %+v, want it taken from the request", svc.client)
+	}
+}
+
+func TestSessionRevokeErrors(t *testing.T) {
+	cases := map[string]struct {
+		err      error
+		wantCode int
+		wantErr  string
+	}{
+		"not found": {errors.New("session not found"), http.StatusNotFound, "SESSION_NOT_FOUND"},
+		"failure":   {errors.New("database is locked"), http.StatusInternalServerError, "INTERNAL_ERROR"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := sessionRequest(&fakeSessionService{err: tc.err}, http.MethodDelete, "/sessions/session-2")
+			if code := decodeResponse(t, w, nil); w.Code != tc.wantCode || code != tc.wantErr {
+				t.Errorf("got %d %s, want %d %s", w.Code, code, tc.wantCode, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestSessionRevokeOthersKeepsRequestSession(t *testing.T) {
+	svc := &fakeSessionService{}
+
+	w := sessionRequest(svc, http.MethodPost, "/sessions/revoke-others")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s, want 200", w.Code, w.Body)
+	}
+	var res struct {
+		Revoked int `json:"revoked"`
+	}
+	decodeResponse(t, w, &res)
+	if svc.userID != "user-1" || svc.sessionID != "session-1" {
+		t.Errorf("kept %q for %q, want the request's session-1 for user-1", svc.sessionID, svc.userID)
+	}
+	if res.Revoked != 2 {
+		t.Errorf("revoked = %d, want 2", res.Revoked)
+	}
+}
+
+func TestSessionRevokeOthersWithoutCurrentSession(t *testing.T) {
+	svc := &fakeSessionService{err: errors.New("current session is unknown, please log in again")}
+
+	w := sessionRequest(svc, http.MethodPost, "/sessions/revoke-others")
+	if code := decodeResponse(t, w, nil); w.Code != http.StatusConflict || code != "SESSION_UNKNOWN" {
+		t.Errorf("got %d %s, want 409 SESSION_UNKNOWN", w.Code, code)
+	}
+}
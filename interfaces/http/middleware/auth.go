@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"ambassador/domain/services"
+	"ambassador/interfaces/http/response"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireScope authenticates the bearer access token and rejects tokens that
+// do not grant scope, e.g. the limited tokens handed to users who have not
+// verified their email yet. The user ID, token, session ID and roles are
+// stored in the context.
+func RequireScope(authService services.AuthService, scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parts := strings.Split(c.GetHeader("Authorization"), " ")
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			response.Error(c, http.StatusUnauthorized, "MISSING_TOKEN", "Authorization token required")
+			c.Abort()
+			return
+		}
+
+		user, token, err := authService.Authenticate(parts[1])
+		if err != nil {
+			response.Error(c, http.StatusUnauthorized, "INVALID_TOKEN", err.Error())
+			c.Abort()
+			return
+		}
+
+		if !token.HasScope(scope) {
+			response.Error(c, http.StatusForbidden, "INSUFFICIENT_SCOPE", "Access token does not grant "+scope)
+			c.Abort()
+			return
+		}
+
+		c.Set("userID", user.ID)
+		c.Set("accessToken", token)
+		c.Set("sessionID", token.FamilyID)
+		c.Set("roles", user.Roles)
+		c.Next()
+	}
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/domain/services"
+	"ambassador/interfaces/http/response"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Authorizer guards routes by permission. A request is let through when the
+// current roles of the user its bearer access token belongs to grant the
+// permission under the policy, or when it presents the admin API token in
+// the X-Admin-Token header, which is treated as holding every permission.
+type Authorizer struct {
+	authService services.AuthService
+	policy      entities.Policy
+	adminToken  string
+}
+
+func NewAuthorizer(authService services.AuthService, policy entities.Policy, adminToken string) *Authorizer {
+	return &Authorizer{
+		authService: authService,
+		policy:      policy,
+		adminToken:  adminToken,
+	}
+}
+
+// RequirePermission rejects requests that do not hold permission. For user
+// tokens the user ID, token, session ID and roles are stored in the context
+// as RequireScope does.
+func (a *Authorizer) RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if provided := c.GetHeader("X-Admin-Token"); provided != "" {
+			if a.adminToken == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(a.adminToken)) != 1 {
+				response.Error(c, http.StatusUnauthorized, "UNAUTHORIZED", "Invalid admin token")
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
+		parts := strings.Split(c.GetHeader("Authorization"), " ")
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			response.Error(c, http.StatusUnauthorized, "MISSING_TOKEN", "Authorization token required")
+			c.Abort()
+			return
+		}
+
+		user, token, err := a.authService.Authenticate(parts[1])
+		if err != nil {
+			response.Error(c, http.StatusUnauthorized, "INVALID_TOKEN", err.Error())
+			c.Abort()
+			return
+		}
+
+		// The roles embedded in the token are a snapshot from when it was
+		// issued; the account's are current.
+		if !a.policy.Allows(user.Roles, permission) {
+			response.Error(c, http.StatusForbidden, "INSUFFICIENT_PERMISSION", "Missing permission "+permission)
+			c.Abort()
+			return
+		}
+
+		c.Set("userID", user.ID)
+		c.Set("accessToken", token)
+		c.Set("sessionID", token.FamilyID)
+		c.Set("roles", user.Roles)
+		c.Next()
+	}
+}
@@ -2,14 +2,33 @@ package middleware
 
 import (
 	"net/http"
+
 	"github.com/gin-gonic/gin"
 )
 
-func CORS() gin.HandlerFunc {
+// CORS answers cross-origin requests. Origins in allowedOrigins are echoed
+// back with credentials allowed, which cookie mode needs since browsers
+// never send cookies to a wildcard origin. With no allowed origins any
+// origin may call the API, but only without cookies.
+func CORS(allowedOrigins []string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		allowed[origin] = true
+	}
+
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		if len(allowed) == 0 {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Vary", "Origin")
+			if origin := c.GetHeader("Origin"); allowed[origin] {
+				c.Header("Access-Control-Allow-Origin", origin)
+				c.Header("Access-Control-Allow-Credentials", "true")
+			}
+		}
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, "+CSRFHeader+", X-Session-Mode")
+		c.Header("Access-Control-Expose-Headers", CSRFHeader)
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusOK)
@@ -18,4 +37,4 @@ func CORS() gin.HandlerFunc {
 
 		c.Next()
 	}
-}
\ No newline at end of file
+}
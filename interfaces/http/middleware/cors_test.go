@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func preflight(handler gin.HandlerFunc, origin string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(handler)
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/auth/refresh", nil)
+	req.Header.Set("Origin", origin)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORSEchoesAllowedOriginWithCredentials(t *testing.T) {
+	w := preflight(CORS([]string{"https://app.example.com"}), "https://app.example.com")
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Access-Control-Allow-Credentials = %q", got)
+	}
+}
+
+func TestCORSRefusesOtherOrigins(t *testing.T) {
+	w := preflight(CORS([]string{"https://app.example.com"}), "https://evil.example")
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin = %q for an origin not on the list", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Access-Control-Allow-Credentials = %q for an origin not on the list", got)
+	}
+}
+
+func TestCORSWithoutAllowListNeverAllowsCredentials(t *testing.T) {
+	w := preflight(CORS(nil), "https://evil.example")
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Access-Control-Allow-Credentials = %q with a wildcard origin", got)
+	}
+}
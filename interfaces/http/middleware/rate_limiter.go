@@ -85,22 +85,26 @@ func (rl *RateLimiter) UserAccessTokenMiddleware(authService services.AuthServic
 	}
 }
 
-func (rl *RateLimiter) UserRefreshTokenMiddleware(authService services.AuthService) gin.HandlerFunc {
+// UserRefreshTokenMiddleware limits requests per IP and per owner of the
+// refresh token, which is read from the session cookie when the request
+// carries one and from the body otherwise.
+func (rl *RateLimiter) UserRefreshTokenMiddleware(authService services.AuthService, cookies *SessionCookies) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ip := c.ClientIP()
 		var userID string
 
 		var req dto.RefreshTokenRequest
-		if err := c.ShouldBindJSON(&req); err == nil {
-			token, err := rl.tokenRepo.FindByValue(req.RefreshToken)
-			if err == nil {
-				userID = token.UserID
-			}
-		} else {
+		if refreshToken, ok := cookies.RefreshToken(c); ok {
+			req.RefreshToken = refreshToken
+		} else if err := c.ShouldBindJSON(&req); err != nil {
 			response.Error(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
 			c.Abort()
 			return
 		}
+		token, err := rl.tokenRepo.FindByValue(req.RefreshToken)
+		if err == nil {
+			userID = token.UserID
+		}
 
 		if !rl.allowRequest(ip, userID) {
 			response.Error(c, http.StatusTooManyRequests, ErrCodeRateLimitExceeded, ErrMessageRateLimitExceeded)
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"ambassador/domain/entities"
+	"ambassador/interfaces/http/response"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// CSRFHeader carries the double-submit token on state-changing requests.
+	CSRFHeader = "X-CSRF-Token"
+	// SessionModeHeader set to "cookie" on a sign-in request asks for the
+	// session to be kept in cookies.
+	SessionModeHeader = "X-Session-Mode"
+)
+
+// SessionCookies is the cookie mode for browser clients. The refresh token
+// is kept in an HttpOnly cookie instead of the response body, so scripts
+// cannot read it, and a readable CSRF cookie is set beside it for the
+// double-submit check in CSRF. Clients opt in per sign-in through
+// SessionModeHeader, so other clients keep receiving tokens in the body. A
+// nil *SessionCookies disables cookie mode.
+type SessionCookies struct {
+	RefreshName string
+	CSRFName    string
+	Domain      string
+	// RefreshPath limits where the browser sends the refresh cookie, e.g.
+	// to the auth routes.
+	RefreshPath string
+	Secure      bool
+	SameSite    http.SameSite
+}
+
+// SetSession stores refreshToken in the refresh cookie and issues a new
+// CSRF token, which is also sent in the CSRFHeader response header. It
+// reports whether the request is in cookie mode, which it is when it asked
+// for it or already carries the refresh cookie; otherwise nothing is set.
+func (s *SessionCookies) SetSession(c *gin.Context, refreshToken *entities.Token) bool {
+	if s == nil {
+		return false
+	}
+	if _, ok := s.RefreshToken(c); !ok && !strings.EqualFold(c.GetHeader(SessionModeHeader), "cookie") {
+		return false
+	}
+
+	csrfToken := newCSRFToken()
+	maxAge := int(time.Until(refreshToken.ExpiresAt).Seconds())
+	s.set(c, s.RefreshName, refreshToken.Value, s.RefreshPath, maxAge, true)
+	s.set(c, s.CSRFName, csrfToken, "/", maxAge, false)
+	c.Header(CSRFHeader, csrfToken)
+	return true
+}
+
+// ClearSession deletes both cookies, e.g. on logout.
+func (s *SessionCookies) ClearSession(c *gin.Context) {
+	if s == nil {
+		return
+	}
+	s.set(c, s.RefreshName, "", s.RefreshPath, -1, true)
+	s.set(c, s.CSRFName, "", "/", -1, false)
+}
+
+// RefreshToken returns the refresh token from the cookie, if cookie mode is
+// enabled and the request carries one.
+func (s *SessionCookies) RefreshToken(c *gin.Context) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	value, err := c.Cookie(s.RefreshName)
+	if err != nil || value == "" {
+		return "", false
+	}
+	return value, true
+}
+
+func (s *SessionCookies) set(c *gin.Context, name, value, path string, maxAge int, httpOnly bool) {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   s.Domain,
+		MaxAge:   maxAge,
+		Secure:   s.Secure,
+		HttpOnly: httpOnly,
+		SameSite: s.SameSite,
+	}
+	// Clients that ignore Max-Age still drop a cookie that has expired.
+	if maxAge < 0 {
+		cookie.Expires = time.Unix(1, 0)
+	}
+	http.SetCookie(c.Writer, cookie)
+}
+
+// CSRF protects state-changing requests that carry the refresh cookie with
+// the double-submit pattern: the CSRFHeader must repeat the CSRF cookie,
+// which a cross-site page can neither read nor set. Requests without the
+// cookie, such as bearer token clients, are let through untouched.
+func CSRF(cookies *SessionCookies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if _, ok := cookies.RefreshToken(c); !ok {
+			c.Next()
+			return
+		}
+
+		cookie, err := c.Cookie(cookies.CSRFName)
+		header := c.GetHeader(CSRFHeader)
+		if err != nil || cookie == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) != 1 {
+			response.Error(c, http.StatusForbidden, "CSRF_TOKEN_INVALID", "Missing or invalid CSRF token")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func newCSRFToken() string {
+	token := make([]byte, 32)
+	rand.Read(token)
+	return hex.EncodeToString(token)
+}
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"ambassador/domain/entities"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+var testCookies = &SessionCookies{RefreshName: "refresh_token", CSRFName: "csrf_token", RefreshPath: "/api/v1/auth"}
+
+// csrfRequest sends method through CSRF with the given cookies and
+// CSRFHeader value, and returns the recorder.
+func csrfRequest(method string, cookies map[string]string, header string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestID(), CSRF(testCookies))
+	r.Handle(method, "/api/v1/auth/refresh", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	req := httptest.NewRequest(method, "/api/v1/auth/refresh", nil)
+	for name, value := range cookies {
+		req.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+	if header != "" {
+		req.Header.Set(CSRFHeader, header)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCSRFRejectsCookieRequestsWithoutMatchingHeader(t *testing.T) {
+	cases := map[string]struct {
+		cookies map[string]string
+		header  string
+	}{
+		"missing header":      {map[string]string{"refresh_token": "r", "csrf_token": "abc"}, ""},
+		"mismatched header":   {map[string]string{"refresh_token": "r", "csrf_token": "abc"}, "abd"},
+		"missing csrf cookie": {map[string]string{"refresh_token": "r"}, "abc"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := csrfRequest(http.MethodPost, tc.cookies, tc.header)
+			if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "CSRF_TOKEN_INVALID") {
+				t.Errorf("got %d %s, want 403 CSRF_TOKEN_INVALID", w.Code, w.Body)
+			}
+		})
+	}
+}
+
+func TestCSRFAllowsMatchingHeader(t *testing.T) {
+	w := csrfRequest(http.MethodPost, map[string]string{"refresh_token": "r", "csrf_token": "abc"}, "abc")
+	if w.Code != http.StatusNoContent {
+		t.Errorf("got %d %s, want the request through", w.Code, w.Body)
+	}
+}
+
+func TestCSRFSkipsRequestsWithoutRefreshCookie(t *testing.T) {
+	if w := csrfRequest(http.MethodPost, nil, ""); w.Code != http.StatusNoContent {
+		t.Errorf("bearer client request got %d, want it through", w.Code)
+	}
+	if w := csrfRequest(http.MethodGet, map[string]string{"refresh_token": "r"}, ""); w.Code != http.StatusNoContent {
+		t.Errorf("GET with the refresh cookie got %d, want it through", w.Code)
+	}
+}
+
+func TestSetSessionIssuesCSRFTokenInCookieAndHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", nil)
+	c.Request.Header.Set(SessionModeHeader, "cookie")
+
+	if !testCookies.SetSession(c, entities.NewRefreshToken("user-1")) {
+		t.Fatal("SetSession did not enter cookie mode")
+	}
+
+	var refresh, csrf *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		switch cookie.Name {
+		case testCookies.RefreshName:
+			refresh = cookie
+		case testCookies.CSRFName:
+			csrf = cookie
+		}
+	}
+	if refresh == nil || !refresh.HttpOnly {
+		t.Errorf("refresh cookie = %v, want it HttpOnly", refresh)
+	}
+	if csrf == nil || csrf.HttpOnly || csrf.Value == "" || csrf.Value != w.Header().Get(CSRFHeader) {
+		t.Errorf("CSRF cookie = %v, header %q; want a readable cookie repeated in the header", csrf, w.Header().Get(CSRFHeader))
+	}
+}
@@ -29,6 +29,11 @@ func Error(c *gin.Context, status int, code, message string) {
 	sendResponse(c, false, status, code, message, nil)
 }
 
+// ErrorWithData is Error with details the client can act on.
+func ErrorWithData(c *gin.Context, status int, code, message string, data interface{}) {
+	sendResponse(c, false, status, code, message, data)
+}
+
 func sendResponse(c *gin.Context, success bool, status int, code, message string, data interface{}) {
 	requestID, _ := c.Get("requestID")
 	res := APIResponse{
@@ -1,9 +1,9 @@
 package auth
 
 import (
+	"ambassador/domain/entities"
 	"ambassador/internal/shared/security"
 	"errors"
-	"regexp"
 	"strings"
 	"time"
 
@@ -197,21 +197,11 @@ func (s *AuthService) Logout(refreshTokenValue string) error {
 	return nil
 }
 
+// validatePassword applies the same composition requirements as the main
+// auth service instead of keeping its own copy of the rules.
 func (s *AuthService) validatePassword(password string) error {
-	if len(password) < 8 {
-		return errors.New("password must be at least 8 characters long")
-	}
-	if !regexp.MustCompile(`[A-Z]`).MatchString(password) {
-		return errors.New("password must contain at least one uppercase letter")
-	}
-	if !regexp.MustCompile(`[a-z]`).MatchString(password) {
-		return errors.New("password must contain at least one lowercase letter")
-	}
-	if !regexp.MustCompile(`[0-9]`).MatchString(password) {
-		return errors.New("password must contain at least one number")
-	}
-	if !regexp.MustCompile(`[!@#$%^&*()_+\-=\[\]{}|;:,.<>?]`).MatchString(password) {
-		return errors.New("password must contain at least one special character")
+	if violations := entities.DefaultPasswordRequirements().Check(password); len(violations) > 0 {
+		return &entities.PasswordPolicyError{Violations: violations}
 	}
 	return nil
 }
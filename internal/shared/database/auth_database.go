@@ -0,0 +1,383 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "pgx"
+)
+
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// Querier is the subset of *sql.DB and *sql.Tx used by the repositories, so
+// the same code can run inside or outside a transaction.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type Migration struct {
+	Version     int
+	Description string
+	Statements  []string
+}
+
+// AuthMigrations holds the schema for the user and token tables. Migrations
+// are applied in order and must never be edited once released; add a new
+// version instead.
+var AuthMigrations = []Migration{
+	{
+		Version:     1,
+		Description: "create users and tokens tables",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS users (
+				id TEXT PRIMARY KEY,
+				email TEXT NOT NULL,
+				full_name TEXT NOT NULL,
+				gender TEXT NOT NULL,
+				date_of_birth TIMESTAMP NOT NULL,
+				registration_method TEXT NOT NULL,
+				password_hash TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMP NOT NULL,
+				updated_at TIMESTAMP NOT NULL,
+				is_active BOOLEAN NOT NULL DEFAULT TRUE
+			)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email)`,
+			`CREATE TABLE IF NOT EXISTS tokens (
+				value TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL,
+				type TEXT NOT NULL,
+				expires_at TIMESTAMP NOT NULL,
+				created_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS idx_tokens_value ON tokens (value)`,
+			`CREATE INDEX IF NOT EXISTS idx_tokens_user_type ON tokens (user_id, type)`,
+			`CREATE INDEX IF NOT EXISTS idx_tokens_expires_at ON tokens (expires_at)`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "add refresh token families",
+		Statements: []string{
+			`ALTER TABLE tokens ADD COLUMN family_id TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE tokens ADD COLUMN rotated_at TIMESTAMP NULL`,
+			`CREATE INDEX IF NOT EXISTS idx_tokens_family ON tokens (family_id)`,
+		},
+	},
+	{
+		Version:     3,
+		Description: "create external identities table",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS external_identities (
+				provider TEXT NOT NULL,
+				subject TEXT NOT NULL,
+				user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+				email TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMP NOT NULL,
+				PRIMARY KEY (provider, subject)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_external_identities_user ON external_identities (user_id)`,
+		},
+	},
+	{
+		Version:     4,
+		Description: "add email verification and token scopes",
+		Statements: []string{
+			`ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE`,
+			`ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP NULL`,
+			`ALTER TABLE tokens ADD COLUMN scopes TEXT NULL`,
+		},
+	},
+	{
+		Version:     5,
+		Description: "add user locale",
+		Statements: []string{
+			`ALTER TABLE users ADD COLUMN locale TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		Version:     6,
+		Description: "create totp credentials and recovery codes tables",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS totp_credentials (
+				user_id TEXT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+				secret TEXT NOT NULL,
+				confirmed_at TIMESTAMP NULL,
+				last_used_step BIGINT NOT NULL DEFAULT 0,
+				created_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS recovery_codes (
+				user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+				code_hash TEXT NOT NULL,
+				used_at TIMESTAMP NULL,
+				created_at TIMESTAMP NOT NULL,
+				PRIMARY KEY (user_id, code_hash)
+			)`,
+		},
+	},
+	{
+		Version:     7,
+		Description: "create webauthn credentials and sessions tables",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS webauthn_credentials (
+				id TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+				name TEXT NOT NULL DEFAULT '',
+				public_key TEXT NOT NULL,
+				attestation_type TEXT NOT NULL DEFAULT '',
+				aaguid TEXT NOT NULL DEFAULT '',
+				sign_count BIGINT NOT NULL DEFAULT 0,
+				transports TEXT NOT NULL DEFAULT '',
+				backup_eligible BOOLEAN NOT NULL DEFAULT FALSE,
+				backup_state BOOLEAN NOT NULL DEFAULT FALSE,
+				created_at TIMESTAMP NOT NULL,
+				last_used_at TIMESTAMP NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_user ON webauthn_credentials (user_id)`,
+			`CREATE TABLE IF NOT EXISTS webauthn_sessions (
+				id TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL DEFAULT '',
+				ceremony TEXT NOT NULL,
+				data TEXT NOT NULL,
+				expires_at TIMESTAMP NOT NULL,
+				created_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_webauthn_sessions_expires_at ON webauthn_sessions (expires_at)`,
+		},
+	},
+	{
+		Version:     8,
+		Description: "create sessions table",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS sessions (
+				id TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL,
+				device_name TEXT NOT NULL DEFAULT '',
+				user_agent TEXT NOT NULL DEFAULT '',
+				ip_address TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMP NOT NULL,
+				last_used_at TIMESTAMP NOT NULL,
+				expires_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions (user_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions (expires_at)`,
+			// Existing refresh token families become sessions with no device
+			// details until they are next refreshed.
+			`INSERT INTO sessions (id, user_id, created_at, last_used_at, expires_at)
+				SELECT family_id, MIN(user_id), MIN(created_at), MAX(created_at), MAX(expires_at)
+				FROM tokens
+				WHERE type = 'refresh' AND family_id <> ''
+				GROUP BY family_id`,
+		},
+	},
+	{
+		Version:     9,
+		Description: "create login throttles table",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS login_throttles (
+				throttle_key TEXT PRIMARY KEY,
+				failed_count INTEGER NOT NULL DEFAULT 0,
+				last_failed_at TIMESTAMP NOT NULL,
+				locked_until TIMESTAMP NULL
+			)`,
+		},
+	},
+	{
+		Version:     10,
+		Description: "create audit events table",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS audit_events (
+				id TEXT PRIMARY KEY,
+				type TEXT NOT NULL,
+				user_id TEXT NOT NULL DEFAULT '',
+				ip_address TEXT NOT NULL DEFAULT '',
+				user_agent TEXT NOT NULL DEFAULT '',
+				request_id TEXT NOT NULL DEFAULT '',
+				details TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at, id)`,
+			`CREATE INDEX IF NOT EXISTS idx_audit_events_user ON audit_events (user_id, created_at)`,
+			`CREATE INDEX IF NOT EXISTS idx_audit_events_type ON audit_events (type, created_at)`,
+		},
+	},
+	{
+		Version:     11,
+		Description: "add user roles",
+		Statements: []string{
+			`ALTER TABLE users ADD COLUMN roles TEXT NOT NULL DEFAULT 'user'`,
+			`ALTER TABLE tokens ADD COLUMN roles TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		Version:     12,
+		Description: "add token subjects",
+		Statements: []string{
+			`ALTER TABLE tokens ADD COLUMN subject TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		Version:     13,
+		Description: "add user deletion time",
+		Statements: []string{
+			`ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP NULL`,
+			`CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at)`,
+		},
+	},
+	{
+		Version:     14,
+		Description: "create password history table",
+		Statements: []string{
+			`ALTER TABLE users ADD COLUMN password_changed_at TIMESTAMP NULL`,
+			`CREATE TABLE IF NOT EXISTS password_history (
+				id TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+				password_hash TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_password_history_user ON password_history (user_id, created_at)`,
+		},
+	},
+	{
+		Version:     15,
+		Description: "add audit event actors",
+		Statements: []string{
+			`ALTER TABLE audit_events ADD COLUMN actor_id TEXT NOT NULL DEFAULT ''`,
+			`CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events (actor_id, created_at)`,
+		},
+	},
+}
+
+func Open(driver, dsn string, pool PoolConfig) (*sql.DB, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if driver == DriverSQLite {
+		// SQLite allows a single writer; serialising access through one
+		// connection avoids "database is locked" errors under load.
+		db.SetMaxOpenConns(1)
+		if _, err := db.Exec(`PRAGMA foreign_keys = ON`); err != nil {
+			db.Close()
+			return nil, err
+		}
+	} else {
+		db.SetMaxOpenConns(pool.MaxOpenConns)
+		db.SetMaxIdleConns(pool.MaxIdleConns)
+		db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+		db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Migrate applies every migration whose version has not been recorded in
+// schema_migrations yet. Each migration runs in its own transaction.
+func Migrate(db *sql.DB, driver string, migrations []Migration) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	applied := make(map[int]bool)
+	rows, err := db.Query(`SELECT version FROM schema_migrations`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		for _, stmt := range m.Statements {
+			if _, err := tx.Exec(stmt); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migration %d (%s): %w", m.Version, m.Description, err)
+			}
+		}
+		if _, err := tx.Exec(
+			Rebind(driver, `INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)`),
+			m.Version, m.Description, time.Now().UTC(),
+		); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Rebind rewrites the "?" placeholders used throughout the repositories into
+// the positional "$n" form PostgreSQL expects. Other drivers are returned
+// unchanged.
+func Rebind(driver, query string) string {
+	if driver != DriverPostgres {
+		return query
+	}
+
+	var (
+		b       strings.Builder
+		n       int
+		inQuote bool
+	)
+	b.Grow(len(query) + 8)
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inQuote = !inQuote
+			b.WriteRune(r)
+		case r == '?' && !inQuote:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -131,7 +131,11 @@ func (v *validator) validateMin(field reflect.Value, fieldName, rule string) err
 		if _, err := fmt.Sscanf(minStr, "%d", &minLen); err != nil {
 			return fmt.Errorf("invalid min rule for %s", fieldName)
 		}
-		if len(strings.TrimSpace(field.String())) < minLen {
+		value := strings.TrimSpace(field.String())
+		if value == "" {
+			return nil
+		}
+		if len(value) < minLen {
 			return fmt.Errorf("%s must be at least %d characters long", fieldName, minLen)
 		}
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
@@ -180,7 +184,11 @@ func (v *validator) validateOneOf(field reflect.Value, fieldName, rule string) e
 	oneofStr := strings.TrimPrefix(rule, "oneof=")
 	validValues := strings.Fields(oneofStr)
 
+	// Like min and max, an empty value is left to the required rule.
 	fieldValue := field.String()
+	if fieldValue == "" {
+		return nil
+	}
 	for _, validValue := range validValues {
 		if fieldValue == validValue {
 			return nil